@@ -3,9 +3,11 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -16,7 +18,10 @@ import (
 	appcontext "github.com/yshengliao/gortex/core/context"
 	"github.com/yshengliao/gortex/middleware"
 	"github.com/yshengliao/gortex/observability/tracing"
+	"github.com/yshengliao/gortex/pkg/auth"
+	"github.com/yshengliao/gortex/pkg/auth/rbac"
 	"github.com/yshengliao/gortex/pkg/config"
+	"github.com/yshengliao/gortex/pkg/utils/certreload"
 	httpctx "github.com/yshengliao/gortex/transport/http"
 )
 
@@ -64,7 +69,9 @@ type App struct {
 	developmentMode bool
 	tracer          tracing.Tracer
 	docProvider     doc.DocProvider
-	docRouteInfos   []doc.RouteInfo // Stores route info for documentation
+	docRouteInfos   []doc.RouteInfo       // Stores route info for documentation
+	jwks            *auth.KeySet          // Public keys served at auth.JWKSPath
+	encodings       []middleware.Encoding // Extra response codings, e.g. br
 
 	// stoppables holds resources created by the framework during route
 	// registration (e.g. rate-limit stores started from struct tags) that
@@ -72,12 +79,16 @@ type App struct {
 	// for the process lifetime. Guarded by mu.
 	stoppables []interface{ Stop() }
 
-	// pendingHandlers holds the manager passed to WithHandlers until the
-	// router's default middleware chain is set up in NewApp. The Gortex
-	// router snapshots middleware at route-registration time, so handler
-	// registration must run strictly after setupRouter has attached the
-	// default chain — otherwise routes registered via WithHandlers would
-	// bypass recovery, logging, CORS, etc.
+	// concurrencyLimiters are the load-shedding limiters reported by
+	// /_monitor: those built from concurrency tags and those passed to
+	// WithConcurrencyLimiters. Guarded by mu.
+	concurrencyLimiters []*middleware.ConcurrencyLimiter
+
+	// pendingHandlers holds the manager passed to WithHandlers until every
+	// option has been applied in NewApp, so registration sees the final
+	// logger, config and context whatever order the options came in. The
+	// router resolves middleware chains when it compiles its route table,
+	// so the default chain reaches these routes either way.
 	pendingHandlers any
 }
 
@@ -112,12 +123,21 @@ func NewApp(opts ...Option) (*App, error) {
 		}
 	}
 
+	// Build the RBAC policy before handler tags reference it.
+	if err := app.setupRBAC(); err != nil {
+		return nil, err
+	}
+
+	if app.compressionEnabled() {
+		if _, err := middleware.ParseCompressionLevel(app.config.Server.Compression.Level); err != nil {
+			return nil, fmt.Errorf("invalid compression config: %w", err)
+		}
+	}
+
 	// Configure router and middleware
 	app.setupRouter()
 
-	// Register handlers after setupRouter so they inherit the default
-	// middleware chain. The Gortex router evaluates global middleware at
-	// route-registration time, so the order here is load-bearing.
+	// Register handlers once all options are applied.
 	if app.pendingHandlers != nil {
 		if err := RegisterRoutes(app, app.pendingHandlers); err != nil {
 			return nil, err
@@ -137,6 +157,14 @@ func NewApp(opts ...Option) (*App, error) {
 		app.registerDocumentationRoutes()
 	}
 
+	// Publish the JWT verification keys if a key set is set
+	if app.jwks != nil {
+		app.router.GET(auth.JWKSPath, func(c httpctx.Context) error {
+			app.jwks.ServeHTTP(c.Response(), c.Request())
+			return nil
+		})
+	}
+
 	return app, nil
 }
 
@@ -163,8 +191,8 @@ func WithLogger(logger *zap.Logger) Option {
 }
 
 // WithHandlers registers handlers using reflection. The actual route
-// registration is deferred to NewApp so it runs after setupRouter has
-// installed the default middleware chain; see App.pendingHandlers.
+// registration is deferred to NewApp so it runs after every option has been
+// applied; see App.pendingHandlers.
 func WithHandlers(manager any) Option {
 	return func(app *App) error {
 		app.pendingHandlers = manager
@@ -242,6 +270,67 @@ func WithDocProvider(provider doc.DocProvider) Option {
 	}
 }
 
+// WithJWKS publishes the public keys of keys at auth.JWKSPath, so other
+// services can verify the tokens a JWTService created with
+// auth.NewJWTServiceWithKeys issues. Rotated keys are served as they change.
+func WithJWKS(keys *auth.KeySet) Option {
+	return func(app *App) error {
+		if keys == nil {
+			return fmt.Errorf("key set cannot be nil")
+		}
+		app.jwks = keys
+		return nil
+	}
+}
+
+// WithCompressionEncodings offers further response codings alongside the
+// built-in brotli, zstd and gzip when compression is enabled, or replaces
+// the built-in coding of the same name. They are preferred over the
+// built-in codings in the order given; a "br" coding is only offered with
+// Compression.EnableBrotli, and ahead of the others with PreferBrotli.
+func WithCompressionEncodings(encodings ...middleware.Encoding) Option {
+	return func(app *App) error {
+		for _, enc := range encodings {
+			if enc.Name == "" || enc.NewWriter == nil {
+				return fmt.Errorf("compression encoding needs a name and a writer")
+			}
+		}
+		app.encodings = append(app.encodings, encodings...)
+		return nil
+	}
+}
+
+// WithConcurrencyLimiters reports limiters used with
+// middleware.ConcurrencyLimitWithConfig in /_monitor, next to those built
+// from concurrency tags.
+func WithConcurrencyLimiters(limiters ...*middleware.ConcurrencyLimiter) Option {
+	return func(app *App) error {
+		if slices.Contains(limiters, nil) {
+			return fmt.Errorf("concurrency limiter is nil")
+		}
+		app.registerConcurrencyLimiters(limiters...)
+		return nil
+	}
+}
+
+// setupRBAC registers the policy described by the rbac section of the
+// config in the app context, for the rbac middleware tag and for handlers
+// to inject. A policy registered explicitly takes precedence.
+func (app *App) setupRBAC() error {
+	if app.config == nil || len(app.config.RBAC.Roles) == 0 {
+		return nil
+	}
+	if _, err := appcontext.Get[*rbac.Policy](app.ctx); err == nil {
+		return nil
+	}
+	policy, err := rbac.NewPolicyFromConfig(app.config.RBAC)
+	if err != nil {
+		return fmt.Errorf("invalid rbac config: %w", err)
+	}
+	appcontext.Register(app.ctx, policy)
+	return nil
+}
+
 // setupRouter configures the Gortex router with per-route middleware.
 // The ordering is load-bearing: recovery wraps everything so panics are
 // caught no matter which downstream middleware misbehaves; request-id
@@ -282,7 +371,7 @@ func (app *App) setupRouter() {
 // http.Server.Handler. It applies the middleware that has to see every
 // request — including 404s and OPTIONS preflights — before the router
 // makes routing decisions. Innermost to outermost: router → CORS →
-// gzip.
+// compression.
 func (app *App) serverHandler() http.Handler {
 	var h http.Handler = app.router
 
@@ -293,7 +382,10 @@ func (app *App) serverHandler() http.Handler {
 
 	if app.compressionEnabled() {
 		cfg := middleware.DefaultCompressionConfig()
+		cfg.Encodings = app.compressionEncodings()
 		if app.config != nil {
+			// The level name was validated by NewApp.
+			cfg.Quality, _ = middleware.ParseCompressionLevel(app.config.Server.Compression.Level)
 			if app.config.Server.Compression.MinSize > 0 {
 				cfg.MinSize = app.config.Server.Compression.MinSize
 			}
@@ -301,13 +393,52 @@ func (app *App) serverHandler() http.Handler {
 				cfg.ContentTypes = types
 			}
 		}
-		h = middleware.GzipHandlerWithConfig(cfg, h)
+		h = middleware.CompressHandlerWithConfig(cfg, h)
 	}
 
 	return h
 }
 
-// compressionEnabled reports whether gzip compression should be wired
+// compressionEncodings returns the response codings in preference order:
+// those registered with WithCompressionEncodings, then the built-in zstd
+// and gzip. Brotli is dropped unless EnableBrotli is set and moved first
+// with PreferBrotli.
+func (app *App) compressionEncodings() []middleware.Encoding {
+	var compression config.CompressionConfig
+	if app.config != nil {
+		compression = app.config.Server.Compression
+	}
+	candidates := append([]middleware.Encoding(nil), app.encodings...)
+	for _, builtin := range []middleware.Encoding{
+		middleware.ZstdEncoding(), middleware.GzipEncoding(), middleware.BrotliEncoding(),
+	} {
+		registered := false
+		for _, enc := range app.encodings {
+			registered = registered || strings.EqualFold(enc.Name, builtin.Name)
+		}
+		if !registered {
+			candidates = append(candidates, builtin)
+		}
+	}
+
+	var brotli []middleware.Encoding
+	encodings := make([]middleware.Encoding, 0, len(candidates))
+	for _, enc := range candidates {
+		if strings.EqualFold(enc.Name, "br") {
+			if compression.EnableBrotli {
+				brotli = append(brotli, enc)
+			}
+			continue
+		}
+		encodings = append(encodings, enc)
+	}
+	if compression.PreferBrotli {
+		return append(brotli, encodings...)
+	}
+	return append(encodings, brotli...)
+}
+
+// compressionEnabled reports whether compression should be wired
 // in at the HTTP handler layer. Either the legacy Server.GZip toggle
 // or the newer Server.Compression.Enabled field enables it.
 func (app *App) compressionEnabled() bool {
@@ -322,6 +453,15 @@ func (app *App) Router() httpctx.GortexRouter {
 	return app.router
 }
 
+// URL builds the path of the route registered under name, either with a
+// `name:"..."` struct tag or Route.Name, filling its :param and *wildcard
+// segments in order with values:
+//
+//	link, err := app.URL("user.show", 42) // "/users/42"
+func (app *App) URL(name string, values ...any) (string, error) {
+	return app.router.URL(name, values...)
+}
+
 // ServerHandler returns the HTTP handler that would be installed on
 // http.Server.Handler during Run — the router wrapped with CORS and
 // compression as configured. Exposed so tests can exercise the full
@@ -375,9 +515,53 @@ func (app *App) Run() error {
 		ReadHeaderTimeout: defaultReadHeaderTimeout,
 	}
 
+	if app.config != nil && app.config.Server.TLS.CertFile != "" {
+		tlsConfig, err := app.serverTLSConfig(app.config.Server.TLS)
+		if err != nil {
+			return err
+		}
+		app.server.TLSConfig = tlsConfig
+		return app.server.ListenAndServeTLS("", "")
+	}
 	return app.server.ListenAndServe()
 }
 
+// clientAuthTypes maps config.TLSConfig.ClientAuth names to their policies.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                   tls.NoClientCert,
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// serverTLSConfig loads the server certificates described by cfg. They are
+// reloaded when their files change until Shutdown.
+func (app *App) serverTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	clientAuth, ok := clientAuthTypes[cfg.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unknown tls client_auth %q; expected none, request, require, verify_if_given or require_and_verify", cfg.ClientAuth)
+	}
+	reloader, err := certreload.New(certreload.Config{
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		ClientCAFile: cfg.ClientCAFile,
+		ClientAuth:   clientAuth,
+		Interval:     cfg.ReloadInterval,
+		OnError: func(err error) {
+			if app.logger != nil {
+				app.logger.Error("Failed to reload TLS certificates", zap.Error(err))
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	app.registerStoppable(reloader)
+	return reloader.TLSConfig(), nil
+}
+
 // RegisterShutdownHook registers a function to be called during shutdown
 func (app *App) RegisterShutdownHook(hook ShutdownHook) {
 	app.mu.Lock()
@@ -396,6 +580,24 @@ func (app *App) registerStoppable(s interface{ Stop() }) {
 	app.stoppables = append(app.stoppables, s)
 }
 
+// registerConcurrencyLimiters records limiters for /_monitor.
+func (app *App) registerConcurrencyLimiters(limiters ...*middleware.ConcurrencyLimiter) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.concurrencyLimiters = append(app.concurrencyLimiters, limiters...)
+}
+
+// concurrencyStats returns the state of the registered limiters.
+func (app *App) concurrencyStats() []middleware.ConcurrencyStats {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	stats := make([]middleware.ConcurrencyStats, len(app.concurrencyLimiters))
+	for i, l := range app.concurrencyLimiters {
+		stats[i] = l.Stats()
+	}
+	return stats
+}
+
 // stopStoppables stops every resource registered via registerStoppable. It is
 // invoked by Shutdown and is safe to call when the slice is empty.
 func (app *App) stopStoppables() {
@@ -548,6 +750,13 @@ func (app *App) registerDevelopmentRoutes() {
 		router:     app.router,
 		config:     app.config,
 		routeInfos: app.routeInfos,
+		// Read live: handlers may be registered after the dev routes.
+		concurrency: app.concurrencyStats,
+	}
+	if app.compressionEnabled() {
+		for _, enc := range app.compressionEncodings() {
+			devHandlers.encodings = append(devHandlers.encodings, enc.Name)
+		}
 	}
 
 	// Register development routes
@@ -615,24 +824,42 @@ type devHandler struct {
 	logger     *zap.Logger
 	router     httpctx.GortexRouter
 	config     *Config
-	routeInfos []RouteLogInfo // snapshot of registered routes at startup
+	routeInfos []RouteLogInfo // snapshot of struct-registered routes at startup
+	encodings  []string       // response codings in preference order
+	// concurrency reports the load-shedding limiters
+	concurrency func() []middleware.ConcurrencyStats
 }
 
-// Routes returns all registered routes as JSON.
-// The list is a snapshot taken at app startup; routes registered after
-// NewApp() returns are not included.
+// Routes returns all registered routes as JSON, read live from the router
+// so each route's middlewares list the exact chain it runs through,
+// including global middleware. Handler names come from the struct
+// registration snapshot and are empty for routes registered directly on
+// the router.
 func (h *devHandler) Routes(c httpctx.Context) error {
-	routes := make([]map[string]any, 0, len(h.routeInfos))
+	handlers := make(map[string]string, len(h.routeInfos))
 	for _, r := range h.routeInfos {
-		routes = append(routes, map[string]any{
+		handlers[r.Method+" "+r.Path] = r.Handler
+	}
+
+	infos := h.router.Routes()
+	routes := make([]map[string]any, 0, len(infos))
+	for _, r := range infos {
+		route := map[string]any{
 			"method":      r.Method,
 			"path":        r.Path,
-			"handler":     r.Handler,
+			"handler":     handlers[r.Method+" "+r.Path],
 			"middlewares": r.Middlewares,
-		})
+		}
+		if r.Host != "" {
+			route["host"] = r.Host
+		}
+		if r.Name != "" {
+			route["name"] = r.Name
+		}
+		routes = append(routes, route)
 	}
 	return c.JSON(200, map[string]any{
-		"total_routes": len(h.routeInfos),
+		"total_routes": len(routes),
 		"routes":       routes,
 		"framework":    "Gortex",
 	})
@@ -689,6 +916,11 @@ func (h *devHandler) Config(c httpctx.Context) error {
 			"cors":             h.config.Server.CORS,
 			"recovery":         h.config.Server.Recovery,
 			"compression":      h.config.Server.Compression,
+			"tls": map[string]any{
+				"cert_file":      h.config.Server.TLS.CertFile,
+				"client_ca_file": h.config.Server.TLS.ClientCAFile,
+				"client_auth":    h.config.Server.TLS.ClientAuth,
+			},
 		},
 		"logger": h.config.Logger,
 		"websocket": map[string]any{
@@ -785,8 +1017,9 @@ func (h *devHandler) Monitor(c httpctx.Context) error {
 		if h.config.Server.Compression.Enabled {
 			compressionInfo["enabled"] = true
 			compressionInfo["gzip_enabled"] = true
-			compressionInfo["brotli_enabled"] = h.config.Server.Compression.EnableBrotli
+			compressionInfo["brotli_enabled"] = slices.Contains(h.encodings, "br")
 			compressionInfo["prefer_brotli"] = h.config.Server.Compression.PreferBrotli
+			compressionInfo["encodings"] = h.encodings
 			compressionInfo["compression_level"] = h.config.Server.Compression.Level
 			compressionInfo["min_size_bytes"] = h.config.Server.Compression.MinSize
 
@@ -809,6 +1042,7 @@ func (h *devHandler) Monitor(c httpctx.Context) error {
 			compressionInfo["enabled"] = true
 			compressionInfo["gzip_enabled"] = true
 			compressionInfo["compression_level"] = "default (gzip.DefaultCompression)"
+			compressionInfo["encodings"] = h.encodings
 			compressionInfo["content_types"] = []string{
 				"text/html",
 				"text/css",
@@ -822,6 +1056,12 @@ func (h *devHandler) Monitor(c httpctx.Context) error {
 		}
 	}
 
+	// Load-shedding limiters
+	concurrencyInfo := []middleware.ConcurrencyStats{}
+	if h.concurrency != nil {
+		concurrencyInfo = h.concurrency()
+	}
+
 	// Final response
 	return c.JSON(200, map[string]any{
 		"status":      "healthy",
@@ -830,6 +1070,7 @@ func (h *devHandler) Monitor(c httpctx.Context) error {
 		"gc_stats":    gcStats,
 		"routes":      routesInfo,
 		"compression": compressionInfo,
+		"concurrency": concurrencyInfo,
 		"server_info": map[string]any{
 			"framework":  "Gortex",
 			"debug_mode": h.config != nil && h.config.Logger.Level == "debug",
@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/yshengliao/gortex/core/app"
+	"github.com/yshengliao/gortex/middleware"
 	httpctx "github.com/yshengliao/gortex/transport/http"
 )
 
@@ -107,3 +108,84 @@ func TestDevRoutes_MonitorEndpoint(t *testing.T) {
 	require.True(t, ok, "total_routes must be a number, got %T", totalRaw)
 	assert.Greater(t, int(total), 0, "total_routes must be > 0")
 }
+
+type concurrencyManager struct {
+	Limited *devRoutesTestHandler `url:"/limited" concurrency:"5;max=50;algorithm=aimd"`
+}
+
+// TestDevRoutes_MonitorReportsConcurrency verifies that /_monitor reports
+// the limiters built from concurrency tags and those passed to
+// WithConcurrencyLimiters.
+func TestDevRoutes_MonitorReportsConcurrency(t *testing.T) {
+	custom := middleware.NewConcurrencyLimiter(middleware.ConcurrencyLimiterOptions{Name: "uploads"})
+	a, err := app.NewApp(
+		app.WithDevelopmentMode(),
+		app.WithHandlers(&concurrencyManager{}),
+		app.WithConcurrencyLimiters(custom),
+	)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	a.ServerHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/limited", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	a.ServerHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_monitor", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp struct {
+		Concurrency []middleware.ConcurrencyStats `json:"concurrency"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Concurrency, 2)
+	byName := map[string]middleware.ConcurrencyStats{}
+	for _, stats := range resp.Concurrency {
+		byName[stats.Name] = stats
+	}
+	limited := byName["/limited"]
+	assert.Equal(t, "aimd", limited.Algorithm)
+	assert.Equal(t, 5, limited.Limit)
+	assert.Equal(t, uint64(1), limited.Admitted)
+	assert.Zero(t, limited.InFlight)
+	assert.Equal(t, 20, byName["uploads"].Limit)
+
+	_, err = app.NewApp(app.WithConcurrencyLimiters(nil))
+	assert.Error(t, err)
+}
+
+// TestDevRoutes_ReportsGlobalMiddleware verifies that /_routes lists the
+// chain each route actually runs through, including the default middleware
+// installed with Use and middleware added after NewApp returns.
+func TestDevRoutes_ReportsGlobalMiddleware(t *testing.T) {
+	a, err := app.NewApp(
+		app.WithDevelopmentMode(),
+		app.WithHandlers(&devRoutesManager{Foo: &devRoutesTestHandler{}}),
+	)
+	require.NoError(t, err)
+	a.Router().Use(func(next httpctx.HandlerFunc) httpctx.HandlerFunc { return next })
+
+	req := httptest.NewRequest(http.MethodGet, "/_routes", nil)
+	rec := httptest.NewRecorder()
+	a.ServerHandler().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp struct {
+		Routes []struct {
+			Method      string   `json:"method"`
+			Path        string   `json:"path"`
+			Middlewares []string `json:"middlewares"`
+		} `json:"routes"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	for _, r := range resp.Routes {
+		if r.Method == http.MethodGet && r.Path == "/foo" {
+			assert.Equal(t, "middleware.Recovery", r.Middlewares[0])
+			assert.Contains(t, r.Middlewares, "middleware.RequestID")
+			assert.Contains(t, r.Middlewares, "middleware.ErrorHandler")
+			assert.Equal(t, "app_test.TestDevRoutes_ReportsGlobalMiddleware", r.Middlewares[len(r.Middlewares)-1])
+			return
+		}
+	}
+	t.Fatalf("GET /foo not listed: %s", rec.Body.String())
+}
@@ -0,0 +1,421 @@
+// Package openapi provides a doc.DocProvider that renders the routes
+// collected during struct-tag registration as an OpenAPI 3.1 document and
+// serves it together with Swagger UI and Redoc pages:
+//
+//	app.NewApp(
+//		app.WithDocProvider(openapi.NewProvider(&doc.DocConfig{Title: "Orders API", Version: "1.2.0"})),
+//		app.WithHandlers(handlers),
+//	)
+//
+// Request and response schemas are derived by reflection from the struct a
+// handler method binds and the T of a (T, error) result, using the same
+// json, bind and validate tags the binder and validator read.
+package openapi
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yshengliao/gortex/core/app/doc"
+	httpctx "github.com/yshengliao/gortex/transport/http"
+)
+
+// Documentation endpoints registered by Provider.
+const (
+	SpecPath  = "/_docs/openapi.json"
+	UIPath    = "/_docs"
+	RedocPath = "/_docs/redoc"
+
+	// AssetsPath prefixes the UI assets served when WithAssets is set.
+	AssetsPath = "/_docs/assets/"
+)
+
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithAssets serves the Swagger UI and Redoc builds from fsys instead of
+// unpkg, so the UI pages work without CDN access. fsys must hold
+// swagger-ui.css and swagger-ui-bundle.js from swagger-ui-dist and
+// redoc.standalone.js from redoc; they are served under AssetsPath.
+func WithAssets(fsys fs.FS) Option {
+	return func(p *Provider) { p.assets = fsys }
+}
+
+// Provider generates an OpenAPI 3.1 document. It implements doc.DocProvider.
+type Provider struct {
+	config doc.DocConfig
+	assets fs.FS
+
+	mu   sync.RWMutex
+	spec []byte
+}
+
+// NewProvider returns a Provider describing the API with config. A nil
+// config, or an empty title or version, falls back to defaults.
+func NewProvider(config *doc.DocConfig, opts ...Option) *Provider {
+	p := &Provider{}
+	if config != nil {
+		p.config = *config
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.config.Title == "" {
+		p.config.Title = "Gortex API"
+	}
+	if p.config.Version == "" {
+		p.config.Version = "1.0.0"
+	}
+	return p
+}
+
+// Generate builds the document for routes and keeps it for the spec
+// endpoint.
+func (p *Provider) Generate(routes []doc.RouteInfo) ([]byte, error) {
+	spec, err := json.MarshalIndent(p.Document(routes), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.spec = spec
+	p.mu.Unlock()
+	return spec, nil
+}
+
+// ContentType returns the MIME type of the generated document.
+func (p *Provider) ContentType() string {
+	return "application/json"
+}
+
+// UIHandler returns the Swagger UI page.
+func (p *Provider) UIHandler() http.Handler {
+	return uiHandler(swaggerUIPage, p.config.Title, p.assetBase())
+}
+
+// Endpoints returns the spec, Swagger UI and Redoc endpoints, plus the UI
+// assets when WithAssets is set.
+func (p *Provider) Endpoints() map[string]http.Handler {
+	endpoints := map[string]http.Handler{
+		SpecPath:  http.HandlerFunc(p.serveSpec),
+		UIPath:    p.UIHandler(),
+		RedocPath: uiHandler(redocPage, p.config.Title, p.assetBase()),
+	}
+	if p.assets != nil {
+		for _, name := range uiAssets {
+			endpoints[AssetsPath+name] = assetHandler(p.assets, name)
+		}
+	}
+	return endpoints
+}
+
+// assetBase returns where the UI pages load their assets from.
+func (p *Provider) assetBase() uiAssetURLs {
+	if p.assets != nil {
+		return localAssets
+	}
+	return cdnAssets
+}
+
+func (p *Provider) serveSpec(w http.ResponseWriter, _ *http.Request) {
+	p.mu.RLock()
+	spec := p.spec
+	p.mu.RUnlock()
+	if spec == nil {
+		var err error
+		if spec, err = p.Generate(nil); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", p.ContentType())
+	_, _ = w.Write(spec)
+}
+
+// Document builds the OpenAPI document for routes.
+func (p *Provider) Document(routes []doc.RouteInfo) *Document {
+	d := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       p.config.Title,
+			Version:     p.config.Version,
+			Description: p.config.Description,
+		},
+		Paths: make(map[string]PathItem),
+	}
+	if c := p.config.Contact; c != nil {
+		d.Info.Contact = &Contact{Name: c.Name, Email: c.Email, URL: c.URL}
+	}
+	if l := p.config.License; l != nil {
+		d.Info.License = &License{Name: l.Name, URL: l.URL}
+	}
+	for _, s := range p.config.Servers {
+		d.Servers = append(d.Servers, Server{URL: s.URL, Description: s.Description})
+	}
+	if len(d.Servers) == 0 && p.config.BasePath != "" {
+		d.Servers = []Server{{URL: p.config.BasePath}}
+	}
+
+	g := newSchemaGenerator()
+	handlers := make(map[string]int)
+	for _, route := range routes {
+		handlers[route.Handler]++
+	}
+	used := make(map[string]bool)
+	for _, route := range routes {
+		path, pathParams := templatePath(route.Path)
+		item := d.Paths[path]
+		if item == nil {
+			item = make(PathItem)
+			d.Paths[path] = item
+		}
+
+		op := buildOperation(g, route, pathParams)
+		if op.OperationID != "" {
+			if handlers[route.Handler] > 1 {
+				op.OperationID = routeOperationID(route.Method, path)
+			}
+			id := op.OperationID
+			for n := 2; used[id]; n++ {
+				id = op.OperationID + "_" + strconv.Itoa(n)
+			}
+			op.OperationID = id
+			used[id] = true
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	if len(g.components) > 0 {
+		d.Components = &Components{Schemas: g.components}
+	}
+	return d
+}
+
+// routeOperationID derives an operation ID from method and the templated
+// path, for handlers that serve more than one route: GET /files/{name}
+// becomes get_files_name.
+func routeOperationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, seg := range strings.Split(path, "/") {
+		if seg = strings.Trim(seg, "{}"); seg != "" {
+			id += "_" + seg
+		}
+	}
+	return id
+}
+
+// buildOperation describes route. pathParams are the parameters declared in
+// its pattern, keyed by name.
+func buildOperation(g *schemaGenerator, route doc.RouteInfo, pathParams map[string]httpctx.PathParam) *Operation {
+	op := &Operation{
+		OperationID: route.Handler,
+		Summary:     route.Description,
+		Tags:        route.Tags,
+		Responses:   make(map[string]*Response),
+	}
+
+	seen := make(map[string]bool)
+	var form, body []doc.ParamInfo
+	for _, info := range route.Params {
+		switch info.Type {
+		case "path", "query", "header":
+		case "form":
+			form = append(form, info)
+			continue
+		case "body":
+			body = append(body, info)
+			continue
+		default:
+			continue
+		}
+		if seen[info.Type+":"+info.Name] {
+			continue
+		}
+		param := &Parameter{
+			Name:        info.Name,
+			In:          info.Type,
+			Required:    info.Required || info.Type == "path",
+			Description: info.Description,
+			Schema:      paramSchema(info),
+		}
+		if pp, ok := pathParams[info.Name]; ok && info.Type == "path" {
+			param.Schema = pathParamSchema(pp)
+			param.Schema.Enum = enumValues(info)
+		}
+		op.Parameters = append(op.Parameters, param)
+		seen[info.Type+":"+info.Name] = true
+	}
+	// Every templated path segment must be declared.
+	names := make([]string, 0, len(pathParams))
+	for name := range pathParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !seen["path:"+name] {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name: name, In: "path", Required: true, Schema: pathParamSchema(pathParams[name]),
+			})
+			seen["path:"+name] = true
+		}
+	}
+
+	// The JSON body schema is reflected from the request type when there is
+	// one, so nested structs and validate constraints are kept; otherwise it
+	// is assembled from the "body" parameters.
+	if hasBody(route.Method) {
+		content := make(map[string]*MediaType)
+		if route.RequestType != nil {
+			if s := g.schema(route.RequestType); !isEmptyObject(g, s) {
+				content["application/json"] = &MediaType{Schema: s}
+			}
+		} else if len(body) > 0 {
+			content["application/json"] = &MediaType{Schema: objectSchema(body)}
+		}
+		if len(form) > 0 {
+			content["application/x-www-form-urlencoded"] = &MediaType{Schema: objectSchema(form)}
+		}
+		if len(content) > 0 {
+			op.RequestBody = &RequestBody{Required: true, Content: content}
+		}
+	}
+	if route.RequestType != nil {
+		op.Responses["400"] = &Response{Description: "The request could not be bound or failed validation"}
+	}
+
+	ok := &Response{Description: "OK"}
+	if route.ResponseType != nil {
+		ok.Content = map[string]*MediaType{"application/json": {Schema: g.schema(route.ResponseType)}}
+	}
+	op.Responses["200"] = ok
+	return op
+}
+
+// objectSchema returns an object schema with a property per parameter.
+func objectSchema(params []doc.ParamInfo) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, p := range params {
+		s.Properties[p.Name] = paramSchema(p)
+		if p.Required {
+			s.Required = append(s.Required, p.Name)
+		}
+	}
+	return s
+}
+
+// paramSchema returns the schema of a parameter from its data type and
+// enum.
+func paramSchema(p doc.ParamInfo) *Schema {
+	s := dataTypeSchema(p.DataType)
+	s.Enum = enumValues(p)
+	return s
+}
+
+// enumValues converts p.Enum to the JSON type of the parameter.
+func enumValues(p doc.ParamInfo) []any {
+	if len(p.Enum) == 0 {
+		return nil
+	}
+	values := make([]any, len(p.Enum))
+	for i, v := range p.Enum {
+		values[i] = v
+		switch p.DataType {
+		case "int", "uint", "float", "integer", "number":
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				values[i] = n
+			}
+		case "bool", "boolean":
+			if b, err := strconv.ParseBool(v); err == nil {
+				values[i] = b
+			}
+		}
+	}
+	return values
+}
+
+// hasBody reports whether the binder decodes a JSON body for method.
+func hasBody(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+// isEmptyObject reports whether s describes an object without properties,
+// following a component reference.
+func isEmptyObject(g *schemaGenerator, s *Schema) bool {
+	if name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/"); ok {
+		s = g.components[name]
+	}
+	return s.Type == "object" && len(s.Properties) == 0 && s.AdditionalProperties == nil
+}
+
+// templatePath converts a Gortex pattern into an OpenAPI path template
+// ("/users/:id<int>/*rest" becomes "/users/{id}/{rest}") and returns its
+// parameters by name.
+func templatePath(pattern string) (string, map[string]httpctx.PathParam) {
+	params := make(map[string]httpctx.PathParam)
+	if parsed, err := httpctx.ParsePathParams(pattern); err == nil {
+		for _, p := range parsed {
+			params[p.Name] = p
+		}
+	}
+
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			name, _, _ := strings.Cut(segment[1:], "<")
+			segments[i] = "{" + name + "}"
+		case strings.HasPrefix(segment, "*"):
+			name := segment[1:]
+			if name == "" {
+				name = "*"
+			}
+			segments[i] = "{" + name + "}"
+		}
+	}
+	path := strings.Join(segments, "/")
+	if path == "" {
+		path = "/"
+	}
+	return path, params
+}
+
+// pathParamSchema returns the schema of a path parameter, turning a regex
+// constraint into a pattern.
+func pathParamSchema(p httpctx.PathParam) *Schema {
+	s := dataTypeSchema(p.DataType)
+	switch p.Constraint {
+	case "", "int", "uint", "float", "uuid":
+	case "alpha":
+		s.Pattern = "^[a-zA-Z]+$"
+	case "alnum":
+		s.Pattern = "^[a-zA-Z0-9]+$"
+	default:
+		s.Pattern = "^(?:" + p.Constraint + ")$"
+	}
+	return s
+}
+
+// dataTypeSchema maps a doc.ParamInfo data type to a schema.
+func dataTypeSchema(dataType string) *Schema {
+	switch dataType {
+	case "int", "integer":
+		return &Schema{Type: "integer"}
+	case "uint":
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case "float", "number":
+		return &Schema{Type: "number"}
+	case "bool", "boolean":
+		return &Schema{Type: "boolean"}
+	case "array":
+		return &Schema{Type: "array", Items: &Schema{}}
+	case "object":
+		return &Schema{Type: "object"}
+	case "uuid":
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+	return &Schema{Type: "string"}
+}
@@ -0,0 +1,275 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/yshengliao/gortex/core/app/doc"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type createOrderRequest struct {
+	TenantID string   `bind:"X-Tenant,header" validate:"required"`
+	DryRun   bool     `bind:"dry_run,query"`
+	Sort     string   `bind:"sort,query" validate:"oneof=asc desc"`
+	UserID   string   `bind:"sub,jwt"`
+	Item     string   `json:"item" validate:"required,min=1,max=64"`
+	Quantity int      `json:"quantity" validate:"gte=1,lte=100"`
+	Priority string   `json:"priority,omitempty" validate:"oneof=low normal high"`
+	Email    string   `json:"email" validate:"omitempty,email"`
+	Tags     []string `json:"tags" validate:"max=5,dive,min=1"`
+	Ship     *address `json:"ship"`
+	Internal string   `json:"-"`
+	secret   string
+}
+
+type order struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Parent    *order    `json:"parent,omitempty"`
+}
+
+func generate(t *testing.T, routes []doc.RouteInfo) *Document {
+	t.Helper()
+	data, err := NewProvider(&doc.DocConfig{Title: "Orders", Version: "2.0.0"}).Generate(routes)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	var d Document
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatalf("generated document is not JSON: %v", err)
+	}
+	return &d
+}
+
+func TestGenerate_Operation(t *testing.T) {
+	d := generate(t, []doc.RouteInfo{{
+		Method:      "POST",
+		Path:        "/tenants/:tenant<[a-z]+>/orders/:id<int>",
+		Handler:     "OrderHandler.POST",
+		Description: "Create an order",
+		Params: append([]doc.ParamInfo{{Name: "id", Type: "path", DataType: "int", Required: true}},
+			doc.NewTagParser().ParseRequestParams(reflect.TypeOf(createOrderRequest{}))...),
+		RequestType:  reflect.TypeOf(createOrderRequest{}),
+		ResponseType: reflect.TypeOf(&order{}),
+	}})
+
+	if d.OpenAPI != "3.1.0" || d.Info.Title != "Orders" || d.Info.Version != "2.0.0" {
+		t.Fatalf("unexpected header: %s %+v", d.OpenAPI, d.Info)
+	}
+	op := d.Paths["/tenants/{tenant}/orders/{id}"]["post"]
+	if op == nil {
+		t.Fatalf("operation missing, paths: %v", d.Paths)
+	}
+	if op.OperationID != "OrderHandler.POST" || op.Summary != "Create an order" {
+		t.Errorf("unexpected operation metadata: %+v", op)
+	}
+
+	params := map[string]*Parameter{}
+	for _, p := range op.Parameters {
+		params[p.In+":"+p.Name] = p
+	}
+	if p := params["path:id"]; p == nil || !p.Required || p.Schema.Type != "integer" {
+		t.Errorf("path:id = %+v", p)
+	}
+	if p := params["path:tenant"]; p == nil || p.Schema.Pattern != "^(?:[a-z]+)$" {
+		t.Errorf("path:tenant = %+v", p)
+	}
+	if p := params["header:X-Tenant"]; p == nil || !p.Required {
+		t.Errorf("header:X-Tenant = %+v", p)
+	}
+	if p := params["query:dry_run"]; p == nil || p.Required || p.Schema.Type != "boolean" {
+		t.Errorf("query:dry_run = %+v", p)
+	}
+	if p := params["query:sort"]; p == nil || len(p.Schema.Enum) != 2 || p.Schema.Enum[0] != "asc" {
+		t.Errorf("query:sort = %+v", p)
+	}
+	if len(op.Parameters) != 5 {
+		t.Errorf("got %d parameters, want 5", len(op.Parameters))
+	}
+
+	body := op.RequestBody.Content["application/json"].Schema
+	if body.Ref != "#/components/schemas/createOrderRequest" {
+		t.Fatalf("request body schema = %+v", body)
+	}
+	req := d.Components.Schemas["createOrderRequest"]
+	for _, name := range []string{"TenantID", "DryRun", "Sort", "UserID", "Internal", "secret"} {
+		if _, ok := req.Properties[name]; ok {
+			t.Errorf("property %s should not be in the body schema", name)
+		}
+	}
+	if got := strings.Join(req.Required, ","); got != "item" {
+		t.Errorf("required = %q, want item", got)
+	}
+	item := req.Properties["item"]
+	if *item.MinLength != 1 || *item.MaxLength != 64 {
+		t.Errorf("item = %+v", item)
+	}
+	if q := req.Properties["quantity"]; *q.Minimum != 1 || *q.Maximum != 100 {
+		t.Errorf("quantity = %+v", q)
+	}
+	if p := req.Properties["priority"]; len(p.Enum) != 3 || p.Enum[2] != "high" {
+		t.Errorf("priority = %+v", p)
+	}
+	if e := req.Properties["email"]; e.Format != "email" {
+		t.Errorf("email = %+v", e)
+	}
+	if tags := req.Properties["tags"]; tags.Type != "array" || *tags.MaxItems != 5 || tags.Items.MinLength != nil {
+		t.Errorf("tags = %+v", tags)
+	}
+	if ship := req.Properties["ship"]; ship.Ref != "#/components/schemas/address" {
+		t.Errorf("ship = %+v", ship)
+	}
+
+	resp := op.Responses["200"].Content["application/json"].Schema
+	if resp.Ref != "#/components/schemas/order" {
+		t.Fatalf("response schema = %+v", resp)
+	}
+	o := d.Components.Schemas["order"]
+	if o.Properties["created_at"].Format != "date-time" || o.Properties["parent"].Ref != resp.Ref {
+		t.Errorf("order = %+v", o)
+	}
+	if op.Responses["400"] == nil {
+		t.Error("bound request should document a 400 response")
+	}
+}
+
+func TestGenerate_RoutesWithoutTypes(t *testing.T) {
+	d := generate(t, []doc.RouteInfo{
+		{Method: "GET", Path: "/", Handler: "Home.GET"},
+		{Method: "GET", Path: "/files/*filepath", Handler: "Home.GET"},
+	})
+
+	if op := d.Paths["/"]["get"]; op == nil || op.RequestBody != nil || op.Responses["200"].Content != nil {
+		t.Errorf("root operation = %+v", op)
+	}
+	op := d.Paths["/files/{filepath}"]["get"]
+	if op == nil || len(op.Parameters) != 1 || op.Parameters[0].Name != "filepath" {
+		t.Fatalf("wildcard operation = %+v", op)
+	}
+	if op.OperationID != "get_files_filepath" || d.Paths["/"]["get"].OperationID != "get" {
+		t.Errorf("shared handler operation IDs: %q, %q", d.Paths["/"]["get"].OperationID, op.OperationID)
+	}
+	if d.Components != nil {
+		t.Errorf("unexpected components: %+v", d.Components)
+	}
+}
+
+func TestProvider_Endpoints(t *testing.T) {
+	p := NewProvider(nil)
+	if _, err := p.Generate([]doc.RouteInfo{{Method: "GET", Path: "/health"}}); err != nil {
+		t.Fatal(err)
+	}
+	endpoints := p.Endpoints()
+
+	rec := httptest.NewRecorder()
+	endpoints[SpecPath].ServeHTTP(rec, httptest.NewRequest(http.MethodGet, SpecPath, nil))
+	if rec.Header().Get("Content-Type") != "application/json" || !strings.Contains(rec.Body.String(), `"/health"`) {
+		t.Errorf("spec endpoint: %s %s", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	for _, path := range []string{UIPath, RedocPath} {
+		rec := httptest.NewRecorder()
+		endpoints[path].ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body := rec.Body.String()
+		if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") ||
+			!strings.Contains(body, "<title>Gortex API</title>") || !strings.Contains(body, "openapi.json") {
+			t.Errorf("%s: unexpected page %q", path, body)
+		}
+	}
+}
+
+// Operation IDs stay unique whatever the registration order, even when a
+// derived ID matches one taken from a handler name.
+func TestGenerate_OperationIDsUnique(t *testing.T) {
+	routes := []doc.RouteInfo{
+		{Method: "GET", Path: "/a", Handler: "get_b"},
+		{Method: "GET", Path: "/b", Handler: "Shared.GET"},
+		{Method: "GET", Path: "/c", Handler: "Shared.GET"},
+	}
+	for _, order := range [][]int{{0, 1, 2}, {2, 1, 0}} {
+		var rs []doc.RouteInfo
+		for _, i := range order {
+			rs = append(rs, routes[i])
+		}
+		d := generate(t, rs)
+		ids := map[string]bool{}
+		for _, path := range []string{"/a", "/b", "/c"} {
+			ids[d.Paths[path]["get"].OperationID] = true
+		}
+		if len(ids) != 3 || d.Paths["/c"]["get"].OperationID != "get_c" {
+			t.Errorf("order %v: operation IDs %v", order, ids)
+		}
+	}
+}
+
+// By default the pages load pinned CDN builds; WithAssets serves the assets
+// locally and points the pages at them.
+func TestProvider_Assets(t *testing.T) {
+	page := func(p *Provider, path string) string {
+		rec := httptest.NewRecorder()
+		p.Endpoints()[path].ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Body.String()
+	}
+
+	cdn := NewProvider(nil)
+	if body := page(cdn, UIPath); !strings.Contains(body, "swagger-ui-dist@"+swaggerUIVersion+"/swagger-ui-bundle.js") {
+		t.Errorf("swagger page: %q", body)
+	}
+	if body := page(cdn, RedocPath); !strings.Contains(body, "redoc@"+redocVersion+"/") {
+		t.Errorf("redoc page: %q", body)
+	}
+	if _, ok := cdn.Endpoints()[AssetsPath+"swagger-ui.css"]; ok {
+		t.Error("assets served without WithAssets")
+	}
+
+	local := NewProvider(nil, WithAssets(fstest.MapFS{
+		"swagger-ui.css":       {Data: []byte("body{}")},
+		"swagger-ui-bundle.js": {Data: []byte("var SwaggerUIBundle;")},
+		"redoc.standalone.js":  {Data: []byte("var Redoc;")},
+	}))
+	if body := page(local, UIPath); !strings.Contains(body, `src="/_docs/assets/swagger-ui-bundle.js"`) || strings.Contains(body, "unpkg") {
+		t.Errorf("swagger page: %q", body)
+	}
+	if body := page(local, RedocPath); !strings.Contains(body, `src="/_docs/assets/redoc.standalone.js"`) || strings.Contains(body, "unpkg") {
+		t.Errorf("redoc page: %q", body)
+	}
+	if body := page(local, AssetsPath+"redoc.standalone.js"); body != "var Redoc;" {
+		t.Errorf("asset: %q", body)
+	}
+}
+
+// Without a request type the body and form schemas are assembled from the
+// route's parameters.
+func TestGenerate_BodyFromParams(t *testing.T) {
+	d := generate(t, []doc.RouteInfo{{
+		Method: "PUT",
+		Path:   "/settings",
+		Params: []doc.ParamInfo{
+			{Name: "theme", Type: "body", DataType: "string", Required: true, Enum: []string{"light", "dark"}},
+			{Name: "retries", Type: "body", DataType: "int", Enum: []string{"1", "3"}},
+			{Name: "avatar", Type: "form", DataType: "string"},
+		},
+	}})
+
+	body := d.Paths["/settings"]["put"].RequestBody
+	if body == nil {
+		t.Fatal("request body missing")
+	}
+	js := body.Content["application/json"].Schema
+	if js.Required[0] != "theme" || js.Properties["retries"].Enum[1] != float64(3) {
+		t.Errorf("json body = %+v", js)
+	}
+	if form := body.Content["application/x-www-form-urlencoded"]; form == nil || form.Schema.Properties["avatar"] == nil {
+		t.Errorf("form body = %+v", form)
+	}
+}
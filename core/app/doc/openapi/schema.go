@@ -0,0 +1,288 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nonBodySources are the bind tag sources the binder fills from somewhere
+// other than the JSON body. Fields bound from them are left out of body
+// schemas.
+var nonBodySources = map[string]bool{
+	"path": true, "query": true, "header": true, "form": true,
+	"jwt": true, "claims": true, "context": true,
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// schemaGenerator builds schemas by reflection. Named struct types are
+// emitted once under components and referenced by $ref, which also keeps
+// recursive types finite.
+type schemaGenerator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// schema returns the schema for t.
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+	// Interfaces, funcs and channels accept anything.
+	return &Schema{}
+}
+
+// component registers the schema of named struct type t and returns its
+// component name. Types from different packages sharing a name are told
+// apart by a package prefix.
+func (g *schemaGenerator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.components[name]; taken {
+		pkg := t.PkgPath()
+		if i := strings.LastIndexByte(pkg, '/'); i >= 0 {
+			pkg = pkg[i+1:]
+		}
+		name = pkg + "." + name
+		for i := 2; ; i++ {
+			if _, taken := g.components[name]; !taken {
+				break
+			}
+			name = pkg + "." + t.Name() + strconv.Itoa(i)
+		}
+	}
+
+	// Reserve the name before walking the fields so a field referring
+	// back to t resolves to the same component.
+	g.names[t] = name
+	g.components[name] = &Schema{}
+	*g.components[name] = *g.structSchema(t)
+	return name
+}
+
+// structSchema returns an object schema for the exported fields of t.
+// Fields are named by their json tag; validate tags become constraints and
+// fields bound from outside the body (see nonBodySources) are skipped.
+// Embedded structs without a json name are flattened, as encoding/json
+// does.
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *schemaGenerator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := jsonFieldName(field)
+		if !ok || nonBodySources[bindSource(field)] {
+			continue
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" && ft.Kind() == reflect.Struct {
+			g.addFields(s, ft)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		prop := g.schema(field.Type)
+		required := applyValidate(prop, field.Type, field.Tag.Get("validate"))
+		s.Properties[name] = prop
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// jsonFieldName returns the JSON property name of field, and false when
+// encoding/json skips it.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return field.Name, field.Anonymous || field.IsExported()
+}
+
+// bindSource returns the source part of field's bind tag ("path" for
+// `bind:"id,path"`), or "" when none is given.
+func bindSource(field reflect.StructField) string {
+	_, source, _ := strings.Cut(field.Tag.Get("bind"), ",")
+	return source
+}
+
+// applyValidate adds the constraints expressed by a validate tag to s,
+// the schema of a value of type t, and reports whether the tag marks the
+// value as required. Rules after "dive" apply to elements and are ignored.
+func applyValidate(s *Schema, t reflect.Type, tag string) (required bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "min", "gte":
+			setBound(t, arg, &s.Minimum, &s.MinLength, &s.MinItems)
+		case "max", "lte":
+			setBound(t, arg, &s.Maximum, &s.MaxLength, &s.MaxItems)
+		case "len":
+			setBound(t, arg, &s.Minimum, &s.MinLength, &s.MinItems)
+			setBound(t, arg, &s.Maximum, &s.MaxLength, &s.MaxItems)
+		case "gt":
+			if isNumeric(t) {
+				s.ExclusiveMinimum = parseFloat(arg)
+			}
+		case "lt":
+			if isNumeric(t) {
+				s.ExclusiveMaximum = parseFloat(arg)
+			}
+		case "oneof":
+			for _, v := range strings.Fields(arg) {
+				s.Enum = append(s.Enum, enumValue(t, v))
+			}
+		case "email":
+			s.Format = "email"
+		case "url", "uri", "http_url":
+			s.Format = "uri"
+		case "uuid", "uuid4", "uuid5":
+			s.Format = "uuid"
+		case "datetime":
+			s.Format = "date-time"
+		case "ipv4":
+			s.Format = "ipv4"
+		case "ipv6":
+			s.Format = "ipv6"
+		case "alpha":
+			s.Pattern = "^[a-zA-Z]+$"
+		case "alphanum":
+			s.Pattern = "^[a-zA-Z0-9]+$"
+		case "numeric":
+			s.Pattern = "^[-+]?[0-9]+(?:\\.[0-9]+)?$"
+		}
+	}
+	return required
+}
+
+// setBound applies a min/max style rule: a value bound for numbers, a
+// length bound for strings and an item count for slices and maps.
+func setBound(t reflect.Type, arg string, number **float64, length, items **int) {
+	switch {
+	case isNumeric(t):
+		*number = parseFloat(arg)
+	case t.Kind() == reflect.String:
+		*length = parseInt(arg)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map:
+		*items = parseInt(arg)
+	}
+}
+
+func isNumeric(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// enumValue converts a oneof value to the JSON type of t, falling back to
+// the string when it does not parse.
+func enumValue(t reflect.Type, v string) any {
+	switch {
+	case t.Kind() == reflect.Bool:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	case isNumeric(t):
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+func parseFloat(s string) *float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
+
+func parseInt(s string) *int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil
+	}
+	return &n
+}
+
+func float(f float64) *float64 {
+	return &f
+}
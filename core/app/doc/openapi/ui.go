@@ -0,0 +1,73 @@
+package openapi
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+)
+
+// The UI pages are embedded and point Swagger UI and Redoc at SpecPath. By
+// default they load exact builds of swagger-ui-dist and redoc from unpkg, so
+// the browser viewing them needs access to the CDN; WithAssets serves the
+// same files from the application instead.
+//
+//go:embed ui/*.html
+var uiFiles embed.FS
+
+var (
+	swaggerUIPage = template.Must(template.ParseFS(uiFiles, "ui/swagger.html"))
+	redocPage     = template.Must(template.ParseFS(uiFiles, "ui/redoc.html"))
+)
+
+// Pinned CDN builds of the UI assets.
+const (
+	swaggerUIVersion = "5.17.14"
+	redocVersion     = "2.1.5"
+)
+
+// uiAssets lists the files WithAssets must provide.
+var uiAssets = []string{"swagger-ui.css", "swagger-ui-bundle.js", "redoc.standalone.js"}
+
+// uiAssetURLs locates the assets a UI page loads.
+type uiAssetURLs struct {
+	SwaggerCSS, SwaggerJS, RedocJS string
+}
+
+var (
+	cdnAssets = uiAssetURLs{
+		SwaggerCSS: "https://unpkg.com/swagger-ui-dist@" + swaggerUIVersion + "/swagger-ui.css",
+		SwaggerJS:  "https://unpkg.com/swagger-ui-dist@" + swaggerUIVersion + "/swagger-ui-bundle.js",
+		RedocJS:    "https://unpkg.com/redoc@" + redocVersion + "/bundles/redoc.standalone.js",
+	}
+	localAssets = uiAssetURLs{
+		SwaggerCSS: AssetsPath + "swagger-ui.css",
+		SwaggerJS:  AssetsPath + "swagger-ui-bundle.js",
+		RedocJS:    AssetsPath + "redoc.standalone.js",
+	}
+)
+
+// uiHandler serves page rendered for title, loading its assets from assets.
+func uiHandler(page *template.Template, title string, assets uiAssetURLs) http.Handler {
+	var buf bytes.Buffer
+	err := page.Execute(&buf, struct {
+		Title, SpecURL string
+		Assets         uiAssetURLs
+	}{title, SpecPath, assets})
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+// assetHandler serves name from fsys.
+func assetHandler(fsys fs.FS, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, fsys, name)
+	})
+}
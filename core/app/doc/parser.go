@@ -118,10 +118,26 @@ func (p *TagParser) ParseRouteInfo(handlerType reflect.Type, method reflect.Meth
 
 		// Generate path from method name
 		routePath := p.generateRoutePath(methodName, basePath)
+
+		// A custom method may declare its own HTTP method and path.
+		if declared, subPath, ok := declaredRoute(handlerType, methodName); ok {
+			routeInfo.Method = declared
+			switch subPath {
+			case "":
+			case "/":
+				routePath = basePath
+			default:
+				routePath = strings.TrimSuffix(basePath, "/") + subPath
+			}
+		}
 		routeInfo.Path = routePath
 
 		// Set description based on method name
 		routeInfo.Description = p.generateDescription(handlerType.Name(), methodName)
+
+		// Describe what the binder reads from the request.
+		routeInfo.RequestType, routeInfo.ResponseType = methodTypes(method)
+		routeInfo.Params = p.ParseRequestParams(routeInfo.RequestType)
 	}
 
 	return routeInfo
@@ -131,8 +147,8 @@ func (p *TagParser) ParseRouteInfo(handlerType reflect.Type, method reflect.Meth
 //
 // Standard HTTP verb names (GET, POST, PUT, DELETE, PATCH, HEAD, OPTIONS) map
 // to themselves. All other method names map to POST, which is the HTTP method
-// used by registerCustomMethodWithMiddleware at runtime — regardless of what
-// the Go method name implies.
+// custom methods are registered with at runtime unless the handler declares
+// one (see declaredRoute) — regardless of what the Go method name implies.
 func (p *TagParser) extractHTTPMethod(methodName string) string {
 	// Standard HTTP method names map to themselves.
 	httpMethods := []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"}
@@ -146,6 +162,41 @@ func (p *TagParser) extractHTTPMethod(methodName string) string {
 	return "POST"
 }
 
+// declaredRoute returns the HTTP method and relative path a handler declares
+// for a custom method through a `Routes() map[string]string` method (see
+// app.MethodRoutes), e.g. "GET" and "/profile" for "GetProfile": "GET /profile".
+// Declarations the runtime would reject are ignored.
+func declaredRoute(handlerType reflect.Type, methodName string) (method, path string, ok bool) {
+	if handlerType.Kind() == reflect.Ptr {
+		handlerType = handlerType.Elem()
+	}
+	if handlerType.Kind() != reflect.Struct || isStandardMethod(methodName) {
+		return "", "", false
+	}
+	declarer, isDeclarer := reflect.New(handlerType).Interface().(interface{ Routes() map[string]string })
+	if !isDeclarer {
+		return "", "", false
+	}
+	fields := strings.Fields(declarer.Routes()[methodName])
+	if len(fields) == 0 || len(fields) > 2 || !isStandardMethod(strings.ToUpper(fields[0])) {
+		return "", "", false
+	}
+	if len(fields) == 2 {
+		path = fields[1]
+	}
+	return strings.ToUpper(fields[0]), path, true
+}
+
+// isStandardMethod reports whether name is one of the HTTP verbs a handler
+// method can be named after.
+func isStandardMethod(name string) bool {
+	switch name {
+	case "GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
+
 // generateRoutePath generates route path from method name
 func (p *TagParser) generateRoutePath(methodName string, basePath string) string {
 	// For standard HTTP methods, use base path
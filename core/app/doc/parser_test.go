@@ -60,8 +60,8 @@ func TestExtractHTTPMethod(t *testing.T) {
 		{"PATCH", "PATCH"},
 		{"HEAD", "HEAD"},
 		{"OPTIONS", "OPTIONS"},
-		// All custom (non-standard-verb) methods map to POST, matching the runtime:
-		// registerCustomMethodWithMiddleware always registers as HTTP POST.
+		// Custom (non-standard-verb) methods map to POST, matching the runtime
+		// default for methods without a declared route.
 		{"ListUsers", "POST"},
 		{"GetUser", "POST"},
 		{"CreateUser", "POST"},
@@ -122,3 +122,44 @@ func TestCamelToKebab(t *testing.T) {
 		})
 	}
 }
+
+type ProfileHandler struct{}
+
+func (h *ProfileHandler) GET()        {}
+func (h *ProfileHandler) GetProfile() {}
+func (h *ProfileHandler) Archive()    {}
+func (h *ProfileHandler) Rename()     {}
+func (h *ProfileHandler) Routes() map[string]string {
+	return map[string]string{
+		"GetProfile": "GET /profile",
+		"Archive":    "delete /",
+		"Rename":     "RENAME /name",
+	}
+}
+
+func TestParseRouteInfo_DeclaredRoutes(t *testing.T) {
+	parser := NewTagParser()
+	handlerType := reflect.TypeOf(&ProfileHandler{})
+
+	tests := []struct {
+		method     string
+		wantMethod string
+		wantPath   string
+	}{
+		{"GetProfile", "GET", "/users/:id/profile"},
+		{"Archive", "DELETE", "/users/:id"},
+		// Declarations the runtime rejects fall back to the default.
+		{"Rename", "POST", "/users/:id/rename"},
+		{"GET", "GET", "/users/:id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			method, _ := handlerType.MethodByName(tt.method)
+			info := parser.ParseRouteInfo(handlerType.Elem(), method, "/users/:id", nil)
+			if info.Method != tt.wantMethod || info.Path != tt.wantPath {
+				t.Errorf("ParseRouteInfo(%s) = %s %s, want %s %s", tt.method, info.Method, info.Path, tt.wantMethod, tt.wantPath)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package doc
 
 import (
 	"net/http"
+	"reflect"
 )
 
 // RouteInfo represents information about a registered route
@@ -14,16 +15,24 @@ type RouteInfo struct {
 	Tags        []string               // API tags for grouping
 	Description string                 // Route description
 	Metadata    map[string]interface{} // Additional metadata from struct tags
+
+	// RequestType is the struct the handler method binds from the request
+	// (see ParameterBinder), nil when it only takes a Context.
+	RequestType reflect.Type
+	// ResponseType is T for a handler method returning (T, error), nil when
+	// it only returns an error.
+	ResponseType reflect.Type
 }
 
 // ParamInfo represents information about a route parameter
 type ParamInfo struct {
-	Name        string // Parameter name
-	Type        string // Parameter type (path, query, header, body)
-	DataType    string // Data type (string, int, bool, etc.)
-	Required    bool   // Whether the parameter is required
-	Description string // Parameter description
-	Example     string // Example value
+	Name        string   // Parameter name
+	Type        string   // Parameter type (path, query, header, body)
+	DataType    string   // Data type (string, int, bool, etc.)
+	Required    bool     // Whether the parameter is required
+	Description string   // Parameter description
+	Example     string   // Example value
+	Enum        []string // Allowed values, from a `validate:"oneof=..."` rule
 }
 
 // DocProvider defines the interface for API documentation providers
@@ -0,0 +1,172 @@
+package doc
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+// ParseRequestParams describes the request struct t the way
+// ParameterBinder.BindMethodParams fills it:
+//
+//   - a field tagged `bind:"name,path|query|header|form"` becomes a
+//     parameter of that type (path parameters are always required);
+//   - any other field encoding/json would decode becomes a "body" entry named
+//     by its json tag, embedded structs flattened;
+//   - fields bound from jwt, claims or context are not client input and are
+//     skipped.
+//
+// `validate:"required"` sets Required and `validate:"oneof=a b"` sets Enum.
+func (p *TagParser) ParseRequestParams(t reflect.Type) []ParamInfo {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var params []ParamInfo
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, source, _ := strings.Cut(field.Tag.Get("bind"), ",")
+		switch source {
+		case "path", "query", "header", "form":
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" || name == "-" {
+				name = bindFallbackName(field)
+			}
+			info := fieldParamInfo(name, source, field)
+			if source == "path" {
+				info.Required = true
+			}
+			params = append(params, info)
+		case "jwt", "claims", "context":
+			continue
+		default:
+			params = appendBodyParams(params, field)
+		}
+	}
+	return params
+}
+
+// appendBodyParams appends the JSON body entries for field, flattening an
+// embedded struct without a json name as encoding/json does.
+func appendBodyParams(params []ParamInfo, field reflect.StructField) []ParamInfo {
+	jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if jsonName == "-" {
+		return params
+	}
+
+	ft := field.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	if field.Anonymous && jsonName == "" && ft.Kind() == reflect.Struct {
+		for i := 0; i < ft.NumField(); i++ {
+			inner := ft.Field(i)
+			if _, source, _ := strings.Cut(inner.Tag.Get("bind"), ","); source == "" {
+				params = appendBodyParams(params, inner)
+			}
+		}
+		return params
+	}
+	if !field.IsExported() {
+		return params
+	}
+	if jsonName == "" {
+		jsonName = field.Name
+	}
+	return append(params, fieldParamInfo(jsonName, "body", field))
+}
+
+// bindFallbackName is the name the binder uses for a bind tag without one:
+// the json name, else the lower-cased field name.
+func bindFallbackName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return strings.ToLower(field.Name)
+}
+
+// fieldParamInfo builds the ParamInfo for field, reading its validate tag.
+func fieldParamInfo(name, in string, field reflect.StructField) ParamInfo {
+	info := ParamInfo{
+		Name:     name,
+		Type:     in,
+		DataType: goDataType(field.Type),
+	}
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		rule, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch rule {
+		case "dive":
+			return info
+		case "required":
+			info.Required = true
+		case "oneof":
+			info.Enum = strings.Fields(arg)
+		case "uuid", "uuid4", "uuid5":
+			if info.DataType == "string" {
+				info.DataType = "uuid"
+			}
+		}
+	}
+	return info
+}
+
+// goDataType maps a Go type to the ParamInfo data type vocabulary used for
+// path parameters, extended with "bool", "array" and "object".
+func goDataType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "uint"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		return "array"
+	case reflect.Map, reflect.Struct, reflect.Interface:
+		return "object"
+	}
+	return "string"
+}
+
+// methodTypes returns the request struct a handler method binds and the T
+// of a (T, error) result. The first struct (or struct pointer) parameter is
+// taken to be the request: the parser has no DI context, so it cannot tell a
+// service injected as a struct pointer apart.
+func methodTypes(method reflect.Method) (request, response reflect.Type) {
+	t := method.Type
+	for i := 1; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if in.Kind() == reflect.Ptr {
+			in = in.Elem()
+		}
+		if in.Kind() == reflect.Struct {
+			request = in
+			break
+		}
+	}
+	if t.NumOut() == 2 && t.Out(1) == errorType {
+		response = t.Out(0)
+	}
+	return request, response
+}
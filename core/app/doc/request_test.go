@@ -0,0 +1,81 @@
+package doc
+
+import (
+	"reflect"
+	"testing"
+)
+
+type pageQuery struct {
+	Page int `bind:"page,query"`
+}
+
+type searchRequest struct {
+	pageQuery
+	ID      string   `bind:"id,path" validate:"uuid"`
+	Sort    string   `bind:"sort,query" validate:"oneof=asc desc"`
+	Tenant  string   `bind:",header" validate:"required"`
+	Avatar  []byte   `bind:"avatar,form"`
+	UserID  string   `bind:"user_id,jwt"`
+	Request string   `bind:"request_id,context"`
+	Term    string   `json:"term" validate:"required,min=1"`
+	Filters []string `json:"filters" validate:"dive,oneof=a b"`
+	Limit   *uint    `json:"limit,omitempty"`
+	Skip    string   `json:"-"`
+	private string
+}
+
+type searchResult struct{}
+
+type SearchHandler struct{}
+
+func (h *SearchHandler) POST(c any, req *searchRequest) (*searchResult, error) { return nil, nil }
+func (h *SearchHandler) GET(c any) error                                       { return nil }
+
+func TestParseRequestParams(t *testing.T) {
+	params := NewTagParser().ParseRequestParams(reflect.TypeOf(&searchRequest{}))
+
+	want := []ParamInfo{
+		{Name: "id", Type: "path", DataType: "uuid", Required: true},
+		{Name: "sort", Type: "query", DataType: "string", Enum: []string{"asc", "desc"}},
+		{Name: "tenant", Type: "header", DataType: "string", Required: true},
+		{Name: "avatar", Type: "form", DataType: "string"},
+		{Name: "term", Type: "body", DataType: "string", Required: true},
+		{Name: "filters", Type: "body", DataType: "array"},
+		{Name: "limit", Type: "body", DataType: "uint"},
+	}
+	// The binder does not look for bind tags inside embedded structs, so
+	// pageQuery contributes nothing.
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("ParseRequestParams() =\n%+v\nwant\n%+v", params, want)
+	}
+
+	if got := NewTagParser().ParseRequestParams(nil); got != nil {
+		t.Errorf("ParseRequestParams(nil) = %+v, want nil", got)
+	}
+	if got := NewTagParser().ParseRequestParams(reflect.TypeOf("")); got != nil {
+		t.Errorf("ParseRequestParams(string) = %+v, want nil", got)
+	}
+}
+
+func TestParseRouteInfo_RequestAndResponse(t *testing.T) {
+	parser := NewTagParser()
+	handlerType := reflect.TypeOf(&SearchHandler{})
+
+	post, _ := handlerType.MethodByName("POST")
+	info := parser.ParseRouteInfo(handlerType.Elem(), post, "/search", nil)
+	if info.RequestType != reflect.TypeOf(searchRequest{}) {
+		t.Errorf("RequestType = %v", info.RequestType)
+	}
+	if info.ResponseType != reflect.TypeOf(&searchResult{}) {
+		t.Errorf("ResponseType = %v", info.ResponseType)
+	}
+	if len(info.Params) != 7 {
+		t.Errorf("got %d params, want 7", len(info.Params))
+	}
+
+	get, _ := handlerType.MethodByName("GET")
+	info = parser.ParseRouteInfo(handlerType.Elem(), get, "/search", nil)
+	if info.RequestType != nil || info.ResponseType != nil || info.Params != nil {
+		t.Errorf("GET route = %+v", info)
+	}
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	appcontext "github.com/yshengliao/gortex/core/context"
+	httpctx "github.com/yshengliao/gortex/transport/http"
+)
+
+type hostTenantHandler struct{}
+
+func (hostTenantHandler) GET(c httpctx.Context) error {
+	return c.String(http.StatusOK, "tenant "+c.Param("tenant")+" project "+c.Param("id"))
+}
+
+type hostAdminHandler struct{}
+
+func (hostAdminHandler) GET(c httpctx.Context) error {
+	return c.String(http.StatusOK, "admin")
+}
+
+type hostTenantGroup struct {
+	Project *hostTenantHandler `url:"/projects/:id"`
+}
+
+type hostManager struct {
+	Tenants *hostTenantGroup  `url:"/app" host:"{tenant}.example.com"`
+	Admin   *hostAdminHandler `url:"/" host:"admin.example.com"`
+	Home    *hostAdminHandler `url:"/home"`
+}
+
+func TestHostTagRoutesByHost(t *testing.T) {
+	r := newAppTestRouter()
+	require.NoError(t, RegisterRoutesFromStruct(r, &hostManager{}, appcontext.NewContext()))
+
+	serve := func(host, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, "tenant acme project 9", serve("acme.example.com", "/app/projects/9").Body.String())
+	assert.Equal(t, "admin", serve("admin.example.com", "/").Body.String())
+	assert.Equal(t, http.StatusNotFound, serve("example.com", "/app/projects/9").Code)
+	assert.Equal(t, http.StatusNotFound, serve("example.com", "/").Code)
+	assert.Equal(t, "admin", serve("acme.example.com", "/home").Body.String(),
+		"fields without a host tag serve every host")
+}
+
+type nestedHostManager struct {
+	Tenants *struct {
+		Admin *hostAdminHandler `url:"/admin" host:"admin.example.com"`
+	} `url:"/t" host:"{tenant}.example.com"`
+}
+
+func TestHostTagNestedHostFailsRegistration(t *testing.T) {
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &nestedHostManager{}, appcontext.NewContext())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already bound to host {tenant}.example.com")
+}
+
+type invalidHostManager struct {
+	Admin *hostAdminHandler `url:"/" host:"admin.example.com:443"`
+}
+
+func TestHostTagInvalidPatternFailsRegistration(t *testing.T) {
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &invalidHostManager{}, appcontext.NewContext())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must not include a port")
+}
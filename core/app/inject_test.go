@@ -1,17 +1,23 @@
 package app
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	appcontext "github.com/yshengliao/gortex/core/context"
+	httpctx "github.com/yshengliao/gortex/transport/http"
 )
 
 // TestInjectDependencies_NilFieldReturnsError ensures that a struct field
-// tagged with `inject` that remains nil causes injectDependencies to return
-// an error rather than silently leaving a nil pointer that would panic later.
+// tagged with `inject` that cannot be resolved causes injectDependencies to
+// return an error rather than silently leaving a nil pointer that would panic
+// later.
 func TestInjectDependencies_NilFieldReturnsError(t *testing.T) {
 	type FakeService struct{}
 	type Handler struct {
@@ -23,6 +29,7 @@ func TestInjectDependencies_NilFieldReturnsError(t *testing.T) {
 
 	err := injectDependencies(reflect.ValueOf(h), ctx)
 	require.Error(t, err, "injectDependencies must return error for nil inject field")
+	assert.Contains(t, err.Error(), "Handler.Svc")
 }
 
 // TestInjectDependencies_NonNilFieldIsOK ensures that a field with inject tag
@@ -39,3 +46,145 @@ func TestInjectDependencies_NonNilFieldIsOK(t *testing.T) {
 	err := injectDependencies(reflect.ValueOf(h), ctx)
 	require.NoError(t, err, "injectDependencies must not error when inject field is already populated")
 }
+
+type injectRepo interface{ Find(id string) string }
+
+type memInjectRepo struct{ prefix string }
+
+func (r *memInjectRepo) Find(id string) string { return r.prefix + id }
+
+type injectUsersHandler struct {
+	Repo  injectRepo     `inject:""`
+	Cache *memInjectRepo `inject:"cache"`
+}
+
+func (h *injectUsersHandler) GET(c httpctx.Context) error {
+	return c.String(http.StatusOK, h.Repo.Find("1")+","+h.Cache.Find("2"))
+}
+
+type injectAPIGroup struct {
+	Users *injectUsersHandler `url:"/users"`
+}
+
+type injectManager struct {
+	API *injectAPIGroup `url:"/api"`
+}
+
+func TestInjectDependencies_ResolvesNestedHandlersByTypeAndName(t *testing.T) {
+	ctx := appcontext.NewContext()
+	appcontext.Register(ctx, &memInjectRepo{prefix: "db:"})
+	appcontext.RegisterNamed(ctx, "cache", &memInjectRepo{prefix: "cache:"})
+
+	r := newAppTestRouter()
+	mgr := &injectManager{}
+	require.NoError(t, RegisterRoutesFromStruct(r, mgr, ctx))
+
+	users := mgr.API.Users
+	require.NotNil(t, users.Repo)
+	assert.Equal(t, "db:1", users.Repo.Find("1"))
+	assert.Equal(t, "cache:2", users.Cache.Find("2"))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	assert.Equal(t, "db:1,cache:2", rec.Body.String())
+}
+
+func TestInjectDependencies_MissingProviderReportsFieldPath(t *testing.T) {
+	ctx := appcontext.NewContext()
+	appcontext.RegisterNamed(ctx, "cache", &memInjectRepo{})
+
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &injectManager{}, ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "injectManager.API.Users.Repo")
+	assert.Contains(t, err.Error(), "no provider registered")
+}
+
+type otherInjectRepo struct{}
+
+func (otherInjectRepo) Find(string) string { return "" }
+
+func TestInjectDependencies_AmbiguousInterface(t *testing.T) {
+	ctx := appcontext.NewContext()
+	appcontext.Register(ctx, &memInjectRepo{})
+	appcontext.Register(ctx, otherInjectRepo{})
+	appcontext.RegisterNamed(ctx, "cache", &memInjectRepo{})
+
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &injectManager{}, ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, appcontext.ErrServiceAmbiguous))
+	assert.Contains(t, err.Error(), "injectManager.API.Users.Repo")
+}
+
+func TestInjectDependencies_MissingNamedService(t *testing.T) {
+	ctx := appcontext.NewContext()
+	appcontext.Register(ctx, &memInjectRepo{})
+
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &injectManager{}, ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "injectManager.API.Users.Cache")
+	assert.Contains(t, err.Error(), `"cache"`)
+}
+
+func TestInjectDependencies_SingletonProvider(t *testing.T) {
+	ctx := appcontext.NewContext()
+	calls := 0
+	appcontext.Provide(ctx, appcontext.Singleton, func(*appcontext.Context) (injectRepo, error) {
+		calls++
+		return &memInjectRepo{prefix: "p:"}, nil
+	})
+	appcontext.RegisterNamed(ctx, "cache", &memInjectRepo{})
+
+	mgr := &injectManager{}
+	require.NoError(t, RegisterRoutesFromStruct(newAppTestRouter(), mgr, ctx))
+	assert.Equal(t, "p:1", mgr.API.Users.Repo.Find("1"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestInjectDependencies_ScopedProviderRejectedForFields(t *testing.T) {
+	ctx := appcontext.NewContext()
+	appcontext.Provide(ctx, appcontext.Scoped, func(*appcontext.Context) (injectRepo, error) {
+		return &memInjectRepo{}, nil
+	})
+	appcontext.RegisterNamed(ctx, "cache", &memInjectRepo{})
+
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &injectManager{}, ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "scoped")
+	assert.Contains(t, err.Error(), "injectManager.API.Users.Repo")
+}
+
+// requestTrace is a per-request service handed to handler methods.
+type requestTrace struct{ path string }
+
+type scopedParamHandler struct{}
+
+func (scopedParamHandler) GET(c httpctx.Context, trace *requestTrace) error {
+	return c.String(http.StatusOK, trace.path)
+}
+
+type scopedParamManager struct {
+	Trace *scopedParamHandler `url:"/trace"`
+}
+
+func TestInjectDependencies_ScopedProviderPerRequestParam(t *testing.T) {
+	ctx := appcontext.NewContext()
+	calls := 0
+	appcontext.Provide(ctx, appcontext.Scoped, func(scope *appcontext.Context) (*requestTrace, error) {
+		calls++
+		c, err := appcontext.Get[httpctx.Context](scope)
+		if err != nil {
+			return nil, err
+		}
+		return &requestTrace{path: c.Request().URL.Path}, nil
+	})
+
+	r := newAppTestRouter()
+	require.NoError(t, RegisterRoutesFromStruct(r, &scopedParamManager{}, ctx))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/trace", nil))
+		assert.Equal(t, "/trace", rec.Body.String())
+	}
+	assert.Equal(t, 2, calls, "scoped provider must run once per request")
+}
@@ -15,6 +15,8 @@ import (
 
 	appcontext "github.com/yshengliao/gortex/core/context"
 	"github.com/yshengliao/gortex/middleware"
+	"github.com/yshengliao/gortex/pkg/auth"
+	"github.com/yshengliao/gortex/pkg/auth/rbac"
 	httpctx "github.com/yshengliao/gortex/transport/http"
 )
 
@@ -45,8 +47,8 @@ func TestExtractMiddlewareNames(t *testing.T) {
 	noop := func(next middleware.HandlerFunc) middleware.HandlerFunc { return next }
 	got := extractMiddlewareNames([]middleware.MiddlewareFunc{noop, nil, noop})
 	// nil middleware is skipped; the two non-nil entries produce
-	// the actual function name derived from reflection.
-	assert.Equal(t, []string{"app.TestExtractMiddlewareNames.func1", "app.TestExtractMiddlewareNames.func1"}, got)
+	// the name of the function that defined them, closure suffix dropped.
+	assert.Equal(t, []string{"app.TestExtractMiddlewareNames", "app.TestExtractMiddlewareNames"}, got)
 	assert.Empty(t, extractMiddlewareNames(nil))
 }
 
@@ -87,7 +89,7 @@ func TestIsValidGortexHandler(t *testing.T) {
 func TestParseMiddlewareBuiltins(t *testing.T) {
 	ctx := appcontext.NewContext()
 
-	mws, err := parseMiddleware("requestid,recover", ctx)
+	mws, err := parseMiddleware("requestid,recover", "", ctx)
 	require.NoError(t, err)
 	require.Len(t, mws, 2, "requestid + recover both resolve via the builtin switch")
 
@@ -107,16 +109,16 @@ func TestParseMiddlewareBuiltins(t *testing.T) {
 func TestParseMiddlewareEmptyAndUnknown(t *testing.T) {
 	ctx := appcontext.NewContext()
 	// Empty tag & bare commas resolve to zero middleware without error.
-	mws, err := parseMiddleware("", ctx)
+	mws, err := parseMiddleware("", "", ctx)
 	require.NoError(t, err)
 	assert.Empty(t, mws)
 
-	mws, err = parseMiddleware(",,", ctx)
+	mws, err = parseMiddleware(",,", "", ctx)
 	require.NoError(t, err)
 	assert.Empty(t, mws)
 
 	// Unknown name now fails loudly rather than silently dropping.
-	_, err = parseMiddleware("does-not-exist", ctx)
+	_, err = parseMiddleware("does-not-exist", "", ctx)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "unknown middleware")
 }
@@ -126,7 +128,7 @@ func TestParseMiddlewareEmptyAndUnknown(t *testing.T) {
 func TestParseMiddlewareAuth(t *testing.T) {
 	ctx := appcontext.NewContext()
 
-	_, err := parseMiddleware("auth", ctx)
+	_, err := parseMiddleware("auth", "", ctx)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "auth middleware")
 
@@ -135,18 +137,39 @@ func TestParseMiddlewareAuth(t *testing.T) {
 	})
 	appcontext.Register(ctx, authMW)
 
-	mws, err := parseMiddleware("auth", ctx)
+	mws, err := parseMiddleware("auth", "", ctx)
 	require.NoError(t, err)
 	assert.Len(t, mws, 1)
 }
 
-// rbac has no implementation, so requesting it must error and tell the user to
-// register a custom middleware.
-func TestParseMiddlewareRBACFailsLoud(t *testing.T) {
+// rbac needs permissions from the rbac tag and a registered policy; an rbac
+// tag without the rbac middleware is a mistake.
+func TestParseMiddlewareRBAC(t *testing.T) {
 	ctx := appcontext.NewContext()
-	_, err := parseMiddleware("rbac", ctx)
+
+	_, err := parseMiddleware("rbac", "", ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "without permissions")
+
+	_, err = parseMiddleware("rbac", `rbac:"orders:write"`, ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no RBAC policy is registered")
+
+	policy, err := rbac.NewPolicy(map[string]rbac.Role{"admin": {Permissions: []string{"*"}}})
+	require.NoError(t, err)
+	appcontext.Register(ctx, policy)
+
+	_, err = parseMiddleware("rbac", `rbac:"orders:{id"`, ctx)
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "RBAC is not implemented")
+	assert.Contains(t, err.Error(), "rbac tag")
+
+	_, err = parseMiddleware("requestid", `rbac:"orders:write"`, ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "needs rbac in the middleware tag")
+
+	mws, err := parseMiddleware("rbac", `rbac:"orders:write, orders:{id}:read"`, ctx)
+	require.NoError(t, err)
+	assert.Len(t, mws, 1)
 }
 
 func TestParseMiddlewareRegistry(t *testing.T) {
@@ -163,7 +186,7 @@ func TestParseMiddlewareRegistry(t *testing.T) {
 	}
 	appcontext.Register(ctx, registry)
 
-	mws, err := parseMiddleware("custom", ctx)
+	mws, err := parseMiddleware("custom", "", ctx)
 	require.NoError(t, err)
 	require.Len(t, mws, 1)
 
@@ -190,7 +213,7 @@ func TestParseRateLimitSeconds(t *testing.T) {
 	var last int
 	for i := 0; i < 6; i++ {
 		req := httptest.NewRequest(http.MethodGet, "/rl", nil)
-		req.RemoteAddr = "203.0.113.10:12345" // outside the local skip list
+		req.RemoteAddr = "203.0.113.10:12345"
 		rec := httptest.NewRecorder()
 		c := httpctx.NewDefaultContext(req, rec)
 		_ = handler(c)
@@ -202,14 +225,92 @@ func TestParseRateLimitSeconds(t *testing.T) {
 func TestParseRateLimitMinutesAndHours(t *testing.T) {
 	ctx := appcontext.NewContext()
 
-	// "/min" and "/hour" compute a per-second burst of at least 1.
-	mw, err := parseRateLimit("100/min", ctx, nil)
+	// The burst defaults to the requests the rate allows per second, at
+	// least one.
+	for tag, want := range map[string]int{"600/min": 10, "7200/hour": 2, "100/min": 1, "100/hour": 1} {
+		mw, err := parseRateLimit(tag, ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, want, countAllowed(mw, 150, nil), tag)
+	}
+}
+
+// countAllowed sends n requests from one remote client through mw and
+// returns how many passed; prepare, when set, adjusts each request.
+func countAllowed(mw middleware.MiddlewareFunc, n int, prepare func(c httpctx.Context)) int {
+	handler := mw(func(c httpctx.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	allowed := 0
+	for i := 0; i < n; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/rl", nil)
+		req.RemoteAddr = "203.0.113.10:12345"
+		rec := httptest.NewRecorder()
+		c := httpctx.NewDefaultContext(req, rec)
+		if prepare != nil {
+			prepare(c)
+		}
+		_ = handler(c)
+		if rec.Code == http.StatusOK {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestParseRateLimitOptions(t *testing.T) {
+	ctx := appcontext.NewContext()
+
+	mw, err := parseRateLimit("100/min; burst=5", ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5, countAllowed(mw, 10, nil))
+
+	// Keyed by header, each tenant gets its own allowance.
+	mw, err = parseRateLimit("2/min;burst=2;key=header:X-Tenant", ctx, nil)
+	require.NoError(t, err)
+	for _, tenant := range []string{"a", "b"} {
+		assert.Equal(t, 2, countAllowed(mw, 5, func(c httpctx.Context) {
+			c.Request().Header.Set("X-Tenant", tenant)
+		}), tenant)
+	}
+
+	// Keyed by user, the subject of the claims is the key.
+	mw, err = parseRateLimit("2/min;burst=2;key=user", ctx, nil)
+	require.NoError(t, err)
+	for _, user := range []string{"u1", "u2"} {
+		claims := &auth.Claims{UserID: user}
+		claims.Subject = user
+		assert.Equal(t, 2, countAllowed(mw, 5, func(c httpctx.Context) {
+			c.Set("jwt-claims", claims)
+		}), user)
+	}
+
+	// Tiers are chosen by the claims' role; others get the base rate.
+	mw, err = parseRateLimit("60/min;tier=free:120/min,pro:240/min", ctx, nil)
+	require.NoError(t, err)
+	for role, want := range map[string]int{"free": 2, "pro": 4, "": 1} {
+		assert.Equal(t, want, countAllowed(mw, 6, func(c httpctx.Context) {
+			c.Set("jwt-claims", &auth.Claims{Role: role})
+		}), role)
+	}
+}
+
+func TestParseRateLimitLoopbackExemptionIsOptIn(t *testing.T) {
+	ctx := appcontext.NewContext()
+	fromLoopback := func(addr string) func(httpctx.Context) {
+		return func(c httpctx.Context) { c.Request().RemoteAddr = addr }
+	}
+
+	mw, err := parseRateLimit("1/min", ctx, nil)
 	require.NoError(t, err)
-	assert.NotNil(t, mw)
+	assert.Equal(t, 1, countAllowed(mw, 3, fromLoopback("127.0.0.1:5000")))
 
-	mw, err = parseRateLimit("100/hour", ctx, nil)
+	mw, err = parseRateLimit("1/min;exempt=loopback", ctx, nil)
 	require.NoError(t, err)
-	assert.NotNil(t, mw)
+	assert.Equal(t, 3, countAllowed(mw, 3, fromLoopback("127.0.0.1:5000")))
+	assert.Equal(t, 3, countAllowed(mw, 3, fromLoopback("[::1]:5000")))
+	assert.Equal(t, 1, countAllowed(mw, 3, func(c httpctx.Context) {
+		c.Request().Header.Set("X-Forwarded-For", "127.0.0.1")
+	}), "forwarding headers cannot claim the exemption")
 }
 
 func TestParseRateLimitRejectsBadInput(t *testing.T) {
@@ -221,6 +322,58 @@ func TestParseRateLimitRejectsBadInput(t *testing.T) {
 	assert.Error(t, err)
 	_, err = parseRateLimit("10/fortnight", ctx, nil)
 	assert.Error(t, err)
+
+	for _, tag := range []string{
+		"0/min",
+		"10/min;burst",
+		"10/min;burst=0",
+		"10/min;key=session",
+		"10/min;key=header:",
+		"10/min;tier=pro",
+		"10/min;tier=pro:1/min,pro:2/min",
+		"10/min;tier=pro:fast",
+		"10/min;exempt=internal",
+		"10/min;window=1",
+	} {
+		_, err := parseRateLimit(tag, ctx, nil)
+		assert.Error(t, err, tag)
+	}
+}
+
+func TestParseConcurrency(t *testing.T) {
+	mw, err := parseConcurrency("1; min=1; max=1", "/api", nil)
+	require.NoError(t, err)
+
+	// A request made while another holds the only slot is shed.
+	var handler httpctx.HandlerFunc
+	var nested error
+	serve := func(path string) error {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		return handler(httpctx.NewDefaultContext(req, httptest.NewRecorder()))
+	}
+	handler = mw(func(c httpctx.Context) error {
+		if c.Request().URL.Path == "/api/outer" {
+			nested = serve("/api/inner")
+		}
+		return c.NoContent(http.StatusOK)
+	})
+	require.NoError(t, serve("/api/outer"))
+	assert.Error(t, nested)
+	assert.NoError(t, serve("/api/inner"))
+
+	for _, tag := range []string{
+		"",
+		"0",
+		"ten",
+		"10;max",
+		"10;max=0",
+		"10;min=20;max=5",
+		"10;algorithm=vegas",
+		"10;window=1s",
+	} {
+		_, err := parseConcurrency(tag, "/api", nil)
+		assert.Error(t, err, tag)
+	}
 }
 
 // --- route_registration.go: isHandlerGroup -----------------------------
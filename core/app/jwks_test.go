@@ -0,0 +1,37 @@
+package app_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/core/app"
+	"github.com/yshengliao/gortex/pkg/auth"
+)
+
+func TestWithJWKS_ServesKeySet(t *testing.T) {
+	key, err := auth.GenerateSigningKey("ES256")
+	require.NoError(t, err)
+	keys, err := auth.NewKeySet(key)
+	require.NoError(t, err)
+
+	a, err := app.NewApp(app.WithJWKS(keys))
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	a.ServerHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, auth.JWKSPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/jwk-set+json", rec.Header().Get("Content-Type"))
+
+	var set auth.JWKS
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, key.ID(), set.Keys[0].KeyID)
+
+	_, err = app.NewApp(app.WithJWKS(nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	appcontext "github.com/yshengliao/gortex/core/context"
+	httpctx "github.com/yshengliao/gortex/transport/http"
+)
+
+type profileHandler struct{}
+
+func (profileHandler) GET(c httpctx.Context) error {
+	return c.String(http.StatusOK, "user "+c.Param("id"))
+}
+
+func (profileHandler) GetProfile(c httpctx.Context) error {
+	return c.String(http.StatusOK, "profile "+c.Param("id"))
+}
+
+func (profileHandler) Avatar(c httpctx.Context) error {
+	return c.String(http.StatusOK, "avatar "+c.Param("id")+" "+c.Param("size"))
+}
+
+func (profileHandler) Archive(c httpctx.Context) error {
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (profileHandler) Touch(c httpctx.Context) error {
+	return c.NoContent(http.StatusAccepted)
+}
+
+func (profileHandler) Routes() map[string]string {
+	return map[string]string{
+		"GetProfile": "GET /profile",
+		"Avatar":     "GET /avatar/:size<int>",
+	}
+}
+
+type methodRouteManager struct {
+	User *profileHandler `url:"/users/:id" method:"Archive=DELETE /"`
+}
+
+func TestMethodRoutes(t *testing.T) {
+	r := newAppTestRouter()
+	require.NoError(t, RegisterRoutesFromStruct(r, &methodRouteManager{}, appcontext.NewContext()))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "/users/7", http.StatusOK, "user 7"},
+		{http.MethodGet, "/users/7/profile", http.StatusOK, "profile 7"},
+		{http.MethodGet, "/users/7/avatar/64", http.StatusOK, "avatar 7 64"},
+		{http.MethodDelete, "/users/7", http.StatusNoContent, ""},
+		// Undeclared custom methods keep the POST default.
+		{http.MethodPost, "/users/7/touch", http.StatusAccepted, ""},
+		{http.MethodPost, "/users/7/get-profile", http.StatusNotFound, ""},
+		// Routes is the declaration, not an endpoint.
+		{http.MethodPost, "/users/7/routes", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMethodRoutesRejectInvalidDeclarations(t *testing.T) {
+	tests := []struct {
+		name    string
+		manager any
+		wantErr string
+	}{
+		{"standard verb conflict", &struct {
+			User *profileHandler `url:"/users/:id" method:"Touch=GET /"`
+		}{}, "GET /users/:id: method Touch conflicts with GET"},
+		{"custom method conflict", &struct {
+			User *profileHandler `url:"/users/:id" method:"Touch=GET /profile/"`
+		}{}, "method Touch conflicts with GetProfile"},
+		{"declared twice", &struct {
+			User *profileHandler `url:"/users/:id" method:"GetProfile=GET /me"`
+		}{}, "GetProfile is declared by both Routes() and the method tag"},
+		{"unknown method", &struct {
+			User *profileHandler `url:"/users/:id" method:"Missing=GET"`
+		}{}, "handler has no method Missing"},
+		{"standard method", &struct {
+			User *profileHandler `url:"/users/:id" method:"GET=POST"`
+		}{}, "GET is not a custom method"},
+		{"unsupported verb", &struct {
+			User *profileHandler `url:"/users/:id" method:"Touch=FETCH"`
+		}{}, "unsupported HTTP method FETCH"},
+		{"relative path", &struct {
+			User *profileHandler `url:"/users/:id" method:"Touch=PUT touch"`
+		}{}, "path must start with /"},
+		{"malformed entry", &struct {
+			User *profileHandler `url:"/users/:id" method:"Touch"`
+		}{}, "want Method=VERB [/path]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RegisterRoutesFromStruct(newAppTestRouter(), tt.manager, appcontext.NewContext())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
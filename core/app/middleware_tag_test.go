@@ -1,14 +1,24 @@
 package app
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	appcontext "github.com/yshengliao/gortex/core/context"
 	"github.com/yshengliao/gortex/middleware"
+	"github.com/yshengliao/gortex/pkg/auth"
+	"github.com/yshengliao/gortex/pkg/auth/apikey"
+	"github.com/yshengliao/gortex/pkg/config"
 	httpctx "github.com/yshengliao/gortex/transport/http"
 )
 
@@ -55,15 +65,200 @@ type rbacTaggedManager struct {
 	Admin *rbacTagHandler `url:"/admin" middleware:"rbac"`
 }
 
-// rbac has no implementation, so the tag must fail registration with guidance
-// to register a custom middleware.
+// rbac without an rbac tag naming the permission must fail registration.
 func TestMiddlewareTagRBACFailsRegistration(t *testing.T) {
 	r := newAppTestRouter()
 	ctx := appcontext.NewContext()
 
 	err := RegisterRoutesFromStruct(r, &rbacTaggedManager{}, ctx)
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "RBAC is not implemented")
+	assert.Contains(t, err.Error(), "rbac tag")
+}
+
+type rbacOrdersManager struct {
+	Orders *rbacTagHandler `url:"/orders" middleware:"auth,rbac" rbac:"orders:write"`
+	Order  *rbacTagHandler `url:"/orders/:id" middleware:"auth,rbac" rbac:"orders:{id}:read"`
+}
+
+// The rbac tag is enforced against the policy from the app config, with
+// path parameters substituted into the required permission.
+func TestMiddlewareTagRBACEnforcesPolicy(t *testing.T) {
+	cfg := &Config{}
+	cfg.RBAC.Roles = map[string]config.RoleConfig{
+		"viewer": {Permissions: []string{"orders:1:read"}},
+		"editor": {Inherits: []string{"viewer"}, Permissions: []string{"orders:write"}},
+	}
+	// A stand-in for JWTAuth: the role comes from a header.
+	authMW := middleware.MiddlewareFunc(func(next middleware.HandlerFunc) middleware.HandlerFunc {
+		return func(c httpctx.Context) error {
+			if role := c.Request().Header.Get("X-Role"); role != "" {
+				c.Set("jwt-claims", &auth.Claims{UserID: "u1", Role: role})
+			}
+			return next(c)
+		}
+	})
+
+	a, err := NewApp(WithConfig(cfg), WithHandlers(&rbacOrdersManager{}), func(app *App) error {
+		appcontext.Register(app.ctx, authMW)
+		return nil
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		role string
+		code int
+	}{
+		{"/orders", "", http.StatusUnauthorized},
+		{"/orders", "viewer", http.StatusForbidden},
+		{"/orders", "editor", http.StatusNoContent},
+		{"/orders/1", "viewer", http.StatusNoContent},
+		{"/orders/2", "viewer", http.StatusForbidden},
+		{"/orders/1", "editor", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("X-Role", tt.role)
+		rec := httptest.NewRecorder()
+		a.ServerHandler().ServeHTTP(rec, req)
+		assert.Equal(t, tt.code, rec.Code, "%s as %q", tt.path, tt.role)
+	}
+}
+
+func TestRBACConfigErrorFailsNewApp(t *testing.T) {
+	cfg := &Config{}
+	cfg.RBAC.Roles = map[string]config.RoleConfig{"editor": {Inherits: []string{"viewer"}}}
+
+	_, err := NewApp(WithConfig(cfg))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `inherits undefined role "viewer"`)
+}
+
+type apiKeyScopesOnlyManager struct {
+	Invoices *rbacTagHandler `url:"/invoices" scopes:"invoices:read"`
+}
+
+// The apikey tag authenticates against the registered KeyStore and the
+// scopes tag names the scopes the key needs.
+func TestMiddlewareTagAPIKey(t *testing.T) {
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &struct {
+		Invoices *rbacTagHandler `url:"/invoices" middleware:"apikey"`
+	}{}, appcontext.NewContext())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no API key store is registered")
+
+	store := apikey.NewMemoryStore()
+	reader, readerKey, err := apikey.Generate("billing", []string{"invoices:read"}, 0)
+	require.NoError(t, err)
+	require.NoError(t, store.Add(readerKey))
+	writer, writerKey, err := apikey.Generate("billing", []string{"invoices:write"}, 0)
+	require.NoError(t, err)
+	require.NoError(t, store.Add(writerKey))
+
+	ctx := appcontext.NewContext()
+	appcontext.Register(ctx, store)
+	err = RegisterRoutesFromStruct(newAppTestRouter(), &apiKeyScopesOnlyManager{}, ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "needs apikey in the middleware tag")
+
+	a, err := NewApp(WithHandlers(&struct {
+		Invoices *rbacTagHandler `url:"/invoices" middleware:"apikey" scopes:"invoices:read"`
+	}{}), func(app *App) error {
+		appcontext.Register(app.ctx, store)
+		return nil
+	})
+	require.NoError(t, err)
+
+	for key, code := range map[string]int{
+		"":           http.StatusUnauthorized,
+		"gtx_bad_no": http.StatusUnauthorized,
+		writer:       http.StatusForbidden,
+		reader:       http.StatusNoContent,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/invoices", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		a.ServerHandler().ServeHTTP(rec, req)
+		assert.Equal(t, code, rec.Code, "key %q", key)
+	}
+}
+
+type webhookEvent struct {
+	Event string `json:"event"`
+}
+
+type webhookHandler struct{}
+
+func (webhookHandler) POST(c httpctx.Context, in *webhookEvent) (*webhookEvent, error) {
+	return in, nil
+}
+
+// The parameter binder decodes the body the signature middleware verified.
+func TestMiddlewareTagWebhookSignatureKeepsBody(t *testing.T) {
+	secret := []byte("whsec_test")
+	a, err := NewApp(WithHandlers(&struct {
+		Hooks *webhookHandler `url:"/hooks/billing" middleware:"webhook"`
+	}{}), func(app *App) error {
+		config := &middleware.SignatureConfig{Secrets: [][]byte{secret}}
+		appcontext.Register(app.ctx, map[string]middleware.MiddlewareFunc{"webhook": middleware.WebhookSignatureWithConfig(config)})
+		app.OnShutdown(func(context.Context) error {
+			config.NonceStore.(*middleware.MemoryNonceStore).Stop()
+			return nil
+		})
+		return nil
+	})
+	require.NoError(t, err)
+	defer a.Shutdown(context.Background())
+
+	body := `{"event":"invoice.paid"}`
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/hooks/billing", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Nonce", "n1")
+	req.Header.Set("X-Webhook-Signature", middleware.SignWebhook(secret, http.MethodPost, "/hooks/billing", timestamp, "n1", []byte(body)))
+	rec := httptest.NewRecorder()
+	a.ServerHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, body, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/hooks/billing", strings.NewReader(body))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Nonce", "n2")
+	req.Header.Set("X-Webhook-Signature", "sha256=00")
+	rec = httptest.NewRecorder()
+	a.ServerHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+// The parameter binder decodes a gzip body through the decompress tag.
+func TestMiddlewareTagDecompress(t *testing.T) {
+	a, err := NewApp(WithHandlers(&struct {
+		Hooks *webhookHandler `url:"/events" middleware:"decompress"`
+	}{}))
+	require.NoError(t, err)
+
+	body := `{"event":"app.opened"}`
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte(body))
+	require.NoError(t, zw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/events", &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	a.ServerHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, body, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "compress")
+	rec = httptest.NewRecorder()
+	a.ServerHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unsupported content encoding")
 }
 
 type unknownTagHandler struct{}
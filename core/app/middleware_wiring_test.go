@@ -3,17 +3,22 @@ package app_test
 import (
 	"bytes"
 	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	"github.com/yshengliao/gortex/core/app"
+	"github.com/yshengliao/gortex/middleware"
 	httpctx "github.com/yshengliao/gortex/transport/http"
 )
 
@@ -130,8 +135,10 @@ func TestCORSCanBeDisabled(t *testing.T) {
 	a.ServerHandler().ServeHTTP(rec, req)
 
 	// With CORS off and no OPTIONS route registered the preflight
-	// falls through to the router's default 404.
-	assert.Equal(t, http.StatusNotFound, rec.Code)
+	// falls through to the router's automatic OPTIONS answer, which
+	// advertises the allowed methods but no CORS headers.
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS", rec.Header().Get("Allow"))
 	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
 }
 
@@ -204,9 +211,143 @@ func TestGzipHandlerSkippedWithoutAcceptEncoding(t *testing.T) {
 		"no gzip when the client did not advertise support")
 }
 
+// fakeBrotli replaces the built-in brotli encoder; it emits zlib so the
+// test can tell which encoder wrote the body.
+func fakeBrotli() middleware.Encoding {
+	return middleware.Encoding{Name: "br", NewWriter: func(middleware.CompressionLevel) middleware.EncodingWriter {
+		return zlib.NewWriter(io.Discard)
+	}}
+}
+
+// Brotli and zstd are built in; enabling brotli needs no codec from the
+// caller.
+func TestBuiltinCompressionEncodings(t *testing.T) {
+	cfg := &app.Config{}
+	cfg.Server.Compression.Enabled = true
+	cfg.Server.Compression.EnableBrotli = true
+	cfg.Server.Compression.PreferBrotli = true
+	a, err := app.NewApp(
+		app.WithConfig(cfg),
+		app.WithHandlers(&NewAppHandlers{Big: &BigJSONHandler{}}),
+	)
+	require.NoError(t, err)
+
+	serve := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/big", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		a.ServerHandler().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+
+	rec := serve("gzip, zstd, br")
+	require.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	body, err := io.ReadAll(brotli.NewReader(rec.Body))
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "abcdefghij")
+
+	rec = serve("gzip, zstd")
+	require.Equal(t, "zstd", rec.Header().Get("Content-Encoding"))
+	zr, err := zstd.NewReader(rec.Body)
+	require.NoError(t, err)
+	defer zr.Close()
+	body, err = io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "abcdefghij")
+}
+
+func TestCompressionEncodingsFollowConfig(t *testing.T) {
+	newApp := func(enable, prefer bool) *app.App {
+		cfg := &app.Config{}
+		cfg.Server.Compression.Enabled = true
+		cfg.Server.Compression.Level = "best"
+		cfg.Server.Compression.EnableBrotli = enable
+		cfg.Server.Compression.PreferBrotli = prefer
+		a, err := app.NewApp(
+			app.WithConfig(cfg),
+			app.WithCompressionEncodings(fakeBrotli()),
+			app.WithHandlers(&NewAppHandlers{Big: &BigJSONHandler{}}),
+		)
+		require.NoError(t, err)
+		return a
+	}
+	encodingFor := func(a *app.App, accept string) string {
+		req := httptest.NewRequest(http.MethodGet, "/big", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		a.ServerHandler().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Header().Get("Content-Encoding")
+	}
+
+	preferred := newApp(true, true)
+	assert.Equal(t, "br", encodingFor(preferred, "gzip, deflate, br"))
+	assert.Equal(t, "gzip", encodingFor(preferred, "gzip, br;q=0.5"))
+	assert.Equal(t, "gzip", encodingFor(newApp(true, false), "gzip, deflate, br"))
+	assert.Equal(t, "br", encodingFor(newApp(true, false), "br"))
+	assert.Empty(t, encodingFor(newApp(false, true), "br"), "brotli disabled")
+
+	req := httptest.NewRequest(http.MethodGet, "/big", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	rec := httptest.NewRecorder()
+	preferred.ServerHandler().ServeHTTP(rec, req)
+	zr, err := zlib.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "abcdefghij")
+}
+
+func TestCompressionRejectsUnknownLevel(t *testing.T) {
+	cfg := &app.Config{}
+	cfg.Server.Compression.Enabled = true
+	cfg.Server.Compression.Level = "fastest"
+	_, err := app.NewApp(app.WithConfig(cfg))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown compression level")
+}
+
 // testHandler mirrors what App.Run installs on http.Server.Handler —
 // the router possibly wrapped with the gzip handler when the config
 // enables compression.
 func testHandler(a *app.App) http.Handler {
 	return a.ServerHandler()
 }
+
+// An unmatched path must go through the default middleware chain so the
+// 404 carries a request ID and the error handler's standard body.
+func TestNotFoundRunsThroughDefaultMiddleware(t *testing.T) {
+	cfg := &app.Config{}
+	cfg.Server.Recovery = true
+	a := newAppWithHandlers(t, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/does-not-exist", nil)
+	rec := httptest.NewRecorder()
+	a.Router().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("X-Request-ID"))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	errBody, ok := body["error"].(map[string]any)
+	require.True(t, ok, "expected standard error body, got %s", rec.Body.String())
+	assert.Equal(t, "HTTP_404", errBody["code"])
+	assert.Equal(t, rec.Header().Get("X-Request-ID"), body["request_id"])
+}
+
+func TestMethodNotAllowedRunsThroughDefaultMiddleware(t *testing.T) {
+	cfg := &app.Config{}
+	cfg.Server.Recovery = true
+	a := newAppWithHandlers(t, cfg)
+
+	req := httptest.NewRequest(http.MethodDelete, "/big", nil)
+	rec := httptest.NewRecorder()
+	a.Router().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS", rec.Header().Get("Allow"))
+	assert.Contains(t, rec.Body.String(), "HTTP_405")
+	assert.NotEmpty(t, rec.Header().Get("X-Request-ID"))
+}
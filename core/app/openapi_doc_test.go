@@ -0,0 +1,106 @@
+package app_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/core/app"
+	"github.com/yshengliao/gortex/core/app/doc/openapi"
+	httpctx "github.com/yshengliao/gortex/transport/http"
+)
+
+type widgetQuery struct {
+	Limit int `bind:"limit,query" validate:"max=50"`
+}
+
+type widgetInput struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type widget struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type widgetHandler struct{}
+
+func (widgetHandler) GET(c httpctx.Context, q *widgetQuery) ([]widget, error) {
+	return []widget{{ID: c.Param("id"), Name: "limit " + c.QueryParam("limit")}}, nil
+}
+
+func (widgetHandler) POST(c httpctx.Context, in *widgetInput) (*widget, error) {
+	return &widget{ID: c.Param("id"), Name: in.Name}, nil
+}
+
+func (widgetHandler) PUT(c httpctx.Context, in *widgetInput) (*widget, error) {
+	w := &widget{ID: c.Param("id"), Name: in.Name}
+	return w, c.JSON(http.StatusAccepted, map[string]string{"queued": w.ID})
+}
+
+type widgetManager struct {
+	Widgets *widgetHandler `url:"/widgets/:id<int>"`
+}
+
+func TestOpenAPIProvider_ServesDocument(t *testing.T) {
+	a, err := app.NewApp(
+		app.WithDocProvider(openapi.NewProvider(nil)),
+		app.WithHandlers(&widgetManager{Widgets: &widgetHandler{}}),
+	)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	a.ServerHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, openapi.SpecPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var d openapi.Document
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &d))
+	item := d.Paths["/widgets/{id}"]
+	require.NotNil(t, item["get"])
+	require.NotNil(t, item["post"])
+
+	get := item["get"]
+	assert.Equal(t, "array", get.Responses["200"].Content["application/json"].Schema.Type)
+	var names []string
+	for _, p := range get.Parameters {
+		names = append(names, p.In+":"+p.Name)
+	}
+	assert.ElementsMatch(t, []string{"path:id", "query:limit"}, names)
+
+	post := item["post"]
+	assert.Equal(t, "#/components/schemas/widgetInput", post.RequestBody.Content["application/json"].Schema.Ref)
+	assert.Equal(t, []string{"name"}, d.Components.Schemas["widgetInput"].Required)
+
+	rec = httptest.NewRecorder()
+	a.ServerHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, openapi.UIPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "swagger-ui")
+}
+
+// Handler methods returning (T, error) have T written as JSON, unless they
+// already wrote a response.
+func TestHandlerMethodResultIsWrittenAsJSON(t *testing.T) {
+	a, err := app.NewApp(app.WithHandlers(&widgetManager{Widgets: &widgetHandler{}}))
+	require.NoError(t, err)
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/widgets/7", strings.NewReader(`{"name":"gear"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		a.ServerHandler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id":"7","name":"gear"}`, rec.Body.String())
+
+	rec = serve(http.MethodPut)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	assert.JSONEq(t, `{"queued":"7"}`, rec.Body.String())
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/core/app/doc"
+	appcontext "github.com/yshengliao/gortex/core/context"
+	httpctx "github.com/yshengliao/gortex/transport/http"
+)
+
+type constraintByIDHandler struct{}
+
+func (constraintByIDHandler) GET(c httpctx.Context) error {
+	return c.String(http.StatusOK, "id "+c.Param("id"))
+}
+
+type constraintBySlugHandler struct{}
+
+func (constraintBySlugHandler) GET(c httpctx.Context) error {
+	return c.String(http.StatusOK, "slug "+c.Param("slug"))
+}
+
+type constraintManager struct {
+	ByID   *constraintByIDHandler   `url:"/posts/:id<int>"`
+	BySlug *constraintBySlugHandler `url:"/posts/:slug<[a-z0-9-]+>"`
+}
+
+func TestURLTagConstrainedParams(t *testing.T) {
+	r := newAppTestRouter()
+	require.NoError(t, RegisterRoutesFromStruct(r, &constraintManager{}, appcontext.NewContext()))
+
+	for path, want := range map[string]string{
+		"/posts/12":         "id 12",
+		"/posts/hello-2026": "slug hello-2026",
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, want, rec.Body.String(), path)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/Upper", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+type conflictingConstraintManager struct {
+	ByID  *constraintByIDHandler   `url:"/posts/:id<int>"`
+	ByNum *constraintBySlugHandler `url:"/posts/:num<int>"`
+}
+
+func TestURLTagConflictingParamsFailRegistration(t *testing.T) {
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &conflictingConstraintManager{}, appcontext.NewContext())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/posts/:num<int>")
+	assert.Contains(t, err.Error(), "conflicts with :id")
+}
+
+type invalidConstraintManager struct {
+	Bad *constraintByIDHandler `url:"/posts/:id<[>"`
+}
+
+func TestURLTagInvalidConstraintFailsRegistration(t *testing.T) {
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &invalidConstraintManager{}, appcontext.NewContext())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid constraint")
+}
+
+// recordingDocProvider captures generated routes without rendering them.
+type recordingDocProvider struct {
+	routes []doc.RouteInfo
+}
+
+func (p *recordingDocProvider) Generate(routes []doc.RouteInfo) ([]byte, error) {
+	p.routes = routes
+	return nil, nil
+}
+func (p *recordingDocProvider) ContentType() string                { return "application/json" }
+func (p *recordingDocProvider) UIHandler() http.Handler            { return nil }
+func (p *recordingDocProvider) Endpoints() map[string]http.Handler { return nil }
+
+func TestURLTagConstraintsFeedDocParamTypes(t *testing.T) {
+	provider := &recordingDocProvider{}
+	_, err := NewApp(WithDocProvider(provider), WithHandlers(&constraintManager{}))
+	require.NoError(t, err)
+
+	byPath := map[string]doc.RouteInfo{}
+	for _, ri := range provider.routes {
+		byPath[ri.Path] = ri
+	}
+	require.Len(t, byPath["/posts/:id<int>"].Params, 1)
+	assert.Equal(t, doc.ParamInfo{
+		Name: "id", Type: "path", DataType: "int", Required: true, Description: "must match <int>",
+	}, byPath["/posts/:id<int>"].Params[0])
+	require.Len(t, byPath["/posts/:slug<[a-z0-9-]+>"].Params, 1)
+	assert.Equal(t, "string", byPath["/posts/:slug<[a-z0-9-]+>"].Params[0].DataType)
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	appcontext "github.com/yshengliao/gortex/core/context"
+	httpctx "github.com/yshengliao/gortex/transport/http"
+)
+
+type namedUserHandler struct{}
+
+func (namedUserHandler) GET(c httpctx.Context) error {
+	return c.String(http.StatusOK, "user "+c.Param("id"))
+}
+
+func (namedUserHandler) PUT(c httpctx.Context) error {
+	return c.NoContent(http.StatusNoContent)
+}
+
+type namedUsersHandler struct{}
+
+// POST links to the created user through its route name.
+func (namedUsersHandler) POST(c httpctx.Context) error {
+	location, err := httpctx.Reverse(c, "user.show", 7)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("Location", location)
+	return c.NoContent(http.StatusCreated)
+}
+
+type namedAPIGroup struct {
+	Users *namedUsersHandler `url:"/users"`
+	User  *namedUserHandler  `url:"/users/:id<int>" name:"user.show"`
+}
+
+type namedRouteManager struct {
+	API *namedAPIGroup `url:"/api"`
+}
+
+func TestNameTagAndAppURL(t *testing.T) {
+	a, err := NewApp(WithHandlers(&namedRouteManager{}))
+	require.NoError(t, err)
+
+	link, err := a.URL("user.show", 42)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/users/42", link)
+
+	_, err = a.URL("user.show")
+	assert.ErrorContains(t, err, "missing value for :id")
+
+	rec := httptest.NewRecorder()
+	a.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/users", nil))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/api/users/7", rec.Header().Get("Location"))
+}
+
+type duplicateNameManager struct {
+	User   *namedUserHandler `url:"/users/:id" name:"user.show"`
+	Person *namedUserHandler `url:"/people/:id" name:"user.show"`
+}
+
+func TestNameTagDuplicateFailsRegistration(t *testing.T) {
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &duplicateNameManager{}, appcontext.NewContext())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `route name "user.show" is already used by /users/:id`)
+}
+
+type namedGroupManager struct {
+	API *namedAPIGroup `url:"/api" name:"api"`
+}
+
+func TestNameTagOnGroupWithoutMethodsFailsRegistration(t *testing.T) {
+	err := RegisterRoutesFromStruct(newAppTestRouter(), &namedGroupManager{}, appcontext.NewContext())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "name tag on API")
+}
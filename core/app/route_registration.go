@@ -1,17 +1,22 @@
 package app
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/yshengliao/gortex/core/app/doc"
 	appcontext "github.com/yshengliao/gortex/core/context"
 	"github.com/yshengliao/gortex/middleware"
+	"github.com/yshengliao/gortex/pkg/auth/apikey"
+	"github.com/yshengliao/gortex/pkg/auth/rbac"
 	httpctx "github.com/yshengliao/gortex/transport/http"
 )
 
@@ -94,8 +99,16 @@ func registerRoutesRecursiveWithMiddleware(r httpctx.GortexRouter, manager any,
 			// field an independent chain.
 			currentMiddleware := make([]middleware.MiddlewareFunc, len(parentMiddleware), len(parentMiddleware)+1)
 			copy(currentMiddleware, parentMiddleware)
-			if middlewareTag := field.Tag.Get("middleware"); middlewareTag != "" {
-				mw, err := parseMiddleware(middlewareTag, ctx)
+			// Shed load before any other work is done for the request.
+			if concurrencyTag := field.Tag.Get("concurrency"); concurrencyTag != "" {
+				mw, err := parseConcurrency(concurrencyTag, fullPath, app)
+				if err != nil {
+					return fmt.Errorf("concurrency tag on %s (%q): %w", field.Name, concurrencyTag, err)
+				}
+				currentMiddleware = append(currentMiddleware, mw)
+			}
+			if middlewareTag := field.Tag.Get("middleware"); middlewareTag != "" || field.Tag.Get("rbac") != "" || field.Tag.Get("scopes") != "" {
+				mw, err := parseMiddleware(middlewareTag, field.Tag, ctx)
 				if err != nil {
 					return fmt.Errorf("middleware tag on %s (%q): %w", field.Name, middlewareTag, err)
 				}
@@ -114,6 +127,20 @@ func registerRoutesRecursiveWithMiddleware(r httpctx.GortexRouter, manager any,
 			// Check if it's a WebSocket handler.
 			isWebSocket := field.Tag.Get("hijack") == "ws"
 
+			// An optional route name for reverse URL generation.
+			routeName := field.Tag.Get("name")
+
+			// A host tag binds the field, and everything nested in it, to
+			// a host group.
+			fieldRouter := r
+			if hostTag := field.Tag.Get("host"); hostTag != "" {
+				hr, err := hostGroup(r, hostTag)
+				if err != nil {
+					return fmt.Errorf("host tag on %s (%q): %w", field.Name, hostTag, err)
+				}
+				fieldRouter = hr
+			}
+
 			if logger != nil {
 				logger.Info("Processing handler/group",
 					zap.String("field", field.Name),
@@ -123,14 +150,24 @@ func registerRoutesRecursiveWithMiddleware(r httpctx.GortexRouter, manager any,
 
 			if isWebSocket {
 				// WebSocket handlers are terminal. Register and move to the next field.
-				if err := registerWebSocketHandler(r, fullPath, handler); err != nil {
+				if err := registerWebSocketHandler(fieldRouter, fullPath, routeName, handler); err != nil {
 					return fmt.Errorf("failed to register WebSocket handler %s: %w", field.Name, err)
 				}
 				continue
 			}
 
+			if routeName != "" && !hasStandardMethod(handlerType) {
+				return fmt.Errorf("name tag on %s (%q): handler has no standard HTTP method (GET, POST, ...) to name", field.Name, routeName)
+			}
+
+			// Custom methods declared with the method tag or Routes().
+			methodRoutes, err := customMethodRoutes(handler, handlerType, field.Tag.Get("method"))
+			if err != nil {
+				return fmt.Errorf("method routes of %s: %w", field.Name, err)
+			}
+
 			// 1. Register any HTTP methods defined directly on this struct (e.g., GET, POST, CustomMethod).
-			if err := registerHTTPHandlerWithMiddleware(r, fullPath, handler, handlerType, currentMiddleware, app); err != nil {
+			if err := registerHTTPHandlerWithMiddleware(fieldRouter, fullPath, routeName, handler, handlerType, methodRoutes, currentMiddleware, ctx, app); err != nil {
 				return fmt.Errorf("failed to register HTTP handler %s: %w", field.Name, err)
 			}
 
@@ -142,7 +179,7 @@ func registerRoutesRecursiveWithMiddleware(r httpctx.GortexRouter, manager any,
 						zap.String("field", field.Name),
 						zap.String("prefix", fullPath))
 				}
-				if err := registerRoutesRecursiveWithMiddleware(r, handler, ctx, fullPath, currentMiddleware, app); err != nil {
+				if err := registerRoutesRecursiveWithMiddleware(fieldRouter, handler, ctx, fullPath, currentMiddleware, app); err != nil {
 					return fmt.Errorf("failed to register nested routes for %s: %w", field.Name, err)
 				}
 			}
@@ -153,14 +190,14 @@ func registerRoutesRecursiveWithMiddleware(r httpctx.GortexRouter, manager any,
 }
 
 // registerWebSocketHandler registers a WebSocket handler
-func registerWebSocketHandler(r httpctx.GortexRouter, pattern string, handler any) error {
+func registerWebSocketHandler(r httpctx.GortexRouter, pattern, name string, handler any) error {
 	// Look for HandleConnection method
 	method := reflect.ValueOf(handler).MethodByName("HandleConnection")
 	if !method.IsValid() {
 		return fmt.Errorf("WebSocket handler must have HandleConnection method")
 	}
 
-	r.GET(pattern, func(c httpctx.Context) error {
+	return addRoute(r, "GET", pattern, name, func(c httpctx.Context) error {
 		// Call the HandleConnection method
 		args := []reflect.Value{reflect.ValueOf(c)}
 		results := method.Call(args)
@@ -171,45 +208,206 @@ func registerWebSocketHandler(r httpctx.GortexRouter, pattern string, handler an
 			}
 		}
 		return nil
-	})
-
-	return nil
+	}, nil)
 }
 
-// registerHTTPHandlerWithMiddleware registers HTTP handlers with middleware
-func registerHTTPHandlerWithMiddleware(r httpctx.GortexRouter, basePath string, handler any, handlerType reflect.Type, middleware []middleware.MiddlewareFunc, app *App) error {
-	methods := []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"}
-
-	for _, method := range methods {
+// registerHTTPHandlerWithMiddleware registers HTTP handlers with middleware.
+// A non-empty name is given to the routes of the standard HTTP methods,
+// which share basePath; custom-method sub-routes stay unnamed. methodRoutes
+// holds the declared routes of custom methods (see MethodRoutes); a custom
+// method landing on the same method and path as another route is an error.
+func registerHTTPHandlerWithMiddleware(r httpctx.GortexRouter, basePath, name string, handler any, handlerType reflect.Type, methodRoutes map[string]methodRoute, middleware []middleware.MiddlewareFunc, ctx *appcontext.Context, app *App) error {
+	// taken maps "METHOD path" to the handler method registered there.
+	taken := make(map[string]string)
+	for _, method := range standardMethods {
 		if m, ok := handlerType.MethodByName(method); ok {
-			registerMethodWithMiddleware(r, method, basePath, handler, m, middleware, app)
+			if err := registerMethodWithMiddleware(r, method, basePath, name, handler, m, middleware, ctx, app); err != nil {
+				return err
+			}
+			taken[method+" "+routeKey(basePath)] = method
 		}
 	}
 
+	_, declares := handler.(MethodRoutes)
+
 	// Handle sub-routes
 	for i := 0; i < handlerType.NumMethod(); i++ {
 		method := handlerType.Method(i)
 		methodName := method.Name
 
-		// Skip standard HTTP methods
-		if contains(methods, methodName) {
+		// Skip standard HTTP methods, and the Routes declaration itself.
+		if contains(standardMethods, methodName) || (declares && methodName == "Routes") {
 			continue
 		}
 
-		// Convert method name to route path
-		routePath := camelToKebab(methodName)
-		fullPath := strings.TrimSuffix(basePath, "/") + "/" + routePath
+		// By default a custom method is POST <basePath>/<kebab-name>.
+		httpMethod := http.MethodPost
+		fullPath := strings.TrimSuffix(basePath, "/") + "/" + camelToKebab(methodName)
+		if route, ok := methodRoutes[methodName]; ok {
+			httpMethod = route.method
+			if route.path != "" {
+				fullPath = joinRoutePath(basePath, route.path)
+			}
+		}
+
+		key := httpMethod + " " + routeKey(fullPath)
+		if other, ok := taken[key]; ok {
+			return fmt.Errorf("%s %s: method %s conflicts with %s", httpMethod, fullPath, methodName, other)
+		}
+		taken[key] = methodName
 
 		// Register the route with proper parameter handling
-		registerCustomMethodWithMiddleware(r, fullPath, handler, method, middleware, app)
+		if err := registerCustomMethodWithMiddleware(r, httpMethod, fullPath, handler, method, middleware, ctx, app); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// MethodRoutes is implemented by handlers that declare the HTTP method and
+// path of their custom methods instead of the default POST
+// <url>/<kebab-name>. Routes maps a method name to "VERB" or "VERB /path",
+// where the path is relative to the handler's url and may declare
+// parameters. For a handler at `url:"/users/:id"`:
+//
+//	func (h *UserHandler) Routes() map[string]string {
+//		return map[string]string{
+//			"GetProfile": "GET /profile", // GET /users/:id/profile
+//			"Archive":    "DELETE",       // DELETE /users/:id/archive
+//		}
+//	}
+//
+// The `method` tag on the handler field declares the same thing:
+// `method:"GetProfile=GET /profile;Archive=DELETE"`. Declaring a method in
+// both, naming a method the handler lacks or a standard one, or mapping two
+// methods to the same route fails registration.
+type MethodRoutes interface {
+	Routes() map[string]string
+}
+
+// methodRoute is the declared HTTP method and relative path of a custom
+// method; an empty path keeps the kebab-case default.
+type methodRoute struct {
+	method string
+	path   string
+}
+
+// customMethodRoutes collects the routes declared by handler's Routes
+// method and by the field's method tag, keyed by method name.
+func customMethodRoutes(handler any, handlerType reflect.Type, tag string) (map[string]methodRoute, error) {
+	specs := make(map[string]string)
+	if mr, ok := handler.(MethodRoutes); ok {
+		for name, spec := range mr.Routes() {
+			specs[name] = spec
+		}
+	}
+	tagged := make(map[string]bool)
+	for _, entry := range strings.Split(tag, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("method tag entry %q: want Method=VERB [/path]", entry)
+		}
+		if _, dup := specs[name]; dup {
+			if tagged[name] {
+				return nil, fmt.Errorf("method tag declares %s twice", name)
+			}
+			return nil, fmt.Errorf("%s is declared by both Routes() and the method tag", name)
+		}
+		specs[name] = spec
+		tagged[name] = true
+	}
+
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	routes := make(map[string]methodRoute, len(specs))
+	for name, spec := range specs {
+		if contains(standardMethods, name) || name == "Routes" {
+			return nil, fmt.Errorf("%s is not a custom method", name)
+		}
+		if _, ok := handlerType.MethodByName(name); !ok {
+			return nil, fmt.Errorf("handler has no method %s", name)
+		}
+		route, err := parseMethodRoute(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		routes[name] = route
+	}
+	return routes, nil
+}
+
+// parseMethodRoute parses "VERB" or "VERB /path".
+func parseMethodRoute(spec string) (methodRoute, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return methodRoute{}, fmt.Errorf("route %q: want VERB [/path]", spec)
+	}
+	route := methodRoute{method: strings.ToUpper(fields[0])}
+	if !contains(standardMethods, route.method) {
+		return methodRoute{}, fmt.Errorf("route %q: unsupported HTTP method %s", spec, fields[0])
+	}
+	if len(fields) == 2 {
+		if !strings.HasPrefix(fields[1], "/") {
+			return methodRoute{}, fmt.Errorf("route %q: path must start with /", spec)
+		}
+		route.path = fields[1]
+	}
+	return route, nil
+}
+
+// joinRoutePath appends a declared relative path to basePath; "/" is
+// basePath itself.
+func joinRoutePath(basePath, path string) string {
+	if path == "/" {
+		return basePath
+	}
+	return strings.TrimSuffix(basePath, "/") + path
+}
+
+// routeKey normalises path the way the router matches it, ignoring empty
+// segments and a trailing slash.
+func routeKey(path string) string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// standardMethods are the handler method names registered as their own HTTP
+// method at the handler's path.
+var standardMethods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"}
+
+// hasStandardMethod reports whether handlerType defines any of
+// standardMethods.
+func hasStandardMethod(handlerType reflect.Type) bool {
+	for _, method := range standardMethods {
+		if _, ok := handlerType.MethodByName(method); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // registerMethodWithMiddleware registers a standard HTTP method with middleware
-func registerMethodWithMiddleware(r httpctx.GortexRouter, httpMethod, path string, handler any, method reflect.Method, middleware []middleware.MiddlewareFunc, app *App) {
-	handlerFunc := createHandlerFunc(handler, method)
+func registerMethodWithMiddleware(r httpctx.GortexRouter, httpMethod, path, name string, handler any, method reflect.Method, middleware []middleware.MiddlewareFunc, ctx *appcontext.Context, app *App) error {
+	handlerFunc := createHandlerFunc(handler, method, ctx)
+
+	params, err := httpctx.ParsePathParams(path)
+	if err != nil {
+		return fmt.Errorf("invalid route %s %s: %w", httpMethod, path, err)
+	}
+	if err := addRoute(r, httpMethod, path, name, handlerFunc, middleware); err != nil {
+		return err
+	}
 
 	// Collect route info if app is provided, logging is enabled, or dev mode is on.
 	if app != nil && (app.enableRoutesLog || app.developmentMode) {
@@ -226,44 +424,164 @@ func registerMethodWithMiddleware(r httpctx.GortexRouter, httpMethod, path strin
 	// Collect documentation info if app has doc provider
 	if app != nil && app.docProvider != nil {
 		handlerType := reflect.TypeOf(handler).Elem()
+		requestType, responseType := handlerDocTypes(method, ctx)
 		routeInfo := doc.RouteInfo{
-			Method:      httpMethod,
-			Path:        path,
-			Handler:     handlerType.Name() + "." + method.Name,
-			Middleware:  extractMiddlewareNames(middleware),
-			Description: fmt.Sprintf("%s %s", httpMethod, path),
-			Metadata:    make(map[string]interface{}),
+			Method:       httpMethod,
+			Path:         path,
+			Handler:      handlerType.Name() + "." + method.Name,
+			Middleware:   extractMiddlewareNames(middleware),
+			Params:       docParams(params, requestType),
+			Description:  fmt.Sprintf("%s %s", httpMethod, path),
+			Metadata:     make(map[string]interface{}),
+			RequestType:  requestType,
+			ResponseType: responseType,
 		}
 		app.AddDocumentationRoute(routeInfo)
 	}
 
+	return nil
+}
+
+// addRoute registers h on r under httpMethod and, when name is non-empty,
+// names the route. The router panics with a *httpctx.RouteError on a
+// malformed or conflicting pattern or route name; that panic is turned back
+// into an error so NewApp can report it.
+func addRoute(r httpctx.GortexRouter, httpMethod, path, name string, h middleware.HandlerFunc, mw []middleware.MiddlewareFunc) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			routeErr, ok := rec.(*httpctx.RouteError)
+			if !ok {
+				panic(rec)
+			}
+			err = routeErr
+		}
+	}()
+
+	var route *httpctx.Route
 	switch httpMethod {
 	case "GET":
-		r.GET(path, handlerFunc, middleware...)
+		route = r.GET(path, h, mw...)
 	case "POST":
-		r.POST(path, handlerFunc, middleware...)
+		route = r.POST(path, h, mw...)
 	case "PUT":
-		r.PUT(path, handlerFunc, middleware...)
+		route = r.PUT(path, h, mw...)
 	case "DELETE":
-		r.DELETE(path, handlerFunc, middleware...)
+		route = r.DELETE(path, h, mw...)
 	case "PATCH":
-		r.PATCH(path, handlerFunc, middleware...)
+		route = r.PATCH(path, h, mw...)
 	case "HEAD":
-		r.HEAD(path, handlerFunc, middleware...)
+		route = r.HEAD(path, h, mw...)
 	case "OPTIONS":
-		r.OPTIONS(path, handlerFunc, middleware...)
+		route = r.OPTIONS(path, h, mw...)
+	default:
+		return fmt.Errorf("unsupported HTTP method %q for %s", httpMethod, path)
 	}
+	if name != "" {
+		route.Name(name)
+	}
+	return nil
 }
 
-// registerCustomMethodWithMiddleware registers a non-standard handler method as
-// an HTTP POST route.
+// hostGroup returns r.Host(pattern), turning the router's *httpctx.RouteError
+// panic for a malformed or nested host pattern into an error.
+func hostGroup(r httpctx.GortexRouter, pattern string) (hr httpctx.GortexRouter, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			routeErr, ok := rec.(*httpctx.RouteError)
+			if !ok {
+				panic(rec)
+			}
+			err = routeErr
+		}
+	}()
+	return r.Host(pattern), nil
+}
+
+// pathParamInfos converts the parameters declared in a route pattern into
+// documentation entries. Path parameters are always required.
+func pathParamInfos(params []httpctx.PathParam) []doc.ParamInfo {
+	if len(params) == 0 {
+		return nil
+	}
+	infos := make([]doc.ParamInfo, 0, len(params))
+	for _, p := range params {
+		info := doc.ParamInfo{
+			Name:     p.Name,
+			Type:     "path",
+			DataType: p.DataType,
+			Required: true,
+		}
+		if p.Constraint != "" {
+			info.Description = "must match <" + p.Constraint + ">"
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// docParams returns the documented parameters of a route: those declared
+// in its pattern, then what the handler's request struct binds (see
+// doc.TagParser.ParseRequestParams). The pattern is authoritative for path
+// parameters; a bound path field only contributes its enum.
+func docParams(params []httpctx.PathParam, requestType reflect.Type) []doc.ParamInfo {
+	infos := pathParamInfos(params)
+	for _, info := range doc.NewTagParser().ParseRequestParams(requestType) {
+		if info.Type != "path" {
+			infos = append(infos, info)
+			continue
+		}
+		for i := range infos {
+			if infos[i].Type == "path" && infos[i].Name == info.Name && infos[i].Enum == nil {
+				infos[i].Enum = info.Enum
+			}
+		}
+	}
+	return infos
+}
+
+// handlerDocTypes returns, for documentation, the struct a handler method
+// binds from the request and the T of a (T, error) result. Parameters the
+// DI context can resolve are services rather than request data and are
+// skipped, as the binder does.
+func handlerDocTypes(method reflect.Method, ctx *appcontext.Context) (request, response reflect.Type) {
+	t := method.Type
+	contextType := reflect.TypeOf((*httpctx.Context)(nil)).Elem()
+	for i := 1; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if in == contextType {
+			continue
+		}
+		if ctx != nil {
+			if _, ok := ctx.LifetimeOf(in); ok {
+				continue
+			}
+		}
+		if in.Kind() == reflect.Ptr {
+			in = in.Elem()
+		}
+		if in.Kind() == reflect.Struct {
+			request = in
+			break
+		}
+	}
+
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	if t.NumOut() == 2 && t.Out(1) == errorType {
+		response = t.Out(0)
+	}
+	return request, response
+}
+
+// registerCustomMethodWithMiddleware registers a non-standard handler method
+// as an httpMethod route at path.
 //
 // # Naming convention
 //
 // Any Go method whose name does not match a standard HTTP verb (GET, POST, PUT,
-// DELETE, PATCH, HEAD, OPTIONS) is treated as a "custom" method. It is always
-// registered as HTTP POST at <basePath>/<kebab-case-method-name>, regardless
-// of what the Go method name implies.
+// DELETE, PATCH, HEAD, OPTIONS) is treated as a "custom" method. Unless the
+// handler declares otherwise it is registered as HTTP POST at
+// <basePath>/<kebab-case-method-name>, regardless of what the Go method name
+// implies.
 //
 // Example:
 //
@@ -271,18 +589,25 @@ func registerMethodWithMiddleware(r httpctx.GortexRouter, httpMethod, path strin
 //	func (h *UserHandler) GetProfile(c types.Context) error { ... }
 //	// → registered as: POST /users/get-profile   (NOT GET /users/get-profile)
 //
-// If you need a specific HTTP method for a custom endpoint, define the method
-// using a standard name (GET, POST, …) or add a `method` struct tag in a
-// future Gortex version once that tag is implemented.
-func registerCustomMethodWithMiddleware(r httpctx.GortexRouter, path string, handler any, method reflect.Method, middleware []middleware.MiddlewareFunc, app *App) {
-	handlerFunc := createHandlerFunc(handler, method)
+// To choose the HTTP method and path, implement MethodRoutes or use the
+// `method` struct tag on the handler field.
+func registerCustomMethodWithMiddleware(r httpctx.GortexRouter, httpMethod, path string, handler any, method reflect.Method, middleware []middleware.MiddlewareFunc, ctx *appcontext.Context, app *App) error {
+	handlerFunc := createHandlerFunc(handler, method, ctx)
+
+	params, err := httpctx.ParsePathParams(path)
+	if err != nil {
+		return fmt.Errorf("invalid route %s %s: %w", httpMethod, path, err)
+	}
+	if err := addRoute(r, httpMethod, path, "", handlerFunc, middleware); err != nil {
+		return err
+	}
 
 	// Collect route info for custom methods (same condition as standard methods).
 	if app != nil && (app.enableRoutesLog || app.developmentMode) {
 		handlerName := reflect.TypeOf(handler).Elem().Name()
 
 		app.routeInfos = append(app.routeInfos, RouteLogInfo{
-			Method:      "POST", // Custom methods are always registered as POST.
+			Method:      httpMethod,
 			Path:        path,
 			Handler:     handlerName + "." + method.Name,
 			Middlewares: extractMiddlewareNames(middleware),
@@ -292,22 +617,28 @@ func registerCustomMethodWithMiddleware(r httpctx.GortexRouter, path string, han
 	// Collect documentation info if app has doc provider.
 	if app != nil && app.docProvider != nil {
 		handlerType := reflect.TypeOf(handler).Elem()
+		requestType, responseType := handlerDocTypes(method, ctx)
 		routeInfo := doc.RouteInfo{
-			Method:      "POST", // Custom methods are always registered as POST.
-			Path:        path,
-			Handler:     handlerType.Name() + "." + method.Name,
-			Middleware:  extractMiddlewareNames(middleware),
-			Description: fmt.Sprintf("POST %s (custom method: %s)", path, method.Name),
-			Metadata:    make(map[string]interface{}),
+			Method:       httpMethod,
+			Path:         path,
+			Handler:      handlerType.Name() + "." + method.Name,
+			Middleware:   extractMiddlewareNames(middleware),
+			Params:       docParams(params, requestType),
+			Description:  fmt.Sprintf("%s %s (custom method: %s)", httpMethod, path, method.Name),
+			Metadata:     make(map[string]interface{}),
+			RequestType:  requestType,
+			ResponseType: responseType,
 		}
 		app.AddDocumentationRoute(routeInfo)
 	}
 
-	r.POST(path, handlerFunc, middleware...)
+	return nil
 }
 
-// createHandlerFunc creates a gortex.HandlerFunc from a reflect.Method
-func createHandlerFunc(handler any, method reflect.Method) middleware.HandlerFunc {
+// createHandlerFunc creates a gortex.HandlerFunc from a reflect.Method.
+// ctx is the app's DI context used to resolve method parameters; a
+// "di_context" value on the request context overrides it.
+func createHandlerFunc(handler any, method reflect.Method, ctx *appcontext.Context) middleware.HandlerFunc {
 	// Check if method uses automatic parameter binding
 	methodType := method.Type
 	usesBinder := false
@@ -318,18 +649,23 @@ func createHandlerFunc(handler any, method reflect.Method) middleware.HandlerFun
 	}
 
 	return func(c httpctx.Context) error {
-		// Get DI context from gortex context if available
-		var diContext *appcontext.Context
-		if ctx := c.Get("di_context"); ctx != nil {
-			if diCtx, ok := ctx.(*appcontext.Context); ok {
+		diContext := ctx
+		if v := c.Get("di_context"); v != nil {
+			if diCtx, ok := v.(*appcontext.Context); ok {
 				diContext = diCtx
 			}
 		}
 
-		// Create parameter binder if needed
+		// Create parameter binder if needed. Scoped providers get a fresh
+		// scope per request, with the request context itself resolvable
+		// from it.
 		var binder *appcontext.ParameterBinder
 		if usesBinder {
 			if diContext != nil {
+				if diContext.HasScopedProviders() {
+					diContext = diContext.NewScope()
+					appcontext.RegisterAs[httpctx.Context](diContext, c)
+				}
 				binder = appcontext.NewParameterBinderWithContext(diContext)
 			} else {
 				binder = appcontext.NewParameterBinder()
@@ -350,15 +686,32 @@ func createHandlerFunc(handler any, method reflect.Method) middleware.HandlerFun
 			args = []reflect.Value{reflect.ValueOf(handler), reflect.ValueOf(c)}
 		}
 
-		results := method.Func.Call(args)
+		return handlerResult(c, method.Func.Call(args))
+	}
+}
 
-		if len(results) > 0 && results[0].Interface() != nil {
-			if err, ok := results[0].Interface().(error); ok {
-				return err
-			}
-		}
+// handlerResult turns the results of a handler method call into the route's
+// error. A method may return error or (T, error); a non-nil T is written as
+// JSON with 200 unless the method already wrote a response.
+func handlerResult(c httpctx.Context, results []reflect.Value) error {
+	if len(results) == 0 {
+		return nil
+	}
+	if err, ok := results[len(results)-1].Interface().(error); ok && err != nil {
+		return err
+	}
+	if len(results) != 2 {
 		return nil
 	}
+
+	v := results[0]
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	if c.Response().Written() {
+		return nil
+	}
+	return c.JSON(http.StatusOK, v.Interface())
 }
 
 // parseMiddleware resolves a comma-separated `middleware:"..."` tag into a
@@ -367,9 +720,12 @@ func createHandlerFunc(handler any, method reflect.Method) middleware.HandlerFun
 // It fails loudly: any name that cannot be resolved returns an error instead
 // of silently registering the route without that middleware. A typo or a
 // missing wiring step that drops `auth` would otherwise expose a route that
-// the developer believes is protected.
-func parseMiddleware(tag string, ctx *appcontext.Context) ([]middleware.MiddlewareFunc, error) {
+// the developer believes is protected. The rbac and scopes tags of the same
+// field configure the built-in rbac and apikey middleware.
+func parseMiddleware(tag string, fieldTag reflect.StructTag, ctx *appcontext.Context) ([]middleware.MiddlewareFunc, error) {
 	middlewares := []middleware.MiddlewareFunc{}
+	rbacTag, scopesTag := fieldTag.Get("rbac"), fieldTag.Get("scopes")
+	usesRBAC, usesAPIKey := false, false
 
 	// Split by comma for multiple middleware
 	names := strings.Split(tag, ",")
@@ -383,6 +739,8 @@ func parseMiddleware(tag string, ctx *appcontext.Context) ([]middleware.Middlewa
 		if registry, err := appcontext.Get[map[string]middleware.MiddlewareFunc](ctx); err == nil {
 			if mw, ok := registry[name]; ok {
 				middlewares = append(middlewares, mw)
+				usesRBAC = usesRBAC || name == "rbac"
+				usesAPIKey = usesAPIKey || name == "apikey"
 				continue
 			}
 		}
@@ -420,83 +778,329 @@ func parseMiddleware(tag string, ctx *appcontext.Context) ([]middleware.Middlewa
 				})
 			}
 		case "rbac":
-			// No RBAC implementation ships with Gortex, so this name can only
-			// be satisfied by a custom middleware registered under "rbac".
-			// Reaching here means it was not, so fail loudly.
-			return nil, fmt.Errorf("middleware %q requested but RBAC is not implemented; "+
-				"register a custom middleware under %q in the app context (e.g. a role check) instead", name, name)
+			mw, err := rbacMiddleware(rbacTag, ctx)
+			if err != nil {
+				return nil, err
+			}
+			middlewares = append(middlewares, mw)
+			usesRBAC = true
+		case "decompress":
+			// Decode gzip and deflate request bodies; register a configured
+			// middleware under this name for brotli, zstd or other limits.
+			middlewares = append(middlewares, middleware.Decompress())
+		case "mtls":
+			// Authenticate by the verified TLS client certificate; the
+			// server needs a client CA (Server.TLS.ClientCAFile).
+			middlewares = append(middlewares, middleware.MTLS())
+		case "apikey":
+			mw, err := apiKeyMiddleware(scopesTag, ctx)
+			if err != nil {
+				return nil, err
+			}
+			middlewares = append(middlewares, mw)
+			usesAPIKey = true
 		default:
-			return nil, fmt.Errorf("unknown middleware %q; known names are auth, requestid, recover, rbac, "+
+			return nil, fmt.Errorf("unknown middleware %q; known names are auth, apikey, mtls, requestid, recover, rbac, "+
 				"or register a custom middleware under that name in the app context", name)
 		}
 	}
 
+	if rbacTag != "" && !usesRBAC {
+		return nil, fmt.Errorf("rbac tag %q needs rbac in the middleware tag, e.g. middleware:\"auth,rbac\"", rbacTag)
+	}
+	if scopesTag != "" && !usesAPIKey {
+		return nil, fmt.Errorf("scopes tag %q needs apikey in the middleware tag, e.g. middleware:\"apikey\"", scopesTag)
+	}
+
 	return middlewares, nil
 }
 
-// parseRateLimit parses a `ratelimit:"100/min"` tag and returns the rate-limit
+// rbacMiddleware builds the built-in rbac middleware for the permissions
+// listed in an rbac tag (comma-separated, all required) from the
+// *rbac.Policy in the app context.
+func rbacMiddleware(rbacTag string, ctx *appcontext.Context) (middleware.MiddlewareFunc, error) {
+	var permissions []string
+	for _, p := range strings.Split(rbacTag, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, err := rbac.ParsePermission(p); err != nil {
+			return nil, fmt.Errorf("rbac tag: %w", err)
+		}
+		permissions = append(permissions, p)
+	}
+	if len(permissions) == 0 {
+		return nil, fmt.Errorf("middleware \"rbac\" requested without permissions; " +
+			"add an rbac tag naming them, e.g. rbac:\"orders:write\"")
+	}
+
+	policy, err := appcontext.Get[*rbac.Policy](ctx)
+	if err != nil {
+		return nil, fmt.Errorf("middleware \"rbac\" requested but no RBAC policy is registered; " +
+			"configure rbac roles in the app config or register an *rbac.Policy in the app context")
+	}
+	return middleware.RBAC(policy, permissions...), nil
+}
+
+// apiKeyMiddleware builds the built-in apikey middleware from the
+// apikey.KeyStore in the app context, requiring the scopes listed in a
+// scopes tag (comma-separated, all required).
+func apiKeyMiddleware(scopesTag string, ctx *appcontext.Context) (middleware.MiddlewareFunc, error) {
+	store, err := appcontext.Get[apikey.KeyStore](ctx)
+	if err != nil {
+		return nil, fmt.Errorf("middleware \"apikey\" requested but no API key store is registered; " +
+			"register an apikey.KeyStore in the app context (e.g. appcontext.Register(ctx, apikey.NewMemoryStore()))")
+	}
+	var scopes []string
+	for _, scope := range strings.Split(scopesTag, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return middleware.APIKeyAuthWithConfig(&middleware.APIKeyConfig{KeyStore: store, Scopes: scopes}), nil
+}
+
+// parseRateLimit parses a ratelimit tag and returns the rate-limit
 // middleware. Malformed tags fail loudly rather than silently leaving the
-// route unlimited.
+// route unlimited. The tag is a rate followed by ";"-separated options:
+//
+//	ratelimit:"100/min;burst=20;key=user;tier=free:60/min,pro:600/min"
 //
-// The middleware lazily creates a MemoryRateLimiter (which starts a background
-// cleanup goroutine). To avoid leaking one goroutine per tagged route for the
-// process lifetime, the created store is registered with the app so
-// App.Shutdown stops it.
+// burst is the number of requests allowed at once and defaults to the
+// limit's per-second share (limit/60 for /min), at least 1. key is ip (the default), user, apikey or header:<name>. tier lists
+// per-tier rates chosen by the role of the request's claims; requests
+// without a matching tier get the base rate. exempt=loopback skips requests
+// from the loopback interface.
+//
+// Each rate gets its own GCRARateLimiter (which starts a background cleanup
+// goroutine). To avoid leaking one goroutine per tagged route for the
+// process lifetime, the created stores are registered with the app so
+// App.Shutdown stops them.
 func parseRateLimit(tag string, ctx *appcontext.Context, app *App) (middleware.MiddlewareFunc, error) {
-	// Parse formats like "100/min", "10/sec", "1000/hour"
-	parts := strings.Split(tag, "/")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid rate limit format %q; expected <number>/<sec|min|hour>", tag)
-	}
-
-	// Parse limit number
-	limit, err := strconv.Atoi(parts[0])
+	options := strings.Split(tag, ";")
+	limit, period, err := parseRateLimitRate(options[0])
 	if err != nil {
-		return nil, fmt.Errorf("invalid rate limit number in %q: %w", tag, err)
+		return nil, err
 	}
 
-	// Parse time unit
+	config := &middleware.GortexRateLimitConfig{}
 	var burst int
-	switch strings.ToLower(parts[1]) {
+	var tiers map[string]rateLimitRate
+	for _, option := range options[1:] {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit option %q; expected <name>=<value>", option)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(name) {
+		case "burst":
+			burst, err = strconv.Atoi(value)
+			if err != nil || burst < 1 {
+				return nil, fmt.Errorf("invalid rate limit burst %q; expected a positive number", value)
+			}
+		case "key":
+			if config.KeyFunc, err = rateLimitKeyFunc(value); err != nil {
+				return nil, err
+			}
+		case "tier":
+			if tiers, err = parseRateLimitTiers(value); err != nil {
+				return nil, err
+			}
+		case "exempt":
+			if value != "loopback" {
+				return nil, fmt.Errorf("unknown rate limit exemption %q; expected loopback", value)
+			}
+			config.SkipFunc = isLoopbackRequest
+		default:
+			return nil, fmt.Errorf("unknown rate limit option %q; expected burst, key, tier or exempt", name)
+		}
+	}
+	// The stores are created once the whole tag is valid, so a malformed
+	// tag leaves no cleanup goroutine behind. Register them so they are
+	// stopped on shutdown instead of leaking.
+	if burst == 0 {
+		burst = defaultRateLimitBurst(limit, period)
+	}
+	stores := []*middleware.GCRARateLimiter{middleware.NewGCRARateLimiter(limit, period, burst)}
+	config.Store = stores[0]
+	if len(tiers) > 0 {
+		config.Tiers = make(map[string]middleware.RateLimiter, len(tiers))
+		for name, tier := range tiers {
+			store := middleware.NewGCRARateLimiter(tier.limit, tier.period, defaultRateLimitBurst(tier.limit, tier.period))
+			config.Tiers[name] = store
+			stores = append(stores, store)
+		}
+	}
+	if app != nil {
+		for _, store := range stores {
+			app.registerStoppable(store)
+		}
+	}
+
+	return middleware.GortexRateLimitWithConfig(config), nil
+}
+
+// defaultRateLimitBurst is the burst of a ratelimit tag without burst=: the
+// requests the rate allows per second, at least 1, so "100/min" admits one
+// request at a time rather than all 100 at once.
+func defaultRateLimitBurst(limit int, period time.Duration) int {
+	burst := int(int64(limit) * int64(time.Second) / int64(period))
+	if burst < 1 {
+		burst = 1
+	}
+	return burst
+}
+
+// parseRateLimitRate parses a rate such as "100/min" into the limit and
+// the period it applies to.
+func parseRateLimitRate(s string) (int, time.Duration, error) {
+	count, unit, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate limit format %q; expected <number>/<sec|min|hour>", s)
+	}
+	limit, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid rate limit number in %q: %w", s, err)
+	}
+	if limit < 1 {
+		return 0, 0, fmt.Errorf("invalid rate limit number in %q; expected a positive number", s)
+	}
+	switch strings.ToLower(unit) {
 	case "sec", "second":
-		burst = limit
+		return limit, time.Second, nil
 	case "min", "minute":
-		burst = limit / 60
-		if burst < 1 {
-			burst = 1
-		}
+		return limit, time.Minute, nil
 	case "hour":
-		burst = limit / 3600
-		if burst < 1 {
-			burst = 1
+		return limit, time.Hour, nil
+	}
+	return 0, 0, fmt.Errorf("unknown rate limit time unit %q in %q; expected sec, min or hour", unit, s)
+}
+
+// rateLimitRate is a tier's limit per period; its burst is the limit.
+type rateLimitRate struct {
+	limit  int
+	period time.Duration
+}
+
+// parseRateLimitTiers parses a tier list such as "free:60/min,pro:600/min".
+func parseRateLimitTiers(s string) (map[string]rateLimitRate, error) {
+	tiers := make(map[string]rateLimitRate)
+	for _, tier := range strings.Split(s, ",") {
+		name, rate, ok := strings.Cut(tier, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid rate limit tier %q; expected <name>:<number>/<unit>", tier)
 		}
-	default:
-		return nil, fmt.Errorf("unknown rate limit time unit %q in %q; expected sec, min or hour", parts[1], tag)
+		if _, dup := tiers[name]; dup {
+			return nil, fmt.Errorf("duplicate rate limit tier %q", name)
+		}
+		limit, period, err := parseRateLimitRate(rate)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit tier %q: %w", name, err)
+		}
+		tiers[name] = rateLimitRate{limit: limit, period: period}
 	}
+	return tiers, nil
+}
 
-	// Create rate limit config
-	config := &middleware.GortexRateLimitConfig{
-		Rate:  limit,
-		Burst: burst,
-		SkipFunc: func(c httpctx.Context) bool {
-			// Skip rate limiting for local/internal requests
-			remoteAddr := c.Request().RemoteAddr
-			return strings.HasPrefix(remoteAddr, "127.0.0.1") || strings.HasPrefix(remoteAddr, "::1")
-		},
+// rateLimitKeyFunc returns the key function named by a ratelimit key option.
+// A nil function keys on the client IP.
+func rateLimitKeyFunc(source string) (func(httpctx.Context) string, error) {
+	switch source {
+	case "ip":
+		return nil, nil
+	case "user":
+		return middleware.RateLimitByUser("jwt-claims"), nil
+	case "apikey":
+		return middleware.RateLimitByAPIKey(), nil
+	}
+	if header, ok := strings.CutPrefix(source, "header:"); ok && header != "" {
+		return middleware.RateLimitByHeader(header), nil
 	}
+	return nil, fmt.Errorf("unknown rate limit key %q; expected ip, user, apikey or header:<name>", source)
+}
 
-	mw := middleware.GortexRateLimitWithConfig(config)
+// isLoopbackRequest reports whether the request's direct peer is on the
+// loopback interface. Forwarding headers are ignored, so a remote client
+// cannot claim the exemption.
+func isLoopbackRequest(c httpctx.Context) bool {
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		host = c.Request().RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
 
-	// GortexRateLimitWithConfig writes the store it created back into
-	// config.Store; register it so its cleanup goroutine is stopped on
-	// shutdown instead of leaking.
-	if app != nil {
-		if s, ok := config.Store.(interface{ Stop() }); ok {
-			app.registerStoppable(s)
+// parseConcurrency parses a concurrency tag and returns a load-shedding
+// middleware with its own ConcurrencyLimiter, named after the path it
+// guards. The tag is the initial limit followed by ";"-separated options:
+//
+//	concurrency:"50;min=10;max=500;algorithm=aimd"
+//
+// min and max bound the limit, and algorithm is gradient (the default) or
+// aimd. The limiter is registered with the app so /_monitor reports it.
+func parseConcurrency(tag, name string, app *App) (middleware.MiddlewareFunc, error) {
+	options := strings.Split(tag, ";")
+	opts := middleware.ConcurrencyLimiterOptions{Name: name}
+	var err error
+	if opts.InitialLimit, err = parseConcurrencyLimit(options[0]); err != nil {
+		return nil, fmt.Errorf("invalid concurrency limit: %w", err)
+	}
+	for _, option := range options[1:] {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid concurrency option %q; expected <name>=<value>", option)
 		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "min":
+			if opts.MinLimit, err = parseConcurrencyLimit(value); err != nil {
+				return nil, fmt.Errorf("invalid concurrency min: %w", err)
+			}
+		case "max":
+			if opts.MaxLimit, err = parseConcurrencyLimit(value); err != nil {
+				return nil, fmt.Errorf("invalid concurrency max: %w", err)
+			}
+		case "algorithm":
+			switch value {
+			case "gradient":
+				opts.Algorithm = middleware.ConcurrencyGradient
+			case "aimd":
+				opts.Algorithm = middleware.ConcurrencyAIMD
+			default:
+				return nil, fmt.Errorf("unknown concurrency algorithm %q; expected gradient or aimd", value)
+			}
+		default:
+			return nil, fmt.Errorf("unknown concurrency option %q; expected min, max or algorithm", key)
+		}
+	}
+	if opts.MaxLimit > 0 && opts.MaxLimit < max(opts.MinLimit, 1) {
+		return nil, fmt.Errorf("concurrency max %d is below min %d", opts.MaxLimit, opts.MinLimit)
 	}
 
-	return mw, nil
+	limiter := middleware.NewConcurrencyLimiter(opts)
+	if app != nil {
+		app.registerConcurrencyLimiters(limiter)
+	}
+	return middleware.ConcurrencyLimitWithConfig(&middleware.ConcurrencyLimitConfig{Limiter: limiter}), nil
+}
+
+// parseConcurrencyLimit parses a positive concurrency limit.
+func parseConcurrencyLimit(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%q is not a positive number", s)
+	}
+	return n, nil
 }
 
 // isHandlerGroup checks if a handler is a group (has nested fields with url tags)
@@ -559,6 +1163,12 @@ func autoInitHandlers(v reflect.Value, checkURLTag bool) error {
 			continue
 		}
 
+		// Leave `inject` fields nil so injectDependencies can resolve
+		// them instead of finding an empty zero-value struct.
+		if _, hasInject := fieldType.Tag.Lookup("inject"); hasInject {
+			continue
+		}
+
 		// Check url tag if we're at the top level
 		urlTag := fieldType.Tag.Get("url")
 		shouldInit := !checkURLTag || urlTag != ""
@@ -587,31 +1197,46 @@ func autoInitHandlers(v reflect.Value, checkURLTag bool) error {
 	return nil
 }
 
-// injectDependencies is a placeholder for future DI support.
+// injectDependencies resolves struct fields tagged with `inject` from the
+// app context, walking nested handler structs.
+//
+// `inject:""` resolves by the field's type (interface fields are satisfied by
+// the single registered implementation); `inject:"name"` resolves a service
+// registered with appcontext.RegisterNamed. Fields that are already non-nil
+// are left alone so hand-wired dependencies still win.
 //
-// It inspects struct fields tagged with `inject` but does NOT perform actual
-// injection — the feature is not yet implemented. If a field with an `inject`
-// tag is nil, an error is returned so the caller fails loudly rather than
-// proceeding with a nil pointer that would panic later.
+// Every problem is reported with the full field path (for example
+// `HandlersManager.API.Users.Repo`) so a missing or ambiguous provider is
+// caught at NewApp time rather than as a nil-pointer panic on first request.
+// Scoped providers are rejected here: handler structs live for the whole
+// process, so a per-request service must be taken as a method parameter.
 func injectDependencies(v reflect.Value, ctx *appcontext.Context) error {
-	// Handle pointer
 	if v.Kind() == reflect.Ptr && !v.IsNil() {
 		v = v.Elem()
 	}
-
-	// Only process structs
 	if v.Kind() != reflect.Struct {
 		return nil
 	}
+	return injectStruct(v, ctx, v.Type().Name(), make(map[uintptr]bool))
+}
 
-	// Fast path: skip if no field in this struct (non-recursive) has
-	// an `inject` tag. This is the common case and avoids the
-	// per-field Lookup overhead for every route registration.
-	if !hasInjectTag(v.Type()) {
-		return nil
+// injectStruct injects the fields of the struct v, recursing into nested
+// handler structs. path is the dotted field path of v used in error messages;
+// visited guards against pointer cycles.
+func injectStruct(v reflect.Value, ctx *appcontext.Context, path string, visited map[uintptr]bool) error {
+	if v.CanAddr() {
+		addr := v.Addr().Pointer()
+		if visited[addr] {
+			return nil
+		}
+		visited[addr] = true
 	}
 
 	t := v.Type()
+	// Only look up tags field-by-field when this struct actually uses DI;
+	// the recursion below still runs so nested handlers are covered.
+	tagged := hasInjectTag(t)
+
 	for i := 0; i < v.NumField(); i++ {
 		field := v.Field(i)
 		fieldType := t.Field(i)
@@ -621,38 +1246,89 @@ func injectDependencies(v reflect.Value, ctx *appcontext.Context) error {
 			continue
 		}
 
-		// Check for inject tag. Use Lookup so that `inject:""` (empty value)
-		// is detected — Get("inject") would return "" for both a missing tag
-		// and an empty-value tag, making them indistinguishable.
-		if _, hasInject := fieldType.Tag.Lookup("inject"); hasInject {
-			// Injection is not yet implemented. If the field is still nil,
-			// return an error to prevent a later nil-pointer panic.
-			if field.Kind() == reflect.Ptr && field.IsNil() {
-				return fmt.Errorf(
-					"field %s.%s has `inject` tag but DI injection is not implemented; "+
-						"set the field manually before calling RegisterRoutes or remove the `inject` tag",
-					t.Name(), fieldType.Name,
-				)
+		fieldPath := path + "." + fieldType.Name
+
+		// Use Lookup so that `inject:""` (empty value) is detected — Get
+		// would return "" for both a missing tag and an empty-value tag.
+		if tagged {
+			if name, hasInject := fieldType.Tag.Lookup("inject"); hasInject {
+				if err := injectField(field, ctx, strings.TrimSpace(name)); err != nil {
+					return fmt.Errorf("%s: %w", fieldPath, err)
+				}
+				// Injected services are fully constructed; don't walk them.
+				continue
 			}
 		}
 
 		// Recursively process nested structs
-		if field.Kind() == reflect.Ptr && !field.IsNil() {
-			if field.Type().Elem().Kind() == reflect.Struct {
-				if err := injectDependencies(field, ctx); err != nil {
-					return fmt.Errorf("failed to inject dependencies in field %s: %w", fieldType.Name, err)
-				}
+		if field.Kind() == reflect.Ptr && !field.IsNil() && field.Type().Elem().Kind() == reflect.Struct {
+			if err := injectStruct(field.Elem(), ctx, fieldPath, visited); err != nil {
+				return err
 			}
 		} else if field.Kind() == reflect.Struct {
-			if err := injectDependencies(field.Addr(), ctx); err != nil {
-				return fmt.Errorf("failed to inject dependencies in field %s: %w", fieldType.Name, err)
+			if err := injectStruct(field, ctx, fieldPath, visited); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// injectField populates a single `inject`-tagged field. name is the tag
+// value; empty means resolve by type.
+func injectField(field reflect.Value, ctx *appcontext.Context, name string) error {
+	if !isNilable(field.Kind()) {
+		return fmt.Errorf("`inject` requires a pointer, interface, map, slice, chan or func field, got %v", field.Type())
+	}
+	if !field.IsNil() {
+		return nil
+	}
+	if ctx == nil {
+		return fmt.Errorf("cannot inject %v: no app context", field.Type())
+	}
+
+	var (
+		service any
+		err     error
+	)
+	if name != "" {
+		service, err = ctx.ResolveNamed(name)
+		if err != nil {
+			return fmt.Errorf("no service registered under name %q; register one with appcontext.RegisterNamed", name)
+		}
+	} else {
+		if lifetime, ok := ctx.LifetimeOf(field.Type()); ok && lifetime == appcontext.Scoped {
+			return fmt.Errorf("cannot inject scoped service %v into a handler field; "+
+				"take it as a handler method parameter instead", field.Type())
+		}
+		service, err = ctx.Resolve(field.Type())
+		if err != nil {
+			if errors.Is(err, appcontext.ErrServiceNotFound) {
+				return fmt.Errorf("no provider registered for %v; register one with appcontext.Register, RegisterAs or Provide", field.Type())
 			}
+			return err
 		}
 	}
 
+	sv := reflect.ValueOf(service)
+	if !sv.IsValid() || !sv.Type().AssignableTo(field.Type()) {
+		return fmt.Errorf("service %T is not assignable to %v", service, field.Type())
+	}
+	field.Set(sv)
 	return nil
 }
 
+// isNilable reports whether values of kind k can be nil, and therefore can
+// express "not yet injected".
+func isNilable(k reflect.Kind) bool {
+	switch k {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+		return true
+	}
+	return false
+}
+
 // hasInjectTag reports whether any exported field of t carries an
 // `inject` struct tag. Used as a fast-path check so that the
 // reflection walk can be skipped entirely for handler structs that
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/internal/testutil"
+	"github.com/yshengliao/gortex/middleware"
+	"github.com/yshengliao/gortex/pkg/config"
+	httpctx "github.com/yshengliao/gortex/transport/http"
+)
+
+type mtlsHandler struct{}
+
+func (mtlsHandler) GET(c httpctx.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{"user": middleware.GetUserID(c), "tls": c.IsTLS()})
+}
+
+// writePEM writes data to a file in dir and returns its path.
+func writePEM(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestRun_MutualTLS(t *testing.T) {
+	ca := testutil.NewTestCA(t)
+	dir := t.TempDir()
+	serverCert, serverKey := ca.IssueServer(t, "server")
+	tlsCfg := config.TLSConfig{
+		CertFile:     writePEM(t, dir, "server.crt", serverCert),
+		KeyFile:      writePEM(t, dir, "server.key", serverKey),
+		ClientCAFile: writePEM(t, dir, "ca.crt", ca.PEM),
+	}
+
+	a, err := NewApp(WithHandlers(&struct {
+		Internal *mtlsHandler `url:"/internal" middleware:"mtls"`
+	}{}))
+	require.NoError(t, err)
+	serverTLS, err := a.serverTLSConfig(tlsCfg)
+	require.NoError(t, err)
+	defer a.Shutdown(context.Background())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: a.ServerHandler()}
+	go srv.Serve(tls.NewListener(ln, serverTLS))
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	clientCertPEM, clientKeyPEM := ca.Issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "billing-service"}})
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	require.NoError(t, err)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+	url := "https://" + ln.Addr().String() + "/internal"
+
+	resp, err := client(clientCert).Get(url)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"user":"billing-service","tls":true}`, string(body))
+
+	// Without a client certificate the handshake fails.
+	_, err = client().Get(url)
+	assert.Error(t, err)
+
+	// Certificates from another CA are not accepted either.
+	otherCertPEM, otherKeyPEM := testutil.NewTestCA(t).Issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "billing-service"}})
+	otherCert, err := tls.X509KeyPair(otherCertPEM, otherKeyPEM)
+	require.NoError(t, err)
+	_, err = client(otherCert).Get(url)
+	assert.Error(t, err)
+}
+
+func TestServerTLSConfig_Validates(t *testing.T) {
+	ca := testutil.NewTestCA(t)
+	dir := t.TempDir()
+	serverCert, serverKey := ca.IssueServer(t, "server")
+	cfg := config.TLSConfig{
+		CertFile: writePEM(t, dir, "server.crt", serverCert),
+		KeyFile:  writePEM(t, dir, "server.key", serverKey),
+	}
+	a := &App{}
+	defer a.stopStoppables()
+
+	cfg.ClientAuth = "sometimes"
+	_, err := a.serverTLSConfig(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown tls client_auth")
+
+	cfg.ClientAuth = "require_and_verify"
+	_, err = a.serverTLSConfig(cfg)
+	assert.Error(t, err, "verification without a client CA")
+
+	cfg.ClientAuth = ""
+	_, err = a.serverTLSConfig(cfg)
+	assert.NoError(t, err)
+}
@@ -2,7 +2,6 @@ package app
 
 import (
 	"reflect"
-	"runtime"
 	"strings"
 
 	"github.com/yshengliao/gortex/middleware"
@@ -62,19 +61,13 @@ func methodNameToPath(name string) string {
 	return "/" + camelToKebab(name)
 }
 
-// extractMiddlewareNames extracts middleware names from a slice of middleware functions
+// extractMiddlewareNames extracts middleware names from a slice of middleware
+// functions, named the same way as in the router's Routes report.
 func extractMiddlewareNames(middlewares []middleware.MiddlewareFunc) []string {
 	names := make([]string, 0, len(middlewares))
 	for _, mw := range middlewares {
 		if mw != nil {
-			// Extract name from function pointer
-			name := runtime.FuncForPC(reflect.ValueOf(mw).Pointer()).Name()
-			// Clean up package path, just keep the last part
-			parts := strings.Split(name, "/")
-			name = parts[len(parts)-1]
-			// Optional: remove -fm (method value) suffix
-			name = strings.TrimSuffix(name, "-fm")
-			names = append(names, name)
+			names = append(names, httpctx.MiddlewareName(mw))
 		}
 	}
 	return names
@@ -258,12 +258,10 @@ func (pb *ParameterBinder) bindField(c gortexContext.Context, fieldValue reflect
 		value = c.FormValue(name)
 		found = value != ""
 	case "jwt", "claims":
-		// Try to get from JWT claims
-		if claims := pb.getJWTClaims(c); claims != nil {
-			if val, ok := claims[name]; ok {
-				value = fmt.Sprintf("%v", val)
-				found = true
-			}
+		// Claims keep their JSON types: numbers, booleans, arrays and
+		// objects are decoded into the field as they are, not stringified.
+		if raw, ok := pb.getJWTClaims(c)[name]; ok {
+			return pb.setFieldFromJSON(fieldValue, raw)
 		}
 	case "context":
 		// Try to get from gortex context
@@ -405,37 +403,65 @@ func (pb *ParameterBinder) getFromDI(paramType reflect.Type) (reflect.Value, err
 		return reflect.Value{}, fmt.Errorf("DI context not available")
 	}
 
-	// Direct access to context services
-	pb.diContext.mu.RLock()
-	defer pb.diContext.mu.RUnlock()
-
-	if service, ok := pb.diContext.services[paramType]; ok {
-		return reflect.ValueOf(service), nil
+	service, err := pb.diContext.Resolve(paramType)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("%w in DI container", err)
 	}
-
-	return reflect.Value{}, fmt.Errorf("service %v not found in DI container", paramType)
+	return reflect.ValueOf(service), nil
 }
 
-// getJWTClaims extracts JWT claims from the gortex context
-func (pb *ParameterBinder) getJWTClaims(c gortexContext.Context) jwt.MapClaims {
-	// Try to get JWT token from context (commonly set by JWT middleware)
-	if token := c.Get("user"); token != nil {
-		switch t := token.(type) {
-		case *jwt.Token:
-			if claims, ok := t.Claims.(jwt.MapClaims); ok {
-				return claims
-			}
-		case jwt.MapClaims:
-			return t
+// jwtClaimsKeys are the context keys JWT claims are looked up under, in
+// order: the key middleware.JWTAuth stores them under, then the keys
+// commonly used by other JWT middleware.
+var jwtClaimsKeys = []string{"jwt-claims", "user", "claims"}
+
+// getJWTClaims extracts JWT claims from the gortex context as raw JSON
+// values by claim name. Any jwt.Claims value works, such as *auth.Claims,
+// *auth.ClaimsOf[T] or jwt.MapClaims, as does a *jwt.Token.
+func (pb *ParameterBinder) getJWTClaims(c gortexContext.Context) map[string]json.RawMessage {
+	for _, key := range jwtClaimsKeys {
+		val := c.Get(key)
+		if token, ok := val.(*jwt.Token); ok {
+			val = token.Claims
+		}
+		claims, ok := val.(jwt.Claims)
+		if !ok || claims == nil {
+			continue
+		}
+		data, err := json.Marshal(claims)
+		if err != nil {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err == nil && fields != nil {
+			return fields
 		}
 	}
+	return nil
+}
 
-	// Try to get claims directly
-	if claims := c.Get("claims"); claims != nil {
-		if mapClaims, ok := claims.(jwt.MapClaims); ok {
-			return mapClaims
+// setFieldFromJSON sets a field from a raw JSON value. JSON strings go
+// through setFieldValue, so "42" still binds to an int and RFC 3339 strings
+// to a time.Time; other values are decoded into the field's type. A string
+// field receives a non-string value in its JSON form.
+func (pb *ParameterBinder) setFieldFromJSON(fieldValue reflect.Value, raw json.RawMessage) error {
+	if len(raw) > 0 && raw[0] == '"' {
+		var str string
+		if err := json.Unmarshal(raw, &str); err != nil {
+			return err
 		}
+		return pb.setFieldValue(fieldValue, str)
+	}
+	if string(raw) == "null" {
+		return nil // Leave at zero value
 	}
 
-	return nil
+	fieldType := fieldValue.Type()
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType.Kind() == reflect.String {
+		return pb.setFieldValue(fieldValue, string(raw))
+	}
+	return json.Unmarshal(raw, fieldValue.Addr().Interface())
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
+	"github.com/yshengliao/gortex/pkg/auth"
 	httpctx "github.com/yshengliao/gortex/transport/http"
 )
 
@@ -475,6 +476,73 @@ func TestParameterBinderJWTClaims(t *testing.T) {
 	assert.Equal(t, "admin", profile.Role)
 }
 
+type tenantClaims struct {
+	Tenant string   `json:"tenant"`
+	Scopes []string `json:"scopes"`
+	Level  int      `json:"level"`
+}
+
+func TestParameterBinderTypedJWTClaims(t *testing.T) {
+	type TenantRequest struct {
+		Subject  string            `bind:"sub,jwt"`
+		Tenant   string            `bind:"tenant,jwt"`
+		Scopes   []string          `bind:"scopes,jwt"`
+		Level    int               `bind:"level,jwt"`
+		LevelPtr *int64            `bind:"level,jwt"`
+		LevelStr string            `bind:"level,jwt"`
+		Expires  time.Time         `bind:"expires_at,jwt"`
+		Missing  []string          `bind:"missing,jwt"`
+		Extra    map[string]string `bind:"extra,jwt"`
+	}
+
+	binder := NewParameterBinder()
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	req := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+	ctx := httpctx.NewDefaultContext(req, httptest.NewRecorder())
+
+	// Typed claims stored the way middleware.JWTAuthOf stores them.
+	ctx.Set("jwt-claims", &auth.ClaimsOf[map[string]any]{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"},
+		Custom: map[string]any{
+			"tenant":     "acme",
+			"scopes":     []string{"orders:read", "orders:write"},
+			"level":      3,
+			"expires_at": expires.Format(time.RFC3339),
+			"extra":      map[string]string{"plan": "pro"},
+		},
+	})
+
+	var got TenantRequest
+	require.NoError(t, binder.bindStruct(ctx, reflect.ValueOf(&got).Elem()))
+	assert.Equal(t, "user-1", got.Subject)
+	assert.Equal(t, "acme", got.Tenant)
+	assert.Equal(t, []string{"orders:read", "orders:write"}, got.Scopes)
+	assert.Equal(t, 3, got.Level)
+	require.NotNil(t, got.LevelPtr)
+	assert.Equal(t, int64(3), *got.LevelPtr)
+	assert.Equal(t, "3", got.LevelStr)
+	assert.True(t, expires.Equal(got.Expires))
+	assert.Nil(t, got.Missing)
+	assert.Equal(t, map[string]string{"plan": "pro"}, got.Extra)
+
+	// A claim of the wrong type is an error for an explicitly tagged field.
+	ctx.Set("jwt-claims", &auth.ClaimsOf[tenantClaims]{Custom: tenantClaims{Tenant: "acme"}})
+	var bad struct {
+		Tenant int `bind:"tenant,jwt"`
+	}
+	assert.Error(t, binder.bindStruct(ctx, reflect.ValueOf(&bad).Elem()))
+
+	// The fixed claims stored by middleware.JWTAuth bind too.
+	ctx.Set("jwt-claims", &auth.Claims{UserID: "42", Role: "admin"})
+	var fixed struct {
+		UserID int    `bind:"user_id,jwt"`
+		Role   string `bind:"role,jwt"`
+	}
+	require.NoError(t, binder.bindStruct(ctx, reflect.ValueOf(&fixed).Elem()))
+	assert.Equal(t, 42, fixed.UserID)
+	assert.Equal(t, "admin", fixed.Role)
+}
+
 func TestParameterBinderDI(t *testing.T) {
 
 	// Create DI context and register service
@@ -1,22 +1,113 @@
 package context
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 )
 
+// ErrServiceNotFound is returned (wrapped) when no service or provider
+// satisfies a lookup.
+var ErrServiceNotFound = errors.New("service not found")
+
+// ErrServiceAmbiguous is returned (wrapped) when an interface lookup is
+// satisfied by more than one registered type.
+var ErrServiceAmbiguous = errors.New("service ambiguous")
+
+// Lifetime controls how often a provider's factory runs.
+type Lifetime int
+
+const (
+	// Singleton providers run their factory once, on first resolution, and
+	// share the result for the lifetime of the Context.
+	Singleton Lifetime = iota
+	// Scoped providers run their factory once per scope (see NewScope).
+	// The framework opens one scope per request.
+	Scoped
+)
+
+// String returns the lifetime name used in error messages.
+func (l Lifetime) String() string {
+	switch l {
+	case Singleton:
+		return "singleton"
+	case Scoped:
+		return "scoped"
+	default:
+		return "unknown"
+	}
+}
+
+// provider lazily produces a service of a single type.
+type provider struct {
+	lifetime Lifetime
+	factory  func(*Context) (any, error)
+
+	// once, value and err cache the result of a singleton factory.
+	once  sync.Once
+	value any
+	err   error
+}
+
 // Context is a simple dependency injection container using generics
 type Context struct {
-	mu       sync.RWMutex
-	services map[reflect.Type]any
+	mu        sync.RWMutex
+	services  map[reflect.Type]any
+	named     map[string]any
+	providers map[reflect.Type]*provider
+
+	// scopedProviders counts Scoped entries in providers so the per-request
+	// HasScopedProviders check is O(1).
+	scopedProviders int
+
+	// parent is set on request scopes created by NewScope. Lookups that
+	// miss in a scope fall through to the parent; providers always live on
+	// the root context.
+	parent *Context
 }
 
 // NewContext creates a new DI context
 func NewContext() *Context {
+	return &Context{
+		services:  make(map[reflect.Type]any),
+		named:     make(map[string]any),
+		providers: make(map[reflect.Type]*provider),
+	}
+}
+
+// NewScope returns a child context for a single unit of work (typically one
+// request). Scoped providers resolved through the child run once and are
+// cached on it; everything else is delegated to the parent.
+func (ctx *Context) NewScope() *Context {
 	return &Context{
 		services: make(map[reflect.Type]any),
+		parent:   ctx,
+	}
+}
+
+// IsScope reports whether ctx was created by NewScope.
+func (ctx *Context) IsScope() bool {
+	return ctx.parent != nil
+}
+
+// HasScopedProviders reports whether any Scoped provider is registered, so
+// callers can skip opening a scope when none would be used.
+func (ctx *Context) HasScopedProviders() bool {
+	root := ctx.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.scopedProviders > 0
+}
+
+// root returns the top-level context that owns providers and named services.
+func (ctx *Context) root() *Context {
+	for ctx.parent != nil {
+		ctx = ctx.parent
 	}
+	return ctx
 }
 
 // Register adds a service to the context
@@ -29,21 +120,82 @@ func Register[T any](ctx *Context, service T) {
 	ctx.services[actualType] = service
 }
 
-// Get retrieves a service from the context
-func Get[T any](ctx *Context) (T, error) {
-	ctx.mu.RLock()
-	defer ctx.mu.RUnlock()
+// RegisterAs adds a service keyed by the type parameter T rather than the
+// service's dynamic type. Use it to bind an implementation to an interface:
+//
+//	appcontext.RegisterAs[UserRepository](ctx, &pgUserRepository{db: db})
+func RegisterAs[T any](ctx *Context, service T) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.services[reflect.TypeOf((*T)(nil)).Elem()] = service
+}
 
+// RegisterNamed adds a service under name. Named services are resolved by
+// `inject:"name"` struct tags and GetNamed, independently of their type.
+func RegisterNamed[T any](ctx *Context, name string, service T) {
+	root := ctx.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	root.named[name] = service
+}
+
+// Provide registers a lazily-constructed service of type T. A Singleton
+// factory runs once on first resolution; a Scoped factory runs once per
+// scope created by NewScope and cannot be resolved from the root context.
+//
+// Factories receive the context they are resolved from, so they may look up
+// their own dependencies with Get.
+func Provide[T any](ctx *Context, lifetime Lifetime, factory func(*Context) (T, error)) {
+	root := ctx.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if old, ok := root.providers[t]; ok && old.lifetime == Scoped {
+		root.scopedProviders--
+	}
+	if lifetime == Scoped {
+		root.scopedProviders++
+	}
+	root.providers[t] = &provider{
+		lifetime: lifetime,
+		factory: func(c *Context) (any, error) {
+			return factory(c)
+		},
+	}
+}
+
+// Get retrieves a service from the context. Interface types that were not
+// registered directly are satisfied by the single registered service or
+// provider whose type implements them.
+func Get[T any](ctx *Context) (T, error) {
 	var zero T
 	t := reflect.TypeOf((*T)(nil)).Elem()
 
-	if service, ok := ctx.services[t]; ok {
-		if s, ok := service.(T); ok {
-			return s, nil
-		}
+	service, err := ctx.Resolve(t)
+	if err != nil {
+		return zero, err
+	}
+	if s, ok := service.(T); ok {
+		return s, nil
 	}
+	return zero, fmt.Errorf("service %v: %w", t, ErrServiceNotFound)
+}
 
-	return zero, fmt.Errorf("service %v not found", t)
+// GetNamed retrieves a service registered with RegisterNamed.
+func GetNamed[T any](ctx *Context, name string) (T, error) {
+	var zero T
+	service, ok := ctx.lookupNamed(name)
+	if !ok {
+		return zero, fmt.Errorf("service %q: %w", name, ErrServiceNotFound)
+	}
+	s, ok := service.(T)
+	if !ok {
+		return zero, fmt.Errorf("service %q has type %T, not %v", name, service, reflect.TypeOf((*T)(nil)).Elem())
+	}
+	return s, nil
 }
 
 // MustGet retrieves a service from the context, panics if not found
@@ -54,3 +206,148 @@ func MustGet[T any](ctx *Context) T {
 	}
 	return service
 }
+
+// Resolve returns the service for t, running its provider if needed. It is
+// the reflection counterpart of Get, used by struct-tag injection and the
+// parameter binder.
+func (ctx *Context) Resolve(t reflect.Type) (any, error) {
+	if service, ok := ctx.lookup(t); ok {
+		return service, nil
+	}
+	if p := ctx.providerFor(t); p != nil {
+		return ctx.runProvider(t, p)
+	}
+	if t.Kind() != reflect.Interface {
+		return nil, fmt.Errorf("service %v: %w", t, ErrServiceNotFound)
+	}
+
+	candidates := ctx.implementers(t)
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("service %v: %w", t, ErrServiceNotFound)
+	case 1:
+		return ctx.Resolve(candidates[0])
+	default:
+		names := make([]string, len(candidates))
+		for i, c := range candidates {
+			names[i] = c.String()
+		}
+		return nil, fmt.Errorf("service %v is implemented by %s: %w; register one with RegisterAs to disambiguate",
+			t, strings.Join(names, ", "), ErrServiceAmbiguous)
+	}
+}
+
+// ResolveNamed returns the service registered under name.
+func (ctx *Context) ResolveNamed(name string) (any, error) {
+	if service, ok := ctx.lookupNamed(name); ok {
+		return service, nil
+	}
+	return nil, fmt.Errorf("service %q: %w", name, ErrServiceNotFound)
+}
+
+// LifetimeOf reports how the service for t would be produced. Directly
+// registered instances are reported as Singleton. ok is false when nothing
+// (including an interface implementer) satisfies t.
+func (ctx *Context) LifetimeOf(t reflect.Type) (lifetime Lifetime, ok bool) {
+	if _, found := ctx.lookup(t); found {
+		return Singleton, true
+	}
+	if p := ctx.providerFor(t); p != nil {
+		return p.lifetime, true
+	}
+	if t.Kind() == reflect.Interface {
+		if candidates := ctx.implementers(t); len(candidates) == 1 {
+			return ctx.LifetimeOf(candidates[0])
+		}
+	}
+	return Singleton, false
+}
+
+// lookup finds an already-constructed service of exactly type t in ctx or
+// any of its parents.
+func (ctx *Context) lookup(t reflect.Type) (any, bool) {
+	for c := ctx; c != nil; c = c.parent {
+		c.mu.RLock()
+		service, ok := c.services[t]
+		c.mu.RUnlock()
+		if ok {
+			return service, true
+		}
+	}
+	return nil, false
+}
+
+func (ctx *Context) lookupNamed(name string) (any, bool) {
+	root := ctx.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	service, ok := root.named[name]
+	return service, ok
+}
+
+func (ctx *Context) providerFor(t reflect.Type) *provider {
+	root := ctx.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.providers[t]
+}
+
+// runProvider produces the value for p. No lock is held while the factory
+// runs so it may resolve its own dependencies from the same context.
+func (ctx *Context) runProvider(t reflect.Type, p *provider) (any, error) {
+	if p.lifetime == Singleton {
+		root := ctx.root()
+		p.once.Do(func() {
+			p.value, p.err = p.factory(root)
+		})
+		if p.err != nil {
+			return nil, fmt.Errorf("provider for %v: %w", t, p.err)
+		}
+		return p.value, nil
+	}
+
+	if !ctx.IsScope() {
+		return nil, fmt.Errorf("service %v is scoped and can only be resolved inside a scope (see NewScope)", t)
+	}
+	value, err := p.factory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("provider for %v: %w", t, err)
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	// Another resolution in the same scope may have won the race; keep the
+	// first value so every consumer in the scope shares one instance.
+	if existing, ok := ctx.services[t]; ok {
+		return existing, nil
+	}
+	ctx.services[t] = value
+	return value, nil
+}
+
+// implementers lists every registered service or provider type that
+// implements the interface t, sorted for stable error messages.
+func (ctx *Context) implementers(t reflect.Type) []reflect.Type {
+	seen := make(map[reflect.Type]struct{})
+	for c := ctx; c != nil; c = c.parent {
+		c.mu.RLock()
+		for st := range c.services {
+			if st != t && st.Implements(t) {
+				seen[st] = struct{}{}
+			}
+		}
+		for pt := range c.providers {
+			if pt != t && pt.Implements(t) {
+				seen[pt] = struct{}{}
+			}
+		}
+		c.mu.RUnlock()
+	}
+
+	out := make([]reflect.Type, 0, len(seen))
+	for st := range seen {
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+	return out
+}
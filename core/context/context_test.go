@@ -0,0 +1,116 @@
+package context
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type greeter interface{ Greet() string }
+
+type englishGreeter struct{}
+
+func (englishGreeter) Greet() string { return "hello" }
+
+type frenchGreeter struct{}
+
+func (frenchGreeter) Greet() string { return "bonjour" }
+
+func TestGetResolvesInterfaceByUniqueImplementer(t *testing.T) {
+	ctx := NewContext()
+	Register(ctx, &englishGreeter{})
+
+	g, err := Get[greeter](ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", g.Greet())
+}
+
+func TestGetReportsAmbiguousInterface(t *testing.T) {
+	ctx := NewContext()
+	Register(ctx, &englishGreeter{})
+	Register(ctx, &frenchGreeter{})
+
+	_, err := Get[greeter](ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrServiceAmbiguous))
+	assert.Contains(t, err.Error(), "*context.englishGreeter")
+	assert.Contains(t, err.Error(), "*context.frenchGreeter")
+
+	// An explicit interface binding resolves the ambiguity.
+	RegisterAs[greeter](ctx, &frenchGreeter{})
+	g, err := Get[greeter](ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "bonjour", g.Greet())
+}
+
+func TestGetNamed(t *testing.T) {
+	ctx := NewContext()
+	RegisterNamed(ctx, "primary", &englishGreeter{})
+
+	g, err := GetNamed[greeter](ctx, "primary")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", g.Greet())
+
+	_, err = GetNamed[greeter](ctx, "missing")
+	assert.True(t, errors.Is(err, ErrServiceNotFound))
+
+	_, err = GetNamed[*frenchGreeter](ctx, "primary")
+	assert.Error(t, err, "wrong type must not be returned")
+}
+
+func TestProvideSingletonRunsOnce(t *testing.T) {
+	ctx := NewContext()
+	calls := 0
+	Provide(ctx, Singleton, func(*Context) (*englishGreeter, error) {
+		calls++
+		return &englishGreeter{}, nil
+	})
+
+	a := MustGet[*englishGreeter](ctx)
+	b := MustGet[*englishGreeter](ctx.NewScope())
+	assert.Same(t, a, b)
+	assert.Equal(t, 1, calls)
+	assert.False(t, ctx.HasScopedProviders())
+}
+
+// scopedCounter is non-empty so distinct allocations have distinct addresses.
+type scopedCounter struct{ n int }
+
+func TestProvideScopedRunsOncePerScope(t *testing.T) {
+	ctx := NewContext()
+	calls := 0
+	Provide(ctx, Scoped, func(*Context) (*scopedCounter, error) {
+		calls++
+		return &scopedCounter{n: calls}, nil
+	})
+	require.True(t, ctx.HasScopedProviders())
+
+	_, err := Get[*scopedCounter](ctx)
+	require.Error(t, err, "scoped services must not resolve from the root context")
+
+	s1 := ctx.NewScope()
+	a := MustGet[*scopedCounter](s1)
+	assert.Same(t, a, MustGet[*scopedCounter](s1))
+
+	s2 := ctx.NewScope()
+	assert.NotSame(t, a, MustGet[*scopedCounter](s2))
+	assert.Equal(t, 2, calls)
+
+	lifetime, ok := ctx.LifetimeOf(reflect.TypeOf(&scopedCounter{}))
+	require.True(t, ok)
+	assert.Equal(t, Scoped, lifetime)
+}
+
+func TestProvideFactoryError(t *testing.T) {
+	ctx := NewContext()
+	Provide(ctx, Singleton, func(*Context) (*englishGreeter, error) {
+		return nil, errors.New("boom")
+	})
+
+	_, err := Get[*englishGreeter](ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+}
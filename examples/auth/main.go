@@ -91,7 +91,9 @@ func (h *AuthHandler) Refresh(c httpctx.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return httpctx.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
-	access, err := h.JWT.RefreshAccessToken(req.RefreshToken, func(userID string) (string, string, string, error) {
+	// Refresh rotates the refresh token: the one presented is spent, and
+	// presenting it again revokes every token descended from the login.
+	pair, err := h.JWT.Refresh(c.Request().Context(), req.RefreshToken, func(userID string) (string, string, string, error) {
 		if userID != demoUser.ID {
 			return "", "", "", errors.New("unknown user")
 		}
@@ -100,12 +102,34 @@ func (h *AuthHandler) Refresh(c httpctx.Context) error {
 	if err != nil {
 		return httpctx.NewHTTPError(http.StatusUnauthorized, err.Error())
 	}
-	return c.JSON(http.StatusOK, map[string]any{
-		"access_token": access,
-		"expires_in":   int(h.JWT.AccessTokenTTL().Seconds()),
+	return c.JSON(http.StatusOK, tokenResp{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    int(h.JWT.AccessTokenTTL().Seconds()),
 	})
 }
 
+// Logout revokes the presented access token and, if given, the refresh
+// token's family.
+func (h *AuthHandler) Logout(c httpctx.Context) error {
+	var req refreshReq
+	_ = c.Bind(&req)
+	raw := c.Request().Header.Get("Authorization")
+	token := strings.TrimPrefix(raw, "Bearer ")
+	if token == "" || token == raw {
+		return httpctx.NewHTTPError(http.StatusUnauthorized, "missing bearer token")
+	}
+	for _, t := range []string{token, req.RefreshToken} {
+		if t == "" {
+			continue
+		}
+		if err := h.JWT.Revoke(c.Request().Context(), t); err != nil {
+			return httpctx.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
 // MeHandler validates the bearer token and echoes the caller's claims.
 type MeHandler struct {
 	JWT *auth.JWTService
@@ -133,6 +157,7 @@ func (h *MeHandler) GET(c httpctx.Context) error {
 type AuthGroup struct {
 	Login   *LoginHandler   `url:"/login"`
 	Refresh *RefreshHandler `url:"/refresh"`
+	Logout  *LogoutHandler  `url:"/logout"`
 }
 
 // LoginHandler and RefreshHandler are thin POST-only shells around
@@ -145,6 +170,10 @@ type RefreshHandler struct{ Auth *AuthHandler }
 
 func (h *RefreshHandler) POST(c httpctx.Context) error { return h.Auth.Refresh(c) }
 
+type LogoutHandler struct{ Auth *AuthHandler }
+
+func (h *LogoutHandler) POST(c httpctx.Context) error { return h.Auth.Logout(c) }
+
 type Handlers struct {
 	Auth *AuthGroup `url:"/auth"`
 	Me   *MeHandler `url:"/me"`
@@ -162,12 +191,17 @@ func main() {
 	if err != nil {
 		logger.Fatal("jwt init failed", zap.Error(err))
 	}
+	// Track refresh-token families and logouts in memory.
+	tokens := auth.NewMemoryTokenStore(10 * time.Minute)
+	defer tokens.Stop()
+	jwtSvc.SetTokenStore(tokens)
 
 	authHandler := &AuthHandler{JWT: jwtSvc}
 	handlers := &Handlers{
 		Auth: &AuthGroup{
 			Login:   &LoginHandler{Auth: authHandler},
 			Refresh: &RefreshHandler{Auth: authHandler},
+			Logout:  &LogoutHandler{Auth: authHandler},
 		},
 		Me: &MeHandler{JWT: jwtSvc},
 	}
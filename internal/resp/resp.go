@@ -0,0 +1,212 @@
+// Package resp implements the subset of the Redis serialization protocol
+// (RESP2) the framework's shared stores need, so they can keep state in a
+// Redis-compatible server without a client library dependency.
+//
+// Replies decode to int64 (integers), string (simple and bulk strings), nil
+// (null bulk strings and arrays), []any (arrays) and Error (error replies).
+package resp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// maxBulkLen bounds the bulk strings and arrays accepted from a peer.
+const maxBulkLen = 512 << 20
+
+// ErrProtocol reports a malformed message.
+var ErrProtocol = errors.New("resp: protocol error")
+
+// Error is an error reply.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Conn is a client connection. It is not safe for concurrent use.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// Dial connects to addr, over TLS when tlsConfig is non-nil.
+func Dial(addr string, timeout time.Duration, tlsConfig *tls.Config) (*Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
+
+// NewConn wraps an established connection.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Do sends one command and returns its reply, with an error reply returned
+// as an Error.
+func (c *Conn) Do(deadline time.Time, args ...string) (any, error) {
+	replies, err := c.Pipeline(deadline, args)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := replies[0].(Error); ok {
+		return nil, e
+	}
+	return replies[0], nil
+}
+
+// Pipeline sends cmds in one round trip and returns their replies in order.
+// Error replies are returned in the slice; err reports I/O and protocol
+// failures, after which the connection must not be reused.
+func (c *Conn) Pipeline(deadline time.Time, cmds ...[]string) ([]any, error) {
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		if err := WriteCommand(c.w, cmd); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]any, len(cmds))
+	for i := range cmds {
+		reply, err := ReadValue(c.r)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// WriteCommand writes args as an array of bulk strings, the form commands
+// are sent in.
+func WriteCommand(w *bufio.Writer, args []string) error {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg
+	}
+	return WriteValue(w, values)
+}
+
+// WriteValue writes v, one of the reply types, with strings sent as bulk
+// strings.
+func WriteValue(w *bufio.Writer, v any) error {
+	var err error
+	switch v := v.(type) {
+	case nil:
+		_, err = w.WriteString("$-1\r\n")
+	case int64:
+		_, err = fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		_, err = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case Error:
+		_, err = fmt.Fprintf(w, "-%s\r\n", v)
+	case []any:
+		if _, err = fmt.Fprintf(w, "*%d\r\n", len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err = WriteValue(w, item); err != nil {
+				return err
+			}
+		}
+	default:
+		err = fmt.Errorf("resp: cannot encode %T", v)
+	}
+	return err
+}
+
+// ReadValue reads one message.
+func ReadValue(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, ErrProtocol
+	}
+	payload := string(line[1:])
+	switch line[0] {
+	case '+':
+		return payload, nil
+	case '-':
+		return Error(payload), nil
+	case ':':
+		n, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := parseLen(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return nil, ErrProtocol
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := parseLen(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = ReadValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, ErrProtocol
+}
+
+// parseLen parses a bulk string or array length; -1 means null.
+func parseLen(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < -1 || n > maxBulkLen {
+		return 0, ErrProtocol
+	}
+	return n, nil
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return nil, ErrProtocol
+		}
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, ErrProtocol
+	}
+	return line[:len(line)-2], nil
+}
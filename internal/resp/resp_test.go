@@ -0,0 +1,92 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueRoundTrip(t *testing.T) {
+	values := []any{
+		"OK",
+		"",
+		"multi\r\nline",
+		int64(-42),
+		nil,
+		Error("ERR wrong type"),
+		[]any{"GET", int64(1), nil, []any{"nested"}},
+	}
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	for _, v := range values {
+		if err := WriteValue(w, v); err != nil {
+			t.Fatalf("WriteValue(%v): %v", v, err)
+		}
+	}
+	w.Flush()
+
+	r := bufio.NewReader(&buf)
+	for _, want := range values {
+		got, err := ReadValue(r)
+		if err != nil {
+			t.Fatalf("ReadValue: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadValue = %#v, want %#v", got, want)
+		}
+	}
+}
+
+func TestReadValue_SimpleStringsAndErrors(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("+PONG\r\n*-1\r\n"))
+	if got, err := ReadValue(r); err != nil || got != "PONG" {
+		t.Errorf("simple string = %#v, %v", got, err)
+	}
+	if got, err := ReadValue(r); err != nil || got != nil {
+		t.Errorf("null array = %#v, %v", got, err)
+	}
+
+	for _, input := range []string{"PONG\r\n", "+PONG\n", "$3\r\nabcd\r\n", "$-2\r\n", ":x\r\n", "?\r\n"} {
+		if _, err := ReadValue(bufio.NewReader(strings.NewReader(input))); !errors.Is(err, ErrProtocol) {
+			t.Errorf("ReadValue(%q) error = %v, want ErrProtocol", input, err)
+		}
+	}
+}
+
+func TestConn_Pipeline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		r, w := bufio.NewReader(server), bufio.NewWriter(server)
+		for i := 0; i < 2; i++ {
+			cmd, err := ReadValue(r)
+			if err != nil {
+				return
+			}
+			if args := cmd.([]any); args[0] == "INCRBY" {
+				WriteValue(w, int64(3))
+			} else {
+				WriteValue(w, Error("ERR unknown command"))
+			}
+		}
+		w.Flush()
+	}()
+
+	conn := NewConn(client)
+	replies, err := conn.Pipeline(time.Now().Add(time.Second), []string{"INCRBY", "k", "3"}, []string{"NOPE"})
+	if err != nil {
+		t.Fatalf("Pipeline: %v", err)
+	}
+	if replies[0] != int64(3) {
+		t.Errorf("INCRBY reply = %#v", replies[0])
+	}
+	if _, ok := replies[1].(Error); !ok {
+		t.Errorf("error reply = %#v, want Error", replies[1])
+	}
+}
@@ -0,0 +1,297 @@
+package testutil
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yshengliao/gortex/internal/resp"
+)
+
+// RESPServer is an in-process stand-in for a Redis server. It implements
+// the string, counter and expiry commands the framework's shared stores
+// use: PING, AUTH, SELECT, GET, MGET, SET (with PX and NX), INCRBY,
+// DECRBY, PEXPIRE, PTTL and DEL.
+type RESPServer struct {
+	// Addr is the address the server listens on
+	Addr string
+
+	ln       net.Listener
+	mu       sync.Mutex
+	data     map[string]respEntry
+	now      func() time.Time
+	password string
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type respEntry struct {
+	value   string
+	expires time.Time // zero means no expiry
+}
+
+// NewRESPServer starts a server on a loopback port, closed when the test
+// ends.
+func NewRESPServer(t testing.TB) *RESPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &RESPServer{
+		Addr:  ln.Addr().String(),
+		ln:    ln,
+		data:  make(map[string]respEntry),
+		now:   time.Now,
+		conns: make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// SetClock replaces the clock used for key expiry.
+func (s *RESPServer) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// RequirePassword makes connections AUTH with password before other
+// commands.
+func (s *RESPServer) RequirePassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// Keys returns the number of live keys.
+func (s *RESPServer) Keys() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key := range s.data {
+		if _, ok := s.lookup(key); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Close stops the server and drops its connections.
+func (s *RESPServer) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *RESPServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *RESPServer) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	authed := false
+	for {
+		msg, err := resp.ReadValue(r)
+		if err != nil {
+			return
+		}
+		items, ok := msg.([]any)
+		if !ok || len(items) == 0 {
+			return
+		}
+		args := make([]string, len(items))
+		for i, item := range items {
+			if args[i], ok = item.(string); !ok {
+				return
+			}
+		}
+		reply := s.exec(args, &authed)
+		if err := resp.WriteValue(w, reply); err != nil {
+			return
+		}
+		// Flush once the pipelined commands already received are answered.
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+var errRESPSyntax = resp.Error("ERR syntax error")
+
+func (s *RESPServer) exec(args []string, authed *bool) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cmd := strings.ToUpper(args[0])
+	args = args[1:]
+	if cmd == "AUTH" {
+		if len(args) != 1 || args[0] != s.password {
+			return resp.Error("WRONGPASS invalid password")
+		}
+		*authed = true
+		return "OK"
+	}
+	if s.password != "" && !*authed {
+		return resp.Error("NOAUTH Authentication required.")
+	}
+
+	switch cmd {
+	case "PING":
+		return "PONG"
+	case "SELECT":
+		return "OK"
+	case "GET":
+		if len(args) != 1 {
+			return errRESPSyntax
+		}
+		if e, ok := s.lookup(args[0]); ok {
+			return e.value
+		}
+		return nil
+	case "MGET":
+		values := make([]any, len(args))
+		for i, key := range args {
+			if e, ok := s.lookup(key); ok {
+				values[i] = e.value
+			}
+		}
+		return values
+	case "SET":
+		if len(args) < 2 {
+			return errRESPSyntax
+		}
+		entry := respEntry{value: args[1]}
+		nx := false
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				if i+1 == len(args) {
+					return errRESPSyntax
+				}
+				ms, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil || ms <= 0 {
+					return errRESPSyntax
+				}
+				entry.expires = s.now().Add(time.Duration(ms) * time.Millisecond)
+				i++
+			default:
+				return errRESPSyntax
+			}
+		}
+		if _, exists := s.lookup(args[0]); exists && nx {
+			return nil
+		}
+		s.data[args[0]] = entry
+		return "OK"
+	case "INCRBY", "DECRBY":
+		if len(args) != 2 {
+			return errRESPSyntax
+		}
+		delta, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return resp.Error("ERR value is not an integer or out of range")
+		}
+		if cmd == "DECRBY" {
+			delta = -delta
+		}
+		e, _ := s.lookup(args[0])
+		n := int64(0)
+		if e.value != "" {
+			if n, err = strconv.ParseInt(e.value, 10, 64); err != nil {
+				return resp.Error("ERR value is not an integer or out of range")
+			}
+		}
+		n += delta
+		e.value = strconv.FormatInt(n, 10)
+		s.data[args[0]] = e
+		return n
+	case "PEXPIRE":
+		if len(args) != 2 {
+			return errRESPSyntax
+		}
+		ms, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return resp.Error("ERR value is not an integer or out of range")
+		}
+		e, ok := s.lookup(args[0])
+		if !ok {
+			return int64(0)
+		}
+		e.expires = s.now().Add(time.Duration(ms) * time.Millisecond)
+		s.data[args[0]] = e
+		return int64(1)
+	case "PTTL":
+		if len(args) != 1 {
+			return errRESPSyntax
+		}
+		e, ok := s.lookup(args[0])
+		switch {
+		case !ok:
+			return int64(-2)
+		case e.expires.IsZero():
+			return int64(-1)
+		}
+		return e.expires.Sub(s.now()).Milliseconds()
+	case "DEL":
+		n := int64(0)
+		for _, key := range args {
+			if _, ok := s.lookup(key); ok {
+				delete(s.data, key)
+				n++
+			}
+		}
+		return n
+	}
+	return resp.Error("ERR unknown command '" + cmd + "'")
+}
+
+// lookup returns the live entry for key, evicting it if expired. The
+// caller holds s.mu.
+func (s *RESPServer) lookup(key string) (respEntry, bool) {
+	e, ok := s.data[key]
+	if ok && !e.expires.IsZero() && !s.now().Before(e.expires) {
+		delete(s.data, key)
+		return respEntry{}, false
+	}
+	return e, ok
+}
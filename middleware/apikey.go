@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/yshengliao/gortex/pkg/auth"
+	"github.com/yshengliao/gortex/pkg/auth/apikey"
+	"github.com/yshengliao/gortex/pkg/errors"
+)
+
+// apiKeyContextKey stores the verified *apikey.Key in the router context.
+const apiKeyContextKey = "api-key"
+
+// APIKeyTokenType is the auth.Claims TokenType of API key requests.
+const APIKeyTokenType = "api_key"
+
+// APIKeyConfig contains configuration for the API key middleware
+type APIKeyConfig struct {
+	// KeyStore looks up the stored keys
+	KeyStore apikey.KeyStore
+	// Header carries the key. Default "X-API-Key".
+	Header string
+	// QueryParam, when set, also accepts the key as a query parameter.
+	// Query strings end up in access logs, so prefer the header.
+	QueryParam string
+	// Scopes are required of every key
+	Scopes []string
+	// SkipPaths is a list of paths to skip authentication
+	SkipPaths []string
+	// ClaimsContextKey is the key used to store claims in context
+	ClaimsContextKey string
+}
+
+// APIKeyAuth returns a middleware that authenticates requests by API key.
+func APIKeyAuth(store apikey.KeyStore) MiddlewareFunc {
+	return APIKeyAuthWithConfig(&APIKeyConfig{KeyStore: store})
+}
+
+// APIKeyAuthWithConfig returns an API key middleware with custom
+// configuration.
+//
+// A valid key stores an *auth.Claims under ClaimsContextKey, as JWTAuth
+// does, so GetClaims, RequireRole and rbac work unchanged; its Subject is
+// the key's subject, its ID the key ID and its TokenType "api_key". The key
+// itself is available from GetAPIKey.
+func APIKeyAuthWithConfig(config *APIKeyConfig) MiddlewareFunc {
+	if config == nil {
+		panic("api key middleware: config is required")
+	}
+	if config.KeyStore == nil {
+		panic("api key middleware: KeyStore is required")
+	}
+	if config.Header == "" {
+		config.Header = "X-API-Key"
+	}
+	if config.ClaimsContextKey == "" {
+		config.ClaimsContextKey = "jwt-claims"
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+
+			// Skip if path is in skip list (same segment-boundary rule as JWTAuth).
+			for _, skip := range config.SkipPaths {
+				if req.URL.Path == skip ||
+					strings.HasPrefix(req.URL.Path, strings.TrimSuffix(skip, "/")+"/") {
+					return next(c)
+				}
+			}
+
+			token := req.Header.Get(config.Header)
+			if token == "" && config.QueryParam != "" {
+				token = req.URL.Query().Get(config.QueryParam)
+			}
+			if token == "" {
+				return apiKeyError(errors.CodeUnauthorized, "missing api key", nil)
+			}
+
+			key, err := apikey.Verify(req.Context(), config.KeyStore, token)
+			switch {
+			case stderrors.Is(err, apikey.ErrKeyExpired):
+				return apiKeyError(errors.CodeTokenExpired, "api key has expired", nil)
+			case stderrors.Is(err, apikey.ErrInvalidKey):
+				return apiKeyError(errors.CodeUnauthorized, "invalid api key", nil)
+			case err != nil:
+				return apiKeyError(errors.CodeInternalServerError, "api key lookup failed", nil)
+			}
+			if missing := missingScopes(key, config.Scopes); len(missing) > 0 {
+				return apiKeyError(errors.CodeForbidden, "api key lacks required scopes", map[string]interface{}{
+					"missing_scopes": missing,
+				})
+			}
+
+			claims := &auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject: key.Subject,
+					ID:      key.ID,
+				},
+				TokenType: APIKeyTokenType,
+				UserID:    key.Subject,
+				Username:  key.Name,
+				Role:      key.Role,
+			}
+			if !key.ExpiresAt.IsZero() {
+				claims.ExpiresAt = jwt.NewNumericDate(key.ExpiresAt)
+			}
+			c.Set(config.ClaimsContextKey, claims)
+			c.Set(apiKeyContextKey, key)
+
+			//nolint:staticcheck // SA1029: dynamic, user-configured key meant for external lookup
+			ctx := context.WithValue(req.Context(), config.ClaimsContextKey, claims)
+			if setter, ok := c.(interface{ SetRequest(*http.Request) }); ok {
+				setter.SetRequest(req.WithContext(ctx))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireScopes returns a middleware that requires the request's API key
+// to hold every scope. It runs after APIKeyAuth.
+func RequireScopes(scopes ...string) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			key := GetAPIKey(c)
+			if key == nil {
+				return apiKeyError(errors.CodeUnauthorized, "unauthorized", nil)
+			}
+			if missing := missingScopes(key, scopes); len(missing) > 0 {
+				return apiKeyError(errors.CodeForbidden, "api key lacks required scopes", map[string]interface{}{
+					"missing_scopes": missing,
+				})
+			}
+			return next(c)
+		}
+	}
+}
+
+// GetAPIKey retrieves the API key verified by APIKeyAuth
+func GetAPIKey(c Context) *apikey.Key {
+	if key, ok := c.Get(apiKeyContextKey).(*apikey.Key); ok {
+		return key
+	}
+	return nil
+}
+
+// RateLimitByAPIKey returns a key function that limits each API key
+// separately by its ID, falling back to the client IP
+func RateLimitByAPIKey() func(Context) string {
+	return func(c Context) string {
+		if key := GetAPIKey(c); key != nil {
+			return "apikey:" + key.ID
+		}
+		return c.RealIP() // fallback to IP
+	}
+}
+
+func missingScopes(key *apikey.Key, scopes []string) []string {
+	var missing []string
+	for _, scope := range scopes {
+		if !key.HasScope(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+func apiKeyError(code errors.ErrorCode, message string, details map[string]interface{}) error {
+	return &errors.ErrorResponse{
+		Success: false,
+		ErrorDetail: errors.ErrorDetail{
+			Code:    int(code),
+			Message: message,
+			Details: details,
+		},
+	}
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yshengliao/gortex/pkg/auth/apikey"
+	gortexerrors "github.com/yshengliao/gortex/pkg/errors"
+)
+
+func newAPIKey(t *testing.T, store *apikey.MemoryStore, scopes ...string) (string, *apikey.Key) {
+	t.Helper()
+	token, key, err := apikey.Generate("billing-service", scopes, time.Hour)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	key.Role = "service"
+	if err := store.Add(key); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	return token, key
+}
+
+func errorCode(t *testing.T, err error) gortexerrors.ErrorCode {
+	t.Helper()
+	resp, ok := err.(*gortexerrors.ErrorResponse)
+	if !ok {
+		t.Fatalf("error = %v, want *ErrorResponse", err)
+	}
+	return gortexerrors.ErrorCode(resp.ErrorDetail.Code)
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	store := apikey.NewMemoryStore()
+	token, key := newAPIKey(t, store, "invoices:read")
+	mw := APIKeyAuth(store)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/invoices", nil)
+	req.Header.Set("X-API-Key", token)
+	err := mw(func(c Context) error {
+		claims := GetClaims(c)
+		if claims == nil {
+			t.Fatal("no claims stored")
+		}
+		if claims.UserID != "billing-service" || claims.Subject != "billing-service" ||
+			claims.ID != key.ID || claims.Role != "service" || claims.TokenType != APIKeyTokenType {
+			t.Errorf("claims = %+v", claims)
+		}
+		if GetAPIKey(c).ID != key.ID {
+			t.Error("GetAPIKey did not return the key")
+		}
+		if c.Request().Context().Value("jwt-claims") != claims {
+			t.Error("request context does not carry the claims")
+		}
+		return c.String(http.StatusOK, "ok")
+	})(newMockContext(req, rec))
+	if err != nil {
+		t.Fatalf("valid key: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  gortexerrors.ErrorCode
+	}{
+		{"missing", "", gortexerrors.CodeUnauthorized},
+		{"malformed", "not-a-key", gortexerrors.CodeUnauthorized},
+		{"wrong secret", token + "x", gortexerrors.CodeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/invoices", nil)
+			req.Header.Set("X-API-Key", tt.token)
+			err := mw(okHandler)(newMockContext(req, httptest.NewRecorder()))
+			if got := errorCode(t, err); got != tt.want {
+				t.Errorf("code = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	expired, expiredKey := newAPIKey(t, store)
+	expiredKey.ExpiresAt = time.Now().Add(-time.Second)
+	_ = store.Add(expiredKey)
+	req = httptest.NewRequest(http.MethodGet, "/invoices", nil)
+	req.Header.Set("X-API-Key", expired)
+	if got := errorCode(t, mw(okHandler)(newMockContext(req, httptest.NewRecorder()))); got != gortexerrors.CodeTokenExpired {
+		t.Errorf("expired key code = %d", got)
+	}
+}
+
+func TestAPIKeyAuth_QueryParamAndSkipPaths(t *testing.T) {
+	store := apikey.NewMemoryStore()
+	token, _ := newAPIKey(t, store)
+
+	// The query parameter is off by default.
+	req := httptest.NewRequest(http.MethodGet, "/feed?api_key="+token, nil)
+	if err := APIKeyAuth(store)(okHandler)(newMockContext(req, httptest.NewRecorder())); err == nil {
+		t.Error("query parameter accepted without QueryParam")
+	}
+
+	mw := APIKeyAuthWithConfig(&APIKeyConfig{KeyStore: store, QueryParam: "api_key", SkipPaths: []string{"/health"}})
+	req = httptest.NewRequest(http.MethodGet, "/feed?api_key="+token, nil)
+	if err := mw(okHandler)(newMockContext(req, httptest.NewRecorder())); err != nil {
+		t.Errorf("query parameter: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/health", nil)
+	if err := mw(okHandler)(newMockContext(req, httptest.NewRecorder())); err != nil {
+		t.Errorf("skip path: %v", err)
+	}
+}
+
+func TestAPIKeyAuth_Scopes(t *testing.T) {
+	store := apikey.NewMemoryStore()
+	reader, _ := newAPIKey(t, store, "invoices:read")
+	admin, _ := newAPIKey(t, store, "*")
+
+	serve := func(mw MiddlewareFunc, token string) error {
+		req := httptest.NewRequest(http.MethodPost, "/invoices", nil)
+		req.Header.Set("X-API-Key", token)
+		return mw(okHandler)(newMockContext(req, httptest.NewRecorder()))
+	}
+
+	configured := APIKeyAuthWithConfig(&APIKeyConfig{KeyStore: store, Scopes: []string{"invoices:write"}})
+	if got := errorCode(t, serve(configured, reader)); got != gortexerrors.CodeForbidden {
+		t.Errorf("missing scope code = %d, want 403", got)
+	}
+	if err := serve(configured, admin); err != nil {
+		t.Errorf("wildcard scope: %v", err)
+	}
+
+	chained := func(next HandlerFunc) HandlerFunc {
+		return APIKeyAuth(store)(RequireScopes("invoices:write")(next))
+	}
+	if got := errorCode(t, serve(chained, reader)); got != gortexerrors.CodeForbidden {
+		t.Errorf("RequireScopes code = %d, want 403", got)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/invoices", nil)
+	if got := errorCode(t, RequireScopes("x")(okHandler)(newMockContext(req, httptest.NewRecorder()))); got != gortexerrors.CodeUnauthorized {
+		t.Errorf("RequireScopes without a key code = %d, want 401", got)
+	}
+}
+
+func TestRateLimitByAPIKey(t *testing.T) {
+	store := apikey.NewMemoryStore()
+	token, key := newAPIKey(t, store)
+	keyFunc := RateLimitByAPIKey()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", token)
+	var got string
+	_ = APIKeyAuth(store)(func(c Context) error {
+		got = keyFunc(c)
+		return nil
+	})(newMockContext(req, httptest.NewRecorder()))
+	if got != "apikey:"+key.ID {
+		t.Errorf("key = %q, want apikey:%s", got, key.ID)
+	}
+
+	c := newMockContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	if got := keyFunc(c); got != c.RealIP() {
+		t.Errorf("fallback key = %q, want the client IP", got)
+	}
+}
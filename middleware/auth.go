@@ -2,7 +2,9 @@ package middleware
 
 import (
 	"context"
+	stderrors "errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/yshengliao/gortex/pkg/auth"
@@ -27,6 +29,10 @@ type AuthConfig struct {
 	SkipPaths []string
 	// ClaimsContextKey is the key used to store claims in context
 	ClaimsContextKey string
+	// ValidateToken validates the bearer token and returns the claims to
+	// store, instead of JWTService. JWTAuthOf sets it to validate tokens
+	// with custom claims.
+	ValidateToken func(ctx context.Context, token string) (any, error)
 }
 
 // DefaultAuthConfig returns the default configuration
@@ -43,14 +49,35 @@ func JWTAuth(jwtService *auth.JWTService) MiddlewareFunc {
 	return JWTAuthWithConfig(DefaultAuthConfig(jwtService))
 }
 
+// JWTAuthOf returns a middleware that validates JWT tokens carrying custom
+// claims T. The *auth.ClaimsOf[T] is stored under "jwt-claims"; read it
+// with GetClaimsOf, or bind its fields with `bind:"name,jwt"`.
+func JWTAuthOf[T any](jwtService *auth.JWTServiceOf[T]) MiddlewareFunc {
+	if jwtService == nil {
+		panic("auth middleware: JWTService is required")
+	}
+	return JWTAuthWithConfig(&AuthConfig{
+		ClaimsContextKey: "jwt-claims",
+		ValidateToken: func(ctx context.Context, token string) (any, error) {
+			return jwtService.ValidateTokenContext(ctx, token)
+		},
+	})
+}
+
 // JWTAuthWithConfig returns a middleware with custom configuration
 func JWTAuthWithConfig(config *AuthConfig) MiddlewareFunc {
 	// Apply defaults
 	if config == nil {
 		panic("auth middleware: config is required")
 	}
-	if config.JWTService == nil {
-		panic("auth middleware: JWTService is required")
+	if config.ValidateToken == nil {
+		if config.JWTService == nil {
+			panic("auth middleware: JWTService is required")
+		}
+		jwtService := config.JWTService
+		config.ValidateToken = func(ctx context.Context, token string) (any, error) {
+			return jwtService.ValidateTokenContext(ctx, token)
+		}
 	}
 	if config.ClaimsContextKey == "" {
 		config.ClaimsContextKey = "jwt-claims"
@@ -107,8 +134,18 @@ func JWTAuthWithConfig(config *AuthConfig) MiddlewareFunc {
 				}
 			}
 
-			// Validate token
-			claims, err := config.JWTService.ValidateToken(tokenStr)
+			// Validate token. Access tokens revoked in the service's TokenStore
+			// (e.g. on logout) are rejected here too.
+			claims, err := config.ValidateToken(req.Context(), tokenStr)
+			if stderrors.Is(err, auth.ErrTokenRevoked) {
+				return &errors.ErrorResponse{
+					Success: false,
+					ErrorDetail: errors.ErrorDetail{
+						Code:    int(errors.CodeUnauthorized),
+						Message: "token has been revoked",
+					},
+				}
+			}
 			if err != nil {
 				return &errors.ErrorResponse{
 					Success: false,
@@ -162,7 +199,7 @@ func RequireRole(requiredRole string, claimsKey ...string) MiddlewareFunc {
 				}
 			}
 
-			claims, ok := claimsVal.(*auth.Claims)
+			claims, ok := claimsVal.(auth.Principal)
 			if !ok {
 				return &errors.ErrorResponse{
 					Success: false,
@@ -173,7 +210,7 @@ func RequireRole(requiredRole string, claimsKey ...string) MiddlewareFunc {
 				}
 			}
 
-			if claims.Role != requiredRole {
+			if roles := claims.PrincipalRoles(); !slices.Contains(roles, requiredRole) {
 				return &errors.ErrorResponse{
 					Success: false,
 					ErrorDetail: errors.ErrorDetail{
@@ -181,7 +218,7 @@ func RequireRole(requiredRole string, claimsKey ...string) MiddlewareFunc {
 						Message: "insufficient permissions",
 						Details: map[string]interface{}{
 							"required_role": requiredRole,
-							"user_role":     claims.Role,
+							"user_role":     strings.Join(roles, ","),
 						},
 					},
 				}
@@ -238,7 +275,8 @@ func RequireGameID(claimsKey ...string) MiddlewareFunc {
 	}
 }
 
-// GetClaims retrieves JWT claims from context
+// GetClaims retrieves JWT claims from context. Claims stored by JWTAuthOf
+// are read with GetClaimsOf or GetPrincipal instead.
 func GetClaims(c Context, claimsKey ...string) *auth.Claims {
 	key := "jwt-claims"
 	if len(claimsKey) > 0 && claimsKey[0] != "" {
@@ -251,6 +289,33 @@ func GetClaims(c Context, claimsKey ...string) *auth.Claims {
 	return nil
 }
 
+// GetClaimsOf retrieves the custom claims stored by JWTAuthOf
+func GetClaimsOf[T any](c Context, claimsKey ...string) *auth.ClaimsOf[T] {
+	key := "jwt-claims"
+	if len(claimsKey) > 0 && claimsKey[0] != "" {
+		key = claimsKey[0]
+	}
+
+	if claims, ok := c.Get(key).(*auth.ClaimsOf[T]); ok {
+		return claims
+	}
+	return nil
+}
+
+// GetPrincipal retrieves the claims stored by JWTAuth, JWTAuthOf, APIKeyAuth
+// or MTLS as an auth.Principal, or nil
+func GetPrincipal(c Context, claimsKey ...string) auth.Principal {
+	key := "jwt-claims"
+	if len(claimsKey) > 0 && claimsKey[0] != "" {
+		key = claimsKey[0]
+	}
+
+	if p, ok := c.Get(key).(auth.Principal); ok {
+		return p
+	}
+	return nil
+}
+
 // GetClaimsFromContext retrieves JWT claims from standard context
 func GetClaimsFromContext(ctx Context, claimsKey ...string) *auth.Claims {
 	key := "jwt-claims"
@@ -268,8 +333,8 @@ func GetClaimsFromContext(ctx Context, claimsKey ...string) *auth.Claims {
 
 // GetUserID retrieves user ID from JWT claims
 func GetUserID(c Context, claimsKey ...string) string {
-	if claims := GetClaims(c, claimsKey...); claims != nil {
-		return claims.UserID
+	if p := GetPrincipal(c, claimsKey...); p != nil {
+		return p.PrincipalID()
 	}
 	return ""
 }
@@ -300,6 +365,16 @@ type SessionStore interface {
 	Validate(sessionID string) (bool, error)
 }
 
+// SessionCreator is a SessionStore that can also start and end sessions,
+// as login flows such as OIDC need.
+type SessionCreator interface {
+	SessionStore
+	// Create starts a session holding data and returns its ID
+	Create(data map[string]interface{}) (string, error)
+	// Destroy ends a session
+	Destroy(sessionID string) error
+}
+
 // SessionAuth returns a middleware that validates session-based authentication
 func SessionAuth(store SessionStore) MiddlewareFunc {
 	return SessionAuthWithConfig(&SessionConfig{
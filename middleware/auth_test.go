@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/yshengliao/gortex/core/types"
 	"github.com/yshengliao/gortex/pkg/auth"
+	gortexerrors "github.com/yshengliao/gortex/pkg/errors"
 )
 
 func TestJWTAuth(t *testing.T) {
@@ -633,3 +635,91 @@ func TestJWTAuthBearerAloneRejected(t *testing.T) {
 		t.Error("'Bearer' alone should be rejected")
 	}
 }
+
+// TestJWTAuthRejectsRevokedToken verifies that access tokens revoked in the
+// service's TokenStore are rejected.
+func TestJWTAuthRejectsRevokedToken(t *testing.T) {
+	jwtService, err := auth.NewJWTService("test-secret-key-at-least-32-chars!!", 1*time.Hour, 24*time.Hour, "test-issuer")
+	if err != nil {
+		t.Fatalf("NewJWTService: %v", err)
+	}
+	store := auth.NewMemoryTokenStore(time.Minute)
+	defer store.Stop()
+	jwtService.SetTokenStore(store)
+
+	token, err := jwtService.GenerateAccessToken("u1", "user1", "u1@example.com", "user")
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	mw := JWTAuth(jwtService)
+	serve := func() error {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return mw(func(c types.Context) error {
+			return c.String(200, "OK")
+		})(newMockContext(req, httptest.NewRecorder()))
+	}
+
+	if err := serve(); err != nil {
+		t.Fatalf("token should be accepted before logout, got: %v", err)
+	}
+	if err := jwtService.Revoke(context.Background(), token); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	err = serve()
+	var resp *gortexerrors.ErrorResponse
+	if !errors.As(err, &resp) {
+		t.Fatalf("expected ErrorResponse, got %v", err)
+	}
+	if resp.ErrorDetail.Code != int(gortexerrors.CodeUnauthorized) || resp.ErrorDetail.Message != "token has been revoked" {
+		t.Errorf("unexpected error detail: %+v", resp.ErrorDetail)
+	}
+}
+
+// TestJWTAuthOf verifies that tokens with custom claims are validated and
+// their typed claims stored for GetClaimsOf.
+func TestJWTAuthOf(t *testing.T) {
+	type tenantClaims struct {
+		Tenant string   `json:"tenant"`
+		Scopes []string `json:"scopes"`
+	}
+
+	base, err := auth.NewJWTService("test-secret-key-at-least-32-chars!!", 1*time.Hour, 24*time.Hour, "test-issuer")
+	if err != nil {
+		t.Fatalf("NewJWTService: %v", err)
+	}
+	svc := auth.NewJWTServiceOf[tenantClaims](base)
+	token, err := svc.GenerateAccessToken("u1", tenantClaims{Tenant: "acme", Scopes: []string{"read"}})
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	mw := JWTAuthOf(svc)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	ctx := newMockContext(req, httptest.NewRecorder())
+
+	var claims *auth.ClaimsOf[tenantClaims]
+	err = mw(func(c types.Context) error {
+		claims = GetClaimsOf[tenantClaims](c)
+		if GetClaims(c) != nil {
+			t.Error("GetClaims should not return typed claims")
+		}
+		return c.String(200, "OK")
+	})(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claims == nil || claims.Subject != "u1" || claims.Custom.Tenant != "acme" || len(claims.Custom.Scopes) != 1 {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer invalid-token")
+	err = mw(func(c types.Context) error { return c.String(200, "OK") })(newMockContext(req, httptest.NewRecorder()))
+	if err == nil {
+		t.Error("invalid token should be rejected")
+	}
+}
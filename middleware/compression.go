@@ -4,19 +4,128 @@ import (
 	"bufio"
 	"compress/gzip"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 )
 
-// CompressionConfig configures the gzip compression wrapper. The wrapper
+// CompressionLevel is a codec-independent speed/ratio trade-off, mapped by
+// each Encoding onto its own level scale.
+type CompressionLevel int
+
+const (
+	// CompressionLevelDefault is the codec's default level
+	CompressionLevelDefault CompressionLevel = iota
+	// CompressionLevelSpeed favours throughput over ratio
+	CompressionLevelSpeed
+	// CompressionLevelBest favours ratio over throughput
+	CompressionLevelBest
+)
+
+// ParseCompressionLevel parses the level names used by
+// config.CompressionConfig: "default" (or empty), "speed" and "best".
+func ParseCompressionLevel(name string) (CompressionLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "default":
+		return CompressionLevelDefault, nil
+	case "speed":
+		return CompressionLevelSpeed, nil
+	case "best":
+		return CompressionLevelBest, nil
+	}
+	return 0, fmt.Errorf("unknown compression level %q", name)
+}
+
+// EncodingWriter is a streaming compressor that can be reused across
+// responses. *gzip.Writer satisfies it, as do the brotli and zstd writers
+// behind BrotliEncoding and ZstdEncoding.
+type EncodingWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Encoding is a content coding the compressor can negotiate.
+type Encoding struct {
+	// Name is the Accept-Encoding / Content-Encoding token, e.g. "br"
+	Name string
+	// NewWriter returns a compressor for level. Writers are pooled and
+	// Reset onto each response they compress.
+	NewWriter func(level CompressionLevel) EncodingWriter
+}
+
+// GzipEncoding returns the built-in gzip Encoding.
+func GzipEncoding() Encoding {
+	return Encoding{Name: "gzip", NewWriter: func(level CompressionLevel) EncodingWriter {
+		switch level {
+		case CompressionLevelSpeed:
+			return newGzipWriter(gzip.BestSpeed)
+		case CompressionLevelBest:
+			return newGzipWriter(gzip.BestCompression)
+		}
+		return newGzipWriter(gzip.DefaultCompression)
+	}}
+}
+
+func newGzipWriter(level int) EncodingWriter {
+	w, err := gzip.NewWriterLevel(io.Discard, level)
+	if err != nil {
+		w = gzip.NewWriter(io.Discard)
+	}
+	return w
+}
+
+// BrotliEncoding returns the built-in brotli ("br") Encoding.
+func BrotliEncoding() Encoding {
+	return Encoding{Name: "br", NewWriter: func(level CompressionLevel) EncodingWriter {
+		switch level {
+		case CompressionLevelSpeed:
+			return brotli.NewWriterLevel(io.Discard, brotli.BestSpeed)
+		case CompressionLevelBest:
+			return brotli.NewWriterLevel(io.Discard, brotli.BestCompression)
+		}
+		return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+	}}
+}
+
+// ZstdEncoding returns the built-in zstd Encoding.
+func ZstdEncoding() Encoding {
+	return Encoding{Name: "zstd", NewWriter: func(level CompressionLevel) EncodingWriter {
+		speed := zstd.SpeedDefault
+		switch level {
+		case CompressionLevelSpeed:
+			speed = zstd.SpeedFastest
+		case CompressionLevelBest:
+			speed = zstd.SpeedBestCompression
+		}
+		// A pooled writer compresses one response at a time, so it needs
+		// no goroutines of its own. These options cannot fail.
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(speed), zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+}
+
+// CompressionConfig configures the response compressor. The compressor
 // buffers writes until enough data has accumulated to apply the content
 // filter and decide whether to stream compressed output.
 type CompressionConfig struct {
-	// Level is the gzip compression level (see gzip.DefaultCompression
-	// etc.). Zero is treated as gzip.DefaultCompression.
+	// Encodings lists the codings offered, in server preference order;
+	// preference breaks ties between codings the client rates equally.
+	// Empty means gzip only; BrotliEncoding and ZstdEncoding add the other
+	// built-in codings.
+	Encodings []Encoding
+	// Quality is the compression level passed to each Encoding
+	Quality CompressionLevel
+	// Level, when non-zero, is the gzip compression level (see
+	// gzip.DefaultCompression etc.) used instead of the one Quality
+	// selects for the built-in gzip Encoding.
 	Level int
 	// MinSize is the minimum response body size in bytes before
 	// compression kicks in. Responses smaller than MinSize are written
@@ -29,11 +138,10 @@ type CompressionConfig struct {
 	ContentTypes []string
 }
 
-// DefaultCompressionConfig returns safe defaults: gzip default level, 1
-// KiB threshold and a broad allowlist of text-like content types.
+// DefaultCompressionConfig returns safe defaults: gzip at its default
+// level, 1 KiB threshold and a broad allowlist of text-like content types.
 func DefaultCompressionConfig() *CompressionConfig {
 	return &CompressionConfig{
-		Level:   gzip.DefaultCompression,
 		MinSize: 1024,
 		ContentTypes: []string{
 			"text/html",
@@ -55,65 +163,138 @@ func GzipHandler(next http.Handler) http.Handler {
 	return GzipHandlerWithConfig(DefaultCompressionConfig(), next)
 }
 
-// GzipHandlerWithConfig wraps next using the supplied configuration.
+// GzipHandlerWithConfig wraps next using the supplied configuration,
+// offering gzip only whatever config.Encodings lists.
 func GzipHandlerWithConfig(config *CompressionConfig, next http.Handler) http.Handler {
 	if config == nil {
 		config = DefaultCompressionConfig()
 	}
-	level := config.Level
-	if level == 0 {
-		level = gzip.DefaultCompression
+	gzipOnly := *config
+	gzipOnly.Encodings = nil
+	return CompressHandlerWithConfig(&gzipOnly, next)
+}
+
+// CompressHandler wraps next with a gzip compressor using the default
+// configuration.
+func CompressHandler(next http.Handler) http.Handler {
+	return CompressHandlerWithConfig(DefaultCompressionConfig(), next)
+}
+
+// CompressHandlerWithConfig wraps next with a compressor that negotiates
+// one of config.Encodings from the request's Accept-Encoding q-values.
+// Responses that already carry a Content-Encoding, partial content and
+// responses outside the size and content-type limits are sent as-is.
+func CompressHandlerWithConfig(config *CompressionConfig, next http.Handler) http.Handler {
+	if config == nil {
+		config = DefaultCompressionConfig()
 	}
-	pool := &sync.Pool{
-		New: func() any {
-			w, _ := gzip.NewWriterLevel(io.Discard, level)
-			return w
-		},
+	encodings := config.Encodings
+	if len(encodings) == 0 {
+		encodings = []Encoding{GzipEncoding()}
+	}
+	pools := make([]*sync.Pool, len(encodings))
+	for i, enc := range encodings {
+		if enc.Name == "" || enc.NewWriter == nil {
+			panic("compression middleware: encodings need a name and a writer")
+		}
+		newWriter := enc.NewWriter
+		if strings.EqualFold(enc.Name, "gzip") && config.Level != 0 {
+			level := config.Level
+			newWriter = func(CompressionLevel) EncodingWriter { return newGzipWriter(level) }
+		}
+		pools[i] = &sync.Pool{New: func() any { return newWriter(config.Quality) }}
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !clientAcceptsGzip(r.Header.Get("Accept-Encoding")) {
+		header := r.Header.Get("Accept-Encoding")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		i := negotiateEncoding(header, encodings)
+		if i < 0 {
+			// The client refused every coding on offer; the response still
+			// depended on the header.
+			addVary(w.Header(), "Accept-Encoding")
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		gz := pool.Get().(*gzip.Writer)
-		gzw := &gzipResponseWriter{
+		cw := &compressResponseWriter{
 			ResponseWriter: w,
-			gz:             gz,
+			encoding:       encodings[i].Name,
+			pool:           pools[i],
 			minSize:        config.MinSize,
 			contentTypes:   config.ContentTypes,
 			status:         http.StatusOK,
 		}
-		defer func() {
-			gzw.close()
-			gz.Reset(io.Discard)
-			pool.Put(gz)
-		}()
+		defer cw.close()
 
-		next.ServeHTTP(gzw, r)
+		next.ServeHTTP(cw, r)
 	})
 }
 
-func clientAcceptsGzip(header string) bool {
-	if header == "" {
-		return false
-	}
+// negotiateEncoding returns the index of the coding in encodings the
+// Accept-Encoding header rates highest, ties going to the earlier entry,
+// or -1 when none is acceptable or the client rates identity higher.
+// Codings the header does not name take the q-value of "*", if present.
+func negotiateEncoding(header string, encodings []Encoding) int {
+	ratings := make(map[string]float64)
 	for _, part := range strings.Split(header, ",") {
-		token := strings.TrimSpace(part)
-		if idx := strings.IndexByte(token, ';'); idx >= 0 {
-			token = token[:idx]
+		token, params, _ := strings.Cut(part, ";")
+		token = strings.ToLower(strings.TrimSpace(token))
+		if token == "" {
+			continue
 		}
-		if strings.EqualFold(token, "gzip") {
-			return true
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(param, "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "q") {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
 		}
+		ratings[token] = q
 	}
-	return false
+
+	best, bestQ := -1, 0.0
+	for i, enc := range encodings {
+		q, ok := ratings[strings.ToLower(enc.Name)]
+		if !ok {
+			q = ratings["*"]
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	if identity, ok := ratings["identity"]; ok && identity > bestQ {
+		return -1
+	}
+	return best
+}
+
+// addVary adds value to the Vary header unless it is already listed.
+func addVary(h http.Header, value string) {
+	for _, line := range h.Values("Vary") {
+		for _, token := range strings.Split(line, ",") {
+			token = strings.TrimSpace(token)
+			if token == "*" || strings.EqualFold(token, value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
 }
 
-type gzipResponseWriter struct {
+type compressResponseWriter struct {
 	http.ResponseWriter
-	gz           *gzip.Writer
+	encoding     string
+	pool         *sync.Pool
+	cw           EncodingWriter
 	minSize      int
 	contentTypes []string
 
@@ -124,7 +305,7 @@ type gzipResponseWriter struct {
 	passthrough bool
 }
 
-func (w *gzipResponseWriter) WriteHeader(status int) {
+func (w *compressResponseWriter) WriteHeader(status int) {
 	if w.wroteHeader {
 		return
 	}
@@ -134,7 +315,7 @@ func (w *gzipResponseWriter) WriteHeader(status int) {
 	// to compress based on Content-Type and accumulated size.
 }
 
-func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
 	if !w.wroteHeader {
 		w.WriteHeader(http.StatusOK)
 	}
@@ -142,44 +323,63 @@ func (w *gzipResponseWriter) Write(p []byte) (int, error) {
 		return w.ResponseWriter.Write(p)
 	}
 	if w.compressing {
-		return w.gz.Write(p)
+		return w.cw.Write(p)
 	}
 
 	w.buf = append(w.buf, p...)
 	if len(w.buf) < w.minSize {
 		return len(p), nil
 	}
+	if err := w.commit(w.shouldCompress()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
 
-	if !w.shouldCompress() || w.Header().Get("Content-Encoding") != "" {
-		// Either the content type is not eligible for compression, or the
-		// handler already set a Content-Encoding (e.g. a pre-compressed asset)
-		// — pass through without re-encoding. We still emit Vary so that
-		// shared caches know this response was subject to content negotiation.
+// commit ends buffering: it writes the header and the buffered body,
+// compressed or as-is. Either way Vary is emitted so that shared caches
+// know this response was subject to content negotiation.
+func (w *compressResponseWriter) commit(compress bool) error {
+	buf := w.buf
+	w.buf = nil
+	h := w.Header()
+	addVary(h, "Accept-Encoding")
+
+	if !compress {
 		w.passthrough = true
-		w.Header().Add("Vary", "Accept-Encoding")
 		w.ResponseWriter.WriteHeader(w.status)
-		if _, err := w.ResponseWriter.Write(w.buf); err != nil {
-			w.buf = nil
-			return 0, err
+		if len(buf) == 0 {
+			return nil
 		}
-		w.buf = nil
-		return len(p), nil
+		_, err := w.ResponseWriter.Write(buf)
+		return err
 	}
 
-	w.startCompressed()
-	if _, err := w.gz.Write(w.buf); err != nil {
-		w.buf = nil
-		return 0, err
-	}
-	w.buf = nil
-	return len(p), nil
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+	w.cw = w.pool.Get().(EncodingWriter)
+	w.cw.Reset(w.ResponseWriter)
+	w.ResponseWriter.WriteHeader(w.status)
+	w.compressing = true
+	_, err := w.cw.Write(buf)
+	return err
 }
 
-func (w *gzipResponseWriter) shouldCompress() bool {
+// shouldCompress reports whether the buffered response may be encoded.
+func (w *compressResponseWriter) shouldCompress() bool {
+	h := w.Header()
+	// A handler that set its own Content-Encoding (e.g. a pre-compressed
+	// asset) must not be encoded twice, and byte ranges refer to the
+	// unencoded representation.
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" ||
+		w.status == http.StatusPartialContent || w.status == http.StatusNoContent ||
+		w.status == http.StatusNotModified || w.status < http.StatusOK {
+		return false
+	}
 	if len(w.contentTypes) == 0 {
 		return true
 	}
-	ct := w.Header().Get("Content-Type")
+	ct := h.Get("Content-Type")
 	if ct == "" {
 		return false
 	}
@@ -195,41 +395,37 @@ func (w *gzipResponseWriter) shouldCompress() bool {
 	return false
 }
 
-func (w *gzipResponseWriter) startCompressed() {
-	h := w.Header()
-	h.Set("Content-Encoding", "gzip")
-	h.Del("Content-Length")
-	h.Add("Vary", "Accept-Encoding")
-	w.gz.Reset(w.ResponseWriter)
-	w.ResponseWriter.WriteHeader(w.status)
-	w.compressing = true
-}
-
-func (w *gzipResponseWriter) close() {
+func (w *compressResponseWriter) close() {
 	if !w.wroteHeader {
 		return
 	}
 	if w.compressing {
-		_ = w.gz.Close()
+		_ = w.cw.Close()
+		w.cw.Reset(io.Discard)
+		w.pool.Put(w.cw)
+		w.cw = nil
 		return
 	}
 	if w.passthrough {
 		return
 	}
-	// Buffered body shorter than MinSize: flush as-is without
-	// Content-Encoding. Still emit Vary because the middleware was active
-	// for this request and could have compressed a larger response.
-	w.Header().Add("Vary", "Accept-Encoding")
-	w.ResponseWriter.WriteHeader(w.status)
-	if len(w.buf) > 0 {
-		_, _ = w.ResponseWriter.Write(w.buf)
-		w.buf = nil
-	}
+	// Buffered body shorter than MinSize: write it as-is.
+	_ = w.commit(false)
 }
 
-func (w *gzipResponseWriter) Flush() {
+// Flush sends what has been written so far. A response still being
+// buffered is committed first, compressed if eligible whatever its size,
+// so streaming handlers (e.g. server-sent events) are not held back by
+// MinSize.
+func (w *compressResponseWriter) Flush() {
+	if !w.compressing && !w.passthrough {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		_ = w.commit(w.shouldCompress())
+	}
 	if w.compressing {
-		_ = w.gz.Flush()
+		_ = w.cw.Flush()
 	}
 	if f, ok := w.ResponseWriter.(http.Flusher); ok {
 		f.Flush()
@@ -239,9 +435,9 @@ func (w *gzipResponseWriter) Flush() {
 // Hijack is forwarded when the underlying writer supports it. Hijacking
 // is incompatible with active compression, so it is refused once we've
 // begun streaming compressed output.
-func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if w.compressing {
-		return nil, nil, errors.New("gzip response writer: cannot hijack after compression started")
+		return nil, nil, errors.New("compress response writer: cannot hijack after compression started")
 	}
 	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
 		return h.Hijack()
@@ -2,12 +2,15 @@ package middleware
 
 import (
 	"compress/gzip"
+	"compress/zlib"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -231,3 +234,208 @@ func TestCompressionVaryNotDuplicated(t *testing.T) {
 	}
 	assert.Equal(t, 1, count, "Vary: Accept-Encoding must appear exactly once")
 }
+
+// deflateEncoding is a "deflate" (zlib) Encoding standing in for the
+// brotli and zstd codecs applications plug in.
+func deflateEncoding() Encoding {
+	return Encoding{Name: "deflate", NewWriter: func(CompressionLevel) EncodingWriter {
+		return zlib.NewWriter(io.Discard)
+	}}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	encodings := []Encoding{GzipEncoding(), deflateEncoding()}
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{"gzip", 0},
+		{"deflate", 1},
+		{"gzip, deflate", 0},             // tie: server preference
+		{"deflate, gzip", 0},             // header order does not matter
+		{"gzip;q=0.5, deflate", 1},       // highest q wins
+		{"GZIP;Q=0.8, deflate;q=0.2", 0}, // case-insensitive
+		{"gzip;q=0", -1},                 // q=0 is a refusal
+		{"*", 0},                         // wildcard covers every coding
+		{"*;q=0.5, gzip;q=0", 1},         // explicit refusal beats wildcard
+		{"br, zstd", -1},                 // nothing on offer
+		{"identity", -1},
+		{"gzip;q=0.5, identity", -1},     // identity rated higher
+		{"gzip;q=abc, deflate;q=0.1", 1}, // malformed q counts as 0
+		{" gzip ; q=1.0 , deflate;q=1", 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, negotiateEncoding(tt.header, encodings), tt.header)
+	}
+}
+
+func TestParseCompressionLevel(t *testing.T) {
+	for name, want := range map[string]CompressionLevel{
+		"":        CompressionLevelDefault,
+		"default": CompressionLevelDefault,
+		"speed":   CompressionLevelSpeed,
+		"Best":    CompressionLevelBest,
+	} {
+		got, err := ParseCompressionLevel(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+	_, err := ParseCompressionLevel("fastest")
+	assert.Error(t, err)
+}
+
+// TestCompressHandlerSelectsEncoding verifies that the negotiated coding is
+// the one applied to the body.
+func TestCompressHandlerSelectsEncoding(t *testing.T) {
+	body := strings.Repeat("negotiate me ", 200)
+	config := DefaultCompressionConfig()
+	config.Encodings = []Encoding{GzipEncoding(), deflateEncoding()}
+	plain := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, body)
+	})
+	handler := CompressHandlerWithConfig(config, plain)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0.5, deflate")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "deflate", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	zr, err := zlib.NewReader(rec.Body)
+	require.NoError(t, err)
+	got, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(got))
+
+	// GzipHandlerWithConfig ignores the extra codings.
+	rec = httptest.NewRecorder()
+	GzipHandlerWithConfig(config, plain).ServeHTTP(rec, req)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+}
+
+// TestCompressHandlerBuiltinEncodings verifies that brotli and zstd bodies
+// decode to the original content, through pooled writers reused across
+// responses.
+func TestCompressHandlerBuiltinEncodings(t *testing.T) {
+	body := strings.Repeat("built-in codings ", 200)
+	config := DefaultCompressionConfig()
+	config.Encodings = []Encoding{BrotliEncoding(), ZstdEncoding(), GzipEncoding()}
+	handler := CompressHandlerWithConfig(config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, body)
+	}))
+
+	decoders := map[string]func(io.Reader) ([]byte, error){
+		"br": func(r io.Reader) ([]byte, error) { return io.ReadAll(brotli.NewReader(r)) },
+		"zstd": func(r io.Reader) ([]byte, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			defer zr.Close()
+			return io.ReadAll(zr)
+		},
+	}
+	for i := 0; i < 2; i++ {
+		for name, decode := range decoders {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", name)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, name, rec.Header().Get("Content-Encoding"))
+			got, err := decode(rec.Body)
+			require.NoError(t, err, name)
+			assert.Equal(t, body, string(got), name)
+		}
+	}
+}
+
+// TestCompressionRefusedEncodingSetsVary verifies that a client refusing
+// every coding gets the plain body, with Vary because the header was
+// consulted.
+func TestCompressionRefusedEncodingSetsVary(t *testing.T) {
+	body := strings.Repeat("refused ", 200)
+	handler := GzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, body)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0, identity")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Equal(t, body, rec.Body.String())
+}
+
+// TestCompressionSkipsPartialContent verifies that range responses are
+// not encoded, since Content-Range refers to the unencoded bytes.
+func TestCompressionSkipsPartialContent(t *testing.T) {
+	body := strings.Repeat("0123456789", 200)
+	handler := GzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Range", "bytes 0-1999/4000")
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.WriteString(w, body)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newGzipRequest("/"))
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, rec.Body.String())
+}
+
+// TestCompressionFlushCommitsBufferedResponse verifies that Flush sends a
+// response still below MinSize instead of holding it back, so streaming
+// handlers work behind the compressor.
+func TestCompressionFlushCommitsBufferedResponse(t *testing.T) {
+	handler := GzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "first event\n")
+		w.(http.Flusher).Flush()
+
+		rec := w.(*compressResponseWriter).ResponseWriter.(*httptest.ResponseRecorder)
+		assert.True(t, rec.Flushed)
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.NotZero(t, rec.Body.Len(), "flushed data must reach the client")
+
+		_, _ = io.WriteString(w, "second event\n")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newGzipRequest("/"))
+
+	assert.Equal(t, "first event\nsecond event\n", string(decompressGzip(t, rec.Body)))
+}
+
+// TestCompressionHijackBeforeWrite verifies that Hijack is passed through
+// to the underlying writer while nothing has been compressed.
+func TestCompressionHijackBeforeWrite(t *testing.T) {
+	srv := httptest.NewServer(GzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		_ = buf.Flush()
+	})))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := (&http.Client{Transport: &http.Transport{DisableCompression: true}}).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hijacked", string(got))
+}
@@ -0,0 +1,339 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yshengliao/gortex/pkg/errors"
+)
+
+// ConcurrencyAlgorithm selects how a ConcurrencyLimiter adapts its limit.
+type ConcurrencyAlgorithm int
+
+const (
+	// ConcurrencyGradient compares the average latency of every ten
+	// requests with the long-term average: the limit grows while latency
+	// holds and shrinks in proportion as it rises. It needs no latency
+	// target.
+	ConcurrencyGradient ConcurrencyAlgorithm = iota
+	// ConcurrencyAIMD grows the limit by one per request faster than
+	// LatencyThreshold and cuts it by BackoffRatio per slower request.
+	ConcurrencyAIMD
+)
+
+// String returns the algorithm's name as used by the concurrency tag.
+func (a ConcurrencyAlgorithm) String() string {
+	switch a {
+	case ConcurrencyGradient:
+		return "gradient"
+	case ConcurrencyAIMD:
+		return "aimd"
+	}
+	return "unknown"
+}
+
+// Priority classifies requests for load shedding.
+type Priority int
+
+const (
+	// PriorityNormal requests are admitted while in-flight requests are
+	// below the limit.
+	PriorityNormal Priority = iota
+	// PriorityLow requests are shed first: they are admitted while
+	// in-flight requests are below LowPriorityShare of the limit.
+	PriorityLow
+	// PriorityCritical requests, such as health checks, are never shed and
+	// are not counted.
+	PriorityCritical
+)
+
+// Gradient algorithm constants, after Netflix's concurrency-limits.
+const (
+	// concurrencySampleWindow is the number of requests whose average
+	// latency is compared with the long-term average.
+	concurrencySampleWindow = 10
+	// concurrencyLongWindow is the number of sample windows the long-term
+	// average spans, so a sustained slowdown keeps the limit down for a
+	// while before it is taken as the new normal.
+	concurrencyLongWindow = 60
+	// concurrencySmoothing is how much of each new estimate is applied.
+	concurrencySmoothing = 0.2
+)
+
+// ConcurrencyLimiterOptions configures a ConcurrencyLimiter.
+type ConcurrencyLimiterOptions struct {
+	// Name identifies the limiter in its stats, e.g. the route it guards
+	Name string
+	// Algorithm adapts the limit. Defaults to ConcurrencyGradient.
+	Algorithm ConcurrencyAlgorithm
+	// InitialLimit is the limit before any latency is observed. Defaults
+	// to 20.
+	InitialLimit int
+	// MinLimit is the lowest the limit goes. Defaults to 1.
+	MinLimit int
+	// MaxLimit is the highest the limit goes. Defaults to 1000.
+	MaxLimit int
+	// Tolerance is how far latency may rise over the long-term average
+	// before the gradient algorithm shrinks the limit. Defaults to 1.5.
+	Tolerance float64
+	// LatencyThreshold is the latency above which the AIMD algorithm cuts
+	// the limit. Defaults to one second.
+	LatencyThreshold time.Duration
+	// BackoffRatio is what the AIMD algorithm multiplies the limit by on a
+	// slow request. Defaults to 0.9.
+	BackoffRatio float64
+	// LowPriorityShare is the fraction of the limit PriorityLow requests
+	// may fill. Defaults to 0.5.
+	LowPriorityShare float64
+}
+
+// ConcurrencyStats is a snapshot of a ConcurrencyLimiter.
+type ConcurrencyStats struct {
+	Name      string  `json:"name"`
+	Algorithm string  `json:"algorithm"`
+	Limit     int     `json:"limit"`
+	InFlight  int     `json:"in_flight"`
+	LatencyMS float64 `json:"latency_ms"`
+	Admitted  uint64  `json:"admitted"`
+	Shed      uint64  `json:"shed"`
+}
+
+// ConcurrencyLimiter caps the requests in flight at once and adapts the cap
+// to the latency it observes, so that when a downstream slows down requests
+// are shed instead of piling up. Unlike a rate limiter it needs no notion of
+// how many requests per second the service can take.
+type ConcurrencyLimiter struct {
+	mu       sync.Mutex
+	opts     ConcurrencyLimiterOptions
+	limit    float64
+	inFlight int
+	latency  float64 // long-term average, in nanoseconds
+	windows  int     // sample windows seen, up to concurrencyLongWindow
+	admitted uint64
+	shed     uint64
+	now      func() time.Time
+
+	// The current sample window.
+	sampleSum      float64
+	sampleCount    int
+	sampleInFlight int // most requests in flight
+}
+
+// NewConcurrencyLimiter creates a ConcurrencyLimiter with the given options.
+func NewConcurrencyLimiter(opts ConcurrencyLimiterOptions) *ConcurrencyLimiter {
+	if opts.MinLimit <= 0 {
+		opts.MinLimit = 1
+	}
+	if opts.MaxLimit <= 0 {
+		opts.MaxLimit = 1000
+	}
+	if opts.MaxLimit < opts.MinLimit {
+		opts.MaxLimit = opts.MinLimit
+	}
+	if opts.InitialLimit <= 0 {
+		opts.InitialLimit = 20
+	}
+	opts.InitialLimit = min(max(opts.InitialLimit, opts.MinLimit), opts.MaxLimit)
+	if opts.Tolerance < 1 {
+		opts.Tolerance = 1.5
+	}
+	if opts.LatencyThreshold <= 0 {
+		opts.LatencyThreshold = time.Second
+	}
+	if opts.BackoffRatio <= 0 || opts.BackoffRatio >= 1 {
+		opts.BackoffRatio = 0.9
+	}
+	if opts.LowPriorityShare <= 0 || opts.LowPriorityShare > 1 {
+		opts.LowPriorityShare = 0.5
+	}
+	return &ConcurrencyLimiter{
+		opts:  opts,
+		limit: float64(opts.InitialLimit),
+		now:   time.Now,
+	}
+}
+
+// Acquire admits a request of priority p, or reports that it should be
+// shed. An admitted request must call release once it completes; its
+// latency adapts the limit. Calling release more than once is harmless.
+func (l *ConcurrencyLimiter) Acquire(p Priority) (release func(), ok bool) {
+	if p == PriorityCritical {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	capacity := int(l.limit)
+	if p == PriorityLow {
+		capacity = max(int(l.limit*l.opts.LowPriorityShare), 1)
+	}
+	if l.inFlight >= capacity {
+		l.shed++
+		l.mu.Unlock()
+		return nil, false
+	}
+	l.inFlight++
+	l.admitted++
+	start := l.now()
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { l.release(l.now().Sub(start)) })
+	}, true
+}
+
+// release ends a request that took latency and adapts the limit.
+func (l *ConcurrencyLimiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inFlight := l.inFlight
+	l.inFlight--
+
+	if l.opts.Algorithm == ConcurrencyAIMD {
+		if latency > l.opts.LatencyThreshold {
+			l.limit *= l.opts.BackoffRatio
+		} else if float64(inFlight)*2 >= l.limit {
+			l.limit++
+		}
+		l.clampLimit()
+	}
+
+	l.sampleSum += float64(latency)
+	l.sampleCount++
+	l.sampleInFlight = max(l.sampleInFlight, inFlight)
+	if l.sampleCount < concurrencySampleWindow {
+		return
+	}
+	sample := l.sampleSum / float64(l.sampleCount)
+	appLimited := float64(l.sampleInFlight)*2 < l.limit
+	l.sampleSum, l.sampleCount, l.sampleInFlight = 0, 0, 0
+
+	if l.windows < concurrencyLongWindow {
+		l.windows++
+		l.latency += (sample - l.latency) / float64(l.windows)
+	} else {
+		l.latency += (sample - l.latency) * 2 / (concurrencyLongWindow + 1)
+	}
+	// Let the average recover quickly once latency drops for good.
+	if l.latency > 2*sample {
+		l.latency *= 0.95
+	}
+
+	// While fewer than half the slots are used the latency says nothing
+	// about the limit.
+	if l.opts.Algorithm != ConcurrencyGradient || appLimited {
+		return
+	}
+	gradient := math.Max(0.5, math.Min(1, l.opts.Tolerance*l.latency/math.Max(sample, 1)))
+	estimate := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = l.limit*(1-concurrencySmoothing) + estimate*concurrencySmoothing
+	l.clampLimit()
+}
+
+// clampLimit keeps the limit within MinLimit and MaxLimit.
+func (l *ConcurrencyLimiter) clampLimit() {
+	l.limit = math.Min(math.Max(l.limit, float64(l.opts.MinLimit)), float64(l.opts.MaxLimit))
+}
+
+// Limit returns the current limit.
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Stats returns a snapshot of the limiter's state.
+func (l *ConcurrencyLimiter) Stats() ConcurrencyStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return ConcurrencyStats{
+		Name:      l.opts.Name,
+		Algorithm: l.opts.Algorithm.String(),
+		Limit:     int(l.limit),
+		InFlight:  l.inFlight,
+		LatencyMS: l.latency / float64(time.Millisecond),
+		Admitted:  l.admitted,
+		Shed:      l.shed,
+	}
+}
+
+// ConcurrencyLimitConfig contains configuration for the ConcurrencyLimit
+// middleware.
+type ConcurrencyLimitConfig struct {
+	// Limiter is shared by every route the middleware wraps. When nil, one
+	// is created with the default options and written back here.
+	Limiter *ConcurrencyLimiter
+	// Priority classifies each request. Defaults to PriorityCritical for
+	// CriticalPaths and PriorityNormal for everything else.
+	Priority func(c Context) Priority
+	// CriticalPaths are never shed. Defaults to /health, /healthz, /livez,
+	// /readyz and /admin; only used by the default Priority.
+	CriticalPaths []string
+	// RetryAfter is sent in the Retry-After header of shed requests.
+	// Defaults to one second.
+	RetryAfter time.Duration
+}
+
+// ConcurrencyLimit returns a load-shedding middleware with a gradient
+// ConcurrencyLimiter and the default critical paths.
+func ConcurrencyLimit() MiddlewareFunc {
+	return ConcurrencyLimitWithConfig(&ConcurrencyLimitConfig{})
+}
+
+// ConcurrencyLimitWithConfig returns a load-shedding middleware with custom
+// configuration. Requests beyond the limiter's limit get a 503 with
+// Retry-After.
+func ConcurrencyLimitWithConfig(config *ConcurrencyLimitConfig) MiddlewareFunc {
+	if config == nil {
+		panic("concurrency limit middleware: config is required")
+	}
+	if config.Limiter == nil {
+		config.Limiter = NewConcurrencyLimiter(ConcurrencyLimiterOptions{})
+	}
+	if config.CriticalPaths == nil {
+		config.CriticalPaths = []string{"/health", "/healthz", "/livez", "/readyz", "/admin"}
+	}
+	if config.Priority == nil {
+		paths := config.CriticalPaths
+		config.Priority = func(c Context) Priority {
+			// Same segment-boundary rule as JWTAuth's SkipPaths.
+			path := c.Request().URL.Path
+			for _, critical := range paths {
+				if path == critical ||
+					strings.HasPrefix(path, strings.TrimSuffix(critical, "/")+"/") {
+					return PriorityCritical
+				}
+			}
+			return PriorityNormal
+		}
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = time.Second
+	}
+	retryAfter := strconv.FormatInt(int64(math.Ceil(config.RetryAfter.Seconds())), 10)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			release, ok := config.Limiter.Acquire(config.Priority(c))
+			if !ok {
+				c.Response().Header().Set(HeaderRetryAfter, retryAfter)
+				return &errors.ErrorResponse{
+					Success: false,
+					ErrorDetail: errors.ErrorDetail{
+						Code:    int(errors.CodeServiceUnavailable),
+						Message: "server is overloaded",
+						Details: map[string]interface{}{
+							"limit": config.Limiter.Limit(),
+						},
+					},
+				}
+			}
+			defer release()
+			return next(c)
+		}
+	}
+}
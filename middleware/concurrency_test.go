@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gortexerrors "github.com/yshengliao/gortex/pkg/errors"
+)
+
+// runRounds fills the limiter, lets latency pass and releases every request,
+// rounds times.
+func runRounds(t *testing.T, l *ConcurrencyLimiter, clock *fakeClock, rounds int, latency time.Duration) {
+	t.Helper()
+	for i := 0; i < rounds; i++ {
+		var releases []func()
+		for {
+			release, ok := l.Acquire(PriorityNormal)
+			if !ok {
+				break
+			}
+			releases = append(releases, release)
+		}
+		clock.Advance(latency)
+		for _, release := range releases {
+			release()
+		}
+	}
+}
+
+func TestConcurrencyLimiter_Priorities(t *testing.T) {
+	l := NewConcurrencyLimiter(ConcurrencyLimiterOptions{Name: "/api", InitialLimit: 4})
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, ok := l.Acquire(PriorityLow)
+		if !ok {
+			t.Fatalf("low priority request %d shed", i)
+		}
+		releases = append(releases, release)
+	}
+	if _, ok := l.Acquire(PriorityLow); ok {
+		t.Error("low priority request admitted beyond half the limit")
+	}
+	for i := 0; i < 2; i++ {
+		release, ok := l.Acquire(PriorityNormal)
+		if !ok {
+			t.Fatalf("normal request %d shed", i)
+		}
+		releases = append(releases, release)
+	}
+	if _, ok := l.Acquire(PriorityNormal); ok {
+		t.Error("normal request admitted beyond the limit")
+	}
+	if release, ok := l.Acquire(PriorityCritical); !ok {
+		t.Error("critical request shed")
+	} else {
+		release()
+	}
+
+	stats := l.Stats()
+	if stats.Name != "/api" || stats.Algorithm != "gradient" || stats.InFlight != 4 ||
+		stats.Admitted != 4 || stats.Shed != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	releases[0]()
+	releases[0]() // a second release is ignored
+	if got := l.Stats().InFlight; got != 3 {
+		t.Errorf("InFlight = %d after one release, want 3", got)
+	}
+	if _, ok := l.Acquire(PriorityNormal); !ok {
+		t.Error("request shed after a slot was released")
+	}
+}
+
+func TestConcurrencyLimiter_AIMD(t *testing.T) {
+	clock := newFakeClock()
+	l := NewConcurrencyLimiter(ConcurrencyLimiterOptions{
+		Algorithm:        ConcurrencyAIMD,
+		InitialLimit:     10,
+		MaxLimit:         15,
+		LatencyThreshold: 100 * time.Millisecond,
+	})
+	l.now = clock.Now
+
+	runRounds(t, l, clock, 1, 10*time.Millisecond)
+	if got := l.Limit(); got <= 10 {
+		t.Fatalf("Limit() = %d after fast requests, want above 10", got)
+	}
+	runRounds(t, l, clock, 5, 10*time.Millisecond)
+	if got := l.Limit(); got != 15 {
+		t.Fatalf("Limit() = %d, want MaxLimit 15", got)
+	}
+
+	release, _ := l.Acquire(PriorityNormal)
+	clock.Advance(200 * time.Millisecond)
+	release()
+	if got := l.Limit(); got != 13 {
+		t.Errorf("Limit() = %d after a slow request, want 13", got)
+	}
+}
+
+func TestConcurrencyLimiter_Gradient(t *testing.T) {
+	clock := newFakeClock()
+	l := NewConcurrencyLimiter(ConcurrencyLimiterOptions{InitialLimit: 10, MinLimit: 2})
+	l.now = clock.Now
+
+	runRounds(t, l, clock, 20, 10*time.Millisecond)
+	grown := l.Limit()
+	if grown <= 10 {
+		t.Fatalf("Limit() = %d while latency holds, want above 10", grown)
+	}
+
+	// A downstream slows down: the limit shrinks towards MinLimit.
+	runRounds(t, l, clock, 10, 200*time.Millisecond)
+	if got := l.Limit(); got >= grown/2 {
+		t.Errorf("Limit() = %d after latency rose, want below %d", got, grown/2)
+	}
+	if got := l.Limit(); got < 2 {
+		t.Errorf("Limit() = %d, below MinLimit", got)
+	}
+	if stats := l.Stats(); stats.LatencyMS <= 10 {
+		t.Errorf("LatencyMS = %v, want the rise reflected", stats.LatencyMS)
+	}
+}
+
+func TestConcurrencyLimit_ShedsWith503(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyLimiterOptions{InitialLimit: 1, MaxLimit: 1})
+	mw := ConcurrencyLimitWithConfig(&ConcurrencyLimitConfig{Limiter: limiter, RetryAfter: 1500 * time.Millisecond})
+	handler := mw(okHandler)
+
+	serve := func(path string) (*httptest.ResponseRecorder, error) {
+		rec := httptest.NewRecorder()
+		err := handler(newMockContext(httptest.NewRequest(http.MethodGet, path, nil), rec))
+		return rec, err
+	}
+
+	if _, err := serve("/api/orders"); err != nil {
+		t.Fatalf("request under the limit: %v", err)
+	}
+
+	release, _ := limiter.Acquire(PriorityNormal)
+	rec, err := serve("/api/orders")
+	if code := errorCode(t, err); code != gortexerrors.CodeServiceUnavailable {
+		t.Errorf("code = %v, want CodeServiceUnavailable", code)
+	}
+	if got := rec.Header().Get(HeaderRetryAfter); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+
+	// Health checks and admin requests are never shed.
+	for _, path := range []string{"/healthz", "/admin/users"} {
+		if _, err := serve(path); err != nil {
+			t.Errorf("%s shed: %v", path, err)
+		}
+	}
+	if _, err := serve("/administrator"); err == nil {
+		t.Error("/administrator treated as a critical path")
+	}
+
+	release()
+	if _, err := serve("/api/orders"); err != nil {
+		t.Errorf("request after release: %v", err)
+	}
+}
+
+func TestConcurrencyLimit_ReleasesOnPanic(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyLimiterOptions{InitialLimit: 1})
+	handler := ConcurrencyLimitWithConfig(&ConcurrencyLimitConfig{Limiter: limiter})(func(c Context) error {
+		panic("boom")
+	})
+
+	func() {
+		defer func() { recover() }()
+		handler(newMockContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder()))
+	}()
+	if got := limiter.Stats().InFlight; got != 0 {
+		t.Errorf("InFlight = %d after a panic, want 0", got)
+	}
+}
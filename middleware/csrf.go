@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	httpctx "github.com/yshengliao/gortex/transport/http"
@@ -25,6 +26,14 @@ var ErrCSRFTokenMismatch = httpctx.NewHTTPError(http.StatusForbidden, "csrf toke
 // all on a method that requires one.
 var ErrCSRFTokenMissing = httpctx.NewHTTPError(http.StatusForbidden, "csrf token missing")
 
+// errCSRFNoSession is returned when SessionBound is set but the Sessions
+// middleware did not run before CSRF.
+var errCSRFNoSession = errors.New("csrf: SessionBound requires the Sessions middleware")
+
+// csrfSessionKey is the session key of a session-bound token, stored as
+// "<session ID>.<token>".
+const csrfSessionKey = "_csrf"
+
 // CSRFConfig tunes the CSRF middleware. The zero value is usable via
 // CSRFWithConfig — missing fields fall back to sensible defaults at
 // construction time.
@@ -54,6 +63,11 @@ type CSRFConfig struct {
 	// Skipper, when non-nil and returning true, bypasses both token
 	// issuance and validation for a request.
 	Skipper func(Context) bool
+	// SessionBound keeps the token in the session (see Sessions) instead
+	// of a cookie, bound to the session ID: it is only accepted for the
+	// session it was issued to and is replaced after the session is
+	// regenerated at login. The Cookie* fields are unused.
+	SessionBound bool
 }
 
 func (c *CSRFConfig) applyDefaults() {
@@ -97,6 +111,10 @@ func CSRFWithConfig(cfg CSRFConfig) MiddlewareFunc {
 				return next(c)
 			}
 
+			if cfg.SessionBound {
+				return csrfWithSession(c, &cfg, next)
+			}
+
 			req := c.Request()
 			method := req.Method
 			safe := isSafeCSRFMethod(method)
@@ -110,10 +128,7 @@ func CSRFWithConfig(cfg CSRFConfig) MiddlewareFunc {
 			}
 
 			if !safe {
-				submitted := req.Header.Get(cfg.HeaderName)
-				if submitted == "" {
-					submitted = c.FormValue(cfg.FormFieldName)
-				}
+				submitted := submittedCSRFToken(c, &cfg)
 				if existing == "" || submitted == "" {
 					return ErrCSRFTokenMissing
 				}
@@ -154,6 +169,52 @@ func CSRFWithConfig(cfg CSRFConfig) MiddlewareFunc {
 	}
 }
 
+// csrfWithSession is the CSRF check with the token kept in the session.
+func csrfWithSession(c Context, cfg *CSRFConfig, next HandlerFunc) error {
+	s := GetSession(c)
+	if s == nil {
+		return errCSRFNoSession
+	}
+	// A token issued under another session ID, i.e. before a Regenerate,
+	// no longer counts.
+	existing := ""
+	if id, token, ok := strings.Cut(s.GetString(csrfSessionKey), "."); ok && id == s.ID() {
+		existing = token
+	}
+
+	if !isSafeCSRFMethod(c.Request().Method) {
+		submitted := submittedCSRFToken(c, cfg)
+		if existing == "" || submitted == "" {
+			return ErrCSRFTokenMissing
+		}
+		if subtle.ConstantTimeCompare([]byte(existing), []byte(submitted)) != 1 {
+			return ErrCSRFTokenMismatch
+		}
+		return next(c)
+	}
+
+	token := existing
+	if token == "" {
+		generated, err := generateCSRFToken(cfg.TokenBytes)
+		if err != nil {
+			return err
+		}
+		token = generated
+		s.Set(csrfSessionKey, s.ID()+"."+token)
+	}
+	c.Response().Header().Set(cfg.HeaderName, token)
+	return next(c)
+}
+
+// submittedCSRFToken returns the token the client echoed in the header or,
+// failing that, the form field.
+func submittedCSRFToken(c Context, cfg *CSRFConfig) string {
+	if submitted := c.Request().Header.Get(cfg.HeaderName); submitted != "" {
+		return submitted
+	}
+	return c.FormValue(cfg.FormFieldName)
+}
+
 // isSafeCSRFMethod reports whether method is one of the RFC 7231
 // "safe" methods, which by definition do not mutate server state and
 // therefore don't require a CSRF token on the request.
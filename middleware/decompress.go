@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/yshengliao/gortex/pkg/errors"
+)
+
+// decompressRatioFloor is the decoded size below which MaxRatio is not
+// enforced: small, highly repetitive bodies are legitimate and harmless.
+const decompressRatioFloor = 64 << 10
+
+// Decoder returns a reader that decodes one content coding of r.
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+// DecompressConfig contains configuration for the request decompression
+// middleware
+type DecompressConfig struct {
+	// Decoders adds or replaces decoders by Content-Encoding token. gzip,
+	// x-gzip, deflate, br and zstd are built in.
+	Decoders map[string]Decoder
+	// MaxSize caps the decoded body, whatever the limits of the binder.
+	// Default 10 MiB.
+	MaxSize int64
+	// MaxRatio caps decoded bytes per encoded byte once the decoded body
+	// passes 64 KiB, rejecting decompression bombs early. Default 100.
+	MaxRatio int64
+	// SkipPaths is a list of paths to skip decompression
+	SkipPaths []string
+}
+
+// Decompress returns a middleware that decodes gzip, deflate, brotli and
+// zstd request bodies with the default limits.
+func Decompress() MiddlewareFunc {
+	return DecompressWithConfig(&DecompressConfig{})
+}
+
+// DecompressWithConfig returns a request decompression middleware with
+// custom configuration.
+//
+// A body sent with a Content-Encoding is decoded up front and replaced by
+// the decoded bytes, with the Content-Encoding header removed and the
+// content length updated, so Bind and the parameter binder read plain
+// JSON. Unknown codings are answered with 415 and an Accept-Encoding header
+// listing the supported ones, bodies over MaxSize or MaxRatio with 413 and
+// corrupt bodies with 400.
+func DecompressWithConfig(config *DecompressConfig) MiddlewareFunc {
+	if config == nil {
+		panic("decompress middleware: config is required")
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = 10 << 20
+	}
+	if config.MaxRatio <= 0 {
+		config.MaxRatio = 100
+	}
+	decoders := map[string]Decoder{
+		"gzip":    func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		"deflate": zlib.NewReader,
+		"br":      func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(brotli.NewReader(r)), nil },
+		"zstd": func(r io.Reader) (io.ReadCloser, error) {
+			// MaxSize also bounds the window the decoder allocates.
+			d, err := zstd.NewReader(r,
+				zstd.WithDecoderConcurrency(1),
+				zstd.WithDecoderMaxMemory(uint64(config.MaxSize)))
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+	}
+	decoders["x-gzip"] = decoders["gzip"]
+	for name, decoder := range config.Decoders {
+		if decoder == nil {
+			panic("decompress middleware: decoder for " + name + " is nil")
+		}
+		decoders[strings.ToLower(name)] = decoder
+	}
+	supported := make([]string, 0, len(decoders))
+	for name := range decoders {
+		supported = append(supported, name)
+	}
+	slices.Sort(supported)
+	acceptEncoding := strings.Join(supported, ", ")
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+
+			// Skip if path is in skip list (same segment-boundary rule as JWTAuth).
+			for _, skip := range config.SkipPaths {
+				if req.URL.Path == skip ||
+					strings.HasPrefix(req.URL.Path, strings.TrimSuffix(skip, "/")+"/") {
+					return next(c)
+				}
+			}
+
+			var codings []string
+			for _, line := range req.Header.Values("Content-Encoding") {
+				for _, token := range strings.Split(line, ",") {
+					token = strings.ToLower(strings.TrimSpace(token))
+					if token != "" && token != "identity" {
+						codings = append(codings, token)
+					}
+				}
+			}
+			if len(codings) == 0 || req.Body == nil || req.ContentLength == 0 {
+				return next(c)
+			}
+			for _, coding := range codings {
+				if decoders[coding] == nil {
+					c.Response().Header().Set("Accept-Encoding", acceptEncoding)
+					return &errors.ErrorResponse{
+						Success: false,
+						ErrorDetail: errors.ErrorDetail{
+							Code:    int(errors.CodeUnsupportedMediaType),
+							Message: "unsupported content encoding",
+							Details: map[string]interface{}{
+								"encoding":  coding,
+								"supported": supported,
+							},
+						},
+					}
+				}
+			}
+
+			body, err := decodeBody(req.Body, codings, decoders, config.MaxSize, config.MaxRatio)
+			req.Body.Close()
+			if err != nil {
+				return err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+			req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			req.Header.Del("Content-Encoding")
+
+			return next(c)
+		}
+	}
+}
+
+// decodeBody undoes codings, applied in the order listed, and reads the
+// result while enforcing the size and ratio limits.
+func decodeBody(body io.Reader, codings []string, decoders map[string]Decoder, maxSize, maxRatio int64) ([]byte, error) {
+	encoded := &countingReader{r: io.LimitReader(body, maxSize+1)}
+	var r io.Reader = encoded
+	for i := len(codings) - 1; i >= 0; i-- {
+		decoded, err := decoders[codings[i]](r)
+		if err != nil {
+			return nil, decompressError(errors.CodeInvalidInput, "malformed "+codings[i]+" body")
+		}
+		defer decoded.Close()
+		r = decoded
+	}
+
+	var out bytes.Buffer
+	chunk := make([]byte, 32<<10)
+	for {
+		n, err := r.Read(chunk)
+		out.Write(chunk[:n])
+		size := int64(out.Len())
+		if size > maxSize || encoded.n > maxSize {
+			return nil, decompressError(errors.CodePayloadTooLarge, "decompressed request body too large")
+		}
+		if size > decompressRatioFloor && size > maxRatio*encoded.n {
+			return nil, decompressError(errors.CodePayloadTooLarge, "request body compression ratio too high")
+		}
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, decompressError(errors.CodeInvalidInput, "malformed "+strings.Join(codings, ", ")+" body")
+		}
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func decompressError(code errors.ErrorCode, message string) error {
+	return &errors.ErrorResponse{
+		Success: false,
+		ErrorDetail: errors.ErrorDetail{
+			Code:    int(code),
+			Message: message,
+		},
+	}
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	gortexerrors "github.com/yshengliao/gortex/pkg/errors"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func brotliBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	bw := brotli.NewWriter(&buf)
+	if _, err := bw.Write(data); err != nil {
+		t.Fatalf("brotli: %v", err)
+	}
+	if err := bw.Close(); err != nil {
+		t.Fatalf("brotli: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("zstd: %v", err)
+	}
+	defer zw.Close()
+	return zw.EncodeAll(data, nil)
+}
+
+func encodedRequest(body []byte, encoding string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", encoding)
+	return req
+}
+
+// readBody is a handler that records the body and headers it was given.
+func readBody(got *string, encoding *string) HandlerFunc {
+	return func(c Context) error {
+		b, err := io.ReadAll(c.Request().Body)
+		*got = string(b)
+		*encoding = c.Request().Header.Get("Content-Encoding")
+		if c.Request().ContentLength != int64(len(b)) {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	body := `{"device":"ios","events":[1,2,3]}`
+	var deflated bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	zw.Write([]byte(body))
+	zw.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+	}{
+		{"gzip", "gzip", gzipBytes(t, []byte(body))},
+		{"x-gzip", "X-GZIP", gzipBytes(t, []byte(body))},
+		{"deflate", "deflate", deflated.Bytes()},
+		{"stacked", "gzip, gzip", gzipBytes(t, gzipBytes(t, []byte(body)))},
+		{"br", "br", brotliBytes(t, []byte(body))},
+		{"zstd", "zstd", zstdBytes(t, []byte(body))},
+		{"identity", "identity", []byte(body)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, encoding string
+			err := Decompress()(readBody(&got, &encoding))(newMockContext(encodedRequest(tt.data, tt.encoding), httptest.NewRecorder()))
+			if err != nil {
+				t.Fatalf("Decompress: %v", err)
+			}
+			if got != body {
+				t.Errorf("body = %q, want %q", got, body)
+			}
+			if encoding != "" && tt.encoding != "identity" {
+				t.Errorf("Content-Encoding = %q, want it removed", encoding)
+			}
+		})
+	}
+}
+
+func TestDecompress_Rejects(t *testing.T) {
+	large := gzipBytes(t, bytes.Repeat([]byte("x"), 2<<20))
+	random := make([]byte, 128<<10)
+	for i := range random {
+		random[i] = byte(i*7919 + i/251)
+	}
+
+	tests := []struct {
+		name   string
+		config *DecompressConfig
+		req    *http.Request
+		code   gortexerrors.ErrorCode
+		status int
+	}{
+		{"unknown encoding", &DecompressConfig{}, encodedRequest([]byte("data"), "compress"), gortexerrors.CodeUnsupportedMediaType, http.StatusUnsupportedMediaType},
+		{"corrupt body", &DecompressConfig{}, encodedRequest([]byte("not gzip"), "gzip"), gortexerrors.CodeInvalidInput, http.StatusBadRequest},
+		{"truncated body", &DecompressConfig{}, encodedRequest(gzipBytes(t, random)[:1000], "gzip"), gortexerrors.CodeInvalidInput, http.StatusBadRequest},
+		{"decoded size", &DecompressConfig{MaxSize: 1 << 20, MaxRatio: 1 << 20}, encodedRequest(large, "gzip"), gortexerrors.CodePayloadTooLarge, http.StatusRequestEntityTooLarge},
+		{"ratio", &DecompressConfig{}, encodedRequest(large, "gzip"), gortexerrors.CodePayloadTooLarge, http.StatusRequestEntityTooLarge},
+		{"incompressible body", &DecompressConfig{MaxSize: 64 << 10}, encodedRequest(gzipBytes(t, random), "gzip"), gortexerrors.CodePayloadTooLarge, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			err := DecompressWithConfig(tt.config)(okHandler)(newMockContext(tt.req, rec))
+			code := errorCode(t, err)
+			if code != tt.code {
+				t.Errorf("code = %d, want %d", code, tt.code)
+			}
+			if got := gortexerrors.GetHTTPStatus(code); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+			if tt.code == gortexerrors.CodeUnsupportedMediaType && rec.Header().Get("Accept-Encoding") != "br, deflate, gzip, x-gzip, zstd" {
+				t.Errorf("Accept-Encoding = %q", rec.Header().Get("Accept-Encoding"))
+			}
+		})
+	}
+
+	// A highly compressible body under the ratio floor is accepted.
+	var got, encoding string
+	small := strings.Repeat("a", 32<<10)
+	if err := Decompress()(readBody(&got, &encoding))(newMockContext(encodedRequest(gzipBytes(t, []byte(small)), "gzip"), httptest.NewRecorder())); err != nil {
+		t.Errorf("small compressible body: %v", err)
+	}
+}
+
+// A registered decoder replaces the built-in one of the same name.
+func TestDecompress_ReplacesBuiltinDecoder(t *testing.T) {
+	// zlib stands in for a replacement brotli reader.
+	replacement := func(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) }
+	mw := DecompressWithConfig(&DecompressConfig{Decoders: map[string]Decoder{"BR": replacement}})
+
+	body := `{"device":"android"}`
+	var encoded bytes.Buffer
+	zw := zlib.NewWriter(&encoded)
+	zw.Write([]byte(body))
+	zw.Close()
+
+	var got, encoding string
+	if err := mw(readBody(&got, &encoding))(newMockContext(encodedRequest(encoded.Bytes(), "br"), httptest.NewRecorder())); err != nil {
+		t.Fatalf("br body: %v", err)
+	}
+	if got != body || encoding != "" {
+		t.Errorf("body = %q, Content-Encoding = %q", got, encoding)
+	}
+}
+
+func TestDecompress_CustomDecoder(t *testing.T) {
+	// A reversing "decoder" stands in for brotli or zstd.
+	reverse := func(r io.Reader) (io.ReadCloser, error) {
+		b, err := io.ReadAll(r)
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		return io.NopCloser(bytes.NewReader(b)), err
+	}
+	mw := DecompressWithConfig(&DecompressConfig{Decoders: map[string]Decoder{"ZSTD": reverse}})
+
+	var got, encoding string
+	if err := mw(readBody(&got, &encoding))(newMockContext(encodedRequest([]byte(`}{`), "zstd"), httptest.NewRecorder())); err != nil {
+		t.Fatalf("custom decoder: %v", err)
+	}
+	if got != `{}` {
+		t.Errorf("body = %q, want {}", got)
+	}
+
+	// Plain bodies and skipped paths are left alone.
+	plain := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(`{}`))
+	if err := mw(readBody(&got, &encoding))(newMockContext(plain, httptest.NewRecorder())); err != nil || got != `{}` {
+		t.Errorf("plain body = %q, %v", got, err)
+	}
+	skip := DecompressWithConfig(&DecompressConfig{SkipPaths: []string{"/upload"}})
+	if err := skip(okHandler)(newMockContext(encodedRequest([]byte("raw"), "br"), httptest.NewRecorder())); err != nil {
+		t.Errorf("skipped path: %v", err)
+	}
+}
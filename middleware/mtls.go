@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/yshengliao/gortex/pkg/auth"
+	"github.com/yshengliao/gortex/pkg/errors"
+)
+
+// clientCertContextKey stores the verified client certificate in the router
+// context.
+const clientCertContextKey = "client-cert"
+
+// MTLSTokenType is the auth.Claims TokenType of client certificate requests.
+const MTLSTokenType = "mtls"
+
+// MTLSConfig contains configuration for the mTLS middleware
+type MTLSConfig struct {
+	// Claims maps a verified client certificate to claims; an error rejects
+	// the certificate with 403. Default DefaultCertificateClaims.
+	Claims func(cert *x509.Certificate) (*auth.Claims, error)
+	// SkipPaths is a list of paths to skip authentication
+	SkipPaths []string
+	// ClaimsContextKey is the key used to store claims in context
+	ClaimsContextKey string
+}
+
+// MTLS returns a middleware that authenticates requests by their verified
+// TLS client certificate.
+func MTLS() MiddlewareFunc {
+	return MTLSWithConfig(&MTLSConfig{})
+}
+
+// MTLSWithConfig returns an mTLS middleware with custom configuration.
+//
+// Only certificates the server verified against its client CAs count, so
+// the server needs a ClientCAFile (see config.TLSConfig). The claims are
+// stored under ClaimsContextKey, as JWTAuth does, so GetClaims,
+// RequireRole and rbac work unchanged; the certificate itself is available
+// from GetClientCertificate.
+func MTLSWithConfig(config *MTLSConfig) MiddlewareFunc {
+	if config == nil {
+		panic("mtls middleware: config is required")
+	}
+	if config.Claims == nil {
+		config.Claims = DefaultCertificateClaims
+	}
+	if config.ClaimsContextKey == "" {
+		config.ClaimsContextKey = "jwt-claims"
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+
+			// Skip if path is in skip list (same segment-boundary rule as JWTAuth).
+			for _, skip := range config.SkipPaths {
+				if req.URL.Path == skip ||
+					strings.HasPrefix(req.URL.Path, strings.TrimSuffix(skip, "/")+"/") {
+					return next(c)
+				}
+			}
+
+			// VerifiedChains is empty for unverified certificates, e.g.
+			// under client_auth "request" or "require".
+			if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+				return &errors.ErrorResponse{
+					Success: false,
+					ErrorDetail: errors.ErrorDetail{
+						Code:    int(errors.CodeUnauthorized),
+						Message: "verified client certificate required",
+					},
+				}
+			}
+			cert := req.TLS.VerifiedChains[0][0]
+
+			claims, err := config.Claims(cert)
+			if err != nil {
+				return &errors.ErrorResponse{
+					Success: false,
+					ErrorDetail: errors.ErrorDetail{
+						Code:    int(errors.CodeForbidden),
+						Message: "client certificate not accepted",
+						Details: map[string]interface{}{
+							"error": err.Error(),
+						},
+					},
+				}
+			}
+
+			c.Set(config.ClaimsContextKey, claims)
+			c.Set(clientCertContextKey, cert)
+
+			//nolint:staticcheck // SA1029: dynamic, user-configured key meant for external lookup
+			ctx := context.WithValue(req.Context(), config.ClaimsContextKey, claims)
+			if setter, ok := c.(interface{ SetRequest(*http.Request) }); ok {
+				setter.SetRequest(req.WithContext(ctx))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// DefaultCertificateClaims maps a client certificate to claims. UserID is
+// the subject common name, or else the first URI, DNS or email SAN (so
+// SPIFFE IDs work); a certificate naming none is rejected. Email is the
+// first email SAN and Role the first organizational unit of the subject.
+// The token ID is the serial number and the expiry the certificate's
+// NotAfter.
+func DefaultCertificateClaims(cert *x509.Certificate) (*auth.Claims, error) {
+	userID := cert.Subject.CommonName
+	if userID == "" {
+		switch {
+		case len(cert.URIs) > 0:
+			userID = cert.URIs[0].String()
+		case len(cert.DNSNames) > 0:
+			userID = cert.DNSNames[0]
+		case len(cert.EmailAddresses) > 0:
+			userID = cert.EmailAddresses[0]
+		default:
+			return nil, stderrors.New("certificate names no subject")
+		}
+	}
+	claims := &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Issuer:    cert.Issuer.CommonName,
+			ID:        cert.SerialNumber.Text(16),
+			ExpiresAt: jwt.NewNumericDate(cert.NotAfter),
+		},
+		TokenType: MTLSTokenType,
+		UserID:    userID,
+		Username:  cert.Subject.CommonName,
+	}
+	if len(cert.EmailAddresses) > 0 {
+		claims.Email = cert.EmailAddresses[0]
+	}
+	if len(cert.Subject.OrganizationalUnit) > 0 {
+		claims.Role = cert.Subject.OrganizationalUnit[0]
+	}
+	return claims, nil
+}
+
+// GetClientCertificate retrieves the client certificate verified by MTLS
+func GetClientCertificate(c Context) *x509.Certificate {
+	if cert, ok := c.Get(clientCertContextKey).(*x509.Certificate); ok {
+		return cert
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/yshengliao/gortex/pkg/auth"
+	gortexerrors "github.com/yshengliao/gortex/pkg/errors"
+)
+
+// mtlsRequest returns a request whose TLS state carries cert as verified.
+func mtlsRequest(cert *x509.Certificate) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/internal", nil)
+	req.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+	return req
+}
+
+func TestMTLS(t *testing.T) {
+	cert := &x509.Certificate{
+		SerialNumber:   big.NewInt(0xbeef),
+		Subject:        pkix.Name{CommonName: "billing-service", OrganizationalUnit: []string{"admin"}},
+		Issuer:         pkix.Name{CommonName: "Internal CA"},
+		EmailAddresses: []string{"billing@example.com"},
+		NotAfter:       time.Now().Add(time.Hour),
+	}
+
+	mw := MTLS()
+	err := mw(RequireRole("admin")(func(c Context) error {
+		claims := GetClaims(c)
+		if claims.UserID != "billing-service" || claims.Email != "billing@example.com" ||
+			claims.ID != "beef" || claims.Issuer != "Internal CA" || claims.TokenType != MTLSTokenType {
+			t.Errorf("claims = %+v", claims)
+		}
+		if GetClientCertificate(c) != cert {
+			t.Error("GetClientCertificate did not return the certificate")
+		}
+		return c.String(http.StatusOK, "ok")
+	}))(newMockContext(mtlsRequest(cert), httptest.NewRecorder()))
+	if err != nil {
+		t.Fatalf("verified certificate: %v", err)
+	}
+
+	// Plain HTTP and unverified certificates are rejected.
+	if got := errorCode(t, mw(okHandler)(newMockContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder()))); got != gortexerrors.CodeUnauthorized {
+		t.Errorf("no TLS code = %d, want %d", got, gortexerrors.CodeUnauthorized)
+	}
+	req := mtlsRequest(cert)
+	req.TLS.VerifiedChains = nil
+	if got := errorCode(t, mw(okHandler)(newMockContext(req, httptest.NewRecorder()))); got != gortexerrors.CodeUnauthorized {
+		t.Errorf("unverified certificate code = %d, want %d", got, gortexerrors.CodeUnauthorized)
+	}
+}
+
+func TestMTLS_CustomClaims(t *testing.T) {
+	cert := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "intruder"}}
+	mw := MTLSWithConfig(&MTLSConfig{Claims: func(cert *x509.Certificate) (*auth.Claims, error) {
+		if cert.Subject.CommonName != "billing-service" {
+			return nil, errors.New("unknown service")
+		}
+		return &auth.Claims{UserID: cert.Subject.CommonName}, nil
+	}})
+	if got := errorCode(t, mw(okHandler)(newMockContext(mtlsRequest(cert), httptest.NewRecorder()))); got != gortexerrors.CodeForbidden {
+		t.Errorf("rejected certificate code = %d, want %d", got, gortexerrors.CodeForbidden)
+	}
+}
+
+func TestDefaultCertificateClaims_SANs(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/ns/prod/sa/billing")
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want string
+	}{
+		{"URI SAN", &x509.Certificate{URIs: []*url.URL{spiffe}, DNSNames: []string{"billing.internal"}}, spiffe.String()},
+		{"DNS SAN", &x509.Certificate{DNSNames: []string{"billing.internal"}}, "billing.internal"},
+		{"email SAN", &x509.Certificate{EmailAddresses: []string{"billing@example.com"}}, "billing@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cert.SerialNumber = big.NewInt(1)
+			claims, err := DefaultCertificateClaims(tt.cert)
+			if err != nil {
+				t.Fatalf("DefaultCertificateClaims: %v", err)
+			}
+			if claims.UserID != tt.want || claims.Subject != tt.want {
+				t.Errorf("UserID = %q, want %q", claims.UserID, tt.want)
+			}
+		})
+	}
+
+	if _, err := DefaultCertificateClaims(&x509.Certificate{SerialNumber: big.NewInt(1)}); err == nil {
+		t.Error("certificate without names accepted")
+	}
+}
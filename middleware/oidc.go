@@ -0,0 +1,311 @@
+package middleware
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yshengliao/gortex/pkg/auth/oidc"
+	"github.com/yshengliao/gortex/pkg/errors"
+)
+
+// Cookies holding the in-flight login; they live for oidcFlowTTL.
+const (
+	oidcStateCookie    = "oidc_state"
+	oidcNonceCookie    = "oidc_nonce"
+	oidcVerifierCookie = "oidc_verifier"
+	oidcReturnCookie   = "oidc_return"
+	oidcFlowTTL        = 10 * time.Minute
+)
+
+// OIDCConfig contains configuration for the OIDC middleware
+type OIDCConfig struct {
+	// Provider is the OpenID Connect provider to log in with
+	Provider *oidc.Provider
+	// SessionStore receives a session for every completed login. It may be
+	// nil when the Sessions middleware runs first: the login is then stored
+	// in GetSession(c), whose ID is regenerated.
+	SessionStore SessionCreator
+	// SessionKey is the session cookie name, shared with SessionAuth.
+	// Default "session_id".
+	SessionKey string
+	// LoginPath starts a login. A same-origin "return_to" query parameter
+	// names the page to land on afterwards. Default "/auth/login".
+	LoginPath string
+	// CallbackPath is the path of the provider's redirect URL. Default
+	// "/auth/callback".
+	CallbackPath string
+	// LogoutPath ends the session. Default "/auth/logout".
+	LogoutPath string
+	// DefaultRedirect is where a login without return_to and a logout land.
+	// Default "/".
+	DefaultRedirect string
+	// FetchUserInfo also fetches the userinfo endpoint after the code
+	// exchange.
+	FetchUserInfo bool
+	// Session builds the session data for a login. The default stores the
+	// ID token's subject as "user_id" along with its email and name.
+	Session func(c Context, login *OIDCLogin) (map[string]interface{}, error)
+	// InsecureCookies drops the Secure attribute from cookies, for local
+	// development over plain HTTP only.
+	InsecureCookies bool
+}
+
+// OIDCLogin is a completed login, passed to OIDCConfig.Session.
+type OIDCLogin struct {
+	Token    *oidc.Token
+	IDToken  *oidc.IDToken
+	UserInfo map[string]any
+}
+
+// OIDC returns a middleware that logs users in with an OpenID Connect
+// provider using the authorization code flow with PKCE.
+func OIDC(provider *oidc.Provider, store SessionCreator) MiddlewareFunc {
+	return OIDCWithConfig(&OIDCConfig{Provider: provider, SessionStore: store})
+}
+
+// OIDCWithConfig returns an OIDC middleware with custom configuration.
+//
+// It answers LoginPath, CallbackPath and LogoutPath itself and passes every
+// other request on; protect routes by combining it with SessionAuth over
+// the same store:
+//
+//	app.Use(middleware.OIDC(provider, store))
+//	appcontext.Register(ctx, middleware.SessionAuth(store)) // middleware:"auth"
+//
+// Behind the Sessions middleware, pass a nil store to keep the login in
+// GetSession(c) instead.
+func OIDCWithConfig(config *OIDCConfig) MiddlewareFunc {
+	if config == nil {
+		panic("oidc middleware: config is required")
+	}
+	if config.Provider == nil {
+		panic("oidc middleware: Provider is required")
+	}
+	if config.SessionKey == "" {
+		config.SessionKey = "session_id"
+	}
+	if config.LoginPath == "" {
+		config.LoginPath = "/auth/login"
+	}
+	if config.CallbackPath == "" {
+		config.CallbackPath = "/auth/callback"
+	}
+	if config.LogoutPath == "" {
+		config.LogoutPath = "/auth/logout"
+	}
+	if config.DefaultRedirect == "" {
+		config.DefaultRedirect = "/"
+	}
+	if config.Session == nil {
+		config.Session = defaultOIDCSession
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			switch c.Request().URL.Path {
+			case config.LoginPath:
+				return oidcLogin(c, config)
+			case config.CallbackPath:
+				return oidcCallback(c, config)
+			case config.LogoutPath:
+				return oidcLogout(c, config)
+			}
+			return next(c)
+		}
+	}
+}
+
+// oidcLogin remembers the flow's secrets in cookies and sends the user to
+// the provider.
+func oidcLogin(c Context, config *OIDCConfig) error {
+	state, nonce, verifier := oidc.NewVerifier(), oidc.NewVerifier(), oidc.NewVerifier()
+	setOIDCCookie(c, config, oidcStateCookie, state, oidcFlowTTL)
+	setOIDCCookie(c, config, oidcNonceCookie, nonce, oidcFlowTTL)
+	setOIDCCookie(c, config, oidcVerifierCookie, verifier, oidcFlowTTL)
+	if returnTo := c.Request().URL.Query().Get("return_to"); isLocalRedirect(returnTo) {
+		setOIDCCookie(c, config, oidcReturnCookie, returnTo, oidcFlowTTL)
+	}
+
+	// The provider is off-site, which Context.Redirect refuses.
+	c.Response().Header().Set("Location", config.Provider.AuthCodeURL(state, nonce, verifier))
+	c.Response().WriteHeader(http.StatusFound)
+	return nil
+}
+
+// oidcCallback completes the login and starts a session.
+func oidcCallback(c Context, config *OIDCConfig) error {
+	req := c.Request()
+	query := req.URL.Query()
+
+	if code := query.Get("error"); code != "" {
+		return oidcError(errors.CodeUnauthorized, "login was not completed", map[string]interface{}{
+			"error":             code,
+			"error_description": query.Get("error_description"),
+		})
+	}
+
+	state := cookieValue(c, oidcStateCookie)
+	nonce := cookieValue(c, oidcNonceCookie)
+	verifier := cookieValue(c, oidcVerifierCookie)
+	returnTo := cookieValue(c, oidcReturnCookie)
+	// The flow is single use whatever the outcome.
+	for _, name := range []string{oidcStateCookie, oidcNonceCookie, oidcVerifierCookie, oidcReturnCookie} {
+		setOIDCCookie(c, config, name, "", -1)
+	}
+	// Without the nonce a replayed ID token would pass, and without the
+	// verifier PKCE would not bind the code to this browser.
+	if state == "" || nonce == "" || verifier == "" || query.Get("state") != state {
+		return oidcError(errors.CodeInvalidState, "invalid login state", nil)
+	}
+
+	token, err := config.Provider.Exchange(req.Context(), query.Get("code"), verifier)
+	if err != nil {
+		var oauthErr *oidc.Error
+		if stderrors.As(err, &oauthErr) {
+			return oidcError(errors.CodeUnauthorized, "authorization code was rejected", map[string]interface{}{
+				"error": oauthErr.Code,
+			})
+		}
+		return oidcError(errors.CodeThirdPartyServiceError, "identity provider unavailable", nil)
+	}
+
+	idToken, err := config.Provider.VerifyIDToken(token.IDToken, nonce)
+	if err != nil {
+		return oidcError(errors.CodeInvalidToken, "invalid ID token", nil)
+	}
+
+	login := &OIDCLogin{Token: token, IDToken: idToken}
+	if config.FetchUserInfo {
+		info, err := config.Provider.UserInfo(req.Context(), token.AccessToken)
+		if err != nil {
+			return oidcError(errors.CodeThirdPartyServiceError, "failed to fetch user info", nil)
+		}
+		if sub, _ := info["sub"].(string); sub != idToken.Subject {
+			return oidcError(errors.CodeUnauthorized, "user info does not match the ID token", nil)
+		}
+		login.UserInfo = info
+	}
+
+	data, err := config.Session(c, login)
+	if err != nil {
+		return err
+	}
+
+	if err := startOIDCSession(c, config, data); err != nil {
+		return err
+	}
+	if !isLocalRedirect(returnTo) {
+		returnTo = config.DefaultRedirect
+	}
+	return c.Redirect(http.StatusFound, returnTo)
+}
+
+// startOIDCSession stores a login in GetSession(c) behind the Sessions
+// middleware, or else in a new session of config.SessionStore. Either way
+// no session ID from before the login is reused (session fixation).
+func startOIDCSession(c Context, config *OIDCConfig, data map[string]interface{}) error {
+	if s := GetSession(c); s != nil && config.SessionStore == nil {
+		s.Clear()
+		if err := s.Regenerate(); err != nil {
+			return oidcError(errors.CodeInternalServerError, "failed to create session", nil)
+		}
+		for key, value := range data {
+			s.Set(key, value)
+		}
+		return nil
+	}
+	if config.SessionStore == nil {
+		return oidcError(errors.CodeInternalServerError, "no session store configured", nil)
+	}
+
+	if old := cookieValue(c, config.SessionKey); old != "" {
+		_ = config.SessionStore.Destroy(old)
+	}
+	sessionID, err := config.SessionStore.Create(data)
+	if err != nil {
+		return oidcError(errors.CodeInternalServerError, "failed to create session", nil)
+	}
+	setOIDCCookie(c, config, config.SessionKey, sessionID, 0)
+	return nil
+}
+
+// oidcLogout ends the session.
+func oidcLogout(c Context, config *OIDCConfig) error {
+	if s := GetSession(c); s != nil && config.SessionStore == nil {
+		s.Destroy()
+		return c.Redirect(http.StatusFound, config.DefaultRedirect)
+	}
+	if config.SessionStore == nil {
+		return oidcError(errors.CodeInternalServerError, "no session store configured", nil)
+	}
+	if sessionID := cookieValue(c, config.SessionKey); sessionID != "" {
+		if err := config.SessionStore.Destroy(sessionID); err != nil {
+			return oidcError(errors.CodeInternalServerError, "failed to end session", nil)
+		}
+	}
+	setOIDCCookie(c, config, config.SessionKey, "", -1)
+	return c.Redirect(http.StatusFound, config.DefaultRedirect)
+}
+
+// defaultOIDCSession stores the ID token's subject, email and name.
+func defaultOIDCSession(_ Context, login *OIDCLogin) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"user_id": login.IDToken.Subject,
+		"issuer":  login.IDToken.Issuer,
+	}
+	if login.IDToken.Email != "" {
+		data["email"] = login.IDToken.Email
+	}
+	if login.IDToken.Name != "" {
+		data["name"] = login.IDToken.Name
+	}
+	return data, nil
+}
+
+// setOIDCCookie sets an HttpOnly, SameSite=Lax cookie. maxAge 0 makes a
+// browser-session cookie and a negative maxAge deletes it.
+func setOIDCCookie(c Context, config *OIDCConfig, name, value string, maxAge time.Duration) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Secure:   !config.InsecureCookies,
+		HttpOnly: true,
+		// Lax, not Strict: the callback is a top-level navigation from the
+		// provider and must carry the flow cookies.
+		SameSite: http.SameSiteLaxMode,
+	}
+	switch {
+	case maxAge < 0:
+		cookie.MaxAge = -1
+	case maxAge > 0:
+		cookie.MaxAge = int(maxAge.Seconds())
+	}
+	c.SetCookie(cookie)
+}
+
+func cookieValue(c Context, name string) string {
+	if cookie, err := c.Cookie(name); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// isLocalRedirect reports whether target is a same-origin path.
+func isLocalRedirect(target string) bool {
+	return strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") &&
+		!strings.HasPrefix(target, "/\\") && !strings.ContainsAny(target, "\r\n")
+}
+
+func oidcError(code errors.ErrorCode, message string, details map[string]interface{}) error {
+	return &errors.ErrorResponse{
+		Success: false,
+		ErrorDetail: errors.ErrorDetail{
+			Code:    int(code),
+			Message: message,
+			Details: details,
+		},
+	}
+}
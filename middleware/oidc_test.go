@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/yshengliao/gortex/pkg/auth/oidc"
+	"github.com/yshengliao/gortex/pkg/auth/oidc/oidctest"
+	gortexerrors "github.com/yshengliao/gortex/pkg/errors"
+)
+
+func (s *mockSessionStore) Create(data map[string]interface{}) (string, error) {
+	id := fmt.Sprintf("session-%d", len(s.sessions)+1)
+	s.sessions[id] = data
+	s.valid[id] = true
+	return id, nil
+}
+
+func (s *mockSessionStore) Destroy(sessionID string) error {
+	delete(s.sessions, sessionID)
+	delete(s.valid, sessionID)
+	return nil
+}
+
+func newOIDCTest(t *testing.T) (*oidctest.Server, *mockSessionStore, MiddlewareFunc) {
+	t.Helper()
+	idp := oidctest.NewServer("client", "s3cret")
+	t.Cleanup(idp.Close)
+	store := newMockSessionStore()
+	return idp, store, OIDCWithConfig(&OIDCConfig{Provider: newOIDCProvider(t, idp), SessionStore: store, FetchUserInfo: true})
+}
+
+func newOIDCProvider(t *testing.T, idp *oidctest.Server) *oidc.Provider {
+	t.Helper()
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		IssuerURL:    idp.URL,
+		ClientID:     "client",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://app.example.com/auth/callback",
+	})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return provider
+}
+
+// oidcLoginFlow starts a login and lets the stub provider approve it,
+// returning the callback URL and the flow cookies.
+func oidcLoginFlow(t *testing.T, mw MiddlewareFunc, loginURL string) (*url.URL, []*http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, loginURL, nil)
+	if err := mw(okHandler)(newMockContext(req, rec)); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want 302", rec.Code)
+	}
+	cookies := rec.Result().Cookies()
+	for _, cookie := range cookies {
+		if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+			t.Errorf("cookie %s is not HttpOnly, Secure and SameSite=Lax", cookie.Name)
+		}
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("callback URL: %v", err)
+	}
+	return callback, cookies
+}
+
+func oidcCallbackRequest(t *testing.T, mw MiddlewareFunc, callback *url.URL, cookies []*http.Cookie) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	return rec, mw(okHandler)(newMockContext(req, rec))
+}
+
+func okHandler(c Context) error {
+	return c.String(http.StatusOK, "next")
+}
+
+func TestOIDC_LoginFlow(t *testing.T) {
+	idp, store, mw := newOIDCTest(t)
+
+	callback, cookies := oidcLoginFlow(t, mw, "/auth/login?return_to=/dashboard")
+	if callback.Path != "/auth/callback" || callback.Query().Get("code") == "" {
+		t.Fatalf("unexpected callback %s", callback)
+	}
+
+	rec, err := oidcCallbackRequest(t, mw, callback, cookies)
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/dashboard" {
+		t.Fatalf("callback = %d %q, want 302 /dashboard", rec.Code, rec.Header().Get("Location"))
+	}
+
+	var sessionID string
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "session_id" {
+			sessionID = cookie.Value
+		}
+	}
+	data, err := store.Get(sessionID)
+	if err != nil {
+		t.Fatalf("session %q not created: %v", sessionID, err)
+	}
+	if data["user_id"] != idp.Subject || data["email"] != "user-1@example.com" {
+		t.Errorf("session data = %v", data)
+	}
+
+	// The session works with SessionAuth.
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+	if err := mw(SessionAuth(store)(okHandler))(newMockContext(req, rec)); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("protected route: %d %v", rec.Code, err)
+	}
+
+	// Replaying the callback fails: the code is spent.
+	if _, err := oidcCallbackRequest(t, mw, callback, cookies); err == nil {
+		t.Error("replayed callback succeeded")
+	}
+
+	// Logout ends the session.
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/auth/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+	if err := mw(okHandler)(newMockContext(req, rec)); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if valid, _ := store.Validate(sessionID); valid {
+		t.Error("session survived logout")
+	}
+}
+
+func TestOIDC_CallbackRejects(t *testing.T) {
+	assertCode := func(t *testing.T, err error, want gortexerrors.ErrorCode) {
+		t.Helper()
+		resp, ok := err.(*gortexerrors.ErrorResponse)
+		if !ok {
+			t.Fatalf("error = %v, want *ErrorResponse", err)
+		}
+		if resp.ErrorDetail.Code != int(want) {
+			t.Errorf("code = %d, want %d (%s)", resp.ErrorDetail.Code, want, resp.ErrorDetail.Message)
+		}
+	}
+
+	t.Run("state mismatch", func(t *testing.T) {
+		_, _, mw := newOIDCTest(t)
+		callback, cookies := oidcLoginFlow(t, mw, "/auth/login")
+		q := callback.Query()
+		q.Set("state", "forged")
+		callback.RawQuery = q.Encode()
+		_, err := oidcCallbackRequest(t, mw, callback, cookies)
+		assertCode(t, err, gortexerrors.CodeInvalidState)
+	})
+
+	t.Run("missing flow cookies", func(t *testing.T) {
+		_, _, mw := newOIDCTest(t)
+		callback, _ := oidcLoginFlow(t, mw, "/auth/login")
+		_, err := oidcCallbackRequest(t, mw, callback, nil)
+		assertCode(t, err, gortexerrors.CodeInvalidState)
+	})
+
+	for _, name := range []string{oidcNonceCookie, oidcVerifierCookie} {
+		t.Run("empty "+name, func(t *testing.T) {
+			_, _, mw := newOIDCTest(t)
+			callback, cookies := oidcLoginFlow(t, mw, "/auth/login")
+			for _, cookie := range cookies {
+				if cookie.Name == name {
+					cookie.Value = ""
+				}
+			}
+			_, err := oidcCallbackRequest(t, mw, callback, cookies)
+			assertCode(t, err, gortexerrors.CodeInvalidState)
+		})
+	}
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		idp, _, mw := newOIDCTest(t)
+		idp.ModifyIDToken = func(claims jwt.MapClaims) { claims["nonce"] = "replayed" }
+		callback, cookies := oidcLoginFlow(t, mw, "/auth/login")
+		_, err := oidcCallbackRequest(t, mw, callback, cookies)
+		assertCode(t, err, gortexerrors.CodeInvalidToken)
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		_, _, mw := newOIDCTest(t)
+		_, cookies := oidcLoginFlow(t, mw, "/auth/login")
+		callback, _ := url.Parse("/auth/callback?error=access_denied")
+		_, err := oidcCallbackRequest(t, mw, callback, cookies)
+		assertCode(t, err, gortexerrors.CodeUnauthorized)
+	})
+
+	t.Run("off-site return_to is ignored", func(t *testing.T) {
+		_, _, mw := newOIDCTest(t)
+		callback, cookies := oidcLoginFlow(t, mw, "/auth/login?return_to=//evil.example.com")
+		rec, err := oidcCallbackRequest(t, mw, callback, cookies)
+		if err != nil {
+			t.Fatalf("callback: %v", err)
+		}
+		if got := rec.Header().Get("Location"); got != "/" {
+			t.Errorf("Location = %q, want /", got)
+		}
+	})
+}
+
+func TestOIDC_PassesOtherPaths(t *testing.T) {
+	_, _, mw := newOIDCTest(t)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/public", nil)
+	if err := mw(okHandler)(newMockContext(req, rec)); err != nil || rec.Body.String() != "next" {
+		t.Fatalf("pass-through: %q %v", rec.Body.String(), err)
+	}
+}
@@ -67,6 +67,15 @@ type GortexRateLimitConfig struct {
 	// store starts a background cleanup goroutine).
 	Store RateLimiter
 
+	// Tiers maps tier names, such as subscription plans, to their own
+	// stores. A request whose TierFunc result names a tier is limited by
+	// that tier's store; any other request is limited by Store.
+	Tiers map[string]RateLimiter
+
+	// TierFunc names the tier of a request. It only takes effect with
+	// Tiers and defaults to RateLimitTierByRole().
+	TierFunc func(c Context) string
+
 	// TrustedProxies lists CIDR ranges whose requests may set
 	// X-Forwarded-For / X-Real-IP. It only takes effect for the default
 	// KeyFunc. If empty, forwarding headers are ignored and the direct peer
@@ -319,6 +328,10 @@ func GortexRateLimitWithConfig(config *GortexRateLimitConfig) MiddlewareFunc {
 		config.Store = store
 	}
 
+	if len(config.Tiers) > 0 && config.TierFunc == nil {
+		config.TierFunc = RateLimitTierByRole()
+	}
+
 	return func(next HandlerFunc) HandlerFunc {
 		return func(c Context) error {
 			// Skip if skip function returns true
@@ -328,8 +341,14 @@ func GortexRateLimitWithConfig(config *GortexRateLimitConfig) MiddlewareFunc {
 
 			// Get key for rate limiting
 			key := config.KeyFunc(c)
-			allowed := config.Store.Allow(key)
-			applyRateLimitHeaders(c, config.Store, key, allowed)
+			store := config.Store
+			if len(config.Tiers) > 0 {
+				if tier, ok := config.Tiers[config.TierFunc(c)]; ok {
+					store = tier
+				}
+			}
+			allowed := store.Allow(key)
+			applyRateLimitHeaders(c, store, key, allowed)
 
 			if !allowed {
 				return config.ErrorHandler(c)
@@ -387,10 +406,18 @@ func RateLimitByIP() func(Context) string {
 	}
 }
 
-// RateLimitByUser returns a key function that uses user ID from context
+// RateLimitByUser returns a key function that uses user ID from context.
+// The value stored under userKey is used as is, except for claims such as
+// those JWTAuth stores under "jwt-claims", which are keyed by their subject.
 func RateLimitByUser(userKey string) func(Context) string {
 	return func(c Context) string {
-		if userID := c.Get(userKey); userID != nil {
+		switch userID := c.Get(userKey).(type) {
+		case nil:
+		case interface{ GetSubject() (string, error) }:
+			if subject, err := userID.GetSubject(); err == nil && subject != "" {
+				return "user:" + subject
+			}
+		default:
 			return fmt.Sprintf("user:%v", userID)
 		}
 		return c.RealIP() // fallback to IP
@@ -406,3 +433,18 @@ func RateLimitByHeader(headerName string) func(Context) string {
 		return c.RealIP() // fallback to IP
 	}
 }
+
+// RateLimitTierByRole returns a tier function that names the tier after the
+// first role of the claims stored by JWTAuth, JWTAuthOf, APIKeyAuth or MTLS,
+// so a role such as "pro" selects the "pro" tier. Requests without a role
+// have no tier.
+func RateLimitTierByRole(claimsKey ...string) func(Context) string {
+	return func(c Context) string {
+		if p := GetPrincipal(c, claimsKey...); p != nil {
+			if roles := p.PrincipalRoles(); len(roles) > 0 {
+				return roles[0]
+			}
+		}
+		return ""
+	}
+}
@@ -0,0 +1,282 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/yshengliao/gortex/internal/resp"
+)
+
+// RedisRateLimiterOptions configures a RedisRateLimiter.
+type RedisRateLimiterOptions struct {
+	// Addr is the host:port of the Redis-compatible server
+	Addr string
+	// Password, when set, is sent with AUTH on every new connection
+	Password string
+	// DB is the database selected on every new connection
+	DB int
+	// TLSConfig, when set, connects over TLS
+	TLSConfig *tls.Config
+	// Prefix namespaces the keys written. Defaults to "gortex:ratelimit:".
+	Prefix string
+	// Limit is the number of requests allowed per Window
+	Limit int
+	// Window is the length of the sliding window. Defaults to one minute.
+	Window time.Duration
+	// Timeout bounds dialing and each round trip. Defaults to one second.
+	Timeout time.Duration
+	// MaxIdleConns is the number of connections kept open. Defaults to 10.
+	MaxIdleConns int
+	// FailClosed rejects requests while the server cannot be reached. By
+	// default they are allowed, so an outage of the limiter's store does
+	// not take the API down with it.
+	FailClosed bool
+	// OnError, when set, is called with every failed round trip
+	OnError func(err error)
+}
+
+// RedisRateLimiter implements RateLimiter with a sliding window counter
+// kept in a Redis-compatible server, so every instance sharing the server
+// shares the limits and they survive deploys. Each key uses a counter per
+// fixed window, updated with INCRBY and expired with PEXPIRE, so no server
+// side scripting is needed.
+type RedisRateLimiter struct {
+	opts   RedisRateLimiterOptions
+	window int64 // milliseconds
+	idle   chan *resp.Conn
+	now    func() time.Time
+}
+
+// NewRedisRateLimiter connects to opts.Addr and returns a limiter allowing
+// opts.Limit requests per opts.Window. It fails if the server cannot be
+// reached or rejects the credentials.
+func NewRedisRateLimiter(opts RedisRateLimiterOptions) (*RedisRateLimiter, error) {
+	if opts.Addr == "" {
+		return nil, fmt.Errorf("redis rate limiter: Addr is required")
+	}
+	if opts.Limit <= 0 {
+		return nil, fmt.Errorf("redis rate limiter: Limit must be positive")
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "gortex:ratelimit:"
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.Window < time.Millisecond {
+		return nil, fmt.Errorf("redis rate limiter: Window must be at least a millisecond")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = 10
+	}
+	l := &RedisRateLimiter{
+		opts:   opts,
+		window: opts.Window.Milliseconds(),
+		idle:   make(chan *resp.Conn, opts.MaxIdleConns),
+		now:    time.Now,
+	}
+	if _, err := l.pipeline([]string{"PING"}); err != nil {
+		return nil, fmt.Errorf("redis rate limiter: %w", err)
+	}
+	return l, nil
+}
+
+// Allow checks if a request is allowed.
+func (l *RedisRateLimiter) Allow(key string) bool {
+	return l.AllowN(key, 1)
+}
+
+// AllowN checks if n requests are allowed, counting them if so. The
+// requests are counted first and taken back if they exceed the limit, so
+// concurrent requests from several instances never overshoot it.
+func (l *RedisRateLimiter) AllowN(key string, n int) bool {
+	nowMs := l.now().UnixMilli()
+	curr, prev := l.keys(key, nowMs)
+	replies, err := l.pipeline(
+		[]string{"INCRBY", curr, strconv.Itoa(n)},
+		[]string{"PEXPIRE", curr, strconv.FormatInt(2*l.window, 10)},
+		[]string{"GET", prev},
+	)
+	if err != nil {
+		return !l.opts.FailClosed
+	}
+	currCount, _ := replies[0].(int64)
+	prevCount, err := parseCount(replies[2])
+	if err != nil {
+		l.reportError(err)
+		return !l.opts.FailClosed
+	}
+	if l.estimate(nowMs, prevCount, currCount) <= float64(l.opts.Limit) {
+		return true
+	}
+	// Take the requests back. Should that fail, the counter's expiry
+	// still bounds the overcount.
+	_, _ = l.pipeline([]string{"DECRBY", curr, strconv.Itoa(n)})
+	return false
+}
+
+// Reset forgets the requests counted for a key.
+func (l *RedisRateLimiter) Reset(key string) {
+	curr, prev := l.keys(key, l.now().UnixMilli())
+	_, _ = l.pipeline([]string{"DEL", curr, prev})
+}
+
+// Status reports the state of key, shared by every instance, without
+// counting a request. limit is Limit, remaining the number of requests
+// that would be allowed now, and reset when the counted requests will all
+// have left the window. While the server cannot be reached it reports the
+// full limit, or none left with FailClosed.
+func (l *RedisRateLimiter) Status(key string) (limit int, remaining int, reset time.Time) {
+	now := l.now()
+	nowMs := now.UnixMilli()
+	curr, prev := l.keys(key, nowMs)
+	currCount, prevCount, err := l.counts(curr, prev)
+	if err != nil {
+		if l.opts.FailClosed {
+			return l.opts.Limit, 0, now
+		}
+		return l.opts.Limit, l.opts.Limit, now
+	}
+	start := time.UnixMilli(nowMs - nowMs%l.window)
+	return l.opts.Limit,
+		slidingWindowStatus(l.opts.Limit, l.estimate(nowMs, prevCount, currCount)),
+		slidingWindowReset(now, start, int(prevCount), int(currCount), l.opts.Window)
+}
+
+// counts reads the counters of the current and previous windows.
+func (l *RedisRateLimiter) counts(curr, prev string) (currCount, prevCount int64, err error) {
+	replies, err := l.pipeline([]string{"MGET", curr, prev})
+	if err != nil {
+		return 0, 0, err
+	}
+	values, _ := replies[0].([]any)
+	if len(values) != 2 {
+		err = resp.ErrProtocol
+	} else if currCount, err = parseCount(values[0]); err == nil {
+		prevCount, err = parseCount(values[1])
+	}
+	if err != nil {
+		l.reportError(err)
+	}
+	return currCount, prevCount, err
+}
+
+// Stop closes the idle connections.
+func (l *RedisRateLimiter) Stop() {
+	for {
+		select {
+		case conn := <-l.idle:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// keys returns the counter keys of the current and previous windows.
+func (l *RedisRateLimiter) keys(key string, nowMs int64) (curr, prev string) {
+	index := nowMs / l.window
+	base := l.opts.Prefix + key + ":"
+	return base + strconv.FormatInt(index, 10), base + strconv.FormatInt(index-1, 10)
+}
+
+// estimate weights the previous window's count by how much of it still
+// overlaps the sliding window ending at nowMs.
+func (l *RedisRateLimiter) estimate(nowMs, prev, curr int64) float64 {
+	overlap := 1 - float64(nowMs%l.window)/float64(l.window)
+	return float64(prev)*overlap + float64(curr)
+}
+
+// pipeline runs cmds on a pooled connection, reporting failures to
+// OnError. Error replies fail the whole pipeline.
+func (l *RedisRateLimiter) pipeline(cmds ...[]string) ([]any, error) {
+	conn, err := l.conn()
+	if err != nil {
+		l.reportError(err)
+		return nil, err
+	}
+	replies, err := conn.Pipeline(time.Now().Add(l.opts.Timeout), cmds...)
+	if err != nil {
+		conn.Close()
+		l.reportError(err)
+		return nil, err
+	}
+	l.release(conn)
+	for _, reply := range replies {
+		if e, ok := reply.(resp.Error); ok {
+			l.reportError(e)
+			return nil, e
+		}
+	}
+	return replies, nil
+}
+
+// conn returns an idle connection or dials a new one.
+func (l *RedisRateLimiter) conn() (*resp.Conn, error) {
+	select {
+	case conn := <-l.idle:
+		return conn, nil
+	default:
+	}
+	conn, err := resp.Dial(l.opts.Addr, l.opts.Timeout, l.opts.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	var setup [][]string
+	if l.opts.Password != "" {
+		setup = append(setup, []string{"AUTH", l.opts.Password})
+	}
+	if l.opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(l.opts.DB)})
+	}
+	if len(setup) > 0 {
+		replies, err := conn.Pipeline(time.Now().Add(l.opts.Timeout), setup...)
+		if err == nil {
+			for _, reply := range replies {
+				if e, ok := reply.(resp.Error); ok {
+					err = e
+					break
+				}
+			}
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// release returns conn to the idle pool, closing it if the pool is full.
+func (l *RedisRateLimiter) release(conn *resp.Conn) {
+	select {
+	case l.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (l *RedisRateLimiter) reportError(err error) {
+	if l.opts.OnError != nil {
+		l.opts.OnError(err)
+	}
+}
+
+// parseCount parses a counter read with GET or MGET; a missing key counts
+// zero.
+func parseCount(v any) (int64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case int64:
+		return v, nil
+	}
+	return 0, resp.ErrProtocol
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/internal/testutil"
+)
+
+func newTestRedisLimiter(t *testing.T, server *testutil.RESPServer, clock *fakeClock, opts RedisRateLimiterOptions) *RedisRateLimiter {
+	t.Helper()
+	opts.Addr = server.Addr
+	l, err := NewRedisRateLimiter(opts)
+	require.NoError(t, err)
+	t.Cleanup(l.Stop)
+	l.now = clock.Now
+	return l
+}
+
+func TestRedisRateLimiter_SharedAcrossInstances(t *testing.T) {
+	server := testutil.NewRESPServer(t)
+	clock := newFakeClock()
+	server.SetClock(clock.Now)
+	a := newTestRedisLimiter(t, server, clock, RedisRateLimiterOptions{Limit: 10, Window: time.Minute})
+	b := newTestRedisLimiter(t, server, clock, RedisRateLimiterOptions{Limit: 10, Window: time.Minute})
+
+	assert.Equal(t, 6, allowCount(a, "client", 6))
+	assert.Equal(t, 4, allowCount(b, "client", 6), "instances share the limit")
+	limit, remaining, reset := a.Status("client")
+	assert.Equal(t, 10, limit)
+	assert.Equal(t, 0, remaining)
+	assert.WithinDuration(t, clock.Now().Add(2*time.Minute), reset, 0)
+
+	// Same sliding window arithmetic as SlidingWindowRateLimiter.
+	clock.Advance(90 * time.Second)
+	_, remaining, reset = b.Status("client")
+	assert.Equal(t, 5, remaining)
+	assert.WithinDuration(t, clock.Now().Add(30*time.Second), reset, 0)
+	assert.Equal(t, 5, allowCount(a, "client", 10))
+
+	// Rejected requests are not counted.
+	clock.Advance(time.Minute)
+	_, remaining, _ = a.Status("client")
+	assert.Equal(t, 7, remaining)
+
+	a.Reset("client")
+	_, remaining, _ = b.Status("client")
+	assert.Equal(t, 10, remaining)
+
+	// Counters expire on the server once they leave the window.
+	assert.True(t, a.Allow("client"))
+	clock.Advance(3 * time.Minute)
+	assert.Zero(t, server.Keys())
+}
+
+func TestRedisRateLimiter_ConcurrentRequestsNeverOvershoot(t *testing.T) {
+	server := testutil.NewRESPServer(t)
+	clock := newFakeClock()
+	server.SetClock(clock.Now)
+	instances := []*RedisRateLimiter{
+		newTestRedisLimiter(t, server, clock, RedisRateLimiterOptions{Limit: 25}),
+		newTestRedisLimiter(t, server, clock, RedisRateLimiterOptions{Limit: 25}),
+	}
+
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(l *RedisRateLimiter) {
+			defer wg.Done()
+			if l.Allow("hot") {
+				allowed.Add(1)
+			}
+		}(instances[i%2])
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, allowed.Load(), int64(25))
+	assert.Positive(t, allowed.Load())
+}
+
+func TestRedisRateLimiter_AuthAndOutage(t *testing.T) {
+	server := testutil.NewRESPServer(t)
+	server.RequirePassword("s3cret")
+
+	_, err := NewRedisRateLimiter(RedisRateLimiterOptions{Addr: server.Addr, Limit: 1})
+	assert.Error(t, err, "missing password")
+	_, err = NewRedisRateLimiter(RedisRateLimiterOptions{Addr: server.Addr})
+	assert.Error(t, err, "missing limit")
+
+	var errs atomic.Int64
+	open, err := NewRedisRateLimiter(RedisRateLimiterOptions{
+		Addr: server.Addr, Password: "s3cret", DB: 2, Limit: 1,
+		OnError: func(error) { errs.Add(1) },
+	})
+	require.NoError(t, err)
+	defer open.Stop()
+	closed, err := NewRedisRateLimiter(RedisRateLimiterOptions{Addr: server.Addr, Password: "s3cret", Limit: 1, FailClosed: true})
+	require.NoError(t, err)
+	defer closed.Stop()
+	assert.True(t, open.Allow("k"))
+	assert.False(t, open.Allow("k"))
+
+	server.Close()
+	assert.True(t, open.Allow("k"), "fails open by default")
+	_, remaining, _ := open.Status("k")
+	assert.Equal(t, 1, remaining)
+	assert.Positive(t, errs.Load())
+	assert.False(t, closed.Allow("k"), "FailClosed rejects")
+	_, remaining, _ = closed.Status("k")
+	assert.Equal(t, 0, remaining)
+}
@@ -3,9 +3,11 @@ package middleware
 import (
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"golang.org/x/time/rate"
 
+	"github.com/yshengliao/gortex/pkg/auth"
 	httpctx "github.com/yshengliao/gortex/transport/http"
 )
 
@@ -208,6 +210,68 @@ func TestRateLimitByUser(t *testing.T) {
 	}
 }
 
+func TestRateLimitByUser_Claims(t *testing.T) {
+	keyFunc := RateLimitByUser("jwt-claims")
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	ctx := httpctx.NewDefaultContext(req, httptest.NewRecorder())
+	claims := &auth.Claims{UserID: "user123"}
+	claims.Subject = "user123"
+	ctx.Set("jwt-claims", claims)
+
+	if key := keyFunc(ctx); key != "user:user123" {
+		t.Errorf("Expected key user:user123, got %s", key)
+	}
+
+	// Claims without a subject fall back to the IP.
+	ctx.Set("jwt-claims", &auth.Claims{})
+	if key := keyFunc(ctx); key != ctx.RealIP() {
+		t.Errorf("Expected IP fallback, got %s", key)
+	}
+}
+
+func TestGortexRateLimit_Tiers(t *testing.T) {
+	base := NewGCRARateLimiter(1, time.Minute, 1)
+	defer base.Stop()
+	pro := NewGCRARateLimiter(3, time.Minute, 3)
+	defer pro.Stop()
+	mw := GortexRateLimitWithConfig(&GortexRateLimitConfig{
+		Store:   base,
+		Tiers:   map[string]RateLimiter{"pro": pro},
+		KeyFunc: func(c Context) string { return "client" },
+	})
+	handler := mw(func(c Context) error { return c.NoContent(200) })
+
+	allowed := func(role string) int {
+		n := 0
+		for i := 0; i < 5; i++ {
+			rec := httptest.NewRecorder()
+			ctx := httpctx.NewDefaultContext(httptest.NewRequest("GET", "/test", nil), rec)
+			if role != "" {
+				ctx.Set("jwt-claims", &auth.Claims{Role: role})
+			}
+			if err := handler(ctx); err != nil {
+				t.Fatalf("handler: %v", err)
+			}
+			if rec.Code == 200 {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := allowed("pro"); n != 3 {
+		t.Errorf("pro tier allowed %d requests, want 3", n)
+	}
+	// Unknown tiers and requests without claims share the base store.
+	if n := allowed("enterprise"); n != 1 {
+		t.Errorf("unknown tier allowed %d requests, want 1", n)
+	}
+	if n := allowed(""); n != 0 {
+		t.Errorf("no tier allowed %d requests, want 0", n)
+	}
+}
+
 func TestRateLimitByHeader(t *testing.T) {
 	keyFunc := RateLimitByHeader("X-API-Key")
 
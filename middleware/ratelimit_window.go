@@ -0,0 +1,325 @@
+package middleware
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// SlidingWindowOptions configures a SlidingWindowRateLimiter.
+type SlidingWindowOptions struct {
+	// Limit is the number of requests allowed per Window
+	Limit int
+	// Window is the length of the sliding window. Defaults to one minute.
+	Window time.Duration
+	// CleanupInterval is how often keys idle for two windows are evicted.
+	// Defaults to Window.
+	CleanupInterval time.Duration
+}
+
+// slidingWindow holds the counts of the current fixed window and the one
+// before it.
+type slidingWindow struct {
+	start      time.Time
+	prev, curr int
+}
+
+// advance moves w to the fixed window containing now.
+func (w *slidingWindow) advance(now time.Time, window time.Duration) {
+	start := now.Truncate(window)
+	switch {
+	case start.Equal(w.start):
+	case start.Equal(w.start.Add(window)):
+		w.prev, w.curr = w.curr, 0
+	default:
+		w.prev, w.curr = 0, 0
+	}
+	w.start = start
+}
+
+// estimate weights the previous window's count by how much of it still
+// overlaps the sliding window ending at now.
+func (w *slidingWindow) estimate(now time.Time, window time.Duration) float64 {
+	overlap := 1 - float64(now.Sub(w.start))/float64(window)
+	return float64(w.prev)*overlap + float64(w.curr)
+}
+
+// SlidingWindowRateLimiter implements RateLimiter with a sliding window
+// counter: a request is allowed while the requests of the last Window,
+// estimated from the counts of the current and previous fixed windows,
+// stay within Limit. Unlike a fixed window it does not let a client send
+// twice the limit across a window boundary, and unlike a request log it
+// keeps two counters per key.
+type SlidingWindowRateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*slidingWindow
+	limit   int
+	window  time.Duration
+	now     func() time.Time
+
+	cleanupInterval time.Duration
+	stopCh          chan struct{}
+	stopOnce        sync.Once
+}
+
+// NewSlidingWindowRateLimiter creates a limiter allowing limit requests
+// per window and starts its background cleanup goroutine.
+func NewSlidingWindowRateLimiter(limit int, window time.Duration) *SlidingWindowRateLimiter {
+	return NewSlidingWindowRateLimiterWithOptions(SlidingWindowOptions{Limit: limit, Window: window})
+}
+
+// NewSlidingWindowRateLimiterWithOptions creates a SlidingWindowRateLimiter
+// with the given options and starts the background cleanup goroutine.
+func NewSlidingWindowRateLimiterWithOptions(opts SlidingWindowOptions) *SlidingWindowRateLimiter {
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.CleanupInterval <= 0 {
+		opts.CleanupInterval = opts.Window
+	}
+	l := &SlidingWindowRateLimiter{
+		windows:         make(map[string]*slidingWindow),
+		limit:           opts.Limit,
+		window:          opts.Window,
+		now:             time.Now,
+		cleanupInterval: opts.CleanupInterval,
+		stopCh:          make(chan struct{}),
+	}
+	go runRateLimitCleanup(l.cleanupInterval, l.stopCh, l.Cleanup)
+	return l
+}
+
+// Allow checks if a request is allowed.
+func (l *SlidingWindowRateLimiter) Allow(key string) bool {
+	return l.AllowN(key, 1)
+}
+
+// AllowN checks if n requests are allowed, counting them if so.
+func (l *SlidingWindowRateLimiter) AllowN(key string, n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, ok := l.windows[key]
+	if !ok {
+		w = &slidingWindow{}
+		l.windows[key] = w
+	}
+	w.advance(now, l.window)
+	if w.estimate(now, l.window)+float64(n) > float64(l.limit) {
+		return false
+	}
+	w.curr += n
+	return true
+}
+
+// Reset forgets the requests counted for a key.
+func (l *SlidingWindowRateLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.windows, key)
+}
+
+// Status reports the state of key without counting a request. limit is
+// Limit, remaining the number of requests that would be allowed now, and
+// reset when the counted requests will all have left the window.
+func (l *SlidingWindowRateLimiter) Status(key string) (limit int, remaining int, reset time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, ok := l.windows[key]
+	if !ok {
+		return l.limit, l.limit, now
+	}
+	current := *w
+	current.advance(now, l.window)
+	return l.limit, slidingWindowStatus(l.limit, current.estimate(now, l.window)),
+		slidingWindowReset(now, current.start, current.prev, current.curr, l.window)
+}
+
+// slidingWindowStatus returns the requests left under limit given the
+// estimated count of the sliding window.
+func slidingWindowStatus(limit int, estimate float64) int {
+	remaining := int(math.Floor(float64(limit) - estimate))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// slidingWindowReset returns when the counts of the fixed window starting
+// at start and the one before it stop weighing on the sliding window.
+func slidingWindowReset(now, start time.Time, prev, curr int, window time.Duration) time.Time {
+	switch {
+	case curr > 0:
+		return start.Add(2 * window)
+	case prev > 0:
+		return start.Add(window)
+	}
+	return now
+}
+
+// Cleanup removes keys whose counts no longer weigh on the window.
+func (l *SlidingWindowRateLimiter) Cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, w := range l.windows {
+		if !now.Before(w.start.Add(2 * l.window)) {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// Stop shuts down the background cleanup goroutine. Safe to call multiple times.
+func (l *SlidingWindowRateLimiter) Stop() {
+	l.stopOnce.Do(func() { close(l.stopCh) })
+}
+
+// GCRAOptions configures a GCRARateLimiter.
+type GCRAOptions struct {
+	// Limit is the sustained number of requests allowed per Period
+	Limit int
+	// Period is the interval Limit applies to. Defaults to one second.
+	Period time.Duration
+	// Burst is the number of requests that may arrive at once. Defaults to
+	// Limit.
+	Burst int
+	// CleanupInterval is how often fully recovered keys are evicted.
+	// Defaults to one minute.
+	CleanupInterval time.Duration
+}
+
+// GCRARateLimiter implements RateLimiter with the generic cell rate
+// algorithm. Each key stores a single theoretical arrival time, which
+// advances by Period/Limit per request; a request is allowed while it is
+// no more than Burst requests ahead of now. It limits like a token bucket
+// refilled continuously, in constant memory per key.
+type GCRARateLimiter struct {
+	mu       sync.Mutex
+	tats     map[string]time.Time
+	interval time.Duration // emission interval, Period/Limit
+	burst    int
+	now      func() time.Time
+
+	cleanupInterval time.Duration
+	stopCh          chan struct{}
+	stopOnce        sync.Once
+}
+
+// NewGCRARateLimiter creates a limiter sustaining limit requests per period
+// with bursts of up to burst, and starts its background cleanup goroutine.
+func NewGCRARateLimiter(limit int, period time.Duration, burst int) *GCRARateLimiter {
+	return NewGCRARateLimiterWithOptions(GCRAOptions{Limit: limit, Period: period, Burst: burst})
+}
+
+// NewGCRARateLimiterWithOptions creates a GCRARateLimiter with the given
+// options and starts the background cleanup goroutine.
+func NewGCRARateLimiterWithOptions(opts GCRAOptions) *GCRARateLimiter {
+	if opts.Limit <= 0 {
+		opts.Limit = 1
+	}
+	if opts.Period <= 0 {
+		opts.Period = time.Second
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = opts.Limit
+	}
+	if opts.CleanupInterval <= 0 {
+		opts.CleanupInterval = time.Minute
+	}
+	l := &GCRARateLimiter{
+		tats:            make(map[string]time.Time),
+		interval:        opts.Period / time.Duration(opts.Limit),
+		burst:           opts.Burst,
+		now:             time.Now,
+		cleanupInterval: opts.CleanupInterval,
+		stopCh:          make(chan struct{}),
+	}
+	go runRateLimitCleanup(l.cleanupInterval, l.stopCh, l.Cleanup)
+	return l
+}
+
+// Allow checks if a request is allowed.
+func (l *GCRARateLimiter) Allow(key string) bool {
+	return l.AllowN(key, 1)
+}
+
+// AllowN checks if n requests are allowed, counting them if so.
+func (l *GCRARateLimiter) AllowN(key string, n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	tat := l.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(time.Duration(n) * l.interval)
+	if next.Sub(now) > time.Duration(l.burst)*l.interval {
+		return false
+	}
+	l.tats[key] = next
+	return true
+}
+
+// Reset forgets the requests counted for a key.
+func (l *GCRARateLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.tats, key)
+}
+
+// Status reports the state of key without counting a request. limit is
+// Burst, remaining the number of requests that would be allowed now, and
+// reset the theoretical arrival time, when the full burst is available
+// again.
+func (l *GCRARateLimiter) Status(key string) (limit int, remaining int, reset time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	tat := l.tats[key]
+	if tat.Before(now) {
+		return l.burst, l.burst, now
+	}
+	remaining = int((time.Duration(l.burst)*l.interval - tat.Sub(now)) / l.interval)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return l.burst, remaining, tat
+}
+
+// Cleanup removes keys whose full burst is available again.
+func (l *GCRARateLimiter) Cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, tat := range l.tats {
+		if !tat.After(now) {
+			delete(l.tats, key)
+		}
+	}
+}
+
+// Stop shuts down the background cleanup goroutine. Safe to call multiple times.
+func (l *GCRARateLimiter) Stop() {
+	l.stopOnce.Do(func() { close(l.stopCh) })
+}
+
+// runRateLimitCleanup calls cleanup every interval until stop is closed.
+func runRateLimitCleanup(interval time.Duration, stop <-chan struct{}, cleanup func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cleanup()
+		case <-stop:
+			return
+		}
+	}
+}
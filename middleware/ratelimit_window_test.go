@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock for the rate limiters.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	// Start on a window boundary so tests can reason about fixed windows.
+	return &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func allowCount(l RateLimiter, key string, attempts int) int {
+	allowed := 0
+	for i := 0; i < attempts; i++ {
+		if l.Allow(key) {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestSlidingWindowRateLimiter(t *testing.T) {
+	clock := newFakeClock()
+	l := NewSlidingWindowRateLimiter(10, time.Minute)
+	defer l.Stop()
+	l.now = clock.Now
+
+	limit, remaining, reset := l.Status("a")
+	assert.Equal(t, 10, limit)
+	assert.Equal(t, 10, remaining)
+	assert.Equal(t, clock.Now(), reset)
+
+	assert.Equal(t, 10, allowCount(l, "a", 15))
+	assert.Equal(t, 10, allowCount(l, "b", 10), "keys are independent")
+	_, remaining, reset = l.Status("a")
+	assert.Equal(t, 0, remaining)
+	assert.Equal(t, clock.Now().Add(2*time.Minute), reset)
+
+	// Halfway through the next window half of the previous window's count
+	// still weighs on the sliding window.
+	clock.Advance(90 * time.Second)
+	_, remaining, reset = l.Status("a")
+	assert.Equal(t, 5, remaining)
+	assert.Equal(t, clock.Now().Add(30*time.Second), reset)
+	assert.Equal(t, 5, allowCount(l, "a", 10))
+
+	// A client cannot send twice the limit across the window boundary.
+	clock.Advance(time.Minute)
+	_, remaining, _ = l.Status("a")
+	assert.Equal(t, 7, remaining, "5 requests weighted by the remaining half")
+
+	clock.Advance(2 * time.Minute)
+	_, remaining, reset = l.Status("a")
+	assert.Equal(t, 10, remaining)
+	assert.Equal(t, clock.Now(), reset)
+
+	assert.False(t, l.AllowN("a", 11))
+	assert.True(t, l.AllowN("a", 10))
+	l.Reset("a")
+	assert.True(t, l.Allow("a"))
+
+	l.Cleanup()
+	assert.Len(t, l.windows, 1, "b went idle two windows ago")
+	clock.Advance(2 * time.Minute)
+	l.Cleanup()
+	assert.Empty(t, l.windows)
+}
+
+func TestGCRARateLimiter(t *testing.T) {
+	clock := newFakeClock()
+	l := NewGCRARateLimiter(60, time.Minute, 5) // one per second, bursts of 5
+	defer l.Stop()
+	l.now = clock.Now
+
+	assert.Equal(t, 5, allowCount(l, "a", 10))
+	limit, remaining, reset := l.Status("a")
+	assert.Equal(t, 5, limit)
+	assert.Equal(t, 0, remaining)
+	assert.Equal(t, clock.Now().Add(5*time.Second), reset)
+
+	// Requests are released at the sustained rate.
+	clock.Advance(time.Second)
+	_, remaining, _ = l.Status("a")
+	assert.Equal(t, 1, remaining)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+
+	clock.Advance(2500 * time.Millisecond)
+	_, remaining, _ = l.Status("a")
+	assert.Equal(t, 2, remaining)
+	assert.False(t, l.AllowN("a", 3))
+	assert.True(t, l.AllowN("a", 2))
+
+	clock.Advance(time.Minute)
+	_, remaining, reset = l.Status("a")
+	assert.Equal(t, 5, remaining)
+	assert.Equal(t, clock.Now(), reset)
+	assert.False(t, l.AllowN("a", 6), "more than a burst is never allowed")
+
+	l.Allow("b")
+	l.Cleanup()
+	assert.Len(t, l.tats, 1)
+	l.Reset("b")
+	assert.Empty(t, l.tats)
+}
+
+// TestRateLimitHeaders_NewLimiters checks that the new limiters drive the
+// X-RateLimit-* and Retry-After headers through GortexRateLimitWithConfig.
+func TestRateLimitHeaders_NewLimiters(t *testing.T) {
+	clock := newFakeClock()
+	sliding := NewSlidingWindowRateLimiter(2, time.Minute)
+	defer sliding.Stop()
+	sliding.now = clock.Now
+	gcra := NewGCRARateLimiter(1, time.Second, 2)
+	defer gcra.Stop()
+	gcra.now = clock.Now
+
+	for name, store := range map[string]RateLimiter{"sliding": sliding, "gcra": gcra} {
+		t.Run(name, func(t *testing.T) {
+			allow := runRateLimiter(t, &GortexRateLimitConfig{Store: store})
+			rec := allow()
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "2", rec.Header().Get(HeaderRateLimitLimit))
+			assert.Equal(t, "1", rec.Header().Get(HeaderRateLimitRemaining))
+
+			allow()
+			rec = allow()
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+			assert.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+			reset, err := strconv.ParseInt(rec.Header().Get(HeaderRateLimitReset), 10, 64)
+			require.NoError(t, err)
+			assert.Greater(t, reset, clock.Now().Unix())
+			assert.NotEmpty(t, rec.Header().Get(HeaderRetryAfter))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/yshengliao/gortex/pkg/auth"
+	"github.com/yshengliao/gortex/pkg/auth/rbac"
+	"github.com/yshengliao/gortex/pkg/errors"
+)
+
+// RBACConfig contains configuration for the RBAC middleware
+type RBACConfig struct {
+	// Policy decides which roles hold which permissions
+	Policy *rbac.Policy
+	// Permissions are required in full. "{name}" placeholders are filled
+	// from the route's path parameters, e.g. "orders:{id}:read".
+	Permissions []string
+	// ClaimsContextKey is the key JWTAuth stored the claims under
+	ClaimsContextKey string
+	// Subject builds the subject to authorize from the request. It defaults
+	// to ClaimsSubject over the claims under ClaimsContextKey. A nil subject
+	// is rejected with 401.
+	Subject func(c Context) *rbac.Subject
+}
+
+// RBAC returns a middleware that requires the authenticated subject to hold
+// every permission in permissions under policy. It must run after JWTAuth.
+func RBAC(policy *rbac.Policy, permissions ...string) MiddlewareFunc {
+	return RBACWithConfig(&RBACConfig{Policy: policy, Permissions: permissions})
+}
+
+// RBACWithConfig returns an RBAC middleware with custom configuration. It
+// panics on a missing policy or a malformed permission.
+func RBACWithConfig(config *RBACConfig) MiddlewareFunc {
+	if config == nil {
+		panic("rbac middleware: config is required")
+	}
+	if config.Policy == nil {
+		panic("rbac middleware: Policy is required")
+	}
+	if len(config.Permissions) == 0 {
+		panic("rbac middleware: at least one permission is required")
+	}
+	if config.ClaimsContextKey == "" {
+		config.ClaimsContextKey = "jwt-claims"
+	}
+	if config.Subject == nil {
+		key := config.ClaimsContextKey
+		config.Subject = func(c Context) *rbac.Subject {
+			return ClaimsSubject(GetPrincipal(c, key))
+		}
+	}
+
+	required := make([]rbac.Permission, len(config.Permissions))
+	for i, s := range config.Permissions {
+		perm, err := rbac.ParsePermission(strings.TrimSpace(s))
+		if err != nil {
+			panic("rbac middleware: " + err.Error())
+		}
+		required[i] = perm
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			subject := config.Subject(c)
+			if subject == nil {
+				return &errors.ErrorResponse{
+					Success: false,
+					ErrorDetail: errors.ErrorDetail{
+						Code:    int(errors.CodeUnauthorized),
+						Message: "unauthorized",
+					},
+				}
+			}
+
+			for _, perm := range required {
+				if !config.Policy.Allowed(subject, perm, c.Param) {
+					return &errors.ErrorResponse{
+						Success: false,
+						ErrorDetail: errors.ErrorDetail{
+							Code:    int(errors.CodeForbidden),
+							Message: "insufficient permissions",
+							Details: map[string]interface{}{
+								"required_permission": perm.Expand(c.Param),
+							},
+						},
+					}
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// ClaimsSubject returns the RBAC subject for the claims stored by the auth
+// middleware: their principal ID and roles, and attributes for placeholders
+// in granted permissions. *auth.Claims provide user_id, username, email,
+// role and game_id; other claims provide user_id. It returns nil for nil
+// claims.
+func ClaimsSubject(principal auth.Principal) *rbac.Subject {
+	claims, ok := principal.(*auth.Claims)
+	if !ok {
+		if principal == nil {
+			return nil
+		}
+		id := principal.PrincipalID()
+		return &rbac.Subject{
+			ID:         id,
+			Roles:      principal.PrincipalRoles(),
+			Attributes: map[string]string{"user_id": id},
+		}
+	}
+	if claims == nil {
+		return nil
+	}
+	subject := &rbac.Subject{
+		ID: claims.UserID,
+		Attributes: map[string]string{
+			"user_id":  claims.UserID,
+			"username": claims.Username,
+			"email":    claims.Email,
+			"role":     claims.Role,
+			"game_id":  claims.GameID,
+		},
+	}
+	if claims.Role != "" {
+		subject.Roles = []string{claims.Role}
+	}
+	return subject
+}
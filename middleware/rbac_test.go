@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yshengliao/gortex/core/types"
+	"github.com/yshengliao/gortex/pkg/auth"
+	"github.com/yshengliao/gortex/pkg/auth/rbac"
+	"github.com/yshengliao/gortex/pkg/errors"
+)
+
+// roleClaims are custom claims exposing their roles to auth.Principal.
+type roleClaims struct {
+	Roles []string `json:"roles"`
+}
+
+func (r roleClaims) PrincipalRoles() []string { return r.Roles }
+
+func customClaims(subject string, roles ...string) *auth.ClaimsOf[roleClaims] {
+	return &auth.ClaimsOf[roleClaims]{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+		Custom:           roleClaims{Roles: roles},
+	}
+}
+
+func TestRBAC(t *testing.T) {
+	policy, err := rbac.NewPolicy(map[string]rbac.Role{
+		"viewer": {Permissions: []string{"orders:*:read", "users:{user_id}:write"}},
+		"editor": {Inherits: []string{"viewer"}, Permissions: []string{"orders:write"}},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		claims     auth.Principal
+		permission string
+		params     map[string]string
+		wantCode   errors.ErrorCode
+	}{
+		{"no claims", nil, "orders:write", nil, errors.CodeUnauthorized},
+		{"inherited permission", &auth.Claims{Role: "editor"}, "orders:{id}:read", map[string]string{"id": "7"}, 0},
+		{"missing permission", &auth.Claims{Role: "viewer"}, "orders:write", nil, errors.CodeForbidden},
+		{"owner", &auth.Claims{UserID: "u1", Role: "viewer"}, "users:{id}:write", map[string]string{"id": "u1"}, 0},
+		{"not owner", &auth.Claims{UserID: "u1", Role: "viewer"}, "users:{id}:write", map[string]string{"id": "u2"}, errors.CodeForbidden},
+		{"missing path parameter", &auth.Claims{Role: "editor"}, "orders:{id}:read", nil, errors.CodeForbidden},
+		{"custom claims owner", customClaims("u1", "viewer"), "users:{id}:write", map[string]string{"id": "u1"}, 0},
+		{"custom claims without roles", customClaims("u1"), "orders:{id}:read", map[string]string{"id": "7"}, errors.CodeForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder())
+			for k, v := range tt.params {
+				ctx.params[k] = v
+			}
+			if tt.claims != nil {
+				ctx.Set("jwt-claims", tt.claims)
+			}
+
+			called := false
+			err := RBAC(policy, tt.permission)(func(c types.Context) error {
+				called = true
+				return nil
+			})(ctx)
+
+			if tt.wantCode == 0 {
+				if err != nil || !called {
+					t.Fatalf("expected access, got %v", err)
+				}
+				return
+			}
+			resp, ok := err.(*errors.ErrorResponse)
+			if !ok || resp.ErrorDetail.Code != int(tt.wantCode) {
+				t.Fatalf("expected code %d, got %v", tt.wantCode, err)
+			}
+			if called {
+				t.Error("handler must not run when access is denied")
+			}
+		})
+	}
+}
+
+// Custom claims stored by JWTAuthOf reach RequireRole and the role tiers
+// through auth.Principal.
+func TestPrincipalFromCustomClaims(t *testing.T) {
+	ctx := newTestContext(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder())
+	ctx.Set("jwt-claims", customClaims("u1", "pro", "admin"))
+
+	if p := GetPrincipal(ctx); p == nil || p.PrincipalID() != "u1" {
+		t.Fatalf("GetPrincipal = %v", p)
+	}
+	if got := GetUserID(ctx); got != "u1" {
+		t.Errorf("GetUserID = %q", got)
+	}
+	if tier := RateLimitTierByRole()(ctx); tier != "pro" {
+		t.Errorf("tier = %q, want pro", tier)
+	}
+	if err := RequireRole("admin")(func(c types.Context) error { return nil })(ctx); err != nil {
+		t.Errorf("RequireRole(admin): %v", err)
+	}
+	if err := RequireRole("owner")(func(c types.Context) error { return nil })(ctx); err == nil {
+		t.Error("RequireRole(owner) should be forbidden")
+	}
+}
+
+func TestRBACDeniedDetails(t *testing.T) {
+	policy, _ := rbac.NewPolicy(map[string]rbac.Role{"viewer": {}})
+	ctx := newTestContext(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder())
+	ctx.params["id"] = "42"
+	ctx.Set("jwt-claims", &auth.Claims{Role: "viewer"})
+
+	err := RBAC(policy, "orders:{id}:read")(func(c types.Context) error { return nil })(ctx)
+	resp, ok := err.(*errors.ErrorResponse)
+	if !ok {
+		t.Fatalf("expected ErrorResponse, got %v", err)
+	}
+	if got := resp.ErrorDetail.Details["required_permission"]; got != "orders:42:read" {
+		t.Errorf("required_permission = %v", got)
+	}
+}
+
+func TestRBACWithConfigPanics(t *testing.T) {
+	policy, _ := rbac.NewPolicy(nil)
+	for name, config := range map[string]*RBACConfig{
+		"nil config":           nil,
+		"nil policy":           {Permissions: []string{"orders:read"}},
+		"no permissions":       {Policy: policy},
+		"malformed permission": {Policy: policy, Permissions: []string{"orders::read"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic")
+				}
+			}()
+			RBACWithConfig(config)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"github.com/yshengliao/gortex/pkg/errors"
+	"github.com/yshengliao/gortex/pkg/session"
+)
+
+// GetSession returns the session the Sessions middleware loaded for the
+// request, or nil.
+func GetSession(c Context) *session.Session {
+	s, _ := c.Get(session.ContextKey).(*session.Session)
+	return s
+}
+
+// Sessions returns a middleware that loads the request's session through
+// manager, exposes it through GetSession (and session.FromContext on the
+// request context) and commits it just before the response header is
+// written, extending its expiry.
+func Sessions(manager *session.Manager) MiddlewareFunc {
+	if manager == nil {
+		panic("sessions middleware: Manager is required")
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+			s, err := manager.Load(req)
+			if err != nil {
+				return &errors.ErrorResponse{
+					Success: false,
+					ErrorDetail: errors.ErrorDetail{
+						Code:    int(errors.CodeInternalServerError),
+						Message: "failed to load session",
+					},
+				}
+			}
+			c.Set(session.ContextKey, s)
+			c.SetRequest(req.WithContext(session.NewContext(req.Context(), s)))
+
+			// A response written by the handler commits through the Before
+			// hook, while headers can still change; one that is not (an
+			// error returned to the error handler) commits here.
+			committed := false
+			var commitErr error
+			commit := func() {
+				if !committed {
+					committed = true
+					commitErr = manager.Commit(req.Context(), c.Response(), s)
+				}
+			}
+			if hook, ok := c.Response().(interface{ Before(func()) }); ok {
+				hook.Before(commit)
+			}
+
+			err = next(c)
+			if !c.Response().Written() {
+				commit()
+				if err == nil && commitErr != nil {
+					return &errors.ErrorResponse{
+						Success: false,
+						ErrorDetail: errors.ErrorDetail{
+							Code:    int(errors.CodeInternalServerError),
+							Message: "failed to save session",
+						},
+					}
+				}
+			}
+			return err
+		}
+	}
+}
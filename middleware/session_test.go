@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yshengliao/gortex/pkg/session"
+)
+
+func newSessionManager(t *testing.T) (*session.Manager, *session.MemoryStore) {
+	t.Helper()
+	store := session.NewMemoryStore(time.Minute)
+	t.Cleanup(store.Stop)
+	manager, err := session.NewManager(session.Config{Store: store})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return manager, store
+}
+
+func sessionCookie(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "session_id" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestSessions(t *testing.T) {
+	manager, _ := newSessionManager(t)
+	mw := Sessions(manager)
+
+	// The session is committed before the handler's response is written.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	err := mw(func(c Context) error {
+		GetSession(c).Set("visits", 1)
+		if session.FromContext(c.Request().Context()) != GetSession(c) {
+			t.Error("request context does not carry the session")
+		}
+		return c.String(http.StatusOK, "ok")
+	})(newMockContext(req, rec))
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	cookie := sessionCookie(rec)
+	if cookie == nil {
+		t.Fatal("no session cookie on a written response")
+	}
+
+	// A handler returning an error still gets its session saved.
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	_ = mw(func(c Context) error {
+		if GetSession(c).Get("visits") != 1 {
+			t.Errorf("visits = %v, want 1", GetSession(c).Get("visits"))
+		}
+		return errors.New("boom")
+	})(newMockContext(req, rec))
+	if sessionCookie(rec) == nil {
+		t.Error("session not committed when the handler failed")
+	}
+
+	// Without the middleware there is no session.
+	ctx := newMockContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	if GetSession(ctx) != nil {
+		t.Error("Session() without the middleware is not nil")
+	}
+}
+
+func TestCSRFSessionBound(t *testing.T) {
+	manager, _ := newSessionManager(t)
+	var regenerate bool
+	handler := Sessions(manager)(CSRFWithConfig(CSRFConfig{SessionBound: true})(func(c Context) error {
+		if regenerate {
+			if err := GetSession(c).Regenerate(); err != nil {
+				return err
+			}
+		}
+		return c.String(http.StatusOK, "ok")
+	}))
+	serve := func(method string, cookie *http.Cookie, token string) (*httptest.ResponseRecorder, error) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if token != "" {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		return rec, handler(newMockContext(req, rec))
+	}
+
+	rec, err := serve(http.MethodGet, nil, "")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	token := rec.Header().Get("X-CSRF-Token")
+	cookie := sessionCookie(rec)
+	if token == "" || cookie == nil {
+		t.Fatal("GET did not issue a token in a session")
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "_csrf" {
+			t.Error("session-bound CSRF set a cookie")
+		}
+	}
+
+	if _, err := serve(http.MethodPost, cookie, token); err != nil {
+		t.Fatalf("POST with token: %v", err)
+	}
+	if _, err := serve(http.MethodPost, cookie, "forged"); err != ErrCSRFTokenMismatch {
+		t.Errorf("POST with forged token: %v", err)
+	}
+	if _, err := serve(http.MethodPost, nil, token); err != ErrCSRFTokenMissing {
+		t.Errorf("POST from another session: %v", err)
+	}
+
+	// Logging in regenerates the session, retiring the token.
+	regenerate = true
+	rec, err = serve(http.MethodPost, cookie, token)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	regenerate = false
+	cookie = sessionCookie(rec)
+	if _, err := serve(http.MethodPost, cookie, token); err != ErrCSRFTokenMissing {
+		t.Errorf("pre-login token after regeneration: %v", err)
+	}
+	rec, _ = serve(http.MethodGet, cookie, "")
+	if fresh := rec.Header().Get("X-CSRF-Token"); fresh == "" || fresh == token {
+		t.Errorf("token after regeneration = %q, want a new one", fresh)
+	}
+
+	// SessionBound without Sessions is a configuration error.
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := CSRFWithConfig(CSRFConfig{SessionBound: true})(okHandler)(newMockContext(req, rec)); err == nil {
+		t.Error("SessionBound without Sessions succeeded")
+	}
+}
+
+func TestOIDCWithSessions(t *testing.T) {
+	idp, _, _ := newOIDCTest(t)
+	provider := newOIDCProvider(t, idp)
+	manager, store := newSessionManager(t)
+	mw := func(next HandlerFunc) HandlerFunc {
+		return Sessions(manager)(OIDC(provider, nil)(next))
+	}
+
+	// An anonymous session from before the login.
+	rec := httptest.NewRecorder()
+	_ = mw(func(c Context) error {
+		GetSession(c).Set("cart", "3 items")
+		return c.String(http.StatusOK, "ok")
+	})(newMockContext(httptest.NewRequest(http.MethodGet, "/", nil), rec))
+	anonymous := sessionCookie(rec)
+
+	callback, cookies := oidcLoginFlow(t, mw, "/auth/login")
+	rec, err := oidcCallbackRequest(t, mw, callback, append(cookies, anonymous))
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	loggedIn := sessionCookie(rec)
+	if loggedIn == nil || loggedIn.Value == anonymous.Value {
+		t.Fatalf("session ID not regenerated: %v", loggedIn)
+	}
+	data, err := store.Get(loggedIn.Value)
+	if err != nil || data["user_id"] != idp.Subject || data["cart"] != nil {
+		t.Errorf("session data = %v, %v", data, err)
+	}
+	if valid, _ := store.Validate(anonymous.Value); valid {
+		t.Error("pre-login session survived the login")
+	}
+}
@@ -0,0 +1,272 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yshengliao/gortex/pkg/errors"
+)
+
+// signaturePrefix names the algorithm in the signature header value.
+const signaturePrefix = "sha256="
+
+// SignatureConfig contains configuration for the webhook signature middleware
+type SignatureConfig struct {
+	// Secrets are the shared HMAC secrets; a signature made with any of them
+	// is accepted, so a secret can be rotated by listing the new one first.
+	Secrets [][]byte
+	// SignatureHeader carries "sha256=<hex HMAC>". Default
+	// "X-Webhook-Signature".
+	SignatureHeader string
+	// TimestampHeader carries the signing time in Unix seconds. Default
+	// "X-Webhook-Timestamp".
+	TimestampHeader string
+	// NonceHeader carries a unique value per request and is required.
+	// Default "X-Webhook-Nonce".
+	NonceHeader string
+	// Tolerance is how far the timestamp may be from the server clock, in
+	// either direction. Default 5 minutes.
+	Tolerance time.Duration
+	// NonceStore rejects any nonce seen before within the tolerance window.
+	// When nil, a MemoryNonceStore is created and written back here so the
+	// caller can Stop it on shutdown; share a store between instances
+	// instead when several serve the same webhooks.
+	NonceStore NonceStore
+	// MaxBodySize caps the body read for the digest. Default 1 MiB, the
+	// limit Bind also applies.
+	MaxBodySize int64
+	// SkipPaths is a list of paths to skip verification
+	SkipPaths []string
+}
+
+// NonceStore remembers the nonces of verified requests. Implementations
+// must be safe for concurrent use; a store shared between instances makes
+// replay detection work across them.
+type NonceStore interface {
+	// UseNonce records nonce until expiresAt. It returns false if the nonce
+	// was already recorded and has not expired.
+	UseNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// WebhookSignature returns a middleware that verifies HMAC-SHA256 request
+// signatures made with secret and rejects replayed nonces with a
+// MemoryNonceStore. Use WebhookSignatureWithConfig to reach the store and
+// stop its cleanup goroutine with the app.
+func WebhookSignature(secret []byte) MiddlewareFunc {
+	return WebhookSignatureWithConfig(&SignatureConfig{Secrets: [][]byte{secret}})
+}
+
+// WebhookSignatureWithConfig returns a webhook signature middleware with
+// custom configuration.
+//
+// The signature is the HMAC-SHA256 of the canonical string
+//
+//	METHOD \n PATH?QUERY \n TIMESTAMP \n NONCE \n hex(SHA-256(body))
+//
+// as computed by SignWebhook. The body is read once for the digest and
+// then restored, so Bind and the parameter binder still see it.
+func WebhookSignatureWithConfig(config *SignatureConfig) MiddlewareFunc {
+	if config == nil {
+		panic("webhook signature middleware: config is required")
+	}
+	if len(config.Secrets) == 0 {
+		panic("webhook signature middleware: Secrets is required")
+	}
+	for _, secret := range config.Secrets {
+		if len(secret) == 0 {
+			panic("webhook signature middleware: secrets must not be empty")
+		}
+	}
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = "X-Webhook-Signature"
+	}
+	if config.TimestampHeader == "" {
+		config.TimestampHeader = "X-Webhook-Timestamp"
+	}
+	if config.NonceHeader == "" {
+		config.NonceHeader = "X-Webhook-Nonce"
+	}
+	if config.Tolerance <= 0 {
+		config.Tolerance = 5 * time.Minute
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+	if config.NonceStore == nil {
+		// Write the store back so the caller can Stop() its cleanup goroutine.
+		config.NonceStore = NewMemoryNonceStore(0)
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+
+			// Skip if path is in skip list (same segment-boundary rule as JWTAuth).
+			for _, skip := range config.SkipPaths {
+				if req.URL.Path == skip ||
+					strings.HasPrefix(req.URL.Path, strings.TrimSuffix(skip, "/")+"/") {
+					return next(c)
+				}
+			}
+
+			signature := req.Header.Get(config.SignatureHeader)
+			timestamp := req.Header.Get(config.TimestampHeader)
+			nonce := req.Header.Get(config.NonceHeader)
+			if signature == "" || timestamp == "" {
+				return signatureError(errors.CodeUnauthorized, "missing request signature")
+			}
+			if nonce == "" {
+				return signatureError(errors.CodeUnauthorized, "missing request nonce")
+			}
+
+			// Check the timestamp before reading the body: stale requests are
+			// cheap to reject.
+			unix, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return signatureError(errors.CodeUnauthorized, "invalid request timestamp")
+			}
+			signedAt := time.Unix(unix, 0)
+			if age := time.Since(signedAt); age > config.Tolerance || age < -config.Tolerance {
+				return signatureError(errors.CodeUnauthorized, "request timestamp outside the allowed window")
+			}
+
+			body, err := io.ReadAll(io.LimitReader(req.Body, config.MaxBodySize+1))
+			req.Body.Close()
+			if err != nil {
+				return signatureError(errors.CodeInvalidInput, "failed to read request body")
+			}
+			if int64(len(body)) > config.MaxBodySize {
+				return signatureError(errors.CodePayloadTooLarge, "request body too large")
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			if !validSignature(config.Secrets, signature, req.Method, req.URL.RequestURI(), timestamp, nonce, body) {
+				return signatureError(errors.CodeUnauthorized, "invalid request signature")
+			}
+
+			// Only verified nonces are recorded, so forged requests cannot
+			// burn a partner's nonces. A nonce must outlive the window in
+			// which its timestamp is accepted.
+			fresh, err := config.NonceStore.UseNonce(req.Context(), nonce, signedAt.Add(config.Tolerance))
+			if err != nil {
+				return signatureError(errors.CodeInternalServerError, "nonce check failed")
+			}
+			if !fresh {
+				return signatureError(errors.CodeUnauthorized, "replayed request")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// SignWebhook returns the signature header value for a request, for
+// senders and tests. path includes the query string, if any.
+func SignWebhook(secret []byte, method, path, timestamp, nonce string, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(webhookMAC(secret, method, path, timestamp, nonce, body))
+}
+
+func webhookMAC(secret []byte, method, path, timestamp, nonce string, body []byte) []byte {
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	io.WriteString(mac, method+"\n"+path+"\n"+timestamp+"\n"+nonce+"\n"+hex.EncodeToString(digest[:]))
+	return mac.Sum(nil)
+}
+
+func validSignature(secrets [][]byte, signature, method, path, timestamp, nonce string, body []byte) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return false
+	}
+	for _, secret := range secrets {
+		if hmac.Equal(got, webhookMAC(secret, method, path, timestamp, nonce, body)) {
+			return true
+		}
+	}
+	return false
+}
+
+func signatureError(code errors.ErrorCode, message string) error {
+	return &errors.ErrorResponse{
+		Success: false,
+		ErrorDetail: errors.ErrorDetail{
+			Code:    int(code),
+			Message: message,
+		},
+	}
+}
+
+// MemoryNonceStore implements NonceStore in memory, for single-instance
+// deployments. Expired nonces are evicted by a background goroutine.
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+
+	cleanupInterval time.Duration
+	stopCh          chan struct{}
+	stopOnce        sync.Once
+}
+
+// NewMemoryNonceStore creates a MemoryNonceStore that evicts expired
+// nonces every cleanupInterval (default 1 minute).
+func NewMemoryNonceStore(cleanupInterval time.Duration) *MemoryNonceStore {
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+	s := &MemoryNonceStore{
+		nonces:          make(map[string]time.Time),
+		cleanupInterval: cleanupInterval,
+		stopCh:          make(chan struct{}),
+	}
+	go s.runCleanup()
+	return s
+}
+
+func (s *MemoryNonceStore) runCleanup() {
+	ticker := time.NewTicker(s.cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Cleanup()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// Stop shuts down the background cleanup goroutine. Safe to call multiple times.
+func (s *MemoryNonceStore) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+}
+
+// Cleanup removes expired nonces.
+func (s *MemoryNonceStore) Cleanup() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for nonce, expiresAt := range s.nonces {
+		if now.After(expiresAt) {
+			delete(s.nonces, nonce)
+		}
+	}
+}
+
+// UseNonce implements NonceStore.
+func (s *MemoryNonceStore) UseNonce(_ context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if seen, ok := s.nonces[nonce]; ok && time.Now().Before(seen) {
+		return false, nil
+	}
+	s.nonces[nonce] = expiresAt
+	return true, nil
+}
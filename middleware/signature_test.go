@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gortexerrors "github.com/yshengliao/gortex/pkg/errors"
+)
+
+var webhookSecret = []byte("whsec_test")
+
+// signedRequest builds a webhook request signed with secret at signedAt.
+func signedRequest(secret []byte, target, body, nonce string, signedAt time.Time) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Nonce", nonce)
+	req.Header.Set("X-Webhook-Signature", SignWebhook(secret, http.MethodPost, req.URL.RequestURI(), timestamp, nonce, []byte(body)))
+	return req
+}
+
+func TestWebhookSignature(t *testing.T) {
+	mw := WebhookSignature(webhookSecret)
+	body := `{"event":"invoice.paid"}`
+
+	var got string
+	err := mw(func(c Context) error {
+		b, err := io.ReadAll(c.Request().Body)
+		got = string(b)
+		return err
+	})(newMockContext(signedRequest(webhookSecret, "/hooks/billing?v=2", body, "n1", time.Now()), httptest.NewRecorder()))
+	if err != nil {
+		t.Fatalf("valid signature: %v", err)
+	}
+	if got != body {
+		t.Errorf("handler read body %q, want %q", got, body)
+	}
+
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		status int
+	}{
+		{"wrong secret", func() *http.Request {
+			return signedRequest([]byte("other"), "/hooks/billing", body, "n1", time.Now())
+		}, http.StatusUnauthorized},
+		{"tampered body", func() *http.Request {
+			req := signedRequest(webhookSecret, "/hooks/billing", body, "n1", time.Now())
+			req.Body = io.NopCloser(strings.NewReader(`{"event":"invoice.refunded"}`))
+			return req
+		}, http.StatusUnauthorized},
+		{"tampered query", func() *http.Request {
+			req := signedRequest(webhookSecret, "/hooks/billing?v=2", body, "n1", time.Now())
+			req.URL.RawQuery = "v=3"
+			return req
+		}, http.StatusUnauthorized},
+		{"stale timestamp", func() *http.Request {
+			return signedRequest(webhookSecret, "/hooks/billing", body, "n1", time.Now().Add(-10*time.Minute))
+		}, http.StatusUnauthorized},
+		{"future timestamp", func() *http.Request {
+			return signedRequest(webhookSecret, "/hooks/billing", body, "n1", time.Now().Add(10*time.Minute))
+		}, http.StatusUnauthorized},
+		{"missing signature", func() *http.Request {
+			req := signedRequest(webhookSecret, "/hooks/billing", body, "n1", time.Now())
+			req.Header.Del("X-Webhook-Signature")
+			return req
+		}, http.StatusUnauthorized},
+		{"not hex", func() *http.Request {
+			req := signedRequest(webhookSecret, "/hooks/billing", body, "n1", time.Now())
+			req.Header.Set("X-Webhook-Signature", "sha256=zz")
+			return req
+		}, http.StatusUnauthorized},
+		{"missing nonce", func() *http.Request {
+			return signedRequest(webhookSecret, "/hooks/billing", body, "", time.Now())
+		}, http.StatusUnauthorized},
+		{"replayed nonce", func() *http.Request {
+			return signedRequest(webhookSecret, "/hooks/billing?v=2", body, "n1", time.Now())
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mw(okHandler)(newMockContext(tt.req(), httptest.NewRecorder()))
+			if got := gortexerrors.GetHTTPStatus(errorCode(t, err)); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestWebhookSignature_RotationAndBodyLimit(t *testing.T) {
+	newSecret := []byte("whsec_new")
+	config := &SignatureConfig{Secrets: [][]byte{newSecret, webhookSecret}, MaxBodySize: 16}
+	mw := WebhookSignatureWithConfig(config)
+	defer config.NonceStore.(*MemoryNonceStore).Stop()
+
+	for _, secret := range [][]byte{newSecret, webhookSecret} {
+		req := signedRequest(secret, "/hooks", `{}`, string(secret), time.Now())
+		if err := mw(okHandler)(newMockContext(req, httptest.NewRecorder())); err != nil {
+			t.Errorf("secret %q: %v", secret, err)
+		}
+	}
+
+	req := signedRequest(webhookSecret, "/hooks", strings.Repeat("a", 17), "n1", time.Now())
+	if got := errorCode(t, mw(okHandler)(newMockContext(req, httptest.NewRecorder()))); got != gortexerrors.CodePayloadTooLarge {
+		t.Errorf("oversized body code = %d, want %d", got, gortexerrors.CodePayloadTooLarge)
+	}
+}
+
+func TestWebhookSignature_Replay(t *testing.T) {
+	store := NewMemoryNonceStore(time.Minute)
+	defer store.Stop()
+	mw := WebhookSignatureWithConfig(&SignatureConfig{Secrets: [][]byte{webhookSecret}, NonceStore: store})
+	serve := func(req *http.Request) error {
+		return mw(okHandler)(newMockContext(req, httptest.NewRecorder()))
+	}
+
+	now := time.Now()
+	if err := serve(signedRequest(webhookSecret, "/hooks", `{}`, "n1", now)); err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if err := serve(signedRequest(webhookSecret, "/hooks", `{}`, "n1", now)); err == nil {
+		t.Error("replayed nonce accepted")
+	}
+	if err := serve(signedRequest(webhookSecret, "/hooks", `{}`, "n2", now)); err != nil {
+		t.Errorf("new nonce: %v", err)
+	}
+
+	// A forged request does not burn the nonce.
+	if err := serve(signedRequest([]byte("forged"), "/hooks", `{}`, "n3", now)); err == nil {
+		t.Fatal("forged request accepted")
+	}
+	if err := serve(signedRequest(webhookSecret, "/hooks", `{}`, "n3", now)); err != nil {
+		t.Errorf("nonce burned by a forged request: %v", err)
+	}
+}
+
+func TestMemoryNonceStore_Expiry(t *testing.T) {
+	store := NewMemoryNonceStore(time.Minute)
+	defer store.Stop()
+	ctx := context.Background()
+
+	if fresh, _ := store.UseNonce(ctx, "n1", time.Now().Add(-time.Second)); !fresh {
+		t.Fatal("first use not fresh")
+	}
+	if fresh, _ := store.UseNonce(ctx, "n1", time.Now().Add(time.Minute)); !fresh {
+		t.Error("expired nonce still blocks")
+	}
+	if fresh, _ := store.UseNonce(ctx, "n1", time.Now().Add(time.Minute)); fresh {
+		t.Error("live nonce reused")
+	}
+
+	_, _ = store.UseNonce(ctx, "old", time.Now().Add(-time.Second))
+	store.Cleanup()
+	store.mu.Lock()
+	_, kept := store.nonces["old"]
+	store.mu.Unlock()
+	if kept {
+		t.Error("Cleanup kept an expired nonce")
+	}
+}
@@ -368,6 +368,7 @@ type testResponseWriter struct {
 	status  int
 	size    int64
 	written bool
+	before  []func()
 }
 
 // newTestResponseWriter creates a new test response writer
@@ -396,9 +397,19 @@ func (w *testResponseWriter) Written() bool {
 	return w.written
 }
 
+// Before registers fn to run just before the header is written
+func (w *testResponseWriter) Before(fn func()) {
+	w.before = append(w.before, fn)
+}
+
 // WriteHeader writes the header
 func (w *testResponseWriter) WriteHeader(code int) {
 	if !w.written {
+		hooks := w.before
+		w.before = nil
+		for i := len(hooks) - 1; i >= 0; i-- {
+			hooks[i]()
+		}
 		w.status = code
 		w.written = true
 		w.ResponseWriter.WriteHeader(code)
@@ -0,0 +1,183 @@
+// Package apikey issues and verifies API keys for machine-to-machine
+// clients.
+//
+// A key is shown to its owner once, as a token "gtx_<id>_<secret>". Only the
+// ID and a SHA-256 hash of the secret are stored, so a leaked KeyStore does
+// not leak usable keys, and the ID identifies the key in logs and rate
+// limits without revealing it:
+//
+//	token, key, err := apikey.Generate("billing-service", []string{"invoices:read"}, 90*24*time.Hour)
+//	store.Add(key) // hand token to the client
+//
+//	key, err := apikey.Verify(ctx, store, token)
+//	key.HasScope("invoices:read") // true
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenPrefix starts every API key token, so secret scanners can spot keys.
+const TokenPrefix = "gtx_"
+
+// idLength is the length of a key ID: 80 random bits of base32.
+const idLength = 16
+
+var (
+	// ErrInvalidKey is returned for malformed, unknown or wrong keys.
+	ErrInvalidKey = errors.New("apikey: invalid key")
+	// ErrKeyExpired is returned for a key past its ExpiresAt.
+	ErrKeyExpired = errors.New("apikey: key expired")
+)
+
+// Key is a stored API key.
+type Key struct {
+	// ID is the public part of the key, safe to log.
+	ID string
+	// Hash is the SHA-256 hash of the secret part.
+	Hash []byte
+	// Subject is who the key acts for, e.g. a service or user ID.
+	Subject string
+	// Name describes the key to humans.
+	Name string
+	// Role, when set, is the role the key holds for RBAC.
+	Role string
+	// Scopes are the operations the key may perform. "*" allows all.
+	Scopes []string
+	// CreatedAt is when the key was issued.
+	CreatedAt time.Time
+	// ExpiresAt is when the key stops working; zero means never.
+	ExpiresAt time.Time
+}
+
+// HasScope reports whether the key allows scope.
+func (k *Key) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, "*")
+}
+
+// Expired reports whether the key has expired.
+func (k *Key) Expired() bool {
+	return !k.ExpiresAt.IsZero() && time.Now().After(k.ExpiresAt)
+}
+
+// KeyStore looks up stored keys. Implementations must be safe for
+// concurrent use.
+type KeyStore interface {
+	// LookupKey returns the key with ID id, or ErrInvalidKey.
+	LookupKey(ctx context.Context, id string) (*Key, error)
+}
+
+// Generate issues a key for subject with scopes, expiring after ttl (never
+// if ttl is zero). It returns the token to hand to the client, which is not
+// stored anywhere, and the Key to store.
+func Generate(subject string, scopes []string, ttl time.Duration) (string, *Key, error) {
+	if subject == "" {
+		return "", nil, errors.New("apikey: subject is required")
+	}
+	id := rand.Text()[:idLength]
+	secret := rand.Text()
+	key := &Key{
+		ID:        id,
+		Hash:      HashSecret(secret),
+		Subject:   subject,
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		key.ExpiresAt = key.CreatedAt.Add(ttl)
+	}
+	return TokenPrefix + id + "_" + secret, key, nil
+}
+
+// Parse splits a token into its key ID and secret.
+func Parse(token string) (id, secret string, err error) {
+	rest, ok := strings.CutPrefix(token, TokenPrefix)
+	if !ok {
+		return "", "", ErrInvalidKey
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", ErrInvalidKey
+	}
+	return id, secret, nil
+}
+
+// HashSecret returns the stored hash of a key secret. Secrets are random
+// and long, so a fast hash suffices.
+func HashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// Verify checks token against store and returns its key. It returns
+// ErrInvalidKey for a malformed, unknown or wrong token and ErrKeyExpired
+// for an expired key.
+func Verify(ctx context.Context, store KeyStore, token string) (*Key, error) {
+	id, secret, err := Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	key, err := store.LookupKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(HashSecret(secret), key.Hash) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if key.Expired() {
+		return nil, ErrKeyExpired
+	}
+	return key, nil
+}
+
+// MemoryStore implements KeyStore in memory, for single-instance
+// deployments and tests.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]*Key
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]*Key)}
+}
+
+// Add stores key, replacing any key with the same ID.
+func (s *MemoryStore) Add(key *Key) error {
+	if key == nil || key.ID == "" || len(key.Hash) != sha256.Size {
+		return errors.New("apikey: key needs an ID and a SHA-256 hash")
+	}
+	stored := *key
+	stored.Scopes = slices.Clone(key.Scopes)
+	s.mu.Lock()
+	s.keys[key.ID] = &stored
+	s.mu.Unlock()
+	return nil
+}
+
+// Revoke deletes the key with ID id.
+func (s *MemoryStore) Revoke(id string) {
+	s.mu.Lock()
+	delete(s.keys, id)
+	s.mu.Unlock()
+}
+
+// LookupKey implements KeyStore.
+func (s *MemoryStore) LookupKey(_ context.Context, id string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	found := *key
+	return &found, nil
+}
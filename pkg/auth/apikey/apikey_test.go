@@ -0,0 +1,88 @@
+package apikey_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/pkg/auth/apikey"
+)
+
+func TestGenerateAndVerify(t *testing.T) {
+	ctx := context.Background()
+	store := apikey.NewMemoryStore()
+
+	token, key, err := apikey.Generate("billing", []string{"invoices:read"}, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.Add(key))
+
+	assert.True(t, strings.HasPrefix(token, apikey.TokenPrefix))
+	id, secret, err := apikey.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, id)
+	assert.NotContains(t, string(key.Hash), secret, "only the hash is stored")
+
+	verified, err := apikey.Verify(ctx, store, token)
+	require.NoError(t, err)
+	assert.Equal(t, "billing", verified.Subject)
+	assert.True(t, verified.HasScope("invoices:read"))
+	assert.False(t, verified.HasScope("invoices:write"))
+
+	// A right ID with a wrong secret fails.
+	_, err = apikey.Verify(ctx, store, apikey.TokenPrefix+id+"_"+strings.Repeat("A", len(secret)))
+	assert.ErrorIs(t, err, apikey.ErrInvalidKey)
+
+	for _, bad := range []string{"", "gtx_", "gtx_id", "gtx__secret", "sk_" + id + "_" + secret} {
+		_, err = apikey.Verify(ctx, store, bad)
+		assert.ErrorIs(t, err, apikey.ErrInvalidKey, "token %q", bad)
+	}
+
+	store.Revoke(key.ID)
+	_, err = apikey.Verify(ctx, store, token)
+	assert.ErrorIs(t, err, apikey.ErrInvalidKey)
+}
+
+func TestVerify_Expired(t *testing.T) {
+	store := apikey.NewMemoryStore()
+	token, key, err := apikey.Generate("billing", nil, time.Hour)
+	require.NoError(t, err)
+	key.ExpiresAt = time.Now().Add(-time.Second)
+	require.NoError(t, store.Add(key))
+
+	_, err = apikey.Verify(context.Background(), store, token)
+	assert.ErrorIs(t, err, apikey.ErrKeyExpired)
+
+	// A zero ttl never expires.
+	_, key, err = apikey.Generate("billing", nil, 0)
+	require.NoError(t, err)
+	assert.True(t, key.ExpiresAt.IsZero())
+	assert.False(t, key.Expired())
+}
+
+func TestKey_WildcardScope(t *testing.T) {
+	key := &apikey.Key{Scopes: []string{"*"}}
+	assert.True(t, key.HasScope("anything:at:all"))
+}
+
+func TestMemoryStore_Add(t *testing.T) {
+	store := apikey.NewMemoryStore()
+	assert.Error(t, store.Add(nil))
+	assert.Error(t, store.Add(&apikey.Key{ID: "k1", Hash: []byte("short")}))
+
+	_, key, err := apikey.Generate("billing", []string{"a"}, 0)
+	require.NoError(t, err)
+	require.NoError(t, store.Add(key))
+
+	// The store keeps its own copy.
+	key.Scopes[0] = "changed"
+	stored, err := store.LookupKey(context.Background(), key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, stored.Scopes)
+
+	_, _, err = apikey.Generate("", nil, 0)
+	assert.Error(t, err)
+}
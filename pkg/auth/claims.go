@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Principal is the authenticated identity the auth middleware stores for a
+// request. Claims and ClaimsOf implement it, so checks that only need the
+// subject, roles or scopes work with either claims type.
+type Principal interface {
+	// PrincipalID identifies the user or client the token was issued to.
+	PrincipalID() string
+	// PrincipalRoles returns the roles the token grants.
+	PrincipalRoles() []string
+	// PrincipalScopes returns the scopes the token grants.
+	PrincipalScopes() []string
+}
+
+// ClaimsOf holds the claims of an access token that carries
+// application-defined claims T next to the registered ones. T must encode
+// to a JSON object; its fields are written at the top level of the token
+// payload and must not reuse a registered claim name ("sub", "exp", ...)
+// or "typ" and "fam":
+//
+//	type TenantClaims struct {
+//		Tenant string   `json:"tenant"`
+//		Scopes []string `json:"scopes"`
+//		Level  int      `json:"level"`
+//	}
+//
+//	claims.Custom.Scopes // []string{"orders:read"}
+//
+// ClaimsOf implements Principal with the token's subject. Roles and scopes
+// come from Custom when T has PrincipalRoles or PrincipalScopes methods:
+//
+//	func (t TenantClaims) PrincipalScopes() []string { return t.Scopes }
+type ClaimsOf[T any] struct {
+	jwt.RegisteredClaims
+	// TokenType is always "access"; see Claims.TokenType.
+	TokenType string
+	// Custom holds the application-defined claims.
+	Custom T
+}
+
+// registeredClaims is the JSON shape of the claims every token carries.
+type registeredClaims struct {
+	jwt.RegisteredClaims
+	TokenType string `json:"typ,omitempty"`
+	Family    string `json:"fam,omitempty"`
+}
+
+// reservedClaims are the claim names custom claims must not use.
+var reservedClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "typ", "fam"}
+
+// MarshalJSON writes the registered claims and the fields of Custom as one
+// object.
+func (c ClaimsOf[T]) MarshalJSON() ([]byte, error) {
+	custom, err := json.Marshal(c.Custom)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(custom, &fields); err != nil {
+		return nil, fmt.Errorf("auth: custom claims must encode to a JSON object: %w", err)
+	}
+	if fields == nil {
+		fields = make(map[string]json.RawMessage)
+	}
+	for _, name := range reservedClaims {
+		if _, dup := fields[name]; dup {
+			return nil, fmt.Errorf("auth: custom claim %q collides with a registered claim", name)
+		}
+	}
+
+	registered, err := json.Marshal(registeredClaims{RegisteredClaims: c.RegisteredClaims, TokenType: c.TokenType})
+	if err != nil {
+		return nil, err
+	}
+	var base map[string]json.RawMessage
+	if err := json.Unmarshal(registered, &base); err != nil {
+		return nil, err
+	}
+	for name, value := range base {
+		fields[name] = value
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON reads the registered claims and Custom from one object.
+func (c *ClaimsOf[T]) UnmarshalJSON(data []byte) error {
+	var registered registeredClaims
+	if err := json.Unmarshal(data, &registered); err != nil {
+		return err
+	}
+	var custom T
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return err
+	}
+	c.RegisteredClaims = registered.RegisteredClaims
+	c.TokenType = registered.TokenType
+	c.Custom = custom
+	return nil
+}
+
+// accessClaims implements tokenClaims.
+func (c *ClaimsOf[T]) accessClaims() (string, string) {
+	return c.TokenType, c.ID
+}
+
+// PrincipalID implements Principal: the token's subject.
+func (c *ClaimsOf[T]) PrincipalID() string {
+	return c.Subject
+}
+
+// PrincipalRoles implements Principal through Custom's PrincipalRoles
+// method, if any.
+func (c *ClaimsOf[T]) PrincipalRoles() []string {
+	if r, ok := any(&c.Custom).(interface{ PrincipalRoles() []string }); ok {
+		return r.PrincipalRoles()
+	}
+	return nil
+}
+
+// PrincipalScopes implements Principal through Custom's PrincipalScopes
+// method, if any.
+func (c *ClaimsOf[T]) PrincipalScopes() []string {
+	if s, ok := any(&c.Custom).(interface{ PrincipalScopes() []string }); ok {
+		return s.PrincipalScopes()
+	}
+	return nil
+}
+
+// JWTServiceOf issues and validates access tokens carrying
+// application-defined claims T instead of the fixed fields of Claims. It
+// shares the signing keys, TTLs, issuer and TokenStore of the JWTService it
+// wraps; refresh tokens carry no custom claims and are the same for both.
+//
+//	svc := auth.NewJWTServiceOf[TenantClaims](jwtService)
+//	token, _ := svc.GenerateAccessToken("user-1", TenantClaims{Tenant: "acme"})
+//	claims, _ := svc.ValidateToken(token) // *auth.ClaimsOf[TenantClaims]
+type JWTServiceOf[T any] struct {
+	base *JWTService
+}
+
+// NewJWTServiceOf returns a JWTServiceOf signing and verifying with base.
+func NewJWTServiceOf[T any](base *JWTService) *JWTServiceOf[T] {
+	if base == nil {
+		panic("auth: NewJWTServiceOf requires a JWTService")
+	}
+	return &JWTServiceOf[T]{base: base}
+}
+
+// Base returns the wrapped JWTService.
+func (s *JWTServiceOf[T]) Base() *JWTService {
+	return s.base
+}
+
+// GenerateAccessToken generates an access token for subject carrying
+// custom.
+func (s *JWTServiceOf[T]) GenerateAccessToken(subject string, custom T) (string, error) {
+	claims := &ClaimsOf[T]{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.base.accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    s.base.issuer,
+			Subject:   subject,
+			ID:        rand.Text(),
+		},
+		TokenType: tokenTypeAccess,
+		Custom:    custom,
+	}
+	return s.base.sign(claims)
+}
+
+// GenerateRefreshToken generates a refresh token for subject; see
+// JWTService.GenerateRefreshToken.
+func (s *JWTServiceOf[T]) GenerateRefreshToken(subject string) (string, error) {
+	return s.base.GenerateRefreshToken(subject)
+}
+
+// ValidateToken validates an access token and returns its claims.
+func (s *JWTServiceOf[T]) ValidateToken(tokenString string) (*ClaimsOf[T], error) {
+	return s.ValidateTokenContext(context.Background(), tokenString)
+}
+
+// ValidateTokenContext is ValidateToken, additionally rejecting access
+// tokens revoked in the TokenStore with ErrTokenRevoked.
+func (s *JWTServiceOf[T]) ValidateTokenContext(ctx context.Context, tokenString string) (*ClaimsOf[T], error) {
+	claims := &ClaimsOf[T]{}
+	if err := s.base.validateAccessToken(ctx, tokenString, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair, with the access
+// token carrying the claims claimsFor returns for the token's subject. See
+// JWTService.Refresh for rotation and reuse detection.
+func (s *JWTServiceOf[T]) Refresh(ctx context.Context, refreshToken string, claimsFor func(subject string) (T, error)) (*TokenPair, error) {
+	claims, err := s.base.validateRefreshToken(ctx, refreshToken, true)
+	if err != nil {
+		return nil, err
+	}
+	custom, err := claimsFor(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	access, err := s.GenerateAccessToken(claims.Subject, custom)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.base.issueRefreshToken(ctx, claims.Subject, claims.Family)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+// Revoke logs a token out; see JWTService.Revoke.
+func (s *JWTServiceOf[T]) Revoke(ctx context.Context, tokenString string) error {
+	return s.base.Revoke(ctx, tokenString)
+}
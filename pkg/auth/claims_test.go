@@ -0,0 +1,110 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/pkg/auth"
+)
+
+type tenantClaims struct {
+	Tenant      string   `json:"tenant"`
+	Scopes      []string `json:"scopes"`
+	Level       int      `json:"level"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+func newTenantService(t *testing.T) *auth.JWTServiceOf[tenantClaims] {
+	t.Helper()
+	base, err := auth.NewJWTService(testSecret, time.Hour, 24*time.Hour, "issuer")
+	require.NoError(t, err)
+	return auth.NewJWTServiceOf[tenantClaims](base)
+}
+
+func TestJWTServiceOf_RoundTrip(t *testing.T) {
+	svc := newTenantService(t)
+	want := tenantClaims{Tenant: "acme", Scopes: []string{"orders:read", "orders:write"}, Level: 3}
+
+	token, err := svc.GenerateAccessToken("user-1", want)
+	require.NoError(t, err)
+
+	claims, err := svc.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, want, claims.Custom)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "issuer", claims.Issuer)
+	assert.NotEmpty(t, claims.ID)
+
+	// Custom claims sit at the top level of the payload, keeping their types.
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	require.NoError(t, err)
+	payload := parsed.Claims.(jwt.MapClaims)
+	assert.Equal(t, "acme", payload["tenant"])
+	assert.Equal(t, []any{"orders:read", "orders:write"}, payload["scopes"])
+	assert.Equal(t, float64(3), payload["level"])
+	assert.Equal(t, "access", payload["typ"])
+
+	// Refresh tokens are not access tokens, whatever the claims type.
+	refresh, err := svc.GenerateRefreshToken("user-1")
+	require.NoError(t, err)
+	_, err = svc.ValidateToken(refresh)
+	assert.ErrorIs(t, err, auth.ErrInvalidTokenType)
+}
+
+func TestJWTServiceOf_RefreshAndRevoke(t *testing.T) {
+	svc := newTenantService(t)
+	store := auth.NewMemoryTokenStore(time.Minute)
+	defer store.Stop()
+	svc.Base().SetTokenStore(store)
+	ctx := context.Background()
+
+	refresh, err := svc.GenerateRefreshToken("user-1")
+	require.NoError(t, err)
+	pair, err := svc.Refresh(ctx, refresh, func(subject string) (tenantClaims, error) {
+		return tenantClaims{Tenant: "acme-" + subject, Level: 1}, nil
+	})
+	require.NoError(t, err)
+
+	claims, err := svc.ValidateTokenContext(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "acme-user-1", claims.Custom.Tenant)
+
+	_, err = svc.Refresh(ctx, refresh, func(string) (tenantClaims, error) { return tenantClaims{}, nil })
+	assert.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+
+	require.NoError(t, svc.Revoke(ctx, pair.AccessToken))
+	_, err = svc.ValidateToken(pair.AccessToken)
+	assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+}
+
+func TestClaimsOf_JSON(t *testing.T) {
+	type collides struct {
+		Subject string `json:"sub"`
+	}
+	_, err := json.Marshal(auth.ClaimsOf[collides]{Custom: collides{Subject: "x"}})
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), `"sub"`), err.Error())
+
+	_, err = json.Marshal(auth.ClaimsOf[[]string]{Custom: []string{"a"}})
+	assert.ErrorContains(t, err, "JSON object")
+
+	data, err := json.Marshal(auth.ClaimsOf[map[string]any]{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"},
+		Custom:           map[string]any{"tenant": "acme"},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sub":"user-1","tenant":"acme"}`, string(data))
+
+	var decoded auth.ClaimsOf[tenantClaims]
+	require.NoError(t, json.Unmarshal([]byte(`{"sub":"u","typ":"access","tenant":"t","level":7}`), &decoded))
+	assert.Equal(t, "u", decoded.Subject)
+	assert.Equal(t, "access", decoded.TokenType)
+	assert.Equal(t, tenantClaims{Tenant: "t", Level: 7}, decoded.Custom)
+}
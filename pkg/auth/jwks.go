@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// JWKSPath is the conventional path of a JSON Web Key Set.
+const JWKSPath = "/.well-known/jwks.json"
+
+// JWKSCacheMaxAge is how long KeySet.ServeHTTP lets verifiers cache the key
+// set, and so how long a key must be published before it signs.
+const JWKSCacheMaxAge = 5 * time.Minute
+
+// maxJWKSBytes caps the size of a fetched key set.
+const maxJWKSBytes = 1 << 20
+
+// JWK is a public JSON Web Key (RFC 7517) for an RSA, EC or OKP (Ed25519)
+// key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// NewJWK encodes the public half of key.
+func NewJWK(key *SigningKey) (JWK, error) {
+	jwk := JWK{KeyID: key.id, Use: "sig", Algorithm: key.Algorithm()}
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64.EncodeToString(pub.N.Bytes())
+		jwk.E = b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		point, err := pub.Bytes()
+		if err != nil {
+			return JWK{}, err
+		}
+		// Uncompressed point: 0x04 || X || Y.
+		size := (len(point) - 1) / 2
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = b64.EncodeToString(point[1 : 1+size])
+		jwk.Y = b64.EncodeToString(point[1+size:])
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64.EncodeToString(pub)
+	default:
+		return JWK{}, fmt.Errorf("auth: unsupported public key type %T", pub)
+	}
+	return jwk, nil
+}
+
+// PublicKey decodes the key. Keys with "use" other than "sig" are rejected.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, fmt.Errorf("auth: key %q is not a signing key", k.KeyID)
+	}
+	switch k.KeyType {
+	case "RSA":
+		n, err := b64.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %q: invalid n: %w", k.KeyID, err)
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("auth: key %q: invalid e", k.KeyID)
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < MinRSAKeyBits {
+			return nil, fmt.Errorf("auth: key %q: RSA key must be at least %d bits", k.KeyID, MinRSAKeyBits)
+		}
+		return pub, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("auth: key %q: unsupported curve %q", k.KeyID, k.Curve)
+		}
+		x, errX := b64.DecodeString(k.X)
+		y, errY := b64.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("auth: key %q: invalid coordinates", k.KeyID)
+		}
+		pub, err := ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %q: %w", k.KeyID, err)
+		}
+		return pub, nil
+	case "OKP":
+		x, err := b64.DecodeString(k.X)
+		if k.Curve != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("auth: key %q: invalid Ed25519 key", k.KeyID)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("auth: key %q: unsupported key type %q", k.KeyID, k.KeyType)
+}
+
+// keyAlgorithm returns the algorithm a decoded key verifies: the "alg"
+// member when present, else the one implied by the key.
+func keyAlgorithm(jwk JWK, key crypto.PublicKey) string {
+	if jwk.Algorithm != "" {
+		return jwk.Algorithm
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return "RS256"
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P384():
+			return "ES384"
+		case elliptic.P521():
+			return "ES512"
+		}
+		return "ES256"
+	}
+	return "EdDSA"
+}
+
+// JWKS returns the public keys of the set: the signing key, the pending
+// key, then the superseded keys.
+func (ks *KeySet) JWKS() (JWKS, error) {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range ks.publishedKeys() {
+		jwk, err := NewJWK(key)
+		if err != nil {
+			return JWKS{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// ServeHTTP serves the key set as application/jwk-set+json, e.g. at
+// JWKSPath. Verifiers may cache it for JWKSCacheMaxAge.
+func (ks *KeySet) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	set, err := ks.JWKS()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(JWKSCacheMaxAge.Seconds())))
+	_ = json.NewEncoder(w).Encode(set)
+}
+
+// RemoteKeySetConfig configures a RemoteKeySet.
+type RemoteKeySetConfig struct {
+	// URL of the JSON Web Key Set, e.g. "https://issuer.example.com/.well-known/jwks.json"
+	URL string
+	// Client fetches the key set. Defaults to a client with a 10s timeout.
+	Client *http.Client
+	// RefreshInterval is how long a fetched key set is used before it is
+	// fetched again. Defaults to 10 minutes.
+	RefreshInterval time.Duration
+	// MinRefreshInterval limits how often an unknown key ID triggers an
+	// early fetch, e.g. after the issuer rotated its keys. Defaults to 30s.
+	MinRefreshInterval time.Duration
+}
+
+// RemoteKeySet verifies tokens issued elsewhere against a JSON Web Key Set
+// fetched over HTTP. The set is fetched on first use, refreshed every
+// RefreshInterval, and fetched early when a token names an unknown key ID.
+// It implements VerificationKeys and is safe for concurrent use.
+type RemoteKeySet struct {
+	config  RemoteKeySetConfig
+	fetches singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]remoteKey
+	fetchedAt   time.Time // last successful fetch
+	attemptedAt time.Time // last fetch, successful or not
+	fetchErr    error     // error of the last fetch
+}
+
+type remoteKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+// NewRemoteKeySet returns a RemoteKeySet for config. Nothing is fetched
+// until a key is needed.
+func NewRemoteKeySet(config RemoteKeySetConfig) (*RemoteKeySet, error) {
+	if config.URL == "" {
+		return nil, errors.New("auth: remote key set URL is required")
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = 10 * time.Minute
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = 30 * time.Second
+	}
+	return &RemoteKeySet{config: config}, nil
+}
+
+// VerificationKey implements VerificationKeys. An empty kid resolves only
+// when the set holds a single key. Fetches run outside the lock, so only a
+// token that cannot be verified with the keys at hand waits for one; a due
+// refresh runs in the background while the current keys keep serving.
+func (rs *RemoteKeySet) VerificationKey(kid string) (crypto.PublicKey, string, error) {
+	rs.mu.Lock()
+	canFetch := time.Since(rs.attemptedAt) >= rs.config.MinRefreshInterval
+	stale := time.Since(rs.fetchedAt) >= rs.config.RefreshInterval
+	key, ok := rs.lookupLocked(kid)
+	rs.mu.Unlock()
+
+	if !ok && canFetch {
+		// A failed fetch keeps the previous keys.
+		<-rs.refresh()
+		rs.mu.Lock()
+		key, ok = rs.lookupLocked(kid)
+		rs.mu.Unlock()
+	} else if stale && canFetch {
+		rs.refresh()
+	}
+	if !ok {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		if rs.keys == nil && rs.fetchErr != nil {
+			return nil, "", rs.fetchErr
+		}
+		return nil, "", fmt.Errorf("%w %q", ErrUnknownKeyID, kid)
+	}
+	return key.key, key.alg, nil
+}
+
+// Refresh fetches the key set now, or waits for the fetch in progress.
+func (rs *RemoteKeySet) Refresh(ctx context.Context) error {
+	select {
+	case res := <-rs.refresh():
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (rs *RemoteKeySet) lookupLocked(kid string) (remoteKey, bool) {
+	if kid == "" && len(rs.keys) == 1 {
+		for _, k := range rs.keys {
+			return k, true
+		}
+	}
+	k, ok := rs.keys[kid]
+	return k, ok
+}
+
+// refresh starts fetching the key set unless a fetch is already running,
+// and returns a channel that receives its outcome. The fetch holds no lock;
+// the keys are swapped in under mu. A failed fetch keeps the previous keys;
+// VerificationKey retries it no sooner than MinRefreshInterval.
+func (rs *RemoteKeySet) refresh() <-chan singleflight.Result {
+	return rs.fetches.DoChan("", func() (any, error) {
+		keys, err := rs.fetch(context.Background())
+
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		rs.attemptedAt = time.Now()
+		rs.fetchErr = err
+		if err != nil {
+			return nil, err
+		}
+		rs.keys = keys
+		rs.fetchedAt = rs.attemptedAt
+		return nil, nil
+	})
+}
+
+// fetch downloads and decodes the key set. Keys that do not decode are
+// skipped.
+func (rs *RemoteKeySet) fetch(ctx context.Context) (map[string]remoteKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rs.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/jwk-set+json, application/json")
+	resp, err := rs.config.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth: fetch key set: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: fetch key set: %s", resp.Status)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSBytes)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("auth: decode key set: %w", err)
+	}
+	keys := make(map[string]remoteKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = remoteKey{key: key, alg: keyAlgorithm(jwk, key)}
+	}
+	return keys, nil
+}
@@ -0,0 +1,416 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/pkg/auth"
+)
+
+func newKeyService(t *testing.T, alg string) (*auth.JWTService, *auth.KeySet) {
+	t.Helper()
+	key, err := auth.GenerateSigningKey(alg)
+	require.NoError(t, err)
+	keys, err := auth.NewKeySet(key)
+	require.NoError(t, err)
+	svc, err := auth.NewJWTServiceWithKeys(keys, time.Hour, 24*time.Hour, "issuer")
+	require.NoError(t, err)
+	return svc, keys
+}
+
+func tokenHeader(t *testing.T, token string) map[string]any {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+	require.NoError(t, err)
+	return parsed.Header
+}
+
+func TestJWTServiceWithKeys_SignsAndVerifies(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "ES384", "ES512", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			svc, keys := newKeyService(t, alg)
+
+			token, err := svc.GenerateAccessToken("user-1", "alice", "alice@example.com", "admin")
+			require.NoError(t, err)
+			header := tokenHeader(t, token)
+			assert.Equal(t, alg, header["alg"])
+			assert.Equal(t, keys.Current().ID(), header["kid"])
+
+			claims, err := svc.ValidateToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", claims.UserID)
+
+			refresh, err := svc.GenerateRefreshToken("user-1")
+			require.NoError(t, err)
+			_, err = svc.ValidateRefreshToken(refresh)
+			require.NoError(t, err)
+			_, err = svc.ValidateToken(refresh)
+			assert.ErrorIs(t, err, auth.ErrInvalidTokenType)
+		})
+	}
+}
+
+func TestJWTServiceWithKeys_RejectsAlgorithmSubstitution(t *testing.T) {
+	svc, keys := newKeyService(t, "RS256")
+	pub := keys.Current().Public().(*rsa.PublicKey)
+
+	claims := &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		TokenType:        "access",
+		UserID:           "attacker",
+	}
+
+	// HS256 keyed with the published public key.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = keys.Current().ID()
+	signed, err := forged.SignedString(x509.MarshalPKCS1PublicKey(pub))
+	require.NoError(t, err)
+	_, err = svc.ValidateToken(signed)
+	assert.Error(t, err)
+
+	// "none"
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	unsigned.Header["kid"] = keys.Current().ID()
+	signed, err = unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = svc.ValidateToken(signed)
+	assert.Error(t, err)
+
+	// A key the set does not hold.
+	other, err := auth.GenerateSigningKey("RS256")
+	require.NoError(t, err)
+	otherSvc, err := auth.NewJWTServiceWithKeys(mustKeySet(t, other), time.Hour, time.Hour, "issuer")
+	require.NoError(t, err)
+	token, err := otherSvc.GenerateAccessToken("u", "n", "e", "r")
+	require.NoError(t, err)
+	_, err = svc.ValidateToken(token)
+	assert.ErrorIs(t, err, auth.ErrUnknownKeyID)
+}
+
+func mustKeySet(t *testing.T, current *auth.SigningKey, previous ...*auth.SigningKey) *auth.KeySet {
+	t.Helper()
+	keys, err := auth.NewKeySet(current, previous...)
+	require.NoError(t, err)
+	return keys
+}
+
+func TestKeySet_RotateAndPrune(t *testing.T) {
+	svc, keys := newKeyService(t, "ES256")
+	oldToken, err := svc.GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+	oldID := keys.Current().ID()
+
+	next, err := auth.GenerateSigningKey("EdDSA")
+	require.NoError(t, err)
+	require.NoError(t, keys.Rotate(next))
+	assert.Error(t, keys.Rotate(next), "duplicate key ID")
+
+	newToken, err := svc.GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, next.ID(), tokenHeader(t, newToken)["kid"])
+
+	// The superseded key still verifies what it signed.
+	_, err = svc.ValidateToken(oldToken)
+	require.NoError(t, err)
+
+	keys.Prune(time.Hour)
+	_, err = svc.ValidateToken(oldToken)
+	require.NoError(t, err)
+
+	keys.Prune(0)
+	_, err = svc.ValidateToken(oldToken)
+	assert.ErrorIs(t, err, auth.ErrUnknownKeyID)
+	_, _, err = keys.VerificationKey(oldID)
+	assert.ErrorIs(t, err, auth.ErrUnknownKeyID)
+	_, err = svc.ValidateToken(newToken)
+	assert.NoError(t, err)
+}
+
+// A published key is in the JWKS before it signs.
+func TestKeySet_PublishThenRotate(t *testing.T) {
+	svc, keys := newKeyService(t, "ES256")
+	first := keys.Current().ID()
+	next, err := auth.GenerateSigningKey("ES256")
+	require.NoError(t, err)
+
+	require.NoError(t, keys.Publish(next))
+	assert.Error(t, keys.Publish(keys.Current()), "duplicate key ID")
+	assert.Equal(t, first, keys.Current().ID())
+	assert.Equal(t, next, keys.Pending())
+	set, err := keys.JWKS()
+	require.NoError(t, err)
+	require.Len(t, set.Keys, 2)
+	assert.Equal(t, first, set.Keys[0].KeyID, "signing key first")
+	assert.Equal(t, next.ID(), set.Keys[1].KeyID)
+	token, err := svc.GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, first, tokenHeader(t, token)["kid"])
+
+	require.NoError(t, keys.Rotate(next))
+	assert.Nil(t, keys.Pending())
+	assert.Equal(t, next.ID(), keys.Current().ID())
+	set, err = keys.JWKS()
+	require.NoError(t, err)
+	assert.Len(t, set.Keys, 2)
+}
+
+func TestKeySet_StartRotation(t *testing.T) {
+	_, keys := newKeyService(t, "ES256")
+	first := keys.Current().ID()
+	generate := func() (*auth.SigningKey, error) { return auth.GenerateSigningKey("ES256") }
+
+	rotator := keys.StartRotation(50*time.Millisecond, time.Hour, generate, func(err error) { t.Error(err) })
+	// The next key is published at once but does not sign yet.
+	pending := keys.Pending()
+	require.NotNil(t, pending)
+	assert.Equal(t, first, keys.Current().ID())
+	require.Eventually(t, func() bool { return keys.Current().ID() == pending.ID() }, time.Second, 5*time.Millisecond)
+	rotator.Stop()
+	rotator.Stop()
+
+	assert.NotNil(t, keys.Pending(), "the key after next is published")
+	// Retained, so the first key still verifies.
+	_, _, err := keys.VerificationKey(first)
+	assert.NoError(t, err)
+}
+
+func TestJWTService_StartKeyRotation(t *testing.T) {
+	svc, keys := newKeyService(t, "ES256")
+
+	_, err := svc.StartKeyRotation(0, nil)
+	assert.Error(t, err)
+	_, err = svc.StartKeyRotation(time.Minute, nil)
+	assert.Error(t, err, "shorter than the JWKS cache period")
+
+	rotator, err := svc.StartKeyRotation(time.Hour, func(err error) { t.Error(err) })
+	require.NoError(t, err)
+	rotator.Stop()
+	require.NotNil(t, keys.Pending())
+	assert.Equal(t, "ES256", keys.Pending().Algorithm())
+
+	hs, err := auth.NewJWTService(testSecret, time.Hour, time.Hour, "issuer")
+	require.NoError(t, err)
+	_, err = hs.StartKeyRotation(time.Hour, nil)
+	assert.Error(t, err)
+}
+
+func TestNewSigningKey_Validation(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = auth.NewSigningKey("k1", small)
+	assert.ErrorContains(t, err, "at least 2048 bits")
+
+	key, err := auth.GenerateSigningKey("ES256")
+	require.NoError(t, err)
+	_, err = auth.NewKeySet(key, key)
+	assert.ErrorContains(t, err, "duplicate key ID")
+
+	_, err = auth.GenerateSigningKey("HS256")
+	assert.ErrorContains(t, err, "unsupported signing algorithm")
+}
+
+func TestParseSigningKeyPEM(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)})
+	key, err := auth.ParseSigningKeyPEM("rsa-1", pkcs1)
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", key.Algorithm())
+	assert.Equal(t, "rsa-1", key.ID())
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	_, err = auth.ParseSigningKeyPEM("rsa-2", pkcs8)
+	require.NoError(t, err)
+
+	_, err = auth.ParseSigningKeyPEM("bad", []byte("not pem"))
+	assert.Error(t, err)
+}
+
+func TestKeySet_ServeHTTP(t *testing.T) {
+	rsaKey, err := auth.GenerateSigningKey("RS256")
+	require.NoError(t, err)
+	ecKey, err := auth.GenerateSigningKey("ES384")
+	require.NoError(t, err)
+	edKey, err := auth.GenerateSigningKey("EdDSA")
+	require.NoError(t, err)
+	keys := mustKeySet(t, ecKey, rsaKey, edKey)
+
+	rec := httptest.NewRecorder()
+	keys.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, auth.JWKSPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/jwk-set+json", rec.Header().Get("Content-Type"))
+	assert.NotContains(t, rec.Body.String(), `"d"`, "private material must not be published")
+
+	var set auth.JWKS
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 3)
+	assert.Equal(t, ecKey.ID(), set.Keys[0].KeyID, "signing key first")
+
+	for i, want := range []*auth.SigningKey{ecKey, rsaKey, edKey} {
+		jwk := set.Keys[i]
+		assert.Equal(t, want.Algorithm(), jwk.Algorithm)
+		assert.Equal(t, "sig", jwk.Use)
+		pub, err := jwk.PublicKey()
+		require.NoError(t, err)
+		assert.True(t, pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(want.Public()), jwk.KeyType)
+	}
+
+	set.Keys[0].Use = "enc"
+	_, err = set.Keys[0].PublicKey()
+	assert.Error(t, err)
+}
+
+func TestJWTVerifier_RemoteKeySet(t *testing.T) {
+	issuer, keys := newKeyService(t, "ES256")
+
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		keys.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	remote, err := auth.NewRemoteKeySet(auth.RemoteKeySetConfig{
+		URL:                server.URL + auth.JWKSPath,
+		MinRefreshInterval: time.Nanosecond,
+	})
+	require.NoError(t, err)
+	verifier, err := auth.NewJWTVerifier(remote, "issuer")
+	require.NoError(t, err)
+
+	token, err := issuer.GenerateAccessToken("user-1", "alice", "", "admin")
+	require.NoError(t, err)
+	claims, err := verifier.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", claims.Username)
+	_, err = verifier.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load(), "key set is cached")
+
+	// A rotated key is picked up by fetching again on the unknown kid.
+	next, err := auth.GenerateSigningKey("RS256")
+	require.NoError(t, err)
+	require.NoError(t, keys.Rotate(next))
+	token, err = issuer.GenerateAccessToken("user-2", "bob", "", "")
+	require.NoError(t, err)
+	_, err = verifier.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// Verifiers cannot issue tokens.
+	_, err = verifier.GenerateAccessToken("user-1", "alice", "", "")
+	assert.ErrorIs(t, err, auth.ErrVerifyOnly)
+
+	// The issuer must match.
+	other, err := auth.NewJWTServiceWithKeys(keys, time.Hour, time.Hour, "someone-else")
+	require.NoError(t, err)
+	token, err = other.GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+	_, err = verifier.ValidateToken(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+}
+
+// A slow fetch holds up neither tokens signed with known keys nor other
+// fetches: concurrent callers share it.
+func TestRemoteKeySet_FetchOutsideLock(t *testing.T) {
+	_, keys := newKeyService(t, "ES256")
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	var slow atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if slow.Load() {
+			<-release
+		}
+		keys.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	remote, err := auth.NewRemoteKeySet(auth.RemoteKeySetConfig{
+		URL:                server.URL,
+		RefreshInterval:    time.Nanosecond,
+		MinRefreshInterval: time.Nanosecond,
+	})
+	require.NoError(t, err)
+	kid := keys.Current().ID()
+	_, _, err = remote.VerificationKey(kid)
+	require.NoError(t, err)
+
+	slow.Store(true)
+	// The set is due for a refresh: the known key serves while it runs.
+	_, _, err = remote.VerificationKey(kid)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, time.Millisecond)
+	for i := 0; i < 10; i++ {
+		_, _, err = remote.VerificationKey(kid)
+		require.NoError(t, err)
+	}
+
+	// Unknown key IDs wait for the fetch in progress rather than start more.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := remote.VerificationKey("unknown")
+			assert.ErrorIs(t, err, auth.ErrUnknownKeyID)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond) // let them join the fetch
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestRemoteKeySet_FetchErrors(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	_, keys := newKeyService(t, "EdDSA")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code := int(status.Load()); code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+		keys.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	remote, err := auth.NewRemoteKeySet(auth.RemoteKeySetConfig{URL: server.URL, MinRefreshInterval: time.Nanosecond})
+	require.NoError(t, err)
+
+	_, _, err = remote.VerificationKey(keys.Current().ID())
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "500"), err.Error())
+
+	status.Store(http.StatusOK)
+	_, alg, err := remote.VerificationKey(keys.Current().ID())
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", alg)
+
+	// A failed refresh keeps the keys fetched before.
+	status.Store(http.StatusBadGateway)
+	assert.Error(t, remote.Refresh(t.Context()))
+	_, _, err = remote.VerificationKey(keys.Current().ID())
+	assert.NoError(t, err)
+
+	_, err = auth.NewRemoteKeySet(auth.RemoteKeySetConfig{})
+	assert.Error(t, err)
+}
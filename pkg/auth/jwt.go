@@ -2,6 +2,8 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"time"
@@ -34,9 +36,19 @@ var ErrJWTSecretTooShort = errors.New("auth: JWT secret must be at least 32 byte
 // ValidateToken). Empty/legacy tokens with no "typ" claim are also rejected.
 var ErrInvalidTokenType = errors.New("auth: invalid token type")
 
-// JWTService handles JWT token generation and validation
+// ErrVerifyOnly is returned by the token generating methods of a service
+// created with NewJWTVerifier.
+var ErrVerifyOnly = errors.New("auth: JWT verifier cannot issue tokens")
+
+// JWTService handles JWT token generation and validation. It signs with an
+// HS256 secret (NewJWTService) or with the asymmetric keys of a KeySet
+// (NewJWTServiceWithKeys); NewJWTVerifier returns one that only verifies
+// tokens issued elsewhere.
 type JWTService struct {
 	secretKey       string
+	keys            *KeySet          // asymmetric signing keys; nil for HS256
+	verifier        VerificationKeys // resolves a token's kid; nil for HS256
+	store           TokenStore       // refresh-token families and revocations; optional
 	accessTokenTTL  time.Duration
 	refreshTokenTTL time.Duration
 	issuer          string
@@ -53,6 +65,8 @@ type Claims struct {
 	Email     string `json:"email,omitempty"`
 	Role      string `json:"role,omitempty"`
 	GameID    string `json:"game_id,omitempty"`
+	// Family is the rotation chain of a refresh token; see TokenStore.
+	Family string `json:"fam,omitempty"`
 }
 
 // NewJWTService creates a new JWT service instance. It returns
@@ -71,6 +85,118 @@ func NewJWTService(secretKey string, accessTTL, refreshTTL time.Duration, issuer
 	}, nil
 }
 
+// NewJWTServiceWithKeys creates a JWT service that signs with the current
+// key of keys (RS256, ES256 or EdDSA, stamped with its "kid") and verifies
+// against every key in the set, so tokens signed before a rotation stay
+// valid. Publish the public keys with keys.ServeHTTP at JWKSPath.
+func NewJWTServiceWithKeys(keys *KeySet, accessTTL, refreshTTL time.Duration, issuer string) (*JWTService, error) {
+	if keys == nil {
+		return nil, errors.New("auth: key set is required")
+	}
+	return &JWTService{
+		keys:            keys,
+		verifier:        keys,
+		accessTokenTTL:  accessTTL,
+		refreshTokenTTL: refreshTTL,
+		issuer:          issuer,
+	}, nil
+}
+
+// NewJWTVerifier creates a JWT service that validates tokens signed with
+// keys, typically a RemoteKeySet over another service's JWKS. A non-empty
+// issuer must match the "iss" claim. Its Generate methods return
+// ErrVerifyOnly.
+func NewJWTVerifier(keys VerificationKeys, issuer string) (*JWTService, error) {
+	if keys == nil {
+		return nil, errors.New("auth: verification keys are required")
+	}
+	return &JWTService{verifier: keys, issuer: issuer}, nil
+}
+
+// SetTokenStore makes the service track refresh tokens and revocations in
+// store: refresh tokens become single-use under Refresh, presenting one
+// twice revokes its family, and Revoke can log tokens out. Call it before
+// the service is used.
+func (s *JWTService) SetTokenStore(store TokenStore) {
+	s.store = store
+}
+
+// KeySet returns the signing keys of a service created with
+// NewJWTServiceWithKeys, or nil.
+func (s *JWTService) KeySet() *KeySet {
+	return s.keys
+}
+
+// StartKeyRotation signs with a freshly generated key of the current
+// algorithm every interval, each key published in the JWKS an interval
+// before it signs. Superseded keys keep verifying until tokens they signed
+// have expired (the longer of the access and refresh TTLs). It requires a
+// service created with NewJWTServiceWithKeys and an interval of at least
+// JWKSCacheMaxAge.
+func (s *JWTService) StartKeyRotation(interval time.Duration, onError func(error)) (*KeyRotator, error) {
+	if s.keys == nil {
+		return nil, errors.New("auth: key rotation requires NewJWTServiceWithKeys")
+	}
+	if interval < JWKSCacheMaxAge {
+		return nil, fmt.Errorf("auth: key rotation interval must be at least %s", JWKSCacheMaxAge)
+	}
+	retain := max(s.accessTokenTTL, s.refreshTokenTTL)
+	return s.keys.StartRotation(interval, retain, func() (*SigningKey, error) {
+		return GenerateSigningKey(s.keys.Current().Algorithm())
+	}, onError), nil
+}
+
+// KeyFunc returns a jwt.Keyfunc that looks the verification key up in keys
+// by the token's "kid" header. The token's algorithm must be the one that
+// key was issued for, which rules out substituting HS256 with the public
+// key as secret or "none".
+func KeyFunc(keys VerificationKeys) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, alg, err := keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != alg {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key, nil
+	}
+}
+
+// keyFunc returns the key to verify token with: the HS256 secret, or the
+// asymmetric key named by its "kid" (see KeyFunc).
+func (s *JWTService) keyFunc(token *jwt.Token) (any, error) {
+	if s.verifier == nil {
+		return s.keyFuncHS256(token)
+	}
+	return KeyFunc(s.verifier)(token)
+}
+
+// parserOptions returns the options tokens are parsed with. A verifier
+// checks the issuer, since the tokens come from another service.
+func (s *JWTService) parserOptions() []jwt.ParserOption {
+	if s.keys == nil && s.verifier != nil && s.issuer != "" {
+		return []jwt.ParserOption{jwt.WithIssuer(s.issuer)}
+	}
+	return nil
+}
+
+// sign signs claims with the HS256 secret or the current signing key.
+func (s *JWTService) sign(claims jwt.Claims) (string, error) {
+	if s.keys != nil {
+		key := s.keys.Current()
+		token := jwt.NewWithClaims(key.method, claims)
+		token.Header["kid"] = key.id
+		return token.SignedString(key.signer)
+	}
+	if s.verifier != nil {
+		return "", ErrVerifyOnly
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.secretKey))
+}
+
 // keyFuncHS256 returns the secret used to verify a token, but only after
 // confirming the token was signed with exactly HS256. A bare
 // (*jwt.SigningMethodHMAC) assertion would also accept HS384/HS512 — and an
@@ -91,6 +217,7 @@ func (s *JWTService) GenerateAccessToken(userID, username, email, role string) (
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    s.issuer,
 			Subject:   userID,
+			ID:        rand.Text(),
 		},
 		TokenType: tokenTypeAccess,
 		UserID:    userID,
@@ -99,51 +226,98 @@ func (s *JWTService) GenerateAccessToken(userID, username, email, role string) (
 		Role:      role,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
+	return s.sign(claims)
 }
 
 // GenerateRefreshToken generates a new refresh token. It carries the
-// "refresh" token type so it can never be accepted by ValidateToken.
+// "refresh" token type so it can never be accepted by ValidateToken. It
+// starts a new token family, recorded in the TokenStore if one is set.
 func (s *JWTService) GenerateRefreshToken(userID string) (string, error) {
-	claims := &Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.refreshTokenTTL)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    s.issuer,
-			Subject:   userID,
-		},
-		TokenType: tokenTypeRefresh,
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
+	return s.issueRefreshToken(context.Background(), userID, "")
 }
 
 // ValidateToken validates a JWT access token and returns the claims. Tokens
 // whose type is not "access" (including legacy tokens with no type) are
 // rejected so a refresh token cannot be replayed as an access token.
 func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keyFuncHS256)
-	if err != nil {
+	return s.ValidateTokenContext(context.Background(), tokenString)
+}
+
+// ValidateTokenContext is ValidateToken, additionally rejecting access
+// tokens revoked in the TokenStore with ErrTokenRevoked.
+func (s *JWTService) ValidateTokenContext(ctx context.Context, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := s.validateAccessToken(ctx, tokenString, claims); err != nil {
 		return nil, err
 	}
+	return claims, nil
+}
+
+// tokenClaims is implemented by the claims types access tokens are parsed
+// into.
+type tokenClaims interface {
+	jwt.Claims
+	accessClaims() (tokenType, id string)
+}
+
+// accessClaims implements tokenClaims.
+func (c *Claims) accessClaims() (string, string) {
+	return c.TokenType, c.ID
+}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+// PrincipalID implements Principal: the UserID, or the subject of a token
+// without one.
+func (c *Claims) PrincipalID() string {
+	if c.UserID != "" {
+		return c.UserID
 	}
-	if claims.TokenType != tokenTypeAccess {
-		return nil, ErrInvalidTokenType
+	return c.Subject
+}
+
+// PrincipalRoles implements Principal: the Role, if set.
+func (c *Claims) PrincipalRoles() []string {
+	if c.Role == "" {
+		return nil
 	}
+	return []string{c.Role}
+}
 
-	return claims, nil
+// PrincipalScopes implements Principal. Claims carry no scopes.
+func (c *Claims) PrincipalScopes() []string {
+	return nil
+}
+
+// validateAccessToken parses an access token into claims and checks its
+// type and the TokenStore's denylist.
+func (s *JWTService) validateAccessToken(ctx context.Context, tokenString string, claims tokenClaims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keyFunc, s.parserOptions()...)
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return fmt.Errorf("invalid token")
+	}
+
+	tokenType, id := claims.accessClaims()
+	if tokenType != tokenTypeAccess {
+		return ErrInvalidTokenType
+	}
+	if s.store != nil && id != "" {
+		revoked, err := s.store.IsAccessTokenRevoked(ctx, id)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
 }
 
 // RefreshAccessToken generates a new access token from a refresh token. The
 // supplied token must be of type "refresh"; an access token (or any other
 // type) is rejected so it cannot be used to indefinitely mint new access
-// tokens.
+// tokens. It does not rotate the refresh token; see Refresh.
 func (s *JWTService) RefreshAccessToken(refreshToken string, getUserInfo func(userID string) (username, email, role string, err error)) (string, error) {
 	claims, err := s.ValidateRefreshToken(refreshToken)
 	if err != nil {
@@ -170,6 +344,7 @@ func (s *JWTService) GenerateGameToken(userID, username, gameID string) (string,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    s.issuer,
 			Subject:   userID,
+			ID:        rand.Text(),
 		},
 		TokenType: tokenTypeAccess,
 		UserID:    userID,
@@ -177,8 +352,7 @@ func (s *JWTService) GenerateGameToken(userID, username, gameID string) (string,
 		GameID:    gameID,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
+	return s.sign(claims)
 }
 
 // AccessTokenTTL returns the access token TTL
@@ -189,20 +363,12 @@ func (s *JWTService) AccessTokenTTL() time.Duration {
 // ValidateRefreshToken validates a refresh token. Tokens whose type is not
 // "refresh" (including access tokens and legacy tokens with no type) are
 // rejected. The registered claims are returned for callers that only need
-// the subject.
+// the subject. With a TokenStore, a token that was revoked or already
+// exchanged by Refresh is rejected too; see Refresh.
 func (s *JWTService) ValidateRefreshToken(tokenString string) (*jwt.RegisteredClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keyFuncHS256)
+	claims, err := s.validateRefreshToken(context.Background(), tokenString, false)
 	if err != nil {
 		return nil, err
 	}
-
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid refresh token")
-	}
-	if claims.TokenType != tokenTypeRefresh {
-		return nil, ErrInvalidTokenType
-	}
-
 	return &claims.RegisteredClaims, nil
 }
@@ -0,0 +1,353 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MinRSAKeyBits is the smallest RSA modulus accepted for signing.
+const MinRSAKeyBits = 2048
+
+// ErrUnknownKeyID is returned when a token names a key ID that the key set
+// does not hold.
+var ErrUnknownKeyID = errors.New("auth: unknown key ID")
+
+// SigningKey is an asymmetric private key used to sign tokens, identified
+// by the "kid" header it stamps on them.
+type SigningKey struct {
+	id     string
+	method jwt.SigningMethod
+	signer crypto.Signer
+}
+
+// NewSigningKey wraps private as a signing key with ID kid. The algorithm
+// follows from the key type: RS256 for RSA (at least MinRSAKeyBits),
+// ES256/ES384/ES512 for ECDSA on P-256/P-384/P-521 and EdDSA for Ed25519.
+func NewSigningKey(kid string, private crypto.Signer) (*SigningKey, error) {
+	if kid == "" {
+		return nil, errors.New("auth: signing key ID is required")
+	}
+	var method jwt.SigningMethod
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < MinRSAKeyBits {
+			return nil, fmt.Errorf("auth: RSA key must be at least %d bits, got %d", MinRSAKeyBits, k.N.BitLen())
+		}
+		method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			method = jwt.SigningMethodES256
+		case elliptic.P384():
+			method = jwt.SigningMethodES384
+		case elliptic.P521():
+			method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("auth: unsupported ECDSA curve %s", k.Curve.Params().Name)
+		}
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("auth: unsupported signing key type %T", private)
+	}
+	return &SigningKey{id: kid, method: method, signer: private}, nil
+}
+
+// GenerateSigningKey generates a key for alg ("RS256", "ES256", "ES384",
+// "ES512" or "EdDSA") with a random key ID.
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch alg {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, MinRSAKeyBits)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		private, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ES512":
+		private, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("auth: unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return NewSigningKey(hex.EncodeToString(id), private)
+}
+
+// ParseSigningKeyPEM parses a PEM-encoded PKCS #8, PKCS #1 (RSA) or SEC 1
+// (ECDSA) private key as a signing key with ID kid.
+func ParseSigningKeyPEM(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("auth: no PEM block found")
+	}
+	var (
+		private any
+		err     error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: parse private key: %w", err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("auth: unsupported signing key type %T", private)
+	}
+	return NewSigningKey(kid, signer)
+}
+
+// ID returns the key ID.
+func (k *SigningKey) ID() string { return k.id }
+
+// Algorithm returns the JWS algorithm the key signs with, e.g. "ES256".
+func (k *SigningKey) Algorithm() string { return k.method.Alg() }
+
+// Public returns the public half of the key.
+func (k *SigningKey) Public() crypto.PublicKey { return k.signer.Public() }
+
+// VerificationKeys resolves the public key, and the only algorithm it may
+// verify, for the key ID in a token header. *KeySet and *RemoteKeySet
+// implement it.
+type VerificationKeys interface {
+	VerificationKey(kid string) (key crypto.PublicKey, alg string, err error)
+}
+
+// KeySet holds the signing keys of a JWTService. The newest key signs; keys
+// it superseded keep verifying the tokens they signed until they are pruned.
+// A pending key is published before it signs, so that verifiers caching the
+// JWKS already know it when its first tokens arrive. It is safe for
+// concurrent use.
+type KeySet struct {
+	mu      sync.RWMutex
+	keys    []keyEntry // newest first
+	pending *SigningKey
+}
+
+type keyEntry struct {
+	key *SigningKey
+	// retiredAt is when a newer key superseded this one; zero for the
+	// current key.
+	retiredAt time.Time
+}
+
+// NewKeySet returns a key set signing with current. previous keys only
+// verify, e.g. keys that signed tokens before a restart.
+func NewKeySet(current *SigningKey, previous ...*SigningKey) (*KeySet, error) {
+	ks := &KeySet{}
+	seen := make(map[string]bool)
+	now := time.Now()
+	for i, key := range append([]*SigningKey{current}, previous...) {
+		if key == nil {
+			return nil, errors.New("auth: nil signing key")
+		}
+		if seen[key.id] {
+			return nil, fmt.Errorf("auth: duplicate key ID %q", key.id)
+		}
+		seen[key.id] = true
+		entry := keyEntry{key: key}
+		if i > 0 {
+			entry.retiredAt = now
+		}
+		ks.keys = append(ks.keys, entry)
+	}
+	return ks, nil
+}
+
+// Current returns the key new tokens are signed with.
+func (ks *KeySet) Current() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.keys[0].key
+}
+
+// Pending returns the key published ahead of signing, or nil.
+func (ks *KeySet) Pending() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.pending
+}
+
+// Publish adds key to the JWKS without signing with it, replacing any
+// pending key. Rotate to it once it has been published for at least
+// JWKSCacheMaxAge, so remote verifiers have picked it up.
+func (ks *KeySet) Publish(key *SigningKey) error {
+	if key == nil {
+		return errors.New("auth: nil signing key")
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if err := ks.checkUniqueLocked(key); err != nil {
+		return err
+	}
+	ks.pending = key
+	return nil
+}
+
+// Rotate makes key the signing key, taking it out of pending if it was
+// published. The previous keys stay available for verification until
+// Prune removes them. A key that was not published first is unknown to
+// verifiers caching the JWKS until their cache expires.
+func (ks *KeySet) Rotate(key *SigningKey) error {
+	if key == nil {
+		return errors.New("auth: nil signing key")
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if key == ks.pending {
+		ks.pending = nil
+	} else if err := ks.checkUniqueLocked(key); err != nil {
+		return err
+	}
+	ks.keys[0].retiredAt = time.Now()
+	ks.keys = append([]keyEntry{{key: key}}, ks.keys...)
+	return nil
+}
+
+func (ks *KeySet) checkUniqueLocked(key *SigningKey) error {
+	for _, e := range ks.keys {
+		if e.key.id == key.id {
+			return fmt.Errorf("auth: duplicate key ID %q", key.id)
+		}
+	}
+	if ks.pending != nil && ks.pending.id == key.id {
+		return fmt.Errorf("auth: duplicate key ID %q", key.id)
+	}
+	return nil
+}
+
+// Prune removes keys superseded more than age ago. Tokens they signed no
+// longer verify, so age should exceed the longest token TTL.
+func (ks *KeySet) Prune(age time.Duration) {
+	cutoff := time.Now().Add(-age)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	kept := ks.keys[:1]
+	for _, e := range ks.keys[1:] {
+		if e.retiredAt.After(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	ks.keys = kept
+}
+
+// VerificationKey implements VerificationKeys. An empty kid resolves only
+// when the set holds a single key.
+func (ks *KeySet) VerificationKey(kid string) (crypto.PublicKey, string, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if kid == "" && len(ks.keys) == 1 {
+		return ks.keys[0].key.Public(), ks.keys[0].key.Algorithm(), nil
+	}
+	for _, e := range ks.keys {
+		if e.key.id == kid {
+			return e.key.Public(), e.key.Algorithm(), nil
+		}
+	}
+	if ks.pending != nil && ks.pending.id == kid {
+		return ks.pending.Public(), ks.pending.Algorithm(), nil
+	}
+	return nil, "", fmt.Errorf("%w %q", ErrUnknownKeyID, kid)
+}
+
+// publishedKeys returns a snapshot of the keys to publish: the signing key,
+// then the pending key, then the superseded keys, newest first.
+func (ks *KeySet) publishedKeys() []*SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	keys := make([]*SigningKey, 0, len(ks.keys)+1)
+	keys = append(keys, ks.keys[0].key)
+	if ks.pending != nil {
+		keys = append(keys, ks.pending)
+	}
+	for _, e := range ks.keys[1:] {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+// KeyRotator rotates the signing key of a KeySet on a schedule. Stop it on
+// shutdown.
+type KeyRotator struct {
+	stop chan struct{}
+	once sync.Once
+	done chan struct{}
+}
+
+// StartRotation publishes a key from generate straight away and, every
+// interval, rotates to the pending key and publishes the next one, so each
+// key is in the JWKS for an interval before it signs; interval should be at
+// least JWKSCacheMaxAge. Keys superseded more than retain ago are pruned.
+// A failed generation leaves no pending key, so the next tick only
+// publishes one; errors are reported to onError, which may be nil.
+func (ks *KeySet) StartRotation(interval, retain time.Duration, generate func() (*SigningKey, error), onError func(error)) *KeyRotator {
+	r := &KeyRotator{stop: make(chan struct{}), done: make(chan struct{})}
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+	publishNext := func() {
+		key, err := generate()
+		if err == nil {
+			err = ks.Publish(key)
+		}
+		report(err)
+	}
+
+	publishNext()
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if pending := ks.Pending(); pending != nil {
+					report(ks.Rotate(pending))
+				}
+				publishNext()
+				ks.Prune(retain)
+			}
+		}
+	}()
+	return r
+}
+
+// Stop ends the rotation and waits for an in-flight rotation to finish.
+func (r *KeyRotator) Stop() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+}
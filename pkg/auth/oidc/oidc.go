@@ -0,0 +1,342 @@
+// Package oidc is an OpenID Connect relying party for the authorization
+// code flow with PKCE.
+//
+// A Provider is built from the identity provider's discovery document. It
+// builds the authorization URL, exchanges the returned code for tokens,
+// verifies the ID token against the provider's published keys and fetches
+// userinfo:
+//
+//	provider, err := oidc.NewProvider(ctx, oidc.Config{
+//		IssuerURL:    "https://accounts.example.com",
+//		ClientID:     "my-app",
+//		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+//		RedirectURL:  "https://app.example.com/auth/callback",
+//	})
+//
+// middleware.OIDC drives the flow over HTTP, keeping the state, nonce and
+// PKCE verifier in short-lived cookies and the logged-in user in a session.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/yshengliao/gortex/pkg/auth"
+)
+
+// DiscoveryPath is the path of the discovery document below the issuer URL.
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+// maxResponseBytes caps the size of documents read from the provider.
+const maxResponseBytes = 1 << 20
+
+// ErrNonceMismatch is returned by VerifyIDToken when the ID token's nonce is
+// not the one sent with the authorization request, or no nonce is given.
+var ErrNonceMismatch = errors.New("oidc: nonce mismatch")
+
+// Config configures a Provider.
+type Config struct {
+	// IssuerURL is the provider's issuer identifier. The discovery document
+	// is fetched from IssuerURL + DiscoveryPath and must name the same
+	// issuer.
+	IssuerURL string
+	// ClientID and ClientSecret identify this application to the provider.
+	// A public client leaves ClientSecret empty and relies on PKCE alone.
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback URL registered with the provider.
+	RedirectURL string
+	// Scopes requested. Defaults to openid, profile and email; "openid" is
+	// always included.
+	Scopes []string
+	// HTTPClient talks to the provider. Defaults to a client with a 10s
+	// timeout.
+	HTTPClient *http.Client
+}
+
+// Metadata is the part of the provider's discovery document the relying
+// party uses.
+type Metadata struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserInfoEndpoint                 string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	EndSessionEndpoint               string   `json:"end_session_endpoint,omitempty"`
+	ScopesSupported                  []string `json:"scopes_supported,omitempty"`
+	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+}
+
+// Token is the token endpoint's response.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	IDToken      string `json:"id_token"`
+}
+
+// Error is an OAuth 2.0 error returned by the provider, e.g.
+// "invalid_grant" for a code that was already used.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oidc: %s: %s", e.Code, e.Description)
+	}
+	return "oidc: " + e.Code
+}
+
+// IDToken holds the verified claims of an ID token.
+type IDToken struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce,omitempty"`
+	AuthorizedParty   string `json:"azp,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     bool   `json:"email_verified,omitempty"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	// Claims holds every claim of the token, including ones without a field.
+	Claims map[string]any `json:"-"`
+}
+
+// Provider is an OpenID Connect provider as seen by this relying party. It
+// is safe for concurrent use.
+type Provider struct {
+	config   Config
+	metadata Metadata
+	keys     *auth.RemoteKeySet
+}
+
+// NewProvider fetches the provider's discovery document and returns a
+// Provider for it. The provider's signing keys are fetched when the first
+// ID token is verified.
+func NewProvider(ctx context.Context, config Config) (*Provider, error) {
+	if config.IssuerURL == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("oidc: IssuerURL, ClientID and RedirectURL are required")
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	} else if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(config.IssuerURL, "/")+DiscoveryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata Metadata
+	if err := doJSON(config.HTTPClient, req, &metadata); err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if metadata.Issuer != config.IssuerURL {
+		return nil, fmt.Errorf("oidc: discovery: issuer %q does not match %q", metadata.Issuer, config.IssuerURL)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery: authorization_endpoint, token_endpoint and jwks_uri are required")
+	}
+	if methods := metadata.CodeChallengeMethodsSupported; len(methods) > 0 && !slices.Contains(methods, "S256") {
+		return nil, errors.New("oidc: discovery: provider does not support PKCE with S256")
+	}
+
+	keys, err := auth.NewRemoteKeySet(auth.RemoteKeySetConfig{URL: metadata.JWKSURI, Client: config.HTTPClient})
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{config: config, metadata: metadata, keys: keys}, nil
+}
+
+// Metadata returns the provider's discovery document.
+func (p *Provider) Metadata() Metadata {
+	return p.metadata
+}
+
+// Config returns the configuration with defaults applied.
+func (p *Provider) Config() Config {
+	return p.config
+}
+
+// AuthCodeURL returns the URL to send the user to for login. state and nonce
+// must be unguessable and remembered for the callback, as must verifier,
+// whose S256 challenge is sent (see NewVerifier).
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	u, err := url.Parse(p.metadata.AuthorizationEndpoint)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", p.config.RedirectURL)
+	q.Set("scope", strings.Join(p.config.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", S256Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Exchange redeems an authorization code, proving possession of the PKCE
+// verifier. A code the provider rejects yields an *Error.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {p.config.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.config.ClientSecret != "" {
+		// client_secret_basic; RFC 6749 section 2.3.1 form-encodes both parts.
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var token Token
+	if err := doJSON(p.config.HTTPClient, req, &token); err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("oidc: token response has no id_token")
+	}
+	return &token, nil
+}
+
+// VerifyIDToken verifies an ID token's signature against the provider's
+// keys and its issuer, audience, authorized party, expiry and nonce. The
+// nonce sent with the authorization request is required: an empty one
+// yields ErrNonceMismatch.
+func (p *Provider) VerifyIDToken(rawIDToken, nonce string) (*IDToken, error) {
+	if nonce == "" {
+		return nil, ErrNonceMismatch
+	}
+	return p.verifyIDToken(rawIDToken, nonce)
+}
+
+// VerifyIDTokenWithoutNonce verifies an ID token as VerifyIDToken does
+// but ignores its nonce, for ID tokens that were not requested with one,
+// such as those of a refresh token grant. It must not be used to complete
+// an authorization code flow.
+func (p *Provider) VerifyIDTokenWithoutNonce(rawIDToken string) (*IDToken, error) {
+	return p.verifyIDToken(rawIDToken, "")
+}
+
+// verifyIDToken verifies an ID token, and its nonce unless nonce is empty.
+func (p *Provider) verifyIDToken(rawIDToken, nonce string) (*IDToken, error) {
+	claims := &IDToken{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, auth.KeyFunc(p.keys),
+		jwt.WithIssuer(p.metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid ID token: %w", err)
+	}
+	if (len(claims.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != p.config.ClientID {
+		return nil, fmt.Errorf("oidc: invalid ID token: authorized party %q is not this client", claims.AuthorizedParty)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("oidc: invalid ID token: no subject")
+	}
+	if nonce != "" && subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, ErrNonceMismatch
+	}
+
+	// The signature is verified, so the payload can be decoded as is.
+	parts := strings.Split(rawIDToken, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid ID token: %w", err)
+	}
+	if err := json.Unmarshal(payload, &claims.Claims); err != nil {
+		return nil, fmt.Errorf("oidc: invalid ID token: %w", err)
+	}
+	return claims, nil
+}
+
+// UserInfo fetches the claims of the user the access token was issued for.
+// The caller must check that "sub" matches the ID token's subject.
+func (p *Provider) UserInfo(ctx context.Context, accessToken string) (map[string]any, error) {
+	if p.metadata.UserInfoEndpoint == "" {
+		return nil, errors.New("oidc: provider has no userinfo endpoint")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.metadata.UserInfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var info map[string]any
+	if err := doJSON(p.config.HTTPClient, req, &info); err != nil {
+		return nil, fmt.Errorf("oidc: userinfo: %w", err)
+	}
+	return info, nil
+}
+
+// NewVerifier returns a random PKCE code verifier (RFC 7636).
+func NewVerifier() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// S256Challenge returns the S256 code challenge for verifier.
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// doJSON sends req and decodes a JSON response into v. A non-200 response
+// carrying an OAuth error body yields an *Error.
+func doJSON(client *http.Client, req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr Error
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+			return &oauthErr
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/pkg/auth/oidc"
+	"github.com/yshengliao/gortex/pkg/auth/oidc/oidctest"
+)
+
+func newProvider(t *testing.T) (*oidc.Provider, *oidctest.Server) {
+	t.Helper()
+	idp := oidctest.NewServer("client", "s3cret")
+	t.Cleanup(idp.Close)
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		IssuerURL:    idp.URL,
+		ClientID:     "client",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://app.example.com/auth/callback",
+		Scopes:       []string{"email"},
+	})
+	require.NoError(t, err)
+	return provider, idp
+}
+
+// authorize runs the authorization request and returns the code.
+func authorize(t *testing.T, provider *oidc.Provider, nonce, verifier string) string {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(provider.AuthCodeURL("state-1", nonce, verifier))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "state-1", location.Query().Get("state"))
+	return location.Query().Get("code")
+}
+
+func TestProvider_CodeFlow(t *testing.T) {
+	provider, idp := newProvider(t)
+	ctx := context.Background()
+
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1", "nonce-1", "verifier"))
+	require.NoError(t, err)
+	q := authURL.Query()
+	assert.Equal(t, "openid email", q.Get("scope"), "openid is always requested")
+	assert.Equal(t, oidc.S256Challenge("verifier"), q.Get("code_challenge"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	verifier := oidc.NewVerifier()
+	code := authorize(t, provider, "nonce-1", verifier)
+	token, err := provider.Exchange(ctx, code, verifier)
+	require.NoError(t, err)
+
+	idToken, err := provider.VerifyIDToken(token.IDToken, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, idp.Subject, idToken.Subject)
+	assert.Equal(t, "user-1@example.com", idToken.Email)
+	assert.True(t, idToken.EmailVerified)
+	assert.Equal(t, "Test User", idToken.Claims["name"])
+
+	info, err := provider.UserInfo(ctx, token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, idp.Subject, info["sub"])
+
+	// Codes are single use.
+	_, err = provider.Exchange(ctx, code, verifier)
+	var oauthErr *oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, "invalid_grant", oauthErr.Code)
+}
+
+func TestProvider_ExchangeRequiresVerifier(t *testing.T) {
+	provider, _ := newProvider(t)
+	code := authorize(t, provider, "nonce-1", oidc.NewVerifier())
+
+	_, err := provider.Exchange(context.Background(), code, oidc.NewVerifier())
+	var oauthErr *oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, "invalid_grant", oauthErr.Code)
+}
+
+func TestProvider_VerifyIDTokenRejects(t *testing.T) {
+	provider, idp := newProvider(t)
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		nonce  string
+	}{
+		{name: "wrong nonce", nonce: "other"},
+		{name: "wrong audience", modify: func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{name: "wrong issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "no expiry", modify: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "foreign authorized party", modify: func(c jwt.MapClaims) {
+			c["aud"] = []string{"client", "other"}
+			c["azp"] = "other"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.IDTokenClaims("nonce-1")
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			raw, err := idp.SignIDToken(claims)
+			require.NoError(t, err)
+			nonce := tt.nonce
+			if nonce == "" {
+				nonce = "nonce-1"
+			}
+			_, err = provider.VerifyIDToken(raw, nonce)
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("HMAC token", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.IDTokenClaims("nonce-1"))
+		token.Header["kid"] = "test-key"
+		raw, err := token.SignedString([]byte("s3cret"))
+		require.NoError(t, err)
+		_, err = provider.VerifyIDToken(raw, "nonce-1")
+		assert.Error(t, err)
+	})
+
+	_, err := provider.VerifyIDToken(mustSign(t, idp, idp.IDTokenClaims("nonce-1")), "other")
+	assert.ErrorIs(t, err, oidc.ErrNonceMismatch)
+
+	// An empty expected nonce is not a wildcard.
+	_, err = provider.VerifyIDToken(mustSign(t, idp, idp.IDTokenClaims("")), "")
+	assert.ErrorIs(t, err, oidc.ErrNonceMismatch)
+	idToken, err := provider.VerifyIDTokenWithoutNonce(mustSign(t, idp, idp.IDTokenClaims("")))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", idToken.Subject)
+}
+
+func TestNewProvider_Discovery(t *testing.T) {
+	idp := oidctest.NewServer("client", "")
+	defer idp.Close()
+
+	_, err := oidc.NewProvider(context.Background(), oidc.Config{
+		IssuerURL:   idp.URL + "/",
+		ClientID:    "client",
+		RedirectURL: "https://app.example.com/cb",
+	})
+	assert.ErrorContains(t, err, "does not match", "the discovered issuer must match exactly")
+
+	_, err = oidc.NewProvider(context.Background(), oidc.Config{IssuerURL: idp.URL})
+	assert.Error(t, err)
+
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		IssuerURL:   idp.URL,
+		ClientID:    "client",
+		RedirectURL: "https://app.example.com/cb",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, idp.URL+"/token", provider.Metadata().TokenEndpoint)
+	assert.Equal(t, []string{"openid", "profile", "email"}, provider.Config().Scopes)
+}
+
+func mustSign(t *testing.T, idp *oidctest.Server, claims jwt.MapClaims) string {
+	t.Helper()
+	raw, err := idp.SignIDToken(claims)
+	require.NoError(t, err)
+	return raw
+}
@@ -0,0 +1,234 @@
+// Package oidctest provides a stub OpenID Connect provider for tests.
+//
+// The Server approves every authorization request for Subject, issues
+// ES256-signed ID tokens and serves discovery, token, userinfo and JWKS
+// endpoints, checking client authentication and PKCE the way a real
+// provider does:
+//
+//	idp := oidctest.NewServer("client", "secret")
+//	defer idp.Close()
+//	provider, _ := oidc.NewProvider(ctx, oidc.Config{IssuerURL: idp.URL, ...})
+package oidctest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/yshengliao/gortex/pkg/auth"
+	"github.com/yshengliao/gortex/pkg/auth/oidc"
+)
+
+// Server is a stub OpenID Connect provider. Its fields may be changed
+// between requests.
+type Server struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+	// Subject is the user every authorization request logs in as.
+	Subject string
+	// Claims are added to ID tokens and userinfo responses.
+	Claims map[string]any
+	// ModifyIDToken, when set, may change an ID token's claims before it is
+	// signed, e.g. to test verification failures.
+	ModifyIDToken func(claims jwt.MapClaims)
+
+	key *auth.SigningKey
+	ecd *ecdsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// grant is an issued authorization code.
+type grant struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// NewServer starts a stub provider for the given client.
+func NewServer(clientID, clientSecret string) *Server {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	key, err := auth.NewSigningKey("test-key", private)
+	if err != nil {
+		panic(err)
+	}
+
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Subject:      "user-1",
+		Claims:       map[string]any{"email": "user-1@example.com", "email_verified": true, "name": "Test User"},
+		key:          key,
+		ecd:          private,
+		codes:        make(map[string]grant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+oidc.DiscoveryPath, s.discovery)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	mux.HandleFunc("GET /userinfo", s.userinfo)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Metadata returns the provider's discovery document.
+func (s *Server) Metadata() oidc.Metadata {
+	return oidc.Metadata{
+		Issuer:                           s.URL,
+		AuthorizationEndpoint:            s.URL + "/authorize",
+		TokenEndpoint:                    s.URL + "/token",
+		UserInfoEndpoint:                 s.URL + "/userinfo",
+		JWKSURI:                          s.URL + "/jwks",
+		ScopesSupported:                  []string{"openid", "profile", "email"},
+		CodeChallengeMethodsSupported:    []string{"S256"},
+		IDTokenSigningAlgValuesSupported: []string{"ES256"},
+	}
+}
+
+// SignIDToken signs claims with the provider's key.
+func (s *Server) SignIDToken(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = s.key.ID()
+	return token.SignedString(s.ecd)
+}
+
+// IDTokenClaims returns the claims of an ID token for Subject with nonce.
+func (s *Server) IDTokenClaims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": s.URL,
+		"sub": s.Subject,
+		"aud": s.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	for name, value := range s.Claims {
+		claims[name] = value
+	}
+	return claims
+}
+
+func (s *Server) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.Metadata())
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI := q.Get("redirect_uri")
+	target, err := url.Parse(redirectURI)
+	if err != nil || redirectURI == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("client_id") != s.ClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := rand.Text()
+	s.mu.Lock()
+	s.codes[code] = grant{nonce: q.Get("nonce"), challenge: q.Get("code_challenge"), redirectURI: redirectURI}
+	s.mu.Unlock()
+
+	values := target.Query()
+	values.Set("code", code)
+	values.Set("state", q.Get("state"))
+	target.RawQuery = values.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if id != s.ClientID || secret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, oidc.Error{Code: "invalid_client"})
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, oidc.Error{Code: "unsupported_grant_type"})
+		return
+	}
+
+	// Codes are single use.
+	code := r.PostFormValue("code")
+	s.mu.Lock()
+	g, found := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	if !found || g.redirectURI != r.PostFormValue("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, oidc.Error{Code: "invalid_grant", Description: "unknown code"})
+		return
+	}
+	if oidc.S256Challenge(r.PostFormValue("code_verifier")) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, oidc.Error{Code: "invalid_grant", Description: "PKCE verification failed"})
+		return
+	}
+
+	claims := s.IDTokenClaims(g.nonce)
+	if s.ModifyIDToken != nil {
+		s.ModifyIDToken(claims)
+	}
+	idToken, err := s.SignIDToken(claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, oidc.Error{Code: "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, oidc.Token{
+		AccessToken: "access-" + s.Subject,
+		TokenType:   "Bearer",
+		ExpiresIn:   3600,
+		IDToken:     idToken,
+	})
+}
+
+func (s *Server) userinfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer access-"+s.Subject {
+		writeJSON(w, http.StatusUnauthorized, oidc.Error{Code: "invalid_token"})
+		return
+	}
+	info := map[string]any{"sub": s.Subject}
+	for name, value := range s.Claims {
+		info[name] = value
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) jwks(w http.ResponseWriter, _ *http.Request) {
+	jwk, err := auth.NewJWK(s.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, auth.JWKS{Keys: []auth.JWK{jwk}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
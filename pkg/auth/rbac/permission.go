@@ -0,0 +1,136 @@
+package rbac
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permission is a parsed permission: colon-separated segments, each a
+// literal, a "*" wildcard or a "{name}" placeholder.
+type Permission struct {
+	segments []segment
+}
+
+type segment struct {
+	literal     string
+	wildcard    bool
+	placeholder string
+}
+
+// ParsePermission parses a permission such as "orders:{id}:read". It
+// returns an error for an empty segment, a "*" or brace inside a segment,
+// or an empty placeholder name.
+func ParsePermission(s string) (Permission, error) {
+	if s == "" {
+		return Permission{}, fmt.Errorf("empty permission")
+	}
+	parts := strings.Split(s, ":")
+	perm := Permission{segments: make([]segment, len(parts))}
+	for i, part := range parts {
+		switch {
+		case part == "":
+			return Permission{}, fmt.Errorf("permission %q has an empty segment", s)
+		case part == "*":
+			perm.segments[i].wildcard = true
+		case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			name := part[1 : len(part)-1]
+			if name == "" || strings.ContainsAny(name, "{}*") {
+				return Permission{}, fmt.Errorf("permission %q has a malformed placeholder %q", s, part)
+			}
+			perm.segments[i].placeholder = name
+		case strings.ContainsAny(part, "{}*"):
+			return Permission{}, fmt.Errorf("permission %q: wildcards and placeholders must be whole segments, got %q", s, part)
+		default:
+			perm.segments[i].literal = part
+		}
+	}
+	return perm, nil
+}
+
+// Placeholders returns the placeholder names in p, in order.
+func (p Permission) Placeholders() []string {
+	var names []string
+	for _, s := range p.segments {
+		if s.placeholder != "" {
+			names = append(names, s.placeholder)
+		}
+	}
+	return names
+}
+
+// String returns p in its textual form.
+func (p Permission) String() string {
+	return p.Expand(nil)
+}
+
+// Expand returns p with its placeholders filled by values, as used in
+// error details. Unresolved placeholders are left as written.
+func (p Permission) Expand(values func(name string) string) string {
+	parts := make([]string, len(p.segments))
+	for i, s := range p.segments {
+		switch {
+		case s.wildcard:
+			parts[i] = "*"
+		case s.placeholder != "":
+			parts[i] = "{" + s.placeholder + "}"
+			if values != nil {
+				if v := values(s.placeholder); v != "" {
+					parts[i] = v
+				}
+			}
+		default:
+			parts[i] = s.literal
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+// expand returns the segments of a required permission with placeholders
+// filled by values. Substituted values stay single segments, so a value
+// containing ":" or "*" cannot widen the check. It reports false when a
+// placeholder has no value.
+func (p Permission) expand(values func(name string) string) ([]string, bool) {
+	want := make([]string, len(p.segments))
+	for i, s := range p.segments {
+		switch {
+		case s.wildcard:
+			want[i] = "*"
+		case s.placeholder != "":
+			if values == nil {
+				return nil, false
+			}
+			if want[i] = values(s.placeholder); want[i] == "" {
+				return nil, false
+			}
+		default:
+			want[i] = s.literal
+		}
+	}
+	return want, true
+}
+
+// grants reports whether the granted permission p covers want, filling its
+// placeholders from attrs. A placeholder without an attribute matches
+// nothing.
+func (p Permission) grants(want []string, attrs map[string]string) bool {
+	for i, s := range p.segments {
+		if s.wildcard && i == len(p.segments)-1 {
+			return len(want) > i
+		}
+		if i >= len(want) {
+			return false
+		}
+		switch {
+		case s.wildcard:
+		case s.placeholder != "":
+			if v := attrs[s.placeholder]; v == "" || v != want[i] {
+				return false
+			}
+		default:
+			if s.literal != want[i] {
+				return false
+			}
+		}
+	}
+	return len(p.segments) == len(want)
+}
@@ -0,0 +1,178 @@
+// Package rbac is a role-based access control policy engine.
+//
+// A Policy maps roles to permissions. A permission is a colon-separated
+// list of segments naming a resource and an action, such as "orders:write"
+// or "orders:42:read". In granted permissions a "*" segment matches any one
+// segment, and a trailing "*" matches one or more, so "orders:*" grants
+// every permission on orders and "*" grants everything.
+//
+// A segment written as "{name}" is a placeholder. In a granted permission it
+// is replaced by the subject's attribute of that name, which expresses
+// ownership rules such as "users:{user_id}:write". In a required permission
+// it is replaced by a request value, typically a path parameter, as in
+// "orders:{id}:read".
+//
+// Roles inherit the permissions of the roles they list in Inherits:
+//
+//	policy, err := rbac.NewPolicy(map[string]rbac.Role{
+//		"viewer": {Permissions: []string{"orders:*:read"}},
+//		"editor": {Inherits: []string{"viewer"}, Permissions: []string{"orders:write"}},
+//		"admin":  {Permissions: []string{"*"}},
+//	})
+package rbac
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yshengliao/gortex/pkg/config"
+)
+
+// Role defines the permissions of one role.
+type Role struct {
+	// Inherits lists roles whose permissions this role also holds.
+	Inherits []string
+	// Permissions lists the permissions granted to the role.
+	Permissions []string
+}
+
+// Subject is the identity an authorization decision is made for.
+type Subject struct {
+	// ID identifies the subject, e.g. a user ID.
+	ID string
+	// Roles are the roles the subject holds. Roles unknown to the policy
+	// grant nothing.
+	Roles []string
+	// Attributes fill "{name}" placeholders in granted permissions.
+	Attributes map[string]string
+}
+
+// Policy resolves roles to permissions. It is immutable once built and
+// safe for concurrent use.
+type Policy struct {
+	// grants holds the effective permissions of each role, inherited ones
+	// included.
+	grants map[string][]Permission
+}
+
+// NewPolicy builds a Policy from role definitions. It returns an error for a
+// malformed permission, an inherited role that is not defined, or an
+// inheritance cycle.
+func NewPolicy(roles map[string]Role) (*Policy, error) {
+	p := &Policy{grants: make(map[string][]Permission, len(roles))}
+
+	own := make(map[string][]Permission, len(roles))
+	for name, role := range roles {
+		for _, s := range role.Permissions {
+			perm, err := ParsePermission(s)
+			if err != nil {
+				return nil, fmt.Errorf("rbac: role %q: %w", name, err)
+			}
+			own[name] = append(own[name], perm)
+		}
+		for _, parent := range role.Inherits {
+			if _, ok := roles[parent]; !ok {
+				return nil, fmt.Errorf("rbac: role %q inherits undefined role %q", name, parent)
+			}
+		}
+	}
+
+	// Resolve inheritance depth-first, in sorted order so errors are
+	// reported deterministically.
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	visiting := make(map[string]bool)
+	var resolve func(name string, path []string) error
+	resolve = func(name string, path []string) error {
+		if _, done := p.grants[name]; done {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("rbac: role inheritance cycle %s", strings.Join(append(path, name), " -> "))
+		}
+		visiting[name] = true
+		grants := append([]Permission(nil), own[name]...)
+		for _, parent := range roles[name].Inherits {
+			if err := resolve(parent, append(path, name)); err != nil {
+				return err
+			}
+			grants = append(grants, p.grants[parent]...)
+		}
+		visiting[name] = false
+		p.grants[name] = grants
+		return nil
+	}
+	for _, name := range names {
+		if err := resolve(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// NewPolicyFromConfig builds a Policy from the rbac section of the
+// application configuration:
+//
+//	rbac:
+//	  roles:
+//	    viewer:
+//	      permissions: ["orders:*:read"]
+//	    editor:
+//	      inherits: [viewer]
+//	      permissions: ["orders:write"]
+func NewPolicyFromConfig(cfg config.RBACConfig) (*Policy, error) {
+	roles := make(map[string]Role, len(cfg.Roles))
+	for name, role := range cfg.Roles {
+		roles[name] = Role{Inherits: role.Inherits, Permissions: role.Permissions}
+	}
+	return NewPolicy(roles)
+}
+
+// Permissions returns the effective permissions of role, inherited ones
+// included, or nil for an unknown role.
+func (p *Policy) Permissions(role string) []string {
+	grants := p.grants[role]
+	if len(grants) == 0 {
+		return nil
+	}
+	perms := make([]string, len(grants))
+	for i, g := range grants {
+		perms[i] = g.String()
+	}
+	return perms
+}
+
+// Allowed reports whether subject holds required. Placeholders in required
+// are filled by values; a placeholder without a non-empty value denies.
+func (p *Policy) Allowed(subject *Subject, required Permission, values func(name string) string) bool {
+	if subject == nil {
+		return false
+	}
+	want, ok := required.expand(values)
+	if !ok {
+		return false
+	}
+	for _, role := range subject.Roles {
+		for _, grant := range p.grants[role] {
+			if grant.grants(want, subject.Attributes) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Can reports whether subject holds permission, which must not contain
+// placeholders. A malformed permission is never held.
+func (p *Policy) Can(subject *Subject, permission string) bool {
+	perm, err := ParsePermission(permission)
+	if err != nil {
+		return false
+	}
+	return p.Allowed(subject, perm, nil)
+}
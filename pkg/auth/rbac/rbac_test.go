@@ -0,0 +1,125 @@
+package rbac_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/yshengliao/gortex/pkg/auth/rbac"
+	"github.com/yshengliao/gortex/pkg/config"
+)
+
+func newPolicy(t *testing.T) *rbac.Policy {
+	t.Helper()
+	policy, err := rbac.NewPolicy(map[string]rbac.Role{
+		"viewer": {Permissions: []string{"orders:*:read", "users:{user_id}:*"}},
+		"editor": {Inherits: []string{"viewer"}, Permissions: []string{"orders:write"}},
+		"lead":   {Inherits: []string{"editor"}},
+		"admin":  {Permissions: []string{"*"}},
+	})
+	require.NoError(t, err)
+	return policy
+}
+
+func TestPolicy_Can(t *testing.T) {
+	policy := newPolicy(t)
+	viewer := &rbac.Subject{Roles: []string{"viewer"}, Attributes: map[string]string{"user_id": "u1"}}
+	lead := &rbac.Subject{Roles: []string{"lead"}}
+	admin := &rbac.Subject{Roles: []string{"admin"}}
+
+	tests := []struct {
+		subject    *rbac.Subject
+		permission string
+		want       bool
+	}{
+		{viewer, "orders:7:read", true},
+		{viewer, "orders:7:write", false},
+		{viewer, "orders:write", false},
+		{viewer, "orders:read", false}, // "*" in the middle matches exactly one segment
+		{viewer, "users:u1:profile:write", true},
+		{viewer, "users:u2:read", false},
+		{lead, "orders:write", true}, // inherited through editor
+		{lead, "orders:7:read", true},
+		{lead, "users:u1:read", false}, // no user_id attribute
+		{admin, "anything:at:all", true},
+		{&rbac.Subject{Roles: []string{"unknown"}}, "orders:7:read", false},
+		{nil, "orders:7:read", false},
+		{admin, "orders::read", false}, // malformed
+	}
+	for _, tt := range tests {
+		var roles []string
+		if tt.subject != nil {
+			roles = tt.subject.Roles
+		}
+		assert.Equal(t, tt.want, policy.Can(tt.subject, tt.permission), "%v %s", roles, tt.permission)
+	}
+}
+
+func TestPolicy_AllowedSubstitutesValues(t *testing.T) {
+	policy, err := rbac.NewPolicy(map[string]rbac.Role{"viewer": {Permissions: []string{"orders:42:read"}}})
+	require.NoError(t, err)
+	viewer := &rbac.Subject{Roles: []string{"viewer"}}
+	perm, err := rbac.ParsePermission("orders:{id}:read")
+	require.NoError(t, err)
+
+	values := func(id string) func(string) string {
+		return func(name string) string {
+			if name == "id" {
+				return id
+			}
+			return ""
+		}
+	}
+	assert.True(t, policy.Allowed(viewer, perm, values("42")))
+	assert.False(t, policy.Allowed(viewer, perm, values("43")))
+	assert.False(t, policy.Allowed(viewer, perm, values("")), "a missing value denies")
+	assert.False(t, policy.Allowed(viewer, perm, nil))
+	// A substituted value is one literal segment.
+	assert.False(t, policy.Allowed(viewer, perm, values("42:read")))
+
+	assert.Equal(t, "orders:42:read", perm.Expand(values("42")))
+	assert.Equal(t, "orders:{id}:read", perm.String())
+	assert.Equal(t, []string{"id"}, perm.Placeholders())
+}
+
+func TestParsePermission_Errors(t *testing.T) {
+	for _, s := range []string{"", "orders::read", "orders:{}:read", "orders:{id:read", "orders:read*", "a:{b}c"} {
+		_, err := rbac.ParsePermission(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestNewPolicy_Errors(t *testing.T) {
+	_, err := rbac.NewPolicy(map[string]rbac.Role{"editor": {Inherits: []string{"viewer"}}})
+	assert.ErrorContains(t, err, `role "editor" inherits undefined role "viewer"`)
+
+	_, err = rbac.NewPolicy(map[string]rbac.Role{
+		"a": {Inherits: []string{"b"}},
+		"b": {Inherits: []string{"a"}},
+	})
+	assert.ErrorContains(t, err, "cycle a -> b -> a")
+
+	_, err = rbac.NewPolicy(map[string]rbac.Role{"a": {Permissions: []string{"x::y"}}})
+	assert.ErrorContains(t, err, `role "a"`)
+}
+
+func TestNewPolicyFromConfig(t *testing.T) {
+	var cfg config.Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+rbac:
+  roles:
+    viewer:
+      permissions: ["orders:*:read"]
+    editor:
+      inherits: [viewer]
+      permissions: ["orders:write"]
+`), &cfg))
+
+	policy, err := rbac.NewPolicyFromConfig(cfg.RBAC)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"orders:write", "orders:*:read"}, policy.Permissions("editor"))
+	assert.True(t, policy.Can(&rbac.Subject{Roles: []string{"editor"}}, "orders:1:read"))
+	assert.Nil(t, policy.Permissions("nobody"))
+}
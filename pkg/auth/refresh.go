@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenPair is the result of a token refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresh exchanges a refresh token for a new access token and a new
+// refresh token of the same family. With a TokenStore the presented token
+// is spent, even if getUserInfo fails, and presenting it again returns
+// ErrRefreshTokenReused and revokes the family, logging out both the thief
+// and the user. Without a store the old token stays valid until it expires.
+func (s *JWTService) Refresh(ctx context.Context, refreshToken string, getUserInfo func(userID string) (username, email, role string, err error)) (*TokenPair, error) {
+	claims, err := s.validateRefreshToken(ctx, refreshToken, true)
+	if err != nil {
+		return nil, err
+	}
+
+	username, email, role, err := getUserInfo(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.GenerateAccessToken(claims.Subject, username, email, role)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.issueRefreshToken(ctx, claims.Subject, claims.Family)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+// Revoke logs a token out. An access token is denylisted until it expires,
+// so ValidateToken and JWTAuth reject it; a refresh token has its whole
+// family revoked. Expired tokens need no revocation and return nil. It
+// requires a TokenStore.
+func (s *JWTService) Revoke(ctx context.Context, tokenString string) error {
+	if s.store == nil {
+		return errors.New("auth: revoking tokens requires a TokenStore")
+	}
+	// Only the registered claims are decoded, so tokens of a JWTServiceOf
+	// revoke the same way whatever their custom claims.
+	claims := &registeredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keyFunc, s.parserOptions()...)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return fmt.Errorf("invalid token")
+	}
+
+	switch claims.TokenType {
+	case tokenTypeAccess:
+		if claims.ID == "" || claims.ExpiresAt == nil {
+			return errors.New("auth: token has no ID or expiry and cannot be revoked")
+		}
+		return s.store.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time)
+	case tokenTypeRefresh:
+		if claims.Family == "" {
+			return errors.New("auth: refresh token has no family and cannot be revoked")
+		}
+		return s.store.RevokeFamily(ctx, claims.Family)
+	}
+	return ErrInvalidTokenType
+}
+
+// issueRefreshToken signs a refresh token in family, starting a new family
+// when it is empty, and records it in the TokenStore.
+func (s *JWTService) issueRefreshToken(ctx context.Context, userID, family string) (string, error) {
+	id := rand.Text()
+	if family == "" {
+		family = id
+	}
+	expiresAt := time.Now().Add(s.refreshTokenTTL)
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    s.issuer,
+			Subject:   userID,
+			ID:        id,
+		},
+		TokenType: tokenTypeRefresh,
+		Family:    family,
+	}
+
+	token, err := s.sign(claims)
+	if err != nil {
+		return "", err
+	}
+	if s.store != nil {
+		record := RefreshTokenRecord{ID: id, Family: family, UserID: userID, ExpiresAt: expiresAt}
+		if err := s.store.SaveRefreshToken(ctx, record); err != nil {
+			return "", err
+		}
+	}
+	return token, nil
+}
+
+// validateRefreshToken parses a refresh token and, with a TokenStore,
+// checks it is live, marking it exchanged when consume is set. A token
+// that was exchanged before has its family revoked.
+func (s *JWTService) validateRefreshToken(ctx context.Context, tokenString string, consume bool) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keyFunc, s.parserOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+	if claims.TokenType != tokenTypeRefresh {
+		return nil, ErrInvalidTokenType
+	}
+	if s.store == nil {
+		return claims, nil
+	}
+
+	lookup := s.store.LookupRefreshToken
+	if consume {
+		lookup = s.store.UseRefreshToken
+	}
+	record, err := lookup(ctx, claims.ID)
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if err := s.store.RevokeFamily(ctx, record.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+	claims.Family = record.Family
+	return claims, nil
+}
@@ -0,0 +1,217 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/pkg/auth"
+)
+
+func newRotatingService(t *testing.T) (*auth.JWTService, *auth.MemoryTokenStore) {
+	t.Helper()
+	svc, err := auth.NewJWTService(testSecret, time.Hour, 24*time.Hour, "issuer")
+	require.NoError(t, err)
+	store := auth.NewMemoryTokenStore(time.Minute)
+	t.Cleanup(store.Stop)
+	svc.SetTokenStore(store)
+	return svc, store
+}
+
+func userInfo(userID string) (string, string, string, error) {
+	return "alice", "alice@example.com", "admin", nil
+}
+
+func TestRefresh_RotatesRefreshToken(t *testing.T) {
+	svc, _ := newRotatingService(t)
+	ctx := context.Background()
+
+	first, err := svc.GenerateRefreshToken("user-1")
+	require.NoError(t, err)
+
+	pair, err := svc.Refresh(ctx, first, userInfo)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, pair.RefreshToken)
+
+	claims, err := svc.ValidateToken(pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, "alice", claims.Username)
+	assert.NotEmpty(t, claims.ID, "access tokens carry a jti")
+
+	// The rotated token keeps working until it is exchanged in turn.
+	_, err = svc.ValidateRefreshToken(pair.RefreshToken)
+	require.NoError(t, err)
+	next, err := svc.Refresh(ctx, pair.RefreshToken, userInfo)
+	require.NoError(t, err)
+	_, err = svc.RefreshAccessToken(next.RefreshToken, userInfo)
+	require.NoError(t, err)
+}
+
+func TestRefresh_ReuseRevokesFamily(t *testing.T) {
+	svc, _ := newRotatingService(t)
+	ctx := context.Background()
+
+	stolen, err := svc.GenerateRefreshToken("user-1")
+	require.NoError(t, err)
+	other, err := svc.GenerateRefreshToken("user-1") // a second login
+	require.NoError(t, err)
+
+	pair, err := svc.Refresh(ctx, stolen, userInfo)
+	require.NoError(t, err)
+
+	// Replaying the spent token is detected ...
+	_, err = svc.Refresh(ctx, stolen, userInfo)
+	assert.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+
+	// ... and revokes every token of its family.
+	_, err = svc.Refresh(ctx, pair.RefreshToken, userInfo)
+	assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	_, err = svc.ValidateRefreshToken(pair.RefreshToken)
+	assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+
+	// Other families are unaffected.
+	_, err = svc.Refresh(ctx, other, userInfo)
+	assert.NoError(t, err)
+}
+
+func TestRefresh_ConcurrentExchangeSucceedsOnce(t *testing.T) {
+	svc, _ := newRotatingService(t)
+	token, err := svc.GenerateRefreshToken("user-1")
+	require.NoError(t, err)
+
+	const n = 16
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes int
+	)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.Refresh(context.Background(), token, userInfo); err == nil {
+				mu.Lock()
+				successes++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, successes)
+}
+
+func TestRefresh_FailsBeforeIssuingOnUserInfoError(t *testing.T) {
+	svc, _ := newRotatingService(t)
+	token, err := svc.GenerateRefreshToken("user-1")
+	require.NoError(t, err)
+
+	boom := errors.New("user deleted")
+	_, err = svc.Refresh(context.Background(), token, func(string) (string, string, string, error) {
+		return "", "", "", boom
+	})
+	assert.ErrorIs(t, err, boom)
+}
+
+func TestRefresh_WithoutStore(t *testing.T) {
+	svc, err := auth.NewJWTService(testSecret, time.Hour, 24*time.Hour, "issuer")
+	require.NoError(t, err)
+	token, err := svc.GenerateRefreshToken("user-1")
+	require.NoError(t, err)
+
+	pair, err := svc.Refresh(context.Background(), token, userInfo)
+	require.NoError(t, err)
+	_, err = svc.ValidateRefreshToken(pair.RefreshToken)
+	require.NoError(t, err)
+
+	// Nothing is tracked, so the old token stays valid and revocation is
+	// unavailable.
+	_, err = svc.Refresh(context.Background(), token, userInfo)
+	assert.NoError(t, err)
+	assert.Error(t, svc.Revoke(context.Background(), token))
+
+	_, err = svc.Refresh(context.Background(), pair.AccessToken, userInfo)
+	assert.ErrorIs(t, err, auth.ErrInvalidTokenType)
+}
+
+func TestRevoke(t *testing.T) {
+	svc, _ := newRotatingService(t)
+	ctx := context.Background()
+
+	access, err := svc.GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+	other, err := svc.GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+	refresh, err := svc.GenerateRefreshToken("user-1")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Revoke(ctx, access))
+	_, err = svc.ValidateToken(access)
+	assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	_, err = svc.ValidateTokenContext(ctx, other)
+	assert.NoError(t, err, "only the revoked jti is denied")
+
+	require.NoError(t, svc.Revoke(ctx, refresh))
+	_, err = svc.Refresh(ctx, refresh, userInfo)
+	assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+
+	assert.Error(t, svc.Revoke(ctx, "not-a-token"))
+
+	expiredSvc, err := auth.NewJWTService(testSecret, -time.Minute, time.Hour, "issuer")
+	require.NoError(t, err)
+	expiredStore := auth.NewMemoryTokenStore(time.Minute)
+	defer expiredStore.Stop()
+	expiredSvc.SetTokenStore(expiredStore)
+	expired, err := expiredSvc.GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+	assert.NoError(t, expiredSvc.Revoke(ctx, expired), "expired tokens need no revocation")
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	store := auth.NewMemoryTokenStore(time.Minute)
+	defer store.Stop()
+	ctx := context.Background()
+
+	assert.Error(t, store.SaveRefreshToken(ctx, auth.RefreshTokenRecord{ID: "a"}))
+
+	live := auth.RefreshTokenRecord{ID: "a", Family: "a", UserID: "u", ExpiresAt: time.Now().Add(time.Hour)}
+	expired := auth.RefreshTokenRecord{ID: "b", Family: "b", UserID: "u", ExpiresAt: time.Now().Add(-time.Second)}
+	require.NoError(t, store.SaveRefreshToken(ctx, live))
+	require.NoError(t, store.SaveRefreshToken(ctx, expired))
+
+	_, err := store.LookupRefreshToken(ctx, "b")
+	assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+	_, err = store.UseRefreshToken(ctx, "missing")
+	assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+
+	rec, err := store.UseRefreshToken(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, live, rec)
+	rec, err = store.UseRefreshToken(ctx, "a")
+	assert.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+	assert.Equal(t, "a", rec.Family)
+
+	// A family revoked before a token is saved stays revoked.
+	require.NoError(t, store.RevokeFamily(ctx, "c"))
+	require.NoError(t, store.SaveRefreshToken(ctx, auth.RefreshTokenRecord{ID: "c2", Family: "c", ExpiresAt: time.Now().Add(time.Hour)}))
+	_, err = store.LookupRefreshToken(ctx, "c2")
+	assert.ErrorIs(t, err, auth.ErrTokenRevoked)
+
+	require.NoError(t, store.RevokeAccessToken(ctx, "x", time.Now().Add(time.Hour)))
+	require.NoError(t, store.RevokeAccessToken(ctx, "y", time.Now().Add(-time.Second)))
+	revoked, err := store.IsAccessTokenRevoked(ctx, "x")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = store.IsAccessTokenRevoked(ctx, "y")
+	require.NoError(t, err)
+	assert.False(t, revoked, "denylist entries lapse with the token")
+
+	store.Cleanup()
+	_, err = store.LookupRefreshToken(ctx, "c2")
+	assert.ErrorIs(t, err, auth.ErrTokenRevoked, "revocation outlives cleanup while tokens are live")
+}
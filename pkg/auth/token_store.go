@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked is returned for a token that was revoked, or for a
+// refresh token the TokenStore does not know (e.g. one that expired).
+var ErrTokenRevoked = errors.New("auth: token revoked")
+
+// ErrRefreshTokenReused is returned when a refresh token that was already
+// exchanged is presented again. Only one of the two presenters can be the
+// legitimate client, so the token's whole family is revoked.
+var ErrRefreshTokenReused = errors.New("auth: refresh token reused")
+
+// RefreshTokenRecord describes an issued refresh token.
+type RefreshTokenRecord struct {
+	// ID is the token's "jti" claim.
+	ID string
+	// Family is the ID of the first refresh token of the rotation chain
+	// this token belongs to. Tokens from one login share a family.
+	Family string
+	// UserID is the token's subject.
+	UserID string
+	// ExpiresAt is when the token expires; the store may forget it after.
+	ExpiresAt time.Time
+}
+
+// TokenStore tracks issued refresh tokens and revoked token IDs, for
+// refresh-token rotation (JWTService.Refresh) and logout
+// (JWTService.Revoke). Implementations must be safe for concurrent use and
+// UseRefreshToken must be atomic, so that one refresh token cannot be
+// exchanged twice by concurrent requests.
+type TokenStore interface {
+	// SaveRefreshToken records an issued refresh token.
+	SaveRefreshToken(ctx context.Context, record RefreshTokenRecord) error
+	// UseRefreshToken marks refresh token id as exchanged and returns its
+	// record. It returns ErrRefreshTokenReused, along with the record, if
+	// the token was exchanged before, and ErrTokenRevoked if it is unknown,
+	// expired or its family was revoked.
+	UseRefreshToken(ctx context.Context, id string) (RefreshTokenRecord, error)
+	// LookupRefreshToken is UseRefreshToken without marking the token
+	// exchanged.
+	LookupRefreshToken(ctx context.Context, id string) (RefreshTokenRecord, error)
+	// RevokeFamily revokes every refresh token of family, including ones
+	// saved later.
+	RevokeFamily(ctx context.Context, family string) error
+	// RevokeAccessToken denylists access token id until expiresAt.
+	RevokeAccessToken(ctx context.Context, id string, expiresAt time.Time) error
+	// IsAccessTokenRevoked reports whether access token id is denylisted.
+	IsAccessTokenRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// refreshEntry is the state a MemoryTokenStore keeps per refresh token.
+type refreshEntry struct {
+	record RefreshTokenRecord
+	used   bool
+}
+
+// MemoryTokenStore implements TokenStore in memory, for single-instance
+// deployments and tests. Expired entries are removed by a background
+// goroutine; call Stop when done with the store.
+type MemoryTokenStore struct {
+	mu       sync.Mutex
+	refresh  map[string]*refreshEntry
+	families map[string]time.Time // revoked family -> forget after
+	denied   map[string]time.Time // revoked access token -> expiry
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMemoryTokenStore creates a MemoryTokenStore that removes expired
+// entries every cleanupInterval (10 minutes if not positive).
+func NewMemoryTokenStore(cleanupInterval time.Duration) *MemoryTokenStore {
+	if cleanupInterval <= 0 {
+		cleanupInterval = 10 * time.Minute
+	}
+	s := &MemoryTokenStore{
+		refresh:  make(map[string]*refreshEntry),
+		families: make(map[string]time.Time),
+		denied:   make(map[string]time.Time),
+		stopCh:   make(chan struct{}),
+	}
+	go s.runCleanup(cleanupInterval)
+	return s
+}
+
+func (s *MemoryTokenStore) runCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Cleanup()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// Stop shuts down the background cleanup goroutine. Safe to call multiple times.
+func (s *MemoryTokenStore) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+}
+
+// Cleanup removes expired refresh tokens, denylist entries and revoked
+// families none of whose tokens can still be presented.
+func (s *MemoryTokenStore) Cleanup() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, e := range s.refresh {
+		if now.After(e.record.ExpiresAt) {
+			delete(s.refresh, id)
+		}
+	}
+	for id, exp := range s.denied {
+		if now.After(exp) {
+			delete(s.denied, id)
+		}
+	}
+	for family, until := range s.families {
+		if now.After(until) {
+			delete(s.families, family)
+		}
+	}
+}
+
+// SaveRefreshToken implements TokenStore.
+func (s *MemoryTokenStore) SaveRefreshToken(_ context.Context, record RefreshTokenRecord) error {
+	if record.ID == "" || record.Family == "" {
+		return errors.New("auth: refresh token record needs an ID and a family")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh[record.ID] = &refreshEntry{record: record}
+	if until, revoked := s.families[record.Family]; revoked && record.ExpiresAt.After(until) {
+		s.families[record.Family] = record.ExpiresAt
+	}
+	return nil
+}
+
+// UseRefreshToken implements TokenStore.
+func (s *MemoryTokenStore) UseRefreshToken(_ context.Context, id string) (RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookupLocked(id)
+	if err != nil {
+		return RefreshTokenRecord{}, err
+	}
+	if e.used {
+		return e.record, ErrRefreshTokenReused
+	}
+	e.used = true
+	return e.record, nil
+}
+
+// LookupRefreshToken implements TokenStore.
+func (s *MemoryTokenStore) LookupRefreshToken(_ context.Context, id string) (RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookupLocked(id)
+	if err != nil {
+		return RefreshTokenRecord{}, err
+	}
+	if e.used {
+		return e.record, ErrRefreshTokenReused
+	}
+	return e.record, nil
+}
+
+func (s *MemoryTokenStore) lookupLocked(id string) (*refreshEntry, error) {
+	e, ok := s.refresh[id]
+	if !ok || time.Now().After(e.record.ExpiresAt) {
+		return nil, ErrTokenRevoked
+	}
+	if _, revoked := s.families[e.record.Family]; revoked {
+		return nil, ErrTokenRevoked
+	}
+	return e, nil
+}
+
+// RevokeFamily implements TokenStore.
+func (s *MemoryTokenStore) RevokeFamily(_ context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Remember the revocation as long as a token of the family may be
+	// presented.
+	until := time.Now()
+	for _, e := range s.refresh {
+		if e.record.Family == family && e.record.ExpiresAt.After(until) {
+			until = e.record.ExpiresAt
+		}
+	}
+	s.families[family] = until
+	return nil
+}
+
+// RevokeAccessToken implements TokenStore.
+func (s *MemoryTokenStore) RevokeAccessToken(_ context.Context, id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.denied[id] = expiresAt
+	return nil
+}
+
+// IsAccessTokenRevoked implements TokenStore.
+func (s *MemoryTokenStore) IsAccessTokenRevoked(_ context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.denied[id]
+	return ok && !time.Now().After(exp), nil
+}
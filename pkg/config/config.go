@@ -14,6 +14,7 @@ type Config struct {
 	WebSocket WebSocketConfig `yaml:"websocket" env:"WEBSOCKET"`
 	JWT       JWTConfig       `yaml:"jwt" env:"JWT"`
 	Database  DatabaseConfig  `yaml:"database" env:"DATABASE"`
+	RBAC      RBACConfig      `yaml:"rbac"`
 }
 
 // ServerConfig holds HTTP server configuration
@@ -28,6 +29,20 @@ type ServerConfig struct {
 	CORS            bool              `yaml:"cors" env:"CORS" default:"true"`
 	Recovery        bool              `yaml:"recovery" env:"RECOVERY" default:"true"`
 	Compression     CompressionConfig `yaml:"compression" env:"COMPRESSION"`
+	TLS             TLSConfig         `yaml:"tls" env:"TLS"`
+}
+
+// TLSConfig holds HTTPS configuration. The server uses TLS when CertFile
+// is set; the files are reloaded when they change.
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file" env:"CERT_FILE"`
+	KeyFile      string `yaml:"key_file" env:"KEY_FILE"`
+	ClientCAFile string `yaml:"client_ca_file" env:"CLIENT_CA_FILE"`
+	// ClientAuth is none, request, require, verify_if_given or
+	// require_and_verify; it defaults to require_and_verify when
+	// ClientCAFile is set and to none otherwise.
+	ClientAuth     string        `yaml:"client_auth" env:"CLIENT_AUTH"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"RELOAD_INTERVAL" default:"1m"`
 }
 
 // CompressionConfig holds compression middleware configuration
@@ -79,6 +94,19 @@ type DatabaseConfig struct {
 	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"CONN_MAX_LIFETIME" default:"5m"`
 }
 
+// RBACConfig holds the role-based access control policy, built with
+// rbac.NewPolicyFromConfig. It has no env tag: roles are only read from
+// YAML or JSON.
+type RBACConfig struct {
+	Roles map[string]RoleConfig `yaml:"roles"`
+}
+
+// RoleConfig defines one role of the RBAC policy
+type RoleConfig struct {
+	Inherits    []string `yaml:"inherits"`
+	Permissions []string `yaml:"permissions"`
+}
+
 // LoaderFunc is a function that loads configuration
 type LoaderFunc func(*Config) error
 
@@ -116,6 +144,9 @@ func DefaultConfig() *Config {
 					"application/xml",
 				},
 			},
+			TLS: TLSConfig{
+				ReloadInterval: time.Minute,
+			},
 		},
 		Logger: LoggerConfig{
 			Level:            "info",
@@ -21,6 +21,8 @@ const (
 	CodeInvalidType          ErrorCode = 1007
 	CodeInvalidJSON          ErrorCode = 1008
 	CodeInvalidQueryParam    ErrorCode = 1009
+	CodePayloadTooLarge      ErrorCode = 1010
+	CodeUnsupportedMediaType ErrorCode = 1011
 
 	// Authentication/Authorization errors (2xxx)
 	CodeUnauthorized            ErrorCode = 2000
@@ -82,6 +84,8 @@ var errorMessages = map[ErrorCode]string{
 	CodeInvalidType:          "Invalid type",
 	CodeInvalidJSON:          "Invalid JSON format",
 	CodeInvalidQueryParam:    "Invalid query parameter",
+	CodePayloadTooLarge:      "Request body too large",
+	CodeUnsupportedMediaType: "Unsupported media type",
 
 	// Authentication/Authorization errors
 	CodeUnauthorized:            "Unauthorized access",
@@ -161,6 +165,14 @@ func GetHTTPStatus(code ErrorCode) int {
 		CodeInvalidLength, CodeInvalidType:
 		return 400
 
+	// Payload too large -> 413
+	case CodePayloadTooLarge:
+		return 413
+
+	// Unsupported media type -> 415
+	case CodeUnsupportedMediaType:
+		return 415
+
 	// Auth errors -> 401/403
 	case CodeUnauthorized, CodeTokenExpired, CodeInvalidToken:
 		return 401
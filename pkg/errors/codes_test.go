@@ -14,6 +14,8 @@ func TestErrorCode_Message(t *testing.T) {
 		{"ValidationFailed", CodeValidationFailed, "Validation failed"},
 		{"InvalidInput", CodeInvalidInput, "Invalid input provided"},
 		{"MissingRequiredField", CodeMissingRequiredField, "Required field is missing"},
+		{"PayloadTooLarge", CodePayloadTooLarge, "Request body too large"},
+		{"UnsupportedMediaType", CodeUnsupportedMediaType, "Unsupported media type"},
 
 		// Auth errors
 		{"Unauthorized", CodeUnauthorized, "Unauthorized access"},
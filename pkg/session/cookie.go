@@ -0,0 +1,109 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CookieKeySize is the size of a CookieStore key: AES-256.
+const CookieKeySize = 32
+
+// MaxCookieSize is the largest cookie value a CookieStore produces.
+// Browsers cap a cookie, name and attributes included, at about 4KB.
+const MaxCookieSize = 3800
+
+// ErrCookieTooLarge is returned by CookieStore.Save when the sealed
+// session does not fit in a cookie; keep larger data server-side.
+var ErrCookieTooLarge = errors.New("session: sealed session exceeds MaxCookieSize")
+
+// CookieStore keeps the whole session in the cookie, sealed with
+// AES-256-GCM: the client can neither read nor alter it, and the expiry
+// sealed inside is enforced on load. Nothing is stored server-side, so a
+// session cannot be revoked before it expires; Destroy only expires the
+// cookie in the browser.
+type CookieStore struct {
+	aeads []cipher.AEAD
+}
+
+// NewCookieStore returns a CookieStore sealing with the first key and
+// opening with any of them, so keys can be rotated by prepending a new one
+// and dropping the old one once its sessions have expired. Keys must be
+// CookieKeySize random bytes.
+func NewCookieStore(keys ...[]byte) (*CookieStore, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("session: CookieStore requires a key")
+	}
+	s := &CookieStore{}
+	for i, key := range keys {
+		if len(key) != CookieKeySize {
+			return nil, fmt.Errorf("session: cookie key %d must be %d bytes, got %d", i, CookieKeySize, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		s.aeads = append(s.aeads, aead)
+	}
+	return s, nil
+}
+
+// Load implements Store, opening the sealed session in token.
+func (s *CookieStore) Load(_ context.Context, token string) (*Record, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	for _, aead := range s.aeads {
+		size := aead.NonceSize()
+		if len(sealed) < size {
+			return nil, ErrNotFound
+		}
+		plain, err := aead.Open(nil, sealed[:size], sealed[size:], nil)
+		if err != nil {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(plain, &rec); err != nil || rec.ID == "" {
+			return nil, ErrNotFound
+		}
+		if time.Now().After(rec.ExpiresAt) {
+			return nil, ErrNotFound
+		}
+		return &rec, nil
+	}
+	return nil, ErrNotFound
+}
+
+// Save implements Store, returning the sealed session as the cookie value.
+func (s *CookieStore) Save(_ context.Context, rec *Record) (string, error) {
+	plain, err := json.Marshal(rec)
+	if err != nil {
+		return "", fmt.Errorf("session: encode: %w", err)
+	}
+	aead := s.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil))
+	if len(token) > MaxCookieSize {
+		return "", ErrCookieTooLarge
+	}
+	return token, nil
+}
+
+// Delete implements Store. There is no server-side state to delete.
+func (s *CookieStore) Delete(context.Context, string) error {
+	return nil
+}
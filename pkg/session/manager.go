@@ -0,0 +1,160 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// DefaultLifetime is the longest a session lives, however often it is used.
+const DefaultLifetime = 24 * time.Hour
+
+// Config configures a Manager.
+type Config struct {
+	// Store persists sessions.
+	Store Store
+	// CookieName is the session cookie's name. Default "session_id", the
+	// name SessionAuth and the OIDC middleware use.
+	CookieName string
+	// IdleTimeout ends a session that is not used for this long; every
+	// request extends it (sliding expiry). Default DefaultIdleTimeout.
+	IdleTimeout time.Duration
+	// Lifetime ends a session this long after it started, however often
+	// it is used. Default DefaultLifetime.
+	Lifetime time.Duration
+	// CookiePath scopes the cookie to a URL path. Default "/".
+	CookiePath string
+	// CookieDomain, when non-empty, sets the cookie's Domain attribute.
+	CookieDomain string
+	// CookieSameSite controls the SameSite attribute. Default Lax.
+	CookieSameSite http.SameSite
+	// InsecureCookie drops the Secure attribute, for local development
+	// over plain HTTP only.
+	InsecureCookie bool
+}
+
+// Manager loads sessions from requests and commits them to responses.
+type Manager struct {
+	config Config
+}
+
+// NewManager returns a Manager for config.
+func NewManager(config Config) (*Manager, error) {
+	if config.Store == nil {
+		return nil, errors.New("session: Store is required")
+	}
+	if config.CookieName == "" {
+		config.CookieName = "session_id"
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = DefaultIdleTimeout
+	}
+	if config.Lifetime <= 0 {
+		config.Lifetime = DefaultLifetime
+	}
+	if config.IdleTimeout > config.Lifetime {
+		return nil, errors.New("session: IdleTimeout exceeds Lifetime")
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = "/"
+	}
+	if config.CookieSameSite == 0 {
+		config.CookieSameSite = http.SameSiteLaxMode
+	}
+	return &Manager{config: config}, nil
+}
+
+// Config returns the configuration with defaults applied.
+func (m *Manager) Config() Config {
+	return m.config
+}
+
+// Load returns the session r's cookie refers to, or a new empty session
+// when there is none or it expired. Only Store failures are errors.
+func (m *Manager) Load(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(m.config.CookieName)
+	if err != nil || cookie.Value == "" {
+		return newSession(), nil
+	}
+	rec, err := m.config.Store.Load(r.Context(), cookie.Value)
+	if errors.Is(err, ErrNotFound) {
+		return newSession(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(rec.CreatedAt.Add(m.config.Lifetime)) {
+		_ = m.config.Store.Delete(r.Context(), rec.ID)
+		return newSession(), nil
+	}
+	return fromRecord(rec), nil
+}
+
+// Commit saves s and sets its cookie on w, extending its expiry. It must
+// run before the response header is written. A new session that holds
+// nothing is not saved, so requests that never use the session do not
+// create one.
+func (m *Manager) Commit(ctx context.Context, w http.ResponseWriter, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.staleID != "" {
+		if err := m.config.Store.Delete(ctx, s.staleID); err != nil {
+			return err
+		}
+		s.staleID = ""
+	}
+	if s.destroyed {
+		if !s.isNew {
+			if err := m.config.Store.Delete(ctx, s.id); err != nil {
+				return err
+			}
+		}
+		m.setCookie(w, "", -1)
+		return nil
+	}
+	if s.isNew && !s.modified {
+		return nil
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(m.config.IdleTimeout)
+	if end := s.createdAt.Add(m.config.Lifetime); end.Before(expiresAt) {
+		expiresAt = end
+	}
+	token, err := m.config.Store.Save(ctx, &Record{
+		ID:        s.id,
+		Values:    s.values,
+		CreatedAt: s.createdAt,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	s.expiresAt = expiresAt
+	s.isNew = false
+	s.modified = false
+	m.setCookie(w, token, expiresAt.Sub(now))
+	return nil
+}
+
+// setCookie sets the session cookie, deleting it for a negative maxAge.
+func (m *Manager) setCookie(w http.ResponseWriter, value string, maxAge time.Duration) {
+	cookie := &http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    value,
+		Path:     m.config.CookiePath,
+		Domain:   m.config.CookieDomain,
+		Secure:   !m.config.InsecureCookie,
+		HttpOnly: true,
+		SameSite: m.config.CookieSameSite,
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	} else {
+		cookie.MaxAge = int(maxAge.Seconds())
+		cookie.Expires = time.Now().Add(maxAge)
+	}
+	http.SetCookie(w, cookie)
+}
@@ -0,0 +1,151 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+// DefaultIdleTimeout is how long a session lives without being used.
+const DefaultIdleTimeout = 30 * time.Minute
+
+// MemoryStore implements Store in memory, for single-instance deployments
+// and tests. The cookie carries only the session ID. Expired sessions are
+// removed by a background goroutine; call Stop when done with the store.
+//
+// MemoryStore also implements middleware.SessionCreator, so it can back
+// SessionAuth and the OIDC middleware directly.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Record
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMemoryStore creates a MemoryStore that removes expired sessions every
+// cleanupInterval (10 minutes if not positive).
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	if cleanupInterval <= 0 {
+		cleanupInterval = 10 * time.Minute
+	}
+	s := &MemoryStore{
+		sessions: make(map[string]*Record),
+		stopCh:   make(chan struct{}),
+	}
+	go s.runCleanup(cleanupInterval)
+	return s
+}
+
+func (s *MemoryStore) runCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Cleanup()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// Stop shuts down the background cleanup goroutine. Safe to call multiple times.
+func (s *MemoryStore) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+}
+
+// Cleanup removes expired sessions.
+func (s *MemoryStore) Cleanup() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, rec := range s.sessions {
+		if now.After(rec.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// Len returns the number of stored sessions, including expired ones not
+// yet cleaned up.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Load implements Store. The token is the session ID.
+func (s *MemoryStore) Load(_ context.Context, token string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.sessions[token]
+	if !ok || time.Now().After(rec.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	// Hand out a copy so the request's changes stay private until saved.
+	loaded := *rec
+	loaded.Values = maps.Clone(rec.Values)
+	return &loaded, nil
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(_ context.Context, rec *Record) (string, error) {
+	if rec.ID == "" {
+		return "", errors.New("session: record has no ID")
+	}
+	saved := *rec
+	saved.Values = maps.Clone(rec.Values)
+	s.mu.Lock()
+	s.sessions[rec.ID] = &saved
+	s.mu.Unlock()
+	return rec.ID, nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// Get returns the values of session sessionID, for middleware.SessionAuth.
+func (s *MemoryStore) Get(sessionID string) (map[string]interface{}, error) {
+	rec, err := s.Load(context.Background(), sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Values == nil {
+		rec.Values = make(map[string]any)
+	}
+	return rec.Values, nil
+}
+
+// Validate reports whether session sessionID exists and has not expired.
+func (s *MemoryStore) Validate(sessionID string) (bool, error) {
+	_, err := s.Load(context.Background(), sessionID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Create starts a session holding data that expires after
+// DefaultIdleTimeout unless a Manager extends it, and returns its ID.
+func (s *MemoryStore) Create(data map[string]interface{}) (string, error) {
+	now := time.Now()
+	return s.Save(context.Background(), &Record{
+		ID:        newID(),
+		Values:    data,
+		CreatedAt: now,
+		ExpiresAt: now.Add(DefaultIdleTimeout),
+	})
+}
+
+// Destroy deletes session sessionID.
+func (s *MemoryStore) Destroy(sessionID string) error {
+	return s.Delete(context.Background(), sessionID)
+}
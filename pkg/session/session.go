@@ -0,0 +1,209 @@
+// Package session provides server- and client-side HTTP sessions.
+//
+// A Manager loads the session a request's cookie refers to from a Store,
+// hands it to the handler as a *Session and commits it back before the
+// response is written. MemoryStore keeps sessions in process; CookieStore
+// keeps them in the cookie itself, encrypted and authenticated:
+//
+//	store := session.NewMemoryStore(time.Minute) // Stop() on shutdown
+//	manager, _ := session.NewManager(session.Config{Store: store})
+//	app.Use(middleware.Sessions(manager))
+//
+//	func (h *AuthHandler) Login(c httpctx.Context) error {
+//		s := middleware.GetSession(c)
+//		_ = s.Regenerate() // new ID on privilege change
+//		s.Set("user_id", user.ID)
+//		...
+//	}
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+// ContextKey is the key the Sessions middleware stores the *Session under
+// in the request's gortex context.
+const ContextKey = "gortex.session"
+
+// ErrNotFound is returned by Store.Load for unknown, expired or tampered
+// sessions.
+var ErrNotFound = errors.New("session: not found")
+
+// Record is the stored form of a session.
+type Record struct {
+	ID        string         `json:"id"`
+	Values    map[string]any `json:"values,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// Store persists sessions. Implementations must be safe for concurrent use.
+type Store interface {
+	// Load returns the session the cookie value token refers to, or
+	// ErrNotFound.
+	Load(ctx context.Context, token string) (*Record, error)
+	// Save persists rec until rec.ExpiresAt and returns the cookie value
+	// that loads it.
+	Save(ctx context.Context, rec *Record) (string, error)
+	// Delete removes the session with ID id. Stores without server-side
+	// state have nothing to delete.
+	Delete(ctx context.Context, id string) error
+}
+
+// Session is the session of one request. It is safe for concurrent use by
+// the goroutines serving that request.
+type Session struct {
+	mu        sync.Mutex
+	id        string
+	values    map[string]any
+	createdAt time.Time
+	expiresAt time.Time
+
+	// staleID is the ID replaced by Regenerate, deleted on commit.
+	staleID   string
+	isNew     bool
+	modified  bool
+	destroyed bool
+}
+
+// newSession returns an empty session with a fresh ID.
+func newSession() *Session {
+	return &Session{id: newID(), values: make(map[string]any), createdAt: time.Now(), isNew: true}
+}
+
+// fromRecord returns the session stored in rec.
+func fromRecord(rec *Record) *Session {
+	values := rec.Values
+	if values == nil {
+		values = make(map[string]any)
+	}
+	return &Session{id: rec.ID, values: values, createdAt: rec.CreatedAt, expiresAt: rec.ExpiresAt}
+}
+
+// newID returns a random session ID with 130 bits of entropy.
+func newID() string {
+	return rand.Text()
+}
+
+// ID returns the session ID. It changes on Regenerate.
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+// IsNew reports whether the session was started by this request.
+func (s *Session) IsNew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isNew
+}
+
+// CreatedAt returns when the session was started.
+func (s *Session) CreatedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createdAt
+}
+
+// ExpiresAt returns when the session expires unless it is used again. It
+// is zero for a new session until it is committed.
+func (s *Session) ExpiresAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expiresAt
+}
+
+// Get returns the value stored under key, or nil. Values loaded from a
+// CookieStore have been through JSON, so numbers are float64.
+func (s *Session) Get(key string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// GetString returns the string stored under key, or "".
+func (s *Session) GetString(key string) string {
+	v, _ := s.Get(key).(string)
+	return v
+}
+
+// Set stores value under key. Values must encode to JSON for a CookieStore.
+func (s *Session) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.modified = true
+}
+
+// Delete removes the value stored under key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.modified = true
+	}
+}
+
+// Values returns a copy of all stored values.
+func (s *Session) Values() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.values)
+}
+
+// Clear removes all stored values, keeping the session.
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.values) > 0 {
+		clear(s.values)
+		s.modified = true
+	}
+}
+
+// Regenerate gives the session a new ID, keeping its values, and deletes
+// the old one on commit. Call it whenever the user's privileges change,
+// at login in particular, so an ID planted before the login (session
+// fixation) is worthless afterwards.
+func (s *Session) Regenerate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.destroyed {
+		return errors.New("session: regenerate after destroy")
+	}
+	if !s.isNew && s.staleID == "" {
+		s.staleID = s.id
+	}
+	s.id = newID()
+	s.modified = true
+	return nil
+}
+
+// Destroy ends the session: it is deleted from the store and its cookie
+// is expired on commit.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destroyed = true
+	clear(s.values)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying s.
+func NewContext(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// FromContext returns the session carried by ctx, or nil.
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(contextKey{}).(*Session)
+	return s
+}
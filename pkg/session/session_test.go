@@ -0,0 +1,228 @@
+package session_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/pkg/session"
+)
+
+func newManager(t *testing.T, store session.Store, configure ...func(*session.Config)) *session.Manager {
+	t.Helper()
+	config := session.Config{Store: store}
+	for _, fn := range configure {
+		fn(&config)
+	}
+	manager, err := session.NewManager(config)
+	require.NoError(t, err)
+	return manager
+}
+
+// roundTrip loads the session for a request carrying cookie, lets use
+// change it and commits it, returning the session and the response cookie.
+func roundTrip(t *testing.T, manager *session.Manager, cookie *http.Cookie, use func(*session.Session)) (*session.Session, *http.Cookie) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	s, err := manager.Load(req)
+	require.NoError(t, err)
+	if use != nil {
+		use(s)
+	}
+	rec := httptest.NewRecorder()
+	require.NoError(t, manager.Commit(req.Context(), rec, s))
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "session_id" {
+			return s, c
+		}
+	}
+	return s, nil
+}
+
+func TestManager_MemoryStore(t *testing.T) {
+	store := session.NewMemoryStore(time.Minute)
+	defer store.Stop()
+	manager := newManager(t, store)
+
+	// An unused session is neither stored nor sent.
+	s, cookie := roundTrip(t, manager, nil, nil)
+	assert.True(t, s.IsNew())
+	assert.Nil(t, cookie)
+	assert.Equal(t, 0, store.Len())
+
+	s, cookie = roundTrip(t, manager, nil, func(s *session.Session) { s.Set("user_id", "u1") })
+	require.NotNil(t, cookie)
+	assert.Equal(t, s.ID(), cookie.Value, "the cookie carries only the ID")
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	loaded, _ := roundTrip(t, manager, cookie, nil)
+	assert.False(t, loaded.IsNew())
+	assert.Equal(t, "u1", loaded.GetString("user_id"))
+
+	// Unknown IDs start a new session.
+	fresh, _ := roundTrip(t, manager, &http.Cookie{Name: "session_id", Value: "forged"}, nil)
+	assert.True(t, fresh.IsNew())
+	assert.NotEqual(t, "forged", fresh.ID())
+}
+
+func TestManager_SlidingExpiryAndLifetime(t *testing.T) {
+	store := session.NewMemoryStore(time.Minute)
+	defer store.Stop()
+	manager := newManager(t, store, func(c *session.Config) {
+		c.IdleTimeout = 200 * time.Millisecond
+		c.Lifetime = 500 * time.Millisecond
+	})
+
+	_, cookie := roundTrip(t, manager, nil, func(s *session.Session) { s.Set("k", "v") })
+	require.NotNil(t, cookie)
+
+	// Each use extends the idle timeout ...
+	for range 3 {
+		time.Sleep(120 * time.Millisecond)
+		s, _ := roundTrip(t, manager, cookie, nil)
+		require.False(t, s.IsNew(), "session expired while in use")
+	}
+	// ... but not beyond the lifetime.
+	time.Sleep(200 * time.Millisecond)
+	s, _ := roundTrip(t, manager, cookie, nil)
+	assert.True(t, s.IsNew())
+
+	// Idle sessions expire.
+	_, cookie = roundTrip(t, manager, nil, func(s *session.Session) { s.Set("k", "v") })
+	time.Sleep(300 * time.Millisecond)
+	s, _ = roundTrip(t, manager, cookie, nil)
+	assert.True(t, s.IsNew())
+}
+
+func TestManager_RegenerateAndDestroy(t *testing.T) {
+	store := session.NewMemoryStore(time.Minute)
+	defer store.Stop()
+	manager := newManager(t, store)
+
+	anonymous, cookie := roundTrip(t, manager, nil, func(s *session.Session) { s.Set("cart", "3 items") })
+	oldID := anonymous.ID()
+
+	loggedIn, cookie := roundTrip(t, manager, cookie, func(s *session.Session) {
+		require.NoError(t, s.Regenerate())
+		s.Set("user_id", "u1")
+	})
+	assert.NotEqual(t, oldID, loggedIn.ID())
+	assert.Equal(t, loggedIn.ID(), cookie.Value)
+	assert.Equal(t, "3 items", loggedIn.GetString("cart"), "values survive regeneration")
+	valid, err := store.Validate(oldID)
+	require.NoError(t, err)
+	assert.False(t, valid, "the pre-login ID is deleted")
+
+	_, expired := roundTrip(t, manager, cookie, func(s *session.Session) { s.Destroy() })
+	require.NotNil(t, expired)
+	assert.Equal(t, -1, expired.MaxAge)
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestMemoryStore_SessionCreator(t *testing.T) {
+	store := session.NewMemoryStore(time.Minute)
+	defer store.Stop()
+
+	id, err := store.Create(map[string]interface{}{"user_id": "u1"})
+	require.NoError(t, err)
+	valid, err := store.Validate(id)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	data, err := store.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", data["user_id"])
+
+	// Sessions created this way load through a Manager.
+	s, _ := roundTrip(t, newManager(t, store), &http.Cookie{Name: "session_id", Value: id}, nil)
+	assert.Equal(t, "u1", s.GetString("user_id"))
+
+	require.NoError(t, store.Destroy(id))
+	_, err = store.Get(id)
+	assert.ErrorIs(t, err, session.ErrNotFound)
+}
+
+func TestCookieStore(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, session.CookieKeySize)
+	newKey := bytes.Repeat([]byte{2}, session.CookieKeySize)
+
+	_, err := session.NewCookieStore([]byte("short"))
+	assert.Error(t, err)
+	_, err = session.NewCookieStore()
+	assert.Error(t, err)
+
+	oldStore, err := session.NewCookieStore(oldKey)
+	require.NoError(t, err)
+	manager := newManager(t, oldStore)
+
+	s, cookie := roundTrip(t, manager, nil, func(s *session.Session) {
+		s.Set("user_id", "u1")
+		s.Set("level", 3)
+	})
+	require.NotNil(t, cookie)
+	assert.NotContains(t, cookie.Value, "u1", "the cookie is encrypted")
+
+	loaded, _ := roundTrip(t, manager, cookie, nil)
+	assert.Equal(t, s.ID(), loaded.ID())
+	assert.Equal(t, "u1", loaded.GetString("user_id"))
+	assert.Equal(t, float64(3), loaded.Get("level"), "values round-trip through JSON")
+
+	// A tampered cookie is ignored.
+	tampered := *cookie
+	flipped := byte('A')
+	if cookie.Value[20] == 'A' {
+		flipped = 'B'
+	}
+	tampered.Value = cookie.Value[:20] + string(flipped) + cookie.Value[21:]
+	fresh, _ := roundTrip(t, manager, &tampered, nil)
+	assert.True(t, fresh.IsNew())
+
+	// After a key rotation old cookies still open.
+	rotated, err := session.NewCookieStore(newKey, oldKey)
+	require.NoError(t, err)
+	loaded, _ = roundTrip(t, newManager(t, rotated), cookie, nil)
+	assert.Equal(t, "u1", loaded.GetString("user_id"))
+	onlyNew, err := session.NewCookieStore(newKey)
+	require.NoError(t, err)
+	loaded, _ = roundTrip(t, newManager(t, onlyNew), cookie, nil)
+	assert.True(t, loaded.IsNew())
+
+	// The sealed expiry is enforced whatever the browser sends.
+	_, err = oldStore.Load(context.Background(), mustSeal(t, oldStore, &session.Record{ID: "x", ExpiresAt: time.Now().Add(-time.Second)}))
+	assert.ErrorIs(t, err, session.ErrNotFound)
+
+	_, err = oldStore.Save(context.Background(), &session.Record{
+		ID:        "x",
+		Values:    map[string]any{"blob": strings.Repeat("a", session.MaxCookieSize)},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.ErrorIs(t, err, session.ErrCookieTooLarge)
+}
+
+func TestNewManager_Validates(t *testing.T) {
+	_, err := session.NewManager(session.Config{})
+	assert.Error(t, err)
+
+	store := session.NewMemoryStore(time.Minute)
+	defer store.Stop()
+	_, err = session.NewManager(session.Config{Store: store, IdleTimeout: time.Hour, Lifetime: time.Minute})
+	assert.Error(t, err)
+}
+
+func mustSeal(t *testing.T, store *session.CookieStore, rec *session.Record) string {
+	t.Helper()
+	token, err := store.Save(context.Background(), rec)
+	require.NoError(t, err)
+	return token
+}
@@ -0,0 +1,173 @@
+// Package certreload serves TLS from certificate files that are reloaded
+// when they change on disk, so renewed certificates take effect without a
+// restart.
+package certreload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Config represents certificate reloader configuration
+type Config struct {
+	// CertFile and KeyFile hold the PEM server certificate chain and key
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile, when set, holds the PEM CA certificates client
+	// certificates are verified against
+	ClientCAFile string
+
+	// ClientAuth is the client certificate policy. It defaults to
+	// tls.RequireAndVerifyClientCert when ClientCAFile is set.
+	ClientAuth tls.ClientAuthType
+
+	// Interval is how often the files are checked for changes. Default 1
+	// minute.
+	Interval time.Duration
+
+	// OnError is called when a changed file fails to load; the previous
+	// certificates stay in use
+	OnError func(err error)
+}
+
+// Reloader holds the current certificates and reloads them when their
+// files change.
+type Reloader struct {
+	config Config
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// New loads the certificates and starts watching their files.
+func New(config Config) (*Reloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("certreload: CertFile and KeyFile are required")
+	}
+	if config.ClientAuth == tls.NoClientCert && config.ClientCAFile != "" {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if config.ClientAuth >= tls.VerifyClientCertIfGiven && config.ClientCAFile == "" {
+		return nil, errors.New("certreload: verifying client certificates needs a ClientCAFile")
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+
+	r := &Reloader{config: config, stopCh: make(chan struct{})}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	go r.watch()
+	return r, nil
+}
+
+// TLSConfig returns a server TLS configuration that always uses the
+// current certificates. It offers HTTP/2, as http.Server does by default.
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		ClientAuth: r.config.ClientAuth,
+	}
+	config := base.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		current := base.Clone()
+		r.mu.RLock()
+		current.Certificates = []tls.Certificate{*r.cert}
+		current.ClientCAs = r.clientCAs
+		r.mu.RUnlock()
+		return current, nil
+	}
+	return config
+}
+
+// Reload loads the certificate files now. On error the previous
+// certificates stay in use.
+func (r *Reloader) Reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, name := range r.files() {
+		info, err := os.Stat(name)
+		if err != nil {
+			return fmt.Errorf("certreload: %w", err)
+		}
+		modTimes[name] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("certreload: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("certreload: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("certreload: no certificates in %s", r.config.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCAs, r.modTimes = &cert, pool, modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+// Stop stops watching the files. Safe to call multiple times.
+func (r *Reloader) Stop() {
+	r.stopOnce.Do(func() { close(r.stopCh) })
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
+
+// changed reports whether any file's modification time differs from the
+// loaded one.
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, name := range r.files() {
+		info, err := os.Stat(name)
+		if err != nil || !info.ModTime().Equal(r.modTimes[name]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) watch() {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil && r.config.OnError != nil {
+				r.config.OnError(err)
+			}
+		case <-r.stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package certreload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yshengliao/gortex/internal/testutil"
+)
+
+// writeServerCert issues a server certificate and writes it to dir.
+func writeServerCert(t *testing.T, ca *testutil.TestCA, dir, commonName string) (certFile, keyFile string) {
+	t.Helper()
+	certPEM, keyPEM := ca.IssueServer(t, commonName)
+	certFile, keyFile = filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	return certFile, keyFile
+}
+
+// servedCommonName handshakes with a server using config and returns the
+// common name of the certificate it presents.
+func servedCommonName(t *testing.T, config *tls.Config, roots *x509.CertPool) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "localhost"})
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestReloader_ReloadsChangedFiles(t *testing.T) {
+	ca := testutil.NewTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCert(t, ca, dir, "first")
+
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile, Interval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer r.Stop()
+	config := r.TLSConfig()
+	assert.Equal(t, "first", servedCommonName(t, config, roots))
+
+	// Renewed certificates are picked up without a restart. The
+	// modification time is moved explicitly so the test does not depend on
+	// the file system's timestamp resolution.
+	writeServerCert(t, ca, dir, "second")
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	assert.Eventually(t, func() bool {
+		return servedCommonName(t, config, roots) == "second"
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestReloader_KeepsCertificateOnBadReload(t *testing.T) {
+	ca := testutil.NewTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCert(t, ca, dir, "good")
+
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	defer r.Stop()
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+	assert.Error(t, r.Reload())
+	assert.Equal(t, "good", servedCommonName(t, r.TLSConfig(), roots))
+}
+
+func TestNew_Validates(t *testing.T) {
+	ca := testutil.NewTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCert(t, ca, dir, "server")
+
+	_, err := New(Config{CertFile: certFile})
+	assert.Error(t, err)
+	_, err = New(Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: tls.RequireAndVerifyClientCert})
+	assert.Error(t, err, "verification needs a client CA")
+	_, err = New(Config{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.key")})
+	assert.Error(t, err)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, ca.PEM, 0o600))
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+	require.NoError(t, err)
+	defer r.Stop()
+	assert.Equal(t, tls.RequireAndVerifyClientCert, r.config.ClientAuth, "a client CA defaults to verification")
+}
@@ -75,6 +75,7 @@ type DefaultContext struct {
 	lock       sync.RWMutex
 	logger     interface{}
 	stdContext context.Context
+	router     *gortexRouter // router serving the request, for Reverse
 }
 
 // NewDefaultContext creates a new DefaultContext
@@ -667,6 +668,7 @@ func (c *DefaultContext) Reset(r *http.Request, w http.ResponseWriter) {
 		c.params.reset()
 	}
 	c.handler = nil
+	c.router = nil
 	// Keep the store allocated but clear it
 	if c.store == nil {
 		c.store = make(Map)
@@ -697,6 +699,15 @@ func (c *DefaultContext) SetStdContext(ctx context.Context) {
 	c.request = c.request.WithContext(ctx)
 }
 
+// Reverse builds the path of a named route on the router serving the
+// request. See GortexRouter.URL.
+func (c *DefaultContext) Reverse(name string, values ...any) (string, error) {
+	if c.router == nil {
+		return "", fmt.Errorf("%w %q: context is not served by a router", ErrUnknownRoute, name)
+	}
+	return c.router.URL(name, values...)
+}
+
 // Span returns the current trace span from context
 func (c *DefaultContext) Span() interface{} {
 	// Try to get enhanced span first
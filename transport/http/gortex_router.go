@@ -3,34 +3,73 @@ package http
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // GortexRouter defines the main routing interface for Gortex framework
 type GortexRouter interface {
-	// HTTP method handlers
-	GET(path string, h HandlerFunc, m ...MiddlewareFunc)
-	POST(path string, h HandlerFunc, m ...MiddlewareFunc)
-	PUT(path string, h HandlerFunc, m ...MiddlewareFunc)
-	DELETE(path string, h HandlerFunc, m ...MiddlewareFunc)
-	PATCH(path string, h HandlerFunc, m ...MiddlewareFunc)
-	HEAD(path string, h HandlerFunc, m ...MiddlewareFunc)
-	OPTIONS(path string, h HandlerFunc, m ...MiddlewareFunc)
+	// HTTP method handlers. The returned Route can be named for reverse
+	// URL generation.
+	GET(path string, h HandlerFunc, m ...MiddlewareFunc) *Route
+	POST(path string, h HandlerFunc, m ...MiddlewareFunc) *Route
+	PUT(path string, h HandlerFunc, m ...MiddlewareFunc) *Route
+	DELETE(path string, h HandlerFunc, m ...MiddlewareFunc) *Route
+	PATCH(path string, h HandlerFunc, m ...MiddlewareFunc) *Route
+	HEAD(path string, h HandlerFunc, m ...MiddlewareFunc) *Route
+	OPTIONS(path string, h HandlerFunc, m ...MiddlewareFunc) *Route
 
 	// Route grouping
 	Group(prefix string, m ...MiddlewareFunc) GortexRouter
 
-	// Global middleware
+	// Host returns a group that only matches requests for hosts matching
+	// pattern, e.g. "api.example.com" or "{tenant}.example.com".
+	Host(pattern string, m ...MiddlewareFunc) GortexRouter
+
+	// Use appends middleware to the router (or group). It applies to every
+	// route registered on it and its groups, including routes registered
+	// before the call.
 	Use(m ...MiddlewareFunc)
 
+	// Fallback handlers. They run through the middleware chain of the
+	// router (or group) they are set on, so misses are logged, carry a
+	// request ID and are rendered by the error handler like any other
+	// route. On a group they apply to unmatched paths under its prefix.
+	SetNotFoundHandler(h HandlerFunc)
+	SetMethodNotAllowedHandler(h HandlerFunc)
+
+	// URL builds the path of the route registered under name (see
+	// Route.Name), filling its :param and *wildcard segments in order with
+	// values. It fails on an unknown name, a missing or surplus value, or a
+	// value that violates the parameter's constraint.
+	URL(name string, values ...any) (string, error)
+
+	// Routes reports every registered route with the middleware chain it
+	// runs through, outermost first.
+	Routes() []RouteInfo
+
 	// HTTP handler integration
 	ServeHTTP(w http.ResponseWriter, r *http.Request)
 }
 
 // gortexRouter implements GortexRouter interface.
 //
+// Routes are registered into per-method builder trees (routeNode) under mu.
+// Requests are matched against a compiled routeTable of radix trees that is
+// published through an atomic pointer, so the lookup path takes no lock.
+// Registration marks the table stale and the next lookup compiles a fresh
+// one; a router that is fully registered before serving compiles it once.
+//
+// Middleware is resolved when the table is compiled, not when a route is
+// registered: each router only records its own middlewares, and a route runs
+// through the chain of its owner's ancestors, then its owner, then the
+// route's own middleware. A Use call therefore reaches routes registered
+// before it, and Use also marks the table stale.
+//
 // Group() returns a new gortexRouter value that shares the parent's trees map.
 // Because the tree data is shared across the whole router family, the mutex
 // guarding it must be shared too: mu is a pointer created once in
@@ -38,24 +77,86 @@ type GortexRouter interface {
 // here would give each group handle its own independent lock over the same
 // data, which is a data race when sibling groups register concurrently.
 type gortexRouter struct {
-	trees       map[string]*routeNode
+	trees map[string]*routeNode
+	// middlewares holds the middleware passed to Group (or Host) and Use on
+	// this router only; see chain. Guarded by mu.
 	middlewares []MiddlewareFunc
 	prefix      string
 	parent      *gortexRouter
 	mu          *sync.RWMutex
+
+	// fallbacks is shared by the whole router family, like trees, and is
+	// guarded by mu.
+	fallbacks *fallbackRegistry
+
+	// names and hosts are shared by the whole router family and guarded
+	// by mu.
+	names *routeNames
+	hosts *hostRegistry
+
+	// table holds the compiled routes of the whole family, nil while stale.
+	// It is read without mu and replaced under it.
+	table *atomic.Pointer[routeTable]
+
+	// host is set on groups created by Host; trees then holds that host's
+	// route trees instead of the host-agnostic ones.
+	host *hostRoutes
+}
+
+// fallbackRegistry records the NotFound/MethodNotAllowed handlers set on the
+// root router and its groups.
+type fallbackRegistry struct {
+	entries []*fallbackEntry
+}
+
+// fallbackEntry holds the handlers set on a single router or group. The
+// owner supplies the path prefix and middleware chain.
+type fallbackEntry struct {
+	owner            *gortexRouter
+	notFound         HandlerFunc
+	methodNotAllowed HandlerFunc
 }
 
 // routeNode represents a node in the route tree
 type routeNode struct {
-	path        string
-	handler     HandlerFunc
+	path    string
+	handler HandlerFunc
+	// A node with a handler records the pattern it was registered under,
+	// the router that registered it and the route-specific middleware;
+	// the full chain is applied when the tree is compiled.
+	pattern     string
+	owner       *gortexRouter
 	middlewares []MiddlewareFunc
 	children    map[string]*routeNode
-	paramChild  *routeNode
-	wildChild   *routeNode
-	isParam     bool
-	isWild      bool
-	paramName   string
+	// paramChildren holds one child per distinct constraint, sorted by
+	// priority so typed constraints are tried before regex ones, and both
+	// before an unconstrained parameter.
+	paramChildren []*routeNode
+	wildChild     *routeNode
+	isParam       bool
+	isWild        bool
+	paramName     string
+	constraint    *paramConstraint // nil for an unconstrained parameter
+}
+
+// RouteError reports a route that cannot be registered because its pattern
+// is malformed or conflicts with an existing route. The router's
+// registration methods panic with a *RouteError, since they have no error
+// return; struct-tag registration converts it back into an error.
+type RouteError struct {
+	Method string
+	Path   string
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("gortex: cannot register %s %s: %v", e.Method, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying cause.
+func (e *RouteError) Unwrap() error {
+	return e.Err
 }
 
 // NewGortexRouter creates a new Gortex router instance
@@ -64,42 +165,46 @@ func NewGortexRouter() GortexRouter {
 		trees:       make(map[string]*routeNode),
 		middlewares: make([]MiddlewareFunc, 0),
 		mu:          &sync.RWMutex{},
+		fallbacks:   &fallbackRegistry{},
+		names:       &routeNames{},
+		hosts:       &hostRegistry{},
+		table:       &atomic.Pointer[routeTable]{},
 	}
 }
 
 // GET registers a GET route
-func (r *gortexRouter) GET(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	r.addRoute("GET", path, h, m...)
+func (r *gortexRouter) GET(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return r.addRoute("GET", path, h, m...)
 }
 
 // POST registers a POST route
-func (r *gortexRouter) POST(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	r.addRoute("POST", path, h, m...)
+func (r *gortexRouter) POST(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return r.addRoute("POST", path, h, m...)
 }
 
 // PUT registers a PUT route
-func (r *gortexRouter) PUT(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	r.addRoute("PUT", path, h, m...)
+func (r *gortexRouter) PUT(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return r.addRoute("PUT", path, h, m...)
 }
 
 // DELETE registers a DELETE route
-func (r *gortexRouter) DELETE(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	r.addRoute("DELETE", path, h, m...)
+func (r *gortexRouter) DELETE(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return r.addRoute("DELETE", path, h, m...)
 }
 
 // PATCH registers a PATCH route
-func (r *gortexRouter) PATCH(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	r.addRoute("PATCH", path, h, m...)
+func (r *gortexRouter) PATCH(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return r.addRoute("PATCH", path, h, m...)
 }
 
 // HEAD registers a HEAD route
-func (r *gortexRouter) HEAD(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	r.addRoute("HEAD", path, h, m...)
+func (r *gortexRouter) HEAD(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return r.addRoute("HEAD", path, h, m...)
 }
 
 // OPTIONS registers an OPTIONS route
-func (r *gortexRouter) OPTIONS(path string, h HandlerFunc, m ...MiddlewareFunc) {
-	r.addRoute("OPTIONS", path, h, m...)
+func (r *gortexRouter) OPTIONS(path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
+	return r.addRoute("OPTIONS", path, h, m...)
 }
 
 // Group creates a new route group.
@@ -108,32 +213,77 @@ func (r *gortexRouter) OPTIONS(path string, h HandlerFunc, m ...MiddlewareFunc)
 // that concurrent registration on sibling group handles is serialised by a
 // single lock over the shared tree data.
 //
-// The middleware slice is cloned with exact capacity rather than appended onto
-// the parent's slice: a bare append can share the parent's backing array, so
-// two sibling groups extending the same parent would clobber each other's
-// middleware. Cloning gives each group an independent chain.
+// The child records only m, copied so the caller's slice is not retained;
+// the parent's middleware is picked up through parent when the chain is
+// resolved, so a later Use on the parent reaches the group's routes too.
 func (r *gortexRouter) Group(prefix string, m ...MiddlewareFunc) GortexRouter {
-	middlewares := make([]MiddlewareFunc, 0, len(r.middlewares)+len(m))
-	middlewares = append(middlewares, r.middlewares...)
-	middlewares = append(middlewares, m...)
 	return &gortexRouter{
 		trees:       r.trees,
-		middlewares: middlewares,
+		middlewares: append([]MiddlewareFunc(nil), m...),
 		prefix:      r.prefix + prefix,
 		parent:      r,
 		mu:          r.mu,
+		fallbacks:   r.fallbacks,
+		names:       r.names,
+		hosts:       r.hosts,
+		host:        r.host,
+		table:       r.table,
 	}
 }
 
-// Use adds global middleware
+// Use appends middleware to r. Routes registered on r or its groups run
+// through it whether they were registered before or after the call.
 func (r *gortexRouter) Use(m ...MiddlewareFunc) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.middlewares = append(r.middlewares, m...)
+	r.table.Store(nil)
+}
+
+// chain returns the middleware applied to routes and fallbacks owned by r:
+// the root router's first, then each group's down to r. Callers must hold
+// mu.
+func (r *gortexRouter) chain() []MiddlewareFunc {
+	if r.parent == nil {
+		return append([]MiddlewareFunc(nil), r.middlewares...)
+	}
+	return append(r.parent.chain(), r.middlewares...)
+}
+
+// SetNotFoundHandler sets the handler run when no route matches the path
+// under any method. Without one the router returns a 404 HTTPError.
+func (r *gortexRouter) SetNotFoundHandler(h HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallbackEntry().notFound = h
+	r.table.Store(nil)
+}
+
+// SetMethodNotAllowedHandler sets the handler run when the path matches a
+// route under other methods only. The Allow header is already set on the
+// response when it runs. Without one the router returns a 405 HTTPError.
+func (r *gortexRouter) SetMethodNotAllowedHandler(h HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallbackEntry().methodNotAllowed = h
+	r.table.Store(nil)
+}
+
+// fallbackEntry returns the registry entry owned by r, creating it if
+// needed. Callers must hold mu.
+func (r *gortexRouter) fallbackEntry() *fallbackEntry {
+	for _, e := range r.fallbacks.entries {
+		if e.owner == r {
+			return e
+		}
+	}
+	e := &fallbackEntry{owner: r}
+	r.fallbacks.entries = append(r.fallbacks.entries, e)
+	return e
 }
 
 // addRoute adds a route to the router
-func (r *gortexRouter) addRoute(method, path string, h HandlerFunc, m ...MiddlewareFunc) {
+func (r *gortexRouter) addRoute(method, path string, h HandlerFunc, m ...MiddlewareFunc) *Route {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -145,27 +295,26 @@ func (r *gortexRouter) addRoute(method, path string, h HandlerFunc, m ...Middlew
 		}
 	}
 
-	// Combine all middleware: global + group + route-specific
-	allMiddlewares := make([]MiddlewareFunc, 0)
-	allMiddlewares = append(allMiddlewares, r.middlewares...)
-	allMiddlewares = append(allMiddlewares, m...)
-
-	// Create final handler by applying middleware chain
-	finalHandler := h
-	for i := len(allMiddlewares) - 1; i >= 0; i-- {
-		finalHandler = allMiddlewares[i](finalHandler)
+	node, err := r.addToTree(r.trees[method], fullPath)
+	if err != nil {
+		panic(&RouteError{Method: method, Path: fullPath, Err: err})
 	}
-
-	r.addToTree(r.trees[method], fullPath, finalHandler, allMiddlewares)
+	node.handler = h
+	node.pattern = fullPath
+	node.owner = r
+	node.middlewares = append([]MiddlewareFunc(nil), m...)
+	r.table.Store(nil)
+	return &Route{method: method, path: fullPath, router: r}
 }
 
-// addToTree adds a route to the route tree
-func (r *gortexRouter) addToTree(root *routeNode, path string, handler HandlerFunc, middlewares []MiddlewareFunc) {
+// addToTree returns the node for path in the route tree, creating it if
+// needed. Parameter segments may carry a constraint (":id<int>",
+// ":slug<[a-z0-9-]+>"); it returns an error for a malformed segment or a
+// parameter that conflicts with an existing one.
+func (r *gortexRouter) addToTree(root *routeNode, path string) (*routeNode, error) {
 	// Special case for root path
 	if path == "/" || path == "" {
-		root.handler = handler
-		root.middlewares = middlewares
-		return
+		return root, nil
 	}
 
 	segments := strings.Split(strings.Trim(path, "/"), "/")
@@ -178,16 +327,19 @@ func (r *gortexRouter) addToTree(root *routeNode, path string, handler HandlerFu
 
 		if strings.HasPrefix(segment, ":") {
 			// Parameter route
-			paramName := segment[1:]
-			if current.paramChild == nil {
-				current.paramChild = &routeNode{
-					children:  make(map[string]*routeNode),
-					isParam:   true,
-					paramName: paramName,
-				}
+			paramName, constraint, err := parseParamSegment(segment)
+			if err != nil {
+				return nil, err
 			}
-			current = current.paramChild
+			child, err := current.paramChildFor(paramName, constraint)
+			if err != nil {
+				return nil, err
+			}
+			current = child
 		} else if strings.HasPrefix(segment, "*") {
+			if strings.ContainsAny(segment, "<>") {
+				return nil, fmt.Errorf("wildcard %q cannot be constrained", segment)
+			}
 			// Wildcard route. Capture the name after '*' (empty for a bare
 			// "*"). If a wildcard already exists under this parent the first
 			// registration wins — its name is kept, so registering "/a/*x"
@@ -213,11 +365,65 @@ func (r *gortexRouter) addToTree(root *routeNode, path string, handler HandlerFu
 		}
 	}
 
-	current.handler = handler
-	current.middlewares = middlewares
+	return current, nil
 }
 
-// ServeHTTP implements the http.Handler interface
+// chainedHandler returns n's handler wrapped in its owner's chain and the
+// route-specific middleware. Callers must hold mu.
+func (n *routeNode) chainedHandler() HandlerFunc {
+	return applyMiddleware(n.handler, append(n.owner.chain(), n.middlewares...))
+}
+
+// paramChildFor returns the parameter child of n for constraint, creating it
+// if needed. Two parameters with the same constraint at the same position
+// are indistinguishable, so they must also share a name.
+func (n *routeNode) paramChildFor(name string, constraint *paramConstraint) (*routeNode, error) {
+	for _, child := range n.paramChildren {
+		if constraintPattern(child.constraint) != constraintPattern(constraint) {
+			continue
+		}
+		if child.paramName != name {
+			return nil, fmt.Errorf("parameter :%s conflicts with :%s registered at the same position with the same constraint",
+				name, child.paramName)
+		}
+		return child, nil
+	}
+
+	child := &routeNode{
+		children:   make(map[string]*routeNode),
+		isParam:    true,
+		paramName:  name,
+		constraint: constraint,
+	}
+	n.paramChildren = append(n.paramChildren, child)
+	sort.SliceStable(n.paramChildren, func(i, j int) bool {
+		return constraintPriority(n.paramChildren[i].constraint) < constraintPriority(n.paramChildren[j].constraint)
+	})
+	return child, nil
+}
+
+func constraintPattern(c *paramConstraint) string {
+	if c == nil {
+		return ""
+	}
+	return c.pattern
+}
+
+func constraintPriority(c *paramConstraint) int {
+	if c == nil {
+		return priorityUnconstrained
+	}
+	return c.priority
+}
+
+// ServeHTTP implements the http.Handler interface.
+//
+// When no route matches the request method, the other method trees are
+// searched for the same path: HEAD falls back to the GET handler with the
+// body discarded, OPTIONS is answered automatically with 204 and an Allow
+// header, and any other method is passed to the MethodNotAllowed handler
+// (405 by default) with Allow set. Only a path that matches under no method
+// at all reaches the NotFound handler (404 by default).
 func (r *gortexRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	// Use context pool for better performance
 	ctx := AcquireContext(req, w)
@@ -231,121 +437,252 @@ func (r *gortexRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		http.NotFound(w, req)
 		return
 	}
+	dc.router = r
 
-	if handler := r.findRoute(req.Method, req.URL.Path, dc.params); handler != nil {
-		if err := handler(ctx); err != nil {
-			// If the handler already wrote a response, the headers and status
-			// line are committed — writing the error now would emit a second
-			// WriteHeader and append the error body after the real one. Do
-			// nothing in that case. Otherwise write the error through the
-			// tracked writer (dc.response) so status/size accounting stays
-			// correct rather than bypassing it via the raw w.
-			if dc.response.Written() {
-				return
-			}
-			rw := dc.response
-			if he, ok := err.(*HTTPError); ok {
-				// Write the proper HTTP error response
-				rw.Header().Set("Content-Type", "application/json")
-				rw.WriteHeader(he.Code)
-				response := map[string]interface{}{
-					"message": he.Message,
-				}
-				if encErr := json.NewEncoder(rw).Encode(response); encErr != nil {
-					// Fall back to plain text if JSON encoding fails
-					http.Error(rw, he.Error(), he.Code)
-				}
-			} else {
-				// Generic error handling
-				http.Error(rw, err.Error(), http.StatusInternalServerError)
-			}
+	table := r.routeTable()
+	var host string
+	if len(table.hosts) > 0 {
+		host = requestHost(req)
+	}
+	handler := table.find(req.Method, host, req.URL.Path, dc.params)
+	if handler == nil && req.Method == http.MethodHead {
+		if handler = table.find(http.MethodGet, host, req.URL.Path, dc.params); handler != nil {
+			// Serve HEAD from the GET handler: headers and status are
+			// kept, the body is dropped.
+			dc.rw.ResponseWriter = headResponseWriter{w}
 		}
-	} else {
-		http.NotFound(w, req)
 	}
-}
+	if handler == nil {
+		r.serveMiss(ctx, dc, table)
+		return
+	}
 
-// findRoute finds a route handler for the given method and path.
-// params is written to directly, avoiding map allocation.
-func (r *gortexRouter) findRoute(method, path string, params *smartParams) HandlerFunc {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.handleError(dc, handler(ctx))
+}
 
-	root := r.trees[method]
-	if root == nil {
-		return nil
+// routeTable returns the compiled routes, compiling them first if a
+// registration made the current table stale.
+func (r *gortexRouter) routeTable() *routeTable {
+	if t := r.table.Load(); t != nil {
+		return t
 	}
 
-	params.reset()
-	handler, _ := r.searchTree(root, path, params)
-	return handler
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t := r.table.Load(); t != nil {
+		return t
+	}
+	t := &routeTable{trees: compileTrees(r.root().trees)}
+	for _, h := range r.hosts.entries {
+		t.hosts = append(t.hosts, hostTable{host: h, trees: compileTrees(h.trees)})
+	}
+	t.notFound, t.methodNotAllowed = r.compileFallbacks()
+	r.table.Store(t)
+	return t
 }
 
-// searchTree recursively searches the route tree by walking the path
-// string directly, avoiding strings.Split allocation.
-func (r *gortexRouter) searchTree(node *routeNode, path string, params *smartParams) (HandlerFunc, []MiddlewareFunc) {
-	// Trim leading slashes
-	for len(path) > 0 && path[0] == '/' {
-		path = path[1:]
+// handleError writes err as the response unless the handler already
+// committed one. It is the last resort for errors that no error-handling
+// middleware has rendered.
+func (r *gortexRouter) handleError(dc *DefaultContext, err error) {
+	if err == nil {
+		return
 	}
-
-	// No more segments — check current node
-	if path == "" {
-		if node.handler != nil {
-			return node.handler, node.middlewares
+	// If the handler already wrote a response, the headers and status
+	// line are committed — writing the error now would emit a second
+	// WriteHeader and append the error body after the real one. Do
+	// nothing in that case. Otherwise write the error through the
+	// tracked writer (dc.response) so status/size accounting stays
+	// correct rather than bypassing it via the raw w.
+	if dc.response.Written() {
+		return
+	}
+	rw := dc.response
+	if he, ok := err.(*HTTPError); ok {
+		// Write the proper HTTP error response
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(he.Code)
+		response := map[string]interface{}{
+			"message": he.Message,
+		}
+		if encErr := json.NewEncoder(rw).Encode(response); encErr != nil {
+			// Fall back to plain text if JSON encoding fails
+			http.Error(rw, he.Error(), he.Code)
 		}
-		return nil, nil
+	} else {
+		// Generic error handling
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
 	}
+}
 
-	// Extract next segment
-	segment := path
-	rest := ""
-	if idx := strings.IndexByte(path, '/'); idx >= 0 {
-		segment = path[:idx]
-		rest = path[idx+1:]
+// serveMiss answers a request whose method has no route for the path:
+// 204 with Allow for OPTIONS, the MethodNotAllowed handler (with Allow set)
+// when another method matches, and the NotFound handler otherwise. All
+// three run through the middleware chain of the owning router, so CORS
+// middleware can answer preflights.
+func (r *gortexRouter) serveMiss(ctx Context, dc *DefaultContext, table *routeTable) {
+	req := dc.request
+	host := requestHost(req)
+	allowed := table.allowedMethods(host, req.URL.Path, dc.params)
+	if len(allowed) > 0 {
+		dc.response.Header().Set("Allow", strings.Join(allowed, ", "))
 	}
 
-	if segment == "" {
-		if node.handler != nil {
-			return node.handler, node.middlewares
-		}
-		return nil, nil
+	fallbacks := table.notFound
+	if len(allowed) > 0 {
+		fallbacks = table.methodNotAllowed
+	}
+	f := findFallback(fallbacks, host, req.URL.Path, dc.params)
+	handler := f.handler
+	if len(allowed) > 0 && req.Method == http.MethodOptions {
+		handler = f.options
 	}
+	r.handleError(dc, handler(ctx))
+}
 
-	// Try static route first
-	if child, exists := node.children[segment]; exists {
-		if handler, middlewares := r.searchTree(child, rest, params); handler != nil {
-			return handler, middlewares
+// compiledFallback is a NotFound or MethodNotAllowed handler set on a
+// router or group, wrapped in its owner's middleware chain.
+type compiledFallback struct {
+	prefix  string
+	host    *hostRoutes
+	handler HandlerFunc
+	// options answers OPTIONS through the same chain; set only for
+	// MethodNotAllowed fallbacks.
+	options HandlerFunc
+}
+
+// compileFallbacks wraps the fallbacks set on the router family in their
+// owners' chains and orders them so the first one covering a request wins:
+// the longest prefix first, a host group before a host-agnostic one with
+// the same prefix, and otherwise in the order they were set. Each list ends
+// with the built-in default under the root router's chain. Callers must
+// hold mu.
+func (r *gortexRouter) compileFallbacks() (notFound, methodNotAllowed []compiledFallback) {
+	entries := append([]*fallbackEntry(nil), r.fallbacks.entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].owner, entries[j].owner
+		if len(a.prefix) != len(b.prefix) {
+			return len(a.prefix) > len(b.prefix)
+		}
+		return a.host != nil && b.host == nil
+	})
+
+	compile := func(owner *gortexRouter, h HandlerFunc, options bool) compiledFallback {
+		chain := owner.chain()
+		f := compiledFallback{prefix: owner.prefix, host: owner.host, handler: applyMiddleware(h, chain)}
+		if options {
+			f.options = applyMiddleware(autoOptionsHandler, chain)
 		}
+		return f
 	}
+	for _, e := range entries {
+		if e.notFound != nil {
+			notFound = append(notFound, compile(e.owner, e.notFound, false))
+		}
+		if e.methodNotAllowed != nil {
+			methodNotAllowed = append(methodNotAllowed, compile(e.owner, e.methodNotAllowed, true))
+		}
+	}
+	root := r.root()
+	notFound = append(notFound, compile(root, notFoundHandler, false))
+	methodNotAllowed = append(methodNotAllowed, compile(root, methodNotAllowedHandler, true))
+	return notFound, methodNotAllowed
+}
 
-	// Try parameter route
-	if node.paramChild != nil {
-		saved := params.count
-		params.set(node.paramChild.paramName, segment)
-		if handler, middlewares := r.searchTree(node.paramChild, rest, params); handler != nil {
-			return handler, middlewares
+// findFallback returns the first of fallbacks covering host and path; the
+// last, the root default, covers every request. When a host group's
+// fallback wins, its host parameters are written to params.
+func findFallback(fallbacks []compiledFallback, host, path string, params *smartParams) *compiledFallback {
+	for i := range fallbacks {
+		f := &fallbacks[i]
+		if !pathHasPrefix(path, f.prefix) {
+			continue
 		}
-		params.truncate(saved)
-	}
-
-	// Try wildcard route
-	if node.wildChild != nil {
-		saved := params.count
-		// path contains segment + rest — the full remaining path.
-		// Always expose the captured path under "*" for back-compat; when the
-		// wildcard was registered with a name (e.g. "*filepath"), also expose
-		// it under that name so c.Param("filepath") works. A bare "*" has an
-		// empty paramName and is only set under "*".
-		params.set("*", path)
-		if name := node.wildChild.paramName; name != "" {
-			params.set(name, path)
+		if f.host == nil {
+			return f
 		}
-		if handler, middlewares := r.searchTree(node.wildChild, "", params); handler != nil {
-			return handler, middlewares
+		if f.host.match(host, nil) {
+			params.reset()
+			f.host.match(host, params)
+			return f
 		}
-		params.truncate(saved)
 	}
+	return &fallbacks[len(fallbacks)-1]
+}
+
+// root returns the top-level router of r's family.
+func (r *gortexRouter) root() *gortexRouter {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
+// pathHasPrefix reports whether path lies under prefix on a segment boundary,
+// so a "/api" group covers "/api" and "/api/x" but not "/apix".
+func pathHasPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+// applyMiddleware wraps h so that middlewares[0] runs first.
+func applyMiddleware(h HandlerFunc, middlewares []MiddlewareFunc) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
 
-	return nil, nil
+// notFoundHandler is the default NotFound handler.
+func notFoundHandler(Context) error {
+	return NewHTTPError(http.StatusNotFound)
+}
+
+// autoOptionsHandler answers OPTIONS for a path with routes under other
+// methods; serveMiss has set the Allow header.
+func autoOptionsHandler(c Context) error {
+	return c.NoContent(http.StatusNoContent)
+}
+
+// methodNotAllowedHandler is the default MethodNotAllowed handler.
+func methodNotAllowedHandler(Context) error {
+	return NewHTTPError(http.StatusMethodNotAllowed)
+}
+
+// allowedMethods returns the methods that have a route matching host and
+// path, in Allow header order. HEAD is implied by GET and OPTIONS by any
+// match, since the router answers both automatically. params is used as
+// scratch space and left reset.
+func (t *routeTable) allowedMethods(host, path string, params *smartParams) []string {
+	defer params.reset()
+
+	var matched [len(routeMethods)]bool
+	found := false
+	for i, method := range routeMethods {
+		if t.find(method, host, path, params) != nil {
+			matched[i] = true
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	if matched[methodIndex(http.MethodGet)] {
+		matched[methodIndex(http.MethodHead)] = true
+	}
+	matched[methodIndex(http.MethodOptions)] = true
+
+	allowed := make([]string, 0, len(routeMethods))
+	for i, method := range routeMethods {
+		if matched[i] {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
 }
@@ -1,6 +1,7 @@
 package http_test
 
 import (
+	"fmt"
 	"net/http/httptest"
 	"testing"
 
@@ -65,3 +66,75 @@ func BenchmarkGortexRouterManyRoutes(b *testing.B) {
 		r.ServeHTTP(rec, req)
 	}
 }
+
+// largeRouteCount is the number of routes registered by
+// registerLargeRouteSet: four per resource.
+const largeRouteCount = 1200
+
+// largeRouteSetPaths are the static, param and wildcard requests served
+// from registerLargeRouteSet's table.
+var largeRouteSetPaths = map[string]string{
+	"Static":   "/api/v1/res287",
+	"Param":    "/api/v1/res287/42/items/7",
+	"Wildcard": "/files/res287/css/site.css",
+}
+
+// registerLargeRouteSet registers largeRouteCount GET routes mixing static,
+// parameter and wildcard patterns. Resource names share prefixes ("res1",
+// "res10", "res100") so the radix tree has to split and index them.
+func registerLargeRouteSet(r ghttp.GortexRouter, h ghttp.HandlerFunc) {
+	for i := 0; i < largeRouteCount/4; i++ {
+		res := fmt.Sprintf("res%d", i)
+		r.GET("/api/v1/"+res, h)
+		r.GET("/api/v1/"+res+"/:id", h)
+		r.GET("/api/v1/"+res+"/:id/items/:itemId", h)
+		r.GET("/files/"+res+"/*filepath", h)
+	}
+}
+
+func benchmarkLargeRouteSet(b *testing.B, path string) {
+	r := ghttp.NewGortexRouter()
+	registerLargeRouteSet(r, func(c ghttp.Context) error { return nil })
+
+	req := httptest.NewRequest("GET", path, nil)
+	rec := httptest.NewRecorder()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(rec, req)
+	}
+}
+
+// BenchmarkGortexRouterLargeStatic measures a static lookup among 1.2k routes.
+func BenchmarkGortexRouterLargeStatic(b *testing.B) {
+	benchmarkLargeRouteSet(b, largeRouteSetPaths["Static"])
+}
+
+// BenchmarkGortexRouterLargeParam measures a two-parameter lookup among 1.2k
+// routes.
+func BenchmarkGortexRouterLargeParam(b *testing.B) {
+	benchmarkLargeRouteSet(b, largeRouteSetPaths["Param"])
+}
+
+// BenchmarkGortexRouterLargeWildcard measures a wildcard lookup among 1.2k
+// routes.
+func BenchmarkGortexRouterLargeWildcard(b *testing.B) {
+	benchmarkLargeRouteSet(b, largeRouteSetPaths["Wildcard"])
+}
+
+// TestGortexRouterLargeRouteSetZeroAlloc holds ServeHTTP to zero
+// allocations for static, param and wildcard routes in the 1.2k-route table.
+func TestGortexRouterLargeRouteSetZeroAlloc(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	registerLargeRouteSet(r, func(c ghttp.Context) error { return nil })
+	rec := httptest.NewRecorder()
+
+	for kind, path := range largeRouteSetPaths {
+		req := httptest.NewRequest("GET", path, nil)
+		r.ServeHTTP(rec, req) // compile the route table
+		if allocs := testing.AllocsPerRun(100, func() { r.ServeHTTP(rec, req) }); allocs != 0 {
+			t.Errorf("%s: %v allocs per request, want 0", kind, allocs)
+		}
+	}
+}
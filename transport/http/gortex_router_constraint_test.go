@@ -0,0 +1,153 @@
+package http_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ghttp "github.com/yshengliao/gortex/transport/http"
+)
+
+func serveBody(r ghttp.GortexRouter, method, path string) (int, string) {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestConstrainedParams_PriorityOrder(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	// Registered in reverse priority order on purpose.
+	r.GET("/users/:name", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "name="+c.Param("name"))
+	})
+	r.GET("/users/:slug<[a-z0-9-]+>", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "slug="+c.Param("slug"))
+	})
+	r.GET("/users/:id<int>", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "id="+c.Param("id"))
+	})
+	r.GET("/users/me", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "me")
+	})
+
+	cases := map[string]string{
+		"/users/42":        "id=42",
+		"/users/-7":        "id=-7",
+		"/users/john-doe":  "slug=john-doe",
+		"/users/John_Doe":  "name=John_Doe",
+		"/users/me":        "me",
+		"/users/mee":       "slug=mee",
+		"/users/12a":       "slug=12a",
+		"/users/UPPERCASE": "name=UPPERCASE",
+	}
+	for path, want := range cases {
+		code, body := serveBody(r, http.MethodGet, path)
+		if code != http.StatusOK || body != want {
+			t.Errorf("%s: got %d %q, want %q", path, code, body, want)
+		}
+	}
+}
+
+func TestConstrainedParams_BacktrackToLowerPriority(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/items/:id<int>/details", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "details")
+	})
+	r.GET("/items/:key/history", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "history "+c.Param("key"))
+	})
+
+	if _, body := serveBody(r, http.MethodGet, "/items/5/history"); body != "history 5" {
+		t.Errorf("expected fallback to unconstrained param, got %q", body)
+	}
+	if _, body := serveBody(r, http.MethodGet, "/items/5/details"); body != "details" {
+		t.Errorf("expected int route, got %q", body)
+	}
+}
+
+func TestConstrainedParams_NoMatchIsNotFound(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/orders/:id<uuid>", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+
+	code, body := serveBody(r, http.MethodGet, "/orders/123e4567-e89b-12d3-a456-426614174000")
+	if code != http.StatusOK || body != "123e4567-e89b-12d3-a456-426614174000" {
+		t.Errorf("uuid not matched: %d %q", code, body)
+	}
+	if code, _ := serveBody(r, http.MethodGet, "/orders/not-a-uuid"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for rejected constraint, got %d", code)
+	}
+}
+
+func TestConstrainedParams_RegistrationErrors(t *testing.T) {
+	noop := func(c ghttp.Context) error { return nil }
+	cases := map[string][]string{
+		"same constraint different name": {"/a/:id<int>", "/a/:num<int>/x"},
+		"unconstrained different name":   {"/b/:id", "/b/:userId/posts"},
+		"invalid regex":                  {"/c/:id<[a-z>"},
+		"unterminated constraint":        {"/d/:id<int"},
+		"empty constraint":               {"/e/:id<>"},
+		"missing name":                   {"/f/:<int>"},
+		"constrained wildcard":           {"/g/*path<int>"},
+	}
+	for name, paths := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := ghttp.NewGortexRouter()
+			defer func() {
+				rec := recover()
+				var routeErr *ghttp.RouteError
+				err, _ := rec.(error)
+				if !errors.As(err, &routeErr) {
+					t.Fatalf("expected *RouteError panic, got %v", rec)
+				}
+				if routeErr.Path != paths[len(paths)-1] {
+					t.Errorf("error reports path %q, want %q", routeErr.Path, paths[len(paths)-1])
+				}
+			}()
+			for _, p := range paths {
+				r.GET(p, noop)
+			}
+		})
+	}
+}
+
+func TestConstrainedParams_SamePatternReused(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/p/:id<int>", func(c ghttp.Context) error { return c.String(http.StatusOK, "get") })
+	r.GET("/p/:id<int>/edit", func(c ghttp.Context) error { return c.String(http.StatusOK, "edit") })
+	r.PUT("/p/:id<int>", func(c ghttp.Context) error { return c.String(http.StatusOK, "put") })
+
+	if _, body := serveBody(r, http.MethodGet, "/p/1/edit"); body != "edit" {
+		t.Errorf("got %q", body)
+	}
+	if code, _ := serveBody(r, http.MethodDelete, "/p/1"); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for constrained path, got %d", code)
+	}
+}
+
+func TestParsePathParams(t *testing.T) {
+	params, err := ghttp.ParsePathParams("/orgs/:org/users/:id<int>/files/:ref<uuid>/*path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ghttp.PathParam{
+		{Name: "org", DataType: "string"},
+		{Name: "id", Constraint: "int", DataType: "int"},
+		{Name: "ref", Constraint: "uuid", DataType: "uuid"},
+		{Name: "path", DataType: "string", Wildcard: true},
+	}
+	if len(params) != len(want) {
+		t.Fatalf("got %d params, want %d", len(params), len(want))
+	}
+	for i := range want {
+		if params[i] != want[i] {
+			t.Errorf("param %d = %+v, want %+v", i, params[i], want[i])
+		}
+	}
+
+	if _, err := ghttp.ParsePathParams("/x/:id<(>"); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
@@ -0,0 +1,194 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ghttp "github.com/yshengliao/gortex/transport/http"
+)
+
+// tagMiddleware records that it ran by appending name to the X-Chain header.
+func tagMiddleware(name string) ghttp.MiddlewareFunc {
+	return func(next ghttp.HandlerFunc) ghttp.HandlerFunc {
+		return func(c ghttp.Context) error {
+			c.Response().Header().Add("X-Chain", name)
+			return next(c)
+		}
+	}
+}
+
+func TestServeHTTP_DefaultNotFoundRunsGlobalMiddleware(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.Use(tagMiddleware("global"))
+	r.GET("/exists", func(c ghttp.Context) error { return c.NoContent(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-Chain"); got != "global" {
+		t.Errorf("global middleware did not run for 404, X-Chain=%q", got)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON error body, got Content-Type %q", ct)
+	}
+}
+
+func TestServeHTTP_CustomNotFoundHandler(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.Use(tagMiddleware("global"))
+	r.SetNotFoundHandler(func(c ghttp.Context) error {
+		return c.String(http.StatusNotFound, "custom 404 for "+c.Request().URL.Path)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nope", nil))
+
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "custom 404 for /nope" {
+		t.Fatalf("custom handler not used: %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Chain") != "global" {
+		t.Error("custom NotFound handler must run through the global middleware")
+	}
+}
+
+func TestServeHTTP_CustomMethodNotAllowedHandlerSeesAllow(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/items", func(c ghttp.Context) error { return c.NoContent(http.StatusOK) })
+	var allow string
+	r.SetMethodNotAllowedHandler(func(c ghttp.Context) error {
+		allow = c.Response().Header().Get("Allow")
+		return c.String(http.StatusMethodNotAllowed, "nope")
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/items", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed || rec.Body.String() != "nope" {
+		t.Fatalf("custom 405 handler not used: %d %q", rec.Code, rec.Body.String())
+	}
+	if allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("Allow not set before handler ran: %q", allow)
+	}
+}
+
+func TestServeHTTP_GroupFallbackOverride(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.Use(tagMiddleware("global"))
+	r.SetNotFoundHandler(func(c ghttp.Context) error {
+		return c.String(http.StatusNotFound, "root")
+	})
+
+	api := r.Group("/api", tagMiddleware("api"))
+	api.SetNotFoundHandler(func(c ghttp.Context) error {
+		return c.String(http.StatusNotFound, "api")
+	})
+	v2 := api.Group("/v2")
+	v2.SetNotFoundHandler(func(c ghttp.Context) error {
+		return c.String(http.StatusNotFound, "v2")
+	})
+
+	cases := []struct {
+		path, body, chain string
+	}{
+		{"/other", "root", "global"},
+		{"/api", "api", "global,api"},
+		{"/api/missing", "api", "global,api"},
+		{"/apix", "root", "global"},
+		{"/api/v2/missing", "v2", "global,api"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Body.String() != tc.body {
+			t.Errorf("%s: body = %q, want %q", tc.path, rec.Body.String(), tc.body)
+		}
+		if got := strings.Join(rec.Header().Values("X-Chain"), ","); got != tc.chain {
+			t.Errorf("%s: middleware chain = %q, want %q", tc.path, got, tc.chain)
+		}
+	}
+}
+
+func TestServeHTTP_GroupNotFoundDoesNotShadowRootMethodNotAllowed(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.SetMethodNotAllowedHandler(func(c ghttp.Context) error {
+		return c.String(http.StatusMethodNotAllowed, "root 405")
+	})
+	api := r.Group("/api")
+	api.GET("/items", func(c ghttp.Context) error { return c.NoContent(http.StatusOK) })
+	api.SetNotFoundHandler(func(c ghttp.Context) error {
+		return c.String(http.StatusNotFound, "api 404")
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/items", nil))
+
+	if rec.Body.String() != "root 405" {
+		t.Errorf("expected root 405 handler, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServeHTTP_AutoOptionsRunsOwnerMiddleware(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.Use(tagMiddleware("global"))
+	r.POST("/items", func(c ghttp.Context) error { return c.NoContent(http.StatusOK) })
+	api := r.Group("/api", tagMiddleware("api"))
+	api.POST("/items", func(c ghttp.Context) error { return c.NoContent(http.StatusOK) })
+	api.SetMethodNotAllowedHandler(func(c ghttp.Context) error {
+		return c.String(http.StatusMethodNotAllowed, "api 405")
+	})
+
+	cases := []struct {
+		path, chain string
+	}{
+		{"/items", "global"},
+		{"/api/items", "global,api"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, tc.path, nil))
+		if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+			t.Errorf("%s: expected empty 204, got %d %q", tc.path, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Allow"); got != "POST, OPTIONS" {
+			t.Errorf("%s: Allow = %q", tc.path, got)
+		}
+		if got := strings.Join(rec.Header().Values("X-Chain"), ","); got != tc.chain {
+			t.Errorf("%s: middleware chain = %q, want %q", tc.path, got, tc.chain)
+		}
+	}
+}
+
+func TestServeHTTP_FallbackSetAfterServing(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	api := r.Group("/api", tagMiddleware("api"))
+	api.GET("/items", func(c ghttp.Context) error { return c.NoContent(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/missing", nil))
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected default 404, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	api.SetNotFoundHandler(func(c ghttp.Context) error {
+		return c.String(http.StatusNotFound, "api 404")
+	})
+	api.SetMethodNotAllowedHandler(func(c ghttp.Context) error {
+		return c.String(http.StatusMethodNotAllowed, "api 405")
+	})
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/missing", nil))
+	if rec.Body.String() != "api 404" || rec.Header().Get("X-Chain") != "api" {
+		t.Errorf("NotFound handler set after serving not used: %d %q", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/items", nil))
+	if rec.Body.String() != "api 405" {
+		t.Errorf("MethodNotAllowed handler set after serving not used: %d %q", rec.Code, rec.Body.String())
+	}
+}
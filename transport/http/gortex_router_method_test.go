@@ -0,0 +1,173 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ghttp "github.com/yshengliao/gortex/transport/http"
+)
+
+func newMethodTestRouter() ghttp.GortexRouter {
+	r := ghttp.NewGortexRouter()
+	ok := func(c ghttp.Context) error { return c.String(http.StatusOK, "body") }
+	r.GET("/users/:id", ok)
+	r.PUT("/users/:id", ok)
+	r.DELETE("/users/:id", ok)
+	r.POST("/users", ok)
+	return r
+}
+
+func TestServeHTTP_MethodNotAllowedSetsAllow(t *testing.T) {
+	r := newMethodTestRouter()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/42", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+	if got, want := rec.Header().Get("Allow"), "GET, HEAD, PUT, DELETE, OPTIONS"; got != want {
+		t.Errorf("Allow = %q, want %q", got, want)
+	}
+}
+
+func TestServeHTTP_UnknownPathStillNotFound(t *testing.T) {
+	r := newMethodTestRouter()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/nope", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); allow != "" {
+		t.Errorf("404 must not carry Allow, got %q", allow)
+	}
+}
+
+func TestServeHTTP_AutoOptions(t *testing.T) {
+	r := newMethodTestRouter()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/users", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if got, want := rec.Header().Get("Allow"), "POST, OPTIONS"; got != want {
+		t.Errorf("Allow = %q, want %q", got, want)
+	}
+}
+
+// A CORS-style middleware registered with Use must see automatic OPTIONS
+// replies, or preflight requests fail.
+func TestServeHTTP_AutoOptionsRunsMiddleware(t *testing.T) {
+	r := newMethodTestRouter()
+	calls := 0
+	r.Use(func(next ghttp.HandlerFunc) ghttp.HandlerFunc {
+		return func(c ghttp.Context) error {
+			calls++
+			c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+			return next(c)
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/users", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if calls != 1 {
+		t.Errorf("middleware ran %d times, want 1", calls)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got, want := rec.Header().Get("Allow"), "POST, OPTIONS"; got != want {
+		t.Errorf("Allow = %q, want %q", got, want)
+	}
+}
+
+func TestServeHTTP_ExplicitOptionsWins(t *testing.T) {
+	r := newMethodTestRouter()
+	r.OPTIONS("/users", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "custom")
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/users", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "custom" {
+		t.Fatalf("explicit OPTIONS handler not used: %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServeHTTP_HeadFallsBackToGet(t *testing.T) {
+	r := newMethodTestRouter()
+	var gotID string
+	r.GET("/files/:id", func(c ghttp.Context) error {
+		gotID = c.Param("id")
+		c.Response().Header().Set("X-File", "yes")
+		return c.String(http.StatusOK, "file contents")
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/files/7", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if gotID != "7" {
+		t.Errorf("path params not populated for HEAD fallback: %q", gotID)
+	}
+	if rec.Header().Get("X-File") != "yes" {
+		t.Error("headers from the GET handler must be kept")
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("HEAD response must have no body, got %q", rec.Body.String())
+	}
+}
+
+// A GET handler served for HEAD keeps the writer's optional interfaces.
+func TestServeHTTP_HeadFallbackFlushes(t *testing.T) {
+	r := newMethodTestRouter()
+	var flushErr error
+	r.GET("/stream", func(c ghttp.Context) error {
+		c.Response().Header().Set("Content-Type", "text/event-stream")
+		c.Response().WriteHeader(http.StatusOK)
+		if _, err := c.Response().Write([]byte("data: 1\n\n")); err != nil {
+			return err
+		}
+		flushErr = http.NewResponseController(c.Response()).Flush()
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/stream", nil))
+
+	if flushErr != nil {
+		t.Fatalf("Flush: %v", flushErr)
+	}
+	if !rec.Flushed {
+		t.Error("flush did not reach the underlying writer")
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("HEAD response must have no body, got %q", rec.Body.String())
+	}
+}
+
+func TestServeHTTP_ExplicitHeadWins(t *testing.T) {
+	r := newMethodTestRouter()
+	r.HEAD("/users/:id", func(c ghttp.Context) error {
+		return c.NoContent(http.StatusAccepted)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/users/1", nil))
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("explicit HEAD handler not used, got %d", rec.Code)
+	}
+}
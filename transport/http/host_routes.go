@@ -0,0 +1,206 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// hostRegistry records the host-specific route trees of a router family.
+// It is shared like trees and guarded by mu.
+type hostRegistry struct {
+	// entries is kept sorted so hosts with fewer parameters are tried
+	// first: "api.example.com" wins over "{tenant}.example.com".
+	entries []*hostRoutes
+}
+
+// hostRoutes holds the route trees registered for one host pattern.
+type hostRoutes struct {
+	pattern string
+	labels  []hostLabel
+	params  int
+	trees   map[string]*routeNode
+}
+
+// hostLabel is one '.'-separated label of a host pattern. name is empty for
+// a literal label.
+type hostLabel struct {
+	literal    string
+	name       string
+	constraint *paramConstraint
+}
+
+// Host returns a group whose routes only match requests for hosts matching
+// pattern. Labels written as "{name}" (or "{name<constraint>}") match a
+// single DNS label and are exposed through Context.Param:
+//
+//	tenants := r.Host("{tenant}.example.com")
+//	tenants.GET("/dashboard", h) // c.Param("tenant") == "acme" for acme.example.com
+//
+// Host routes are tried before routes registered without a host, and hosts
+// with fewer parameters are tried first. Matching ignores case and the port.
+// Host panics with a *RouteError on a malformed pattern or when called on a
+// group that is already bound to a host.
+func (r *gortexRouter) Host(pattern string, m ...MiddlewareFunc) GortexRouter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.host != nil {
+		panic(&RouteError{Method: "HOST", Path: pattern,
+			Err: fmt.Errorf("group is already bound to host %s", r.host.pattern)})
+	}
+	entry, err := r.hosts.entry(pattern)
+	if err != nil {
+		panic(&RouteError{Method: "HOST", Path: pattern, Err: err})
+	}
+	r.table.Store(nil)
+
+	return &gortexRouter{
+		trees:       entry.trees,
+		middlewares: append([]MiddlewareFunc(nil), m...),
+		prefix:      r.prefix,
+		parent:      r,
+		mu:          r.mu,
+		fallbacks:   r.fallbacks,
+		names:       r.names,
+		hosts:       r.hosts,
+		host:        entry,
+		table:       r.table,
+	}
+}
+
+// entry returns the routes for pattern, creating them if needed. Callers
+// must hold mu.
+func (hr *hostRegistry) entry(pattern string) (*hostRoutes, error) {
+	labels, err := parseHostPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	canonical := hostPatternString(labels)
+	for _, e := range hr.entries {
+		if e.pattern == canonical {
+			return e, nil
+		}
+	}
+
+	entry := &hostRoutes{
+		pattern: canonical,
+		labels:  labels,
+		trees:   make(map[string]*routeNode),
+	}
+	for _, l := range labels {
+		if l.name != "" {
+			entry.params++
+		}
+	}
+	hr.entries = append(hr.entries, entry)
+	sort.SliceStable(hr.entries, func(i, j int) bool {
+		return hr.entries[i].params < hr.entries[j].params
+	})
+	return entry, nil
+}
+
+// parseHostPattern splits pattern into labels. Literal labels are lowered
+// so they compare case-insensitively.
+func parseHostPattern(pattern string) ([]hostLabel, error) {
+	if pattern == "" {
+		return nil, errors.New("host pattern must not be empty")
+	}
+
+	var labels []hostLabel
+	seen := make(map[string]bool)
+	for _, label := range strings.Split(strings.TrimSuffix(pattern, "."), ".") {
+		switch {
+		case label == "":
+			return nil, fmt.Errorf("host pattern %q has an empty label", pattern)
+		case strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}"):
+			name, c, err := parseParamSegment(":" + label[1:len(label)-1])
+			if err != nil {
+				return nil, fmt.Errorf("host label %q: %w", label, err)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("host pattern %q declares {%s} twice", pattern, name)
+			}
+			seen[name] = true
+			labels = append(labels, hostLabel{name: name, constraint: c})
+		case strings.ContainsAny(label, "{}"):
+			return nil, fmt.Errorf("host label %q: braces must enclose the whole label", label)
+		case strings.ContainsRune(label, ':'):
+			return nil, fmt.Errorf("host pattern %q must not include a port", pattern)
+		default:
+			labels = append(labels, hostLabel{literal: strings.ToLower(label)})
+		}
+	}
+	return labels, nil
+}
+
+// hostPatternString renders labels back into a pattern, so differently
+// cased spellings of one host share an entry.
+func hostPatternString(labels []hostLabel) string {
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		switch {
+		case l.name == "":
+			parts[i] = l.literal
+		case l.constraint != nil:
+			parts[i] = "{" + l.name + "<" + l.constraint.pattern + ">}"
+		default:
+			parts[i] = "{" + l.name + "}"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// match reports whether host matches the pattern. Parameter labels are
+// written to params when it is non-nil; on a mismatch params is left as it
+// was. host is walked in place, so matching does not allocate.
+func (h *hostRoutes) match(host string, params *smartParams) bool {
+	saved := 0
+	if params != nil {
+		saved = params.count
+	}
+	for i, l := range h.labels {
+		label := host
+		if i < len(h.labels)-1 {
+			idx := strings.IndexByte(host, '.')
+			if idx < 0 {
+				return h.mismatch(params, saved)
+			}
+			label, host = host[:idx], host[idx+1:]
+		} else if strings.IndexByte(label, '.') >= 0 {
+			return h.mismatch(params, saved)
+		}
+
+		if l.name == "" {
+			if !strings.EqualFold(label, l.literal) {
+				return h.mismatch(params, saved)
+			}
+			continue
+		}
+		if label == "" || l.constraint != nil && !l.constraint.match(label) {
+			return h.mismatch(params, saved)
+		}
+		if params != nil {
+			params.set(l.name, label)
+		}
+	}
+	return true
+}
+
+func (h *hostRoutes) mismatch(params *smartParams, saved int) bool {
+	if params != nil {
+		params.truncate(saved)
+	}
+	return false
+}
+
+// requestHost returns the request's host without port or trailing dot.
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && strings.IndexByte(host[i:], ']') < 0 {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,154 @@
+package http_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ghttp "github.com/yshengliao/gortex/transport/http"
+)
+
+func serveHost(r ghttp.GortexRouter, method, host, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Host = host
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func newHostRouter() ghttp.GortexRouter {
+	r := ghttp.NewGortexRouter()
+	r.GET("/", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "default")
+	})
+	r.GET("/health", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	tenants := r.Host("{tenant}.example.com")
+	tenants.GET("/", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "tenant "+c.Param("tenant"))
+	})
+	tenants.GET("/users/:id", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, c.Param("tenant")+" user "+c.Param("id"))
+	})
+
+	api := r.Host("api.example.com")
+	api.GET("/", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "api")
+	})
+	api.Group("/v1").POST("/orders", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "order")
+	})
+	return r
+}
+
+func TestHostRouting_Match(t *testing.T) {
+	r := newHostRouter()
+
+	cases := []struct {
+		method, host, path string
+		want               string
+	}{
+		{"GET", "acme.example.com", "/", "tenant acme"},
+		{"GET", "ACME.Example.COM:8080", "/", "tenant ACME"},
+		{"GET", "acme.example.com.", "/", "tenant acme"},
+		{"GET", "acme.example.com", "/users/7", "acme user 7"},
+		{"GET", "api.example.com", "/", "api"},
+		{"POST", "api.example.com", "/v1/orders", "order"},
+		{"GET", "example.com", "/", "default"},
+		{"GET", "a.b.example.com", "/", "default"},
+		{"GET", "localhost", "/", "default"},
+		// Host-agnostic routes serve every host.
+		{"GET", "acme.example.com", "/health", "ok"},
+	}
+	for _, tc := range cases {
+		rec := serveHost(r, tc.method, tc.host, tc.path)
+		if rec.Code != http.StatusOK || rec.Body.String() != tc.want {
+			t.Errorf("%s %s%s: got %d %q, want %q", tc.method, tc.host, tc.path, rec.Code, rec.Body.String(), tc.want)
+		}
+	}
+}
+
+func TestHostRouting_NotFoundAndAllow(t *testing.T) {
+	r := newHostRouter()
+
+	if rec := serveHost(r, "GET", "example.com", "/users/7"); rec.Code != http.StatusNotFound {
+		t.Errorf("host route leaked to other hosts: got %d", rec.Code)
+	}
+
+	rec := serveHost(r, "DELETE", "api.example.com", "/v1/orders")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Allow"); got != "POST, OPTIONS" {
+		t.Errorf("Allow = %q, want %q", got, "POST, OPTIONS")
+	}
+}
+
+func TestHostRouting_ConstrainedLabel(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.Host("{n<int>}.db.local").GET("/", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, "shard "+c.Param("n"))
+	})
+
+	if rec := serveHost(r, "GET", "12.db.local", "/"); rec.Body.String() != "shard 12" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := serveHost(r, "GET", "x.db.local", "/"); rec.Code != http.StatusNotFound {
+		t.Errorf("constraint not applied: got %d", rec.Code)
+	}
+}
+
+func TestHostRouting_FallbackSeesHostParams(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	tenants := r.Host("{tenant}.example.com")
+	tenants.GET("/", func(c ghttp.Context) error { return c.NoContent(http.StatusOK) })
+	tenants.SetNotFoundHandler(func(c ghttp.Context) error {
+		return c.String(http.StatusNotFound, "no such page for "+c.Param("tenant"))
+	})
+
+	rec := serveHost(r, "GET", "acme.example.com", "/missing")
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "no such page for acme" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+	// Other hosts keep the default NotFound handler.
+	rec = serveHost(r, "GET", "example.org", "/missing")
+	if strings.Contains(rec.Body.String(), "no such page") {
+		t.Errorf("host fallback applied to another host: %q", rec.Body.String())
+	}
+}
+
+func TestHostRouting_InvalidPatternsPanic(t *testing.T) {
+	for _, pattern := range []string{
+		"",
+		"api..example.com",
+		"{}.example.com",
+		"x{tenant}.example.com",
+		"api.example.com:8080",
+		"{a}.{a}.example.com",
+	} {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				var routeErr *ghttp.RouteError
+				if !errors.As(err, &routeErr) {
+					t.Errorf("Host(%q): expected *RouteError panic, got %v", pattern, err)
+				}
+			}()
+			ghttp.NewGortexRouter().Host(pattern)
+		}()
+	}
+}
+
+func TestHostRouting_NestedHostPanics(t *testing.T) {
+	defer func() {
+		err, _ := recover().(error)
+		if err == nil || !strings.Contains(err.Error(), "already bound to host api.example.com") {
+			t.Errorf("unexpected panic: %v", err)
+		}
+	}()
+	ghttp.NewGortexRouter().Host("api.example.com").Host("admin.example.com")
+}
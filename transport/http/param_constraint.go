@@ -0,0 +1,197 @@
+package http
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Constraint priorities. Lower values are tried first when several param
+// children share a tree node, so "/users/:id<int>" is matched before
+// "/users/:slug<[a-z-]+>", which is matched before "/users/:name".
+const (
+	priorityTyped = iota
+	priorityRegex
+	priorityUnconstrained
+)
+
+// paramConstraint restricts the values a path parameter segment accepts.
+type paramConstraint struct {
+	// pattern is the constraint as written between '<' and '>'.
+	pattern string
+	// dataType is the documented type of matching values.
+	dataType string
+	priority int
+	match    func(string) bool
+}
+
+// typedConstraints are the named constraints usable as ":name<type>".
+// Anything else between the angle brackets is compiled as a regular
+// expression that must match the whole segment.
+var typedConstraints = map[string]*paramConstraint{
+	"int":   {pattern: "int", dataType: "int", priority: priorityTyped, match: isInt},
+	"uint":  {pattern: "uint", dataType: "uint", priority: priorityTyped, match: isUint},
+	"float": {pattern: "float", dataType: "float", priority: priorityTyped, match: isFloat},
+	"uuid":  {pattern: "uuid", dataType: "uuid", priority: priorityTyped, match: isUUID},
+	"alpha": {pattern: "alpha", dataType: "string", priority: priorityTyped, match: isAlpha},
+	"alnum": {pattern: "alnum", dataType: "string", priority: priorityTyped, match: isAlnum},
+}
+
+// PathParam describes a parameter declared in a route pattern.
+type PathParam struct {
+	// Name is the parameter name as passed to Context.Param ("*" for a bare
+	// wildcard).
+	Name string
+	// Constraint is the text between '<' and '>', empty if unconstrained.
+	Constraint string
+	// DataType is the type documented for the parameter: "int", "uint",
+	// "float", "uuid" or "string".
+	DataType string
+	// Wildcard reports whether the parameter captures the rest of the path.
+	Wildcard bool
+}
+
+// ParsePathParams returns the parameters declared in pattern, in order. It
+// reports the same syntax errors route registration would.
+func ParsePathParams(pattern string) ([]PathParam, error) {
+	var params []PathParam
+	for _, segment := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			name, c, err := parseParamSegment(segment)
+			if err != nil {
+				return nil, err
+			}
+			p := PathParam{Name: name, DataType: "string"}
+			if c != nil {
+				p.Constraint = c.pattern
+				p.DataType = c.dataType
+			}
+			params = append(params, p)
+		case strings.HasPrefix(segment, "*"):
+			if strings.ContainsAny(segment, "<>") {
+				return nil, fmt.Errorf("wildcard %q cannot be constrained", segment)
+			}
+			name := segment[1:]
+			if name == "" {
+				name = "*"
+			}
+			params = append(params, PathParam{Name: name, DataType: "string", Wildcard: true})
+		}
+	}
+	return params, nil
+}
+
+// parseParamSegment splits ":name" or ":name<constraint>" into its name and
+// constraint. The constraint is nil when none is given.
+func parseParamSegment(segment string) (string, *paramConstraint, error) {
+	body := segment[1:]
+	open := strings.IndexByte(body, '<')
+	if open < 0 {
+		if body == "" {
+			return "", nil, fmt.Errorf("parameter %q has no name", segment)
+		}
+		if strings.ContainsRune(body, '>') {
+			return "", nil, fmt.Errorf("parameter %q has '>' without '<'", segment)
+		}
+		return body, nil, nil
+	}
+
+	name := body[:open]
+	if name == "" {
+		return "", nil, fmt.Errorf("parameter %q has no name", segment)
+	}
+	if !strings.HasSuffix(body, ">") {
+		return "", nil, fmt.Errorf("parameter %q: constraint must end with '>'", segment)
+	}
+	pattern := body[open+1 : len(body)-1]
+	if pattern == "" {
+		return "", nil, fmt.Errorf("parameter %q has an empty constraint", segment)
+	}
+
+	if c, ok := typedConstraints[pattern]; ok {
+		return name, c, nil
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return "", nil, fmt.Errorf("parameter %q: invalid constraint: %w", segment, err)
+	}
+	return name, &paramConstraint{
+		pattern:  pattern,
+		dataType: "string",
+		priority: priorityRegex,
+		match:    re.MatchString,
+	}, nil
+}
+
+func isUint(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isInt(s string) bool {
+	if len(s) > 1 && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	return isUint(s)
+}
+
+func isFloat(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// isUUID accepts the canonical 8-4-4-4-12 hex form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			c := s[i]
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isAlpha(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
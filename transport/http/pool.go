@@ -34,6 +34,7 @@ func ReleaseContext(c Context) {
 			dc.params.reset()
 		}
 		dc.handler = nil
+		dc.router = nil
 		dc.logger = nil
 		dc.stdContext = nil
 
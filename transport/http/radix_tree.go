@@ -0,0 +1,308 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// routeMethods lists the methods the router registers, in Allow header
+// order. A method's index is its slot in routeTable.trees.
+var routeMethods = [...]string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// methodIndex returns method's slot in routeMethods, or -1.
+func methodIndex(method string) int {
+	switch method {
+	case http.MethodGet:
+		return 0
+	case http.MethodHead:
+		return 1
+	case http.MethodPost:
+		return 2
+	case http.MethodPut:
+		return 3
+	case http.MethodPatch:
+		return 4
+	case http.MethodDelete:
+		return 5
+	case http.MethodOptions:
+		return 6
+	}
+	return -1
+}
+
+// methodTrees holds one compiled tree per routeMethods slot.
+type methodTrees [len(routeMethods)]*radixNode
+
+// routeTable is an immutable, compiled snapshot of every route in a router
+// family. Lookups read it without locking; registration marks it stale and
+// the next lookup compiles a replacement (see gortexRouter.table).
+type routeTable struct {
+	// hosts follows hostRegistry.entries order.
+	hosts []hostTable
+	trees methodTrees
+	// notFound and methodNotAllowed are the fallbacks, most specific
+	// first, each ending with the root router's default.
+	notFound         []compiledFallback
+	methodNotAllowed []compiledFallback
+}
+
+// hostTable is the compiled form of a hostRoutes entry.
+type hostTable struct {
+	host  *hostRoutes
+	trees methodTrees
+}
+
+// radixNode is a node of a compressed radix tree over route paths with
+// leading slashes removed and repeated slashes collapsed ("users/:id/posts").
+// Static text is shared between routes byte by byte; parameters and
+// wildcards hang off nodes that end on a segment boundary.
+type radixNode struct {
+	// label is the static text consumed on entering the node. A '/' in it
+	// matches one or more slashes in the request path.
+	label string
+
+	// indices holds the first byte of each static child's label, so the
+	// child to descend into is found without a map lookup.
+	indices  string
+	children []*radixNode
+
+	handler HandlerFunc
+
+	// params are tried in constraint priority order when no static child
+	// matches; wild is tried last.
+	params []*radixParam
+	wild   *radixWild
+}
+
+// radixParam matches one path segment and continues in next, whose root has
+// an empty label.
+type radixParam struct {
+	name       string
+	constraint *paramConstraint
+	next       *radixNode
+}
+
+// radixWild captures the rest of the path.
+type radixWild struct {
+	name    string
+	handler HandlerFunc
+}
+
+// compileTrees compiles the builder trees of one host (or the host-agnostic
+// routes) into radix trees, wrapping each handler in its middleware chain.
+// Callers must hold mu.
+func compileTrees(trees map[string]*routeNode) methodTrees {
+	var compiled methodTrees
+	for method, root := range trees {
+		if i := methodIndex(method); i >= 0 {
+			compiled[i] = compileNode(root)
+		}
+	}
+	return compiled
+}
+
+// compileNode compiles the builder subtree rooted at n, which sits on a
+// segment boundary.
+func compileNode(n *routeNode) *radixNode {
+	root := &radixNode{}
+	root.addBuilder(n, "")
+	return root
+}
+
+// addBuilder inserts builder node n, reached through the static text key,
+// and its static descendants into the radix tree rooted at root.
+func (root *radixNode) addBuilder(n *routeNode, key string) {
+	if n.handler != nil {
+		root.insert(key).handler = n.chainedHandler()
+	}
+
+	if len(n.paramChildren) > 0 || n.wildChild != nil {
+		boundary := key
+		if key != "" {
+			boundary += "/"
+		}
+		b := root.insert(boundary)
+		for _, p := range n.paramChildren {
+			b.params = append(b.params, &radixParam{
+				name:       p.paramName,
+				constraint: p.constraint,
+				next:       compileNode(p),
+			})
+		}
+		// Matching stops at a wildcard, so anything registered below it
+		// is unreachable and not compiled.
+		if w := n.wildChild; w != nil && w.handler != nil {
+			b.wild = &radixWild{name: w.paramName, handler: w.chainedHandler()}
+		}
+	}
+
+	segments := make([]string, 0, len(n.children))
+	for segment := range n.children {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+	for _, segment := range segments {
+		childKey := segment
+		if key != "" {
+			childKey = key + "/" + segment
+		}
+		root.addBuilder(n.children[segment], childKey)
+	}
+}
+
+// insert returns the node reached by key below n, splitting labels and
+// creating nodes as needed.
+func (n *radixNode) insert(key string) *radixNode {
+	for key != "" {
+		i := strings.IndexByte(n.indices, key[0])
+		if i < 0 {
+			child := &radixNode{label: key}
+			n.indices += key[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+
+		child := n.children[i]
+		l := commonPrefixLen(child.label, key)
+		if l < len(child.label) {
+			split := &radixNode{
+				label:    child.label[:l],
+				indices:  child.label[l : l+1],
+				children: []*radixNode{child},
+			}
+			child.label = child.label[l:]
+			n.children[i] = split
+			child = split
+		}
+		n, key = child, key[l:]
+	}
+	return n
+}
+
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// find returns the handler for method on host and path, trying matching
+// hosts before host-agnostic routes. Parameters are written to params.
+func (t *routeTable) find(method, host, path string, params *smartParams) HandlerFunc {
+	i := methodIndex(method)
+	if i < 0 {
+		return nil
+	}
+	path = trimSlashes(path)
+
+	params.reset()
+	for _, h := range t.hosts {
+		root := h.trees[i]
+		if root == nil || !h.host.match(host, params) {
+			continue
+		}
+		if handler := root.match(path, params); handler != nil {
+			return handler
+		}
+		params.reset()
+	}
+
+	if root := t.trees[i]; root != nil {
+		return root.match(path, params)
+	}
+	return nil
+}
+
+// match resolves path, the request path left after n's label, below n.
+// Static children are tried first, then parameters in priority order, then
+// the wildcard; parameters set by an abandoned branch are dropped.
+func (n *radixNode) match(path string, params *smartParams) HandlerFunc {
+	if onlySlashes(path) {
+		return n.handler
+	}
+
+	if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+		child := n.children[i]
+		if rest, ok := consumeLabel(child.label, path); ok {
+			if handler := child.match(rest, params); handler != nil {
+				return handler
+			}
+		}
+	}
+
+	if len(n.params) == 0 && n.wild == nil {
+		return nil
+	}
+
+	// n ends on a segment boundary, so path starts with a segment.
+	segment, rest := path, ""
+	if idx := strings.IndexByte(path, '/'); idx >= 0 {
+		segment, rest = path[:idx], path[idx:]
+	}
+	for _, p := range n.params {
+		if p.constraint != nil && !p.constraint.match(segment) {
+			continue
+		}
+		saved := params.count
+		params.set(p.name, segment)
+		if handler := p.next.match(trimSlashes(rest), params); handler != nil {
+			return handler
+		}
+		params.truncate(saved)
+	}
+
+	if n.wild != nil {
+		// Always expose the captured path under "*" for back-compat; when
+		// the wildcard was registered with a name (e.g. "*filepath"), also
+		// expose it under that name so c.Param("filepath") works.
+		params.set("*", path)
+		if n.wild.name != "" {
+			params.set(n.wild.name, path)
+		}
+		return n.wild.handler
+	}
+	return nil
+}
+
+// consumeLabel matches label against the start of path and returns the
+// rest. A '/' in label consumes every consecutive slash in path.
+func consumeLabel(label, path string) (string, bool) {
+	for i := 0; i < len(label); i++ {
+		if path == "" {
+			return "", false
+		}
+		if label[i] == '/' {
+			if path[0] != '/' {
+				return "", false
+			}
+			path = trimSlashes(path)
+			continue
+		}
+		if path[0] != label[i] {
+			return "", false
+		}
+		path = path[1:]
+	}
+	return path, true
+}
+
+func trimSlashes(path string) string {
+	for len(path) > 0 && path[0] == '/' {
+		path = path[1:]
+	}
+	return path
+}
+
+func onlySlashes(path string) bool {
+	for i := 0; i < len(path); i++ {
+		if path[i] != '/' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package http_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	ghttp "github.com/yshengliao/gortex/transport/http"
+)
+
+// echoRoute answers with the matched pattern and the parameters it reads.
+func echoRoute(pattern string, params ...string) ghttp.HandlerFunc {
+	return func(c ghttp.Context) error {
+		body := pattern
+		for _, p := range params {
+			body += " " + p + "=" + c.Param(p)
+		}
+		return c.String(http.StatusOK, body)
+	}
+}
+
+func TestRadixTree_LargeRouteSet(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	for i := 0; i < largeRouteCount/4; i++ {
+		res := fmt.Sprintf("res%d", i)
+		r.GET("/api/v1/"+res, echoRoute(res))
+		r.GET("/api/v1/"+res+"/:id", echoRoute(res+"/:id", "id"))
+		r.GET("/api/v1/"+res+"/:id/items/:itemId", echoRoute(res+"/:id/items/:itemId", "id", "itemId"))
+		r.GET("/files/"+res+"/*filepath", echoRoute(res+"/*filepath", "filepath"))
+	}
+
+	for i := 0; i < largeRouteCount/4; i++ {
+		res := fmt.Sprintf("res%d", i)
+		cases := map[string]string{
+			"/api/v1/" + res:                    res,
+			"/api/v1/" + res + "/7":             res + "/:id id=7",
+			"/api/v1/" + res + "/7/items/x":     res + "/:id/items/:itemId id=7 itemId=x",
+			"/files/" + res + "/css/a.css":      res + "/*filepath filepath=css/a.css",
+			"//api//v1/" + res + "//7/":         res + "/:id id=7",
+			"/api/v1/" + res + "/7/items/x/":    res + "/:id/items/:itemId id=7 itemId=x",
+			"/files/" + res + "/deep/er/path/f": res + "/*filepath filepath=deep/er/path/f",
+		}
+		for path, want := range cases {
+			if code, body := serveBody(r, http.MethodGet, path); code != http.StatusOK || body != want {
+				t.Fatalf("%s: got %d %q, want %q", path, code, body, want)
+			}
+		}
+	}
+
+	for _, path := range []string{
+		"/api/v1/res",                  // prefix of every resource
+		"/api/v1/res1x",                // shares "res1" but is a different segment
+		"/api/v1/res300",               // beyond the registered range
+		"/api/v1/res1/7/items",         // missing trailing parameter
+		"/api/v1/res1/7/items/x/extra", // too deep
+		"/files/res1",                  // a wildcard needs a non-empty rest
+	} {
+		if code, _ := serveBody(r, http.MethodGet, path); code != http.StatusNotFound {
+			t.Errorf("%s: got %d, want 404", path, code)
+		}
+	}
+}
+
+func TestRadixTree_SharedPrefixBacktracking(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/user", echoRoute("user"))
+	r.GET("/users/new", echoRoute("users/new"))
+	r.GET("/users/:id", echoRoute("users/:id", "id"))
+	r.GET("/user-settings", echoRoute("user-settings"))
+	r.GET("/:page", echoRoute(":page", "page"))
+	r.GET("/users/:id/files/*path", echoRoute("users/:id/files/*path", "id", "path"))
+	r.GET("/users/new/files/readme", echoRoute("users/new/files/readme"))
+
+	cases := map[string]string{
+		"/user":                   "user",
+		"/users":                  ":page page=users",
+		"/userz":                  ":page page=userz",
+		"/user-settings":          "user-settings",
+		"/user-set":               ":page page=user-set",
+		"/users/new":              "users/new",
+		"/users/newer":            "users/:id id=newer",
+		"/users/new/files/readme": "users/new/files/readme",
+		"/users/new/files/other":  "users/:id/files/*path id=new path=other",
+		"/users/7/files/a/b":      "users/:id/files/*path id=7 path=a/b",
+	}
+	for path, want := range cases {
+		if code, body := serveBody(r, http.MethodGet, path); code != http.StatusOK || body != want {
+			t.Errorf("%s: got %d %q, want %q", path, code, body, want)
+		}
+	}
+}
+
+// Routes registered after the router has served requests must be visible
+// to the next request.
+func TestRadixTree_RegistrationAfterServing(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/a", echoRoute("a"))
+	if code, _ := serveBody(r, http.MethodGet, "/b"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 before registration, got %d", code)
+	}
+
+	r.Group("/g").GET("/b", echoRoute("g/b"))
+	r.Host("api.example.com").GET("/b", echoRoute("api b"))
+
+	if _, body := serveBody(r, http.MethodGet, "/g/b"); body != "g/b" {
+		t.Errorf("group route not visible after registration: %q", body)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/b", nil)
+	req.Host = "api.example.com"
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Body.String() != "api b" {
+		t.Errorf("host route not visible after registration: %q", rec.Body.String())
+	}
+}
+
+// Lookups run without the registration lock; this must stay clean under
+// -race while routes are added concurrently.
+func TestRadixTree_ConcurrentLookupAndRegistration(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/seed/:id", echoRoute("seed"))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if code, _ := serveBody(r, http.MethodGet, "/seed/1"); code != http.StatusOK {
+					t.Errorf("seed route lost: %d", code)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				r.GET(fmt.Sprintf("/r%d/%d", g, i), echoRoute("r"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if code, _ := serveBody(r, http.MethodGet, "/r3/49"); code != http.StatusOK {
+		t.Errorf("route registered concurrently not found: %d", code)
+	}
+}
@@ -12,6 +12,7 @@ type responseWriter struct {
 	status  int
 	size    int64
 	written bool
+	before  []func()
 }
 
 // NewResponseWriter creates a new ResponseWriter
@@ -37,11 +38,24 @@ func (w *responseWriter) Written() bool {
 	return w.written
 }
 
+// Before registers fn to run just before the response header is written,
+// while headers such as Set-Cookie can still be changed. Hooks run in
+// reverse order of registration.
+func (w *responseWriter) Before(fn func()) {
+	w.before = append(w.before, fn)
+}
+
 // WriteHeader writes the status code
 func (w *responseWriter) WriteHeader(code int) {
 	if w.written {
 		return
 	}
+	if hooks := w.before; len(hooks) > 0 {
+		w.before = nil
+		for i := len(hooks) - 1; i >= 0; i-- {
+			hooks[i]()
+		}
+	}
 	w.status = code
 	w.written = true
 	w.ResponseWriter.WriteHeader(code)
@@ -59,6 +73,10 @@ func (w *responseWriter) Write(b []byte) (int, error) {
 
 // Flush implements http.Flusher
 func (w *responseWriter) Flush() {
+	if !w.written {
+		// Flushing sends the header, so the Before hooks must run first.
+		w.WriteHeader(http.StatusOK)
+	}
 	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
 		flusher.Flush()
 	}
@@ -87,4 +105,36 @@ func (w *responseWriter) reset(writer http.ResponseWriter) {
 	w.status = http.StatusOK
 	w.size = 0
 	w.written = false
+	w.before = nil
+}
+
+// headResponseWriter discards the response body so a GET handler can answer
+// a HEAD request. Headers and status pass through unchanged.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Write reports success without writing anything.
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Flush implements http.Flusher, sending the headers.
+func (w headResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker
+func (w headResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
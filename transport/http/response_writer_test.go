@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseWriter_BeforeHooks(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := &responseWriter{ResponseWriter: rec, status: http.StatusOK}
+
+	var order []string
+	rw.Before(func() { order = append(order, "first"); rw.Header().Set("X-Hook", "ran") })
+	rw.Before(func() { order = append(order, "second") })
+
+	_, _ = rw.Write([]byte("body"))
+	rw.WriteHeader(http.StatusTeapot)
+
+	assert.Equal(t, []string{"second", "first"}, order, "hooks run once, last registered first")
+	assert.Equal(t, "ran", rec.Header().Get("X-Hook"), "hooks can still change headers")
+
+	rw.reset(httptest.NewRecorder())
+	assert.Nil(t, rw.before, "reset drops hooks of the previous request")
+}
+
+func TestResponseWriter_FlushRunsBeforeHooks(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := &responseWriter{ResponseWriter: rec, status: http.StatusOK}
+	ran := false
+	rw.Before(func() { ran = true })
+
+	rw.Flush()
+
+	assert.True(t, ran)
+	assert.True(t, rw.Written())
+	assert.True(t, rec.Flushed)
+}
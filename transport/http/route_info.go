@@ -0,0 +1,138 @@
+package http
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// RouteInfo describes a registered route as it is served.
+type RouteInfo struct {
+	Method string `json:"method"`
+	// Path is the full pattern, including any group prefix.
+	Path string `json:"path"`
+	// Host is the host pattern of a route registered through Host.
+	Host string `json:"host,omitempty"`
+	// Name is the name given with Route.Name, if any.
+	Name string `json:"name,omitempty"`
+	// Middlewares names the route's middleware chain, outermost first:
+	// the root router's, each enclosing group's, then the route's own.
+	Middlewares []string `json:"middlewares"`
+}
+
+// Routes returns every route registered in the router family, ordered by
+// host, path and method. The chains are resolved the same way they are when
+// serving, so middleware added with Use after a route was registered is
+// listed too. Middleware names come from MiddlewareName; a route name is
+// reported only for the methods it was given under.
+func (r *gortexRouter) Routes() []RouteInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Names keyed by method and pattern.
+	names := make(map[string]string)
+	for name, route := range r.names.routes {
+		for _, method := range route.methods {
+			key := method + " " + route.pattern
+			if existing, ok := names[key]; !ok || name < existing {
+				names[key] = name
+			}
+		}
+	}
+
+	var routes []RouteInfo
+	collect := func(host string, trees map[string]*routeNode) {
+		for method, root := range trees {
+			root.walk(func(n *routeNode) {
+				chain := append(n.owner.chain(), n.middlewares...)
+				info := RouteInfo{
+					Method:      method,
+					Path:        n.pattern,
+					Host:        host,
+					Name:        names[method+" "+n.pattern],
+					Middlewares: make([]string, 0, len(chain)),
+				}
+				for _, mw := range chain {
+					info.Middlewares = append(info.Middlewares, MiddlewareName(mw))
+				}
+				routes = append(routes, info)
+			})
+		}
+	}
+	collect("", r.root().trees)
+	for _, h := range r.hosts.entries {
+		collect(h.pattern, h.trees)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return methodIndex(a.Method) < methodIndex(b.Method)
+	})
+	return routes
+}
+
+// walk calls fn for every node below n, n included, that has a handler.
+func (n *routeNode) walk(fn func(*routeNode)) {
+	if n.handler != nil {
+		fn(n)
+	}
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+	for _, child := range n.paramChildren {
+		child.walk(fn)
+	}
+	if n.wildChild != nil {
+		n.wildChild.walk(fn)
+	}
+}
+
+// MiddlewareName returns a readable name for mw derived from the function
+// that built it: the package path, the closure suffix (".func1") and a
+// "WithConfig" suffix are dropped, so the value returned by
+// middleware.JWTAuth(svc) is named "middleware.JWTAuth". Middleware that is
+// a method value is named after the method ("ratelimit.(*Limiter).Handle").
+func MiddlewareName(mw MiddlewareFunc) string {
+	if mw == nil {
+		return ""
+	}
+	fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, "-fm")
+	for {
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 || !isClosureSuffix(name[i+1:]) {
+			break
+		}
+		name = name[:i]
+	}
+	return strings.TrimSuffix(name, "WithConfig")
+}
+
+// isClosureSuffix reports whether s is a compiler-generated closure name
+// component: "func1" or a bare counter such as "2".
+func isClosureSuffix(s string) bool {
+	s = strings.TrimPrefix(s, "func")
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
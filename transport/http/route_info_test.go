@@ -0,0 +1,151 @@
+package http_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	ghttp "github.com/yshengliao/gortex/transport/http"
+)
+
+// tagWithConfig returns middleware that appends tag to the X-Chain header.
+func tagWithConfig(tag string) ghttp.MiddlewareFunc {
+	return func(next ghttp.HandlerFunc) ghttp.HandlerFunc {
+		return func(c ghttp.Context) error {
+			c.Response().Header().Add("X-Chain", tag)
+			return next(c)
+		}
+	}
+}
+
+func audit(next ghttp.HandlerFunc) ghttp.HandlerFunc {
+	return next
+}
+
+func chainOf(t *testing.T, r ghttp.GortexRouter, path string) string {
+	t.Helper()
+	rec := serveHost(r, http.MethodGet, "example.com", path)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: got %d", path, rec.Code)
+	}
+	return strings.Join(rec.Header().Values("X-Chain"), ",")
+}
+
+// Use must reach routes registered before it, on the router and on groups,
+// and the chain order must not depend on the registration order.
+func TestUse_AppliesToRoutesRegisteredEarlier(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	api := r.Group("/api", tagWithConfig("api"))
+	api.GET("/users", okHandler, tagWithConfig("route"))
+	r.GET("/health", okHandler)
+
+	r.Use(tagWithConfig("global"))
+	api.Use(tagWithConfig("api-late"))
+
+	if got := chainOf(t, r, "/api/users"); got != "global,api,api-late,route" {
+		t.Errorf("group route chain = %q", got)
+	}
+	if got := chainOf(t, r, "/health"); got != "global" {
+		t.Errorf("root route chain = %q", got)
+	}
+}
+
+func TestUse_AfterServingRecompiles(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/a", okHandler)
+	if got := chainOf(t, r, "/a"); got != "" {
+		t.Fatalf("unexpected chain before Use: %q", got)
+	}
+
+	r.Use(tagWithConfig("late"))
+	if got := chainOf(t, r, "/a"); got != "late" {
+		t.Errorf("chain after Use = %q, want late", got)
+	}
+}
+
+func TestUse_ReachesLateFallbacks(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	api := r.Group("/api")
+	api.GET("/x", okHandler)
+	api.SetNotFoundHandler(func(c ghttp.Context) error { return c.NoContent(http.StatusTeapot) })
+	api.Use(tagWithConfig("api"))
+
+	rec := serveHost(r, http.MethodGet, "example.com", "/api/missing")
+	if rec.Code != http.StatusTeapot || rec.Header().Get("X-Chain") != "api" {
+		t.Errorf("got %d chain %q", rec.Code, rec.Header().Get("X-Chain"))
+	}
+}
+
+func TestRoutes_ReportsResolvedChains(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	api := r.Group("/api", audit)
+	api.GET("/users/:id", okHandler, tagWithConfig("route")).Name("user.show")
+	api.DELETE("/users/:id", okHandler)
+	r.Host("{tenant}.example.com").GET("/", okHandler)
+	r.GET("/static/*filepath", okHandler)
+	r.Use(tagWithConfig("global"))
+
+	want := []ghttp.RouteInfo{
+		{Method: "GET", Path: "/api/users/:id", Name: "user.show",
+			Middlewares: []string{"http_test.tag", "http_test.audit", "http_test.tag"}},
+		{Method: "DELETE", Path: "/api/users/:id",
+			Middlewares: []string{"http_test.tag", "http_test.audit"}},
+		{Method: "GET", Path: "/static/*filepath",
+			Middlewares: []string{"http_test.tag"}},
+		{Method: "GET", Path: "/", Host: "{tenant}.example.com",
+			Middlewares: []string{"http_test.tag"}},
+	}
+	got := r.Routes()
+	if len(got) != len(want) {
+		t.Fatalf("got %d routes, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Method != w.Method || g.Path != w.Path || g.Host != w.Host || g.Name != w.Name ||
+			strings.Join(g.Middlewares, ",") != strings.Join(w.Middlewares, ",") {
+			t.Errorf("route %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+// A name is reported only on the methods it was given under.
+func TestRoutes_NamesFollowMethod(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/users/:id", okHandler).Name("user.show")
+	r.PUT("/users/:id", okHandler)
+	r.POST("/orders", okHandler).Name("order.create")
+	r.PATCH("/orders", okHandler).Name("order.create")
+
+	names := make(map[string]string)
+	for _, route := range r.Routes() {
+		names[route.Method+" "+route.Path] = route.Name
+	}
+	want := map[string]string{
+		"GET /users/:id": "user.show",
+		"PUT /users/:id": "",
+		"POST /orders":   "order.create",
+		"PATCH /orders":  "order.create",
+	}
+	for route, name := range want {
+		if got := names[route]; got != name {
+			t.Errorf("%s name = %q, want %q", route, got, name)
+		}
+	}
+}
+
+func TestMiddlewareName(t *testing.T) {
+	cases := []struct {
+		mw   ghttp.MiddlewareFunc
+		want string
+	}{
+		{audit, "http_test.audit"},
+		{tagWithConfig("x"), "http_test.tag"},
+		{func(next ghttp.HandlerFunc) ghttp.HandlerFunc { return next }, "http_test.TestMiddlewareName"},
+		{nil, ""},
+	}
+	for _, tc := range cases {
+		if got := ghttp.MiddlewareName(tc.mw); got != tc.want {
+			t.Errorf("MiddlewareName = %q, want %q", got, tc.want)
+		}
+	}
+}
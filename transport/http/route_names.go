@@ -0,0 +1,200 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownRoute is returned (wrapped) by URL and Reverse when no route was
+// registered under the requested name.
+var ErrUnknownRoute = errors.New("unknown route name")
+
+// Reverser is implemented by contexts that can build links to the named
+// routes of the router serving them, as DefaultContext does.
+type Reverser interface {
+	Reverse(name string, values ...any) (string, error)
+}
+
+// Reverse builds the path of the named route on the router serving c,
+// filling its parameters in order with values. It fails with
+// ErrUnknownRoute when c does not implement Reverser.
+func Reverse(c Context, name string, values ...any) (string, error) {
+	if r, ok := c.(Reverser); ok {
+		return r.Reverse(name, values...)
+	}
+	return "", fmt.Errorf("%w %q: context cannot reverse routes", ErrUnknownRoute, name)
+}
+
+// Route is returned by the registration methods so the route can be named
+// for reverse URL generation:
+//
+//	r.GET("/users/:id", showUser).Name("user.show")
+type Route struct {
+	method string
+	path   string
+	router *gortexRouter
+}
+
+// Method returns the HTTP method the route was registered under.
+func (rt *Route) Method() string {
+	return rt.method
+}
+
+// Path returns the full route pattern, including any group prefix.
+func (rt *Route) Path() string {
+	return rt.path
+}
+
+// Name registers name for the route's pattern so URL and Reverse can
+// build links to it. Several methods sharing a pattern may use the same
+// name; reusing a name for a different pattern panics with a *RouteError.
+func (rt *Route) Name(name string) *Route {
+	r := rt.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.names.add(name, rt.method, rt.path); err != nil {
+		panic(&RouteError{Method: rt.method, Path: rt.path, Err: err})
+	}
+	return rt
+}
+
+// routeNames maps route names to their patterns. It is shared by a router
+// family, like trees, and is guarded by mu.
+type routeNames struct {
+	routes map[string]*namedRoute
+}
+
+// namedRoute is a route pattern pre-split for reverse URL generation.
+type namedRoute struct {
+	pattern  string
+	segments []urlSegment
+	// methods are those the name was given under, for Routes.
+	methods []string
+}
+
+// urlSegment is one '/'-separated piece of a route pattern. param is nil for
+// a literal segment.
+type urlSegment struct {
+	literal    string
+	param      *PathParam
+	constraint *paramConstraint
+}
+
+func (n *routeNames) add(name, method, pattern string) error {
+	if name == "" {
+		return errors.New("route name must not be empty")
+	}
+	if existing, ok := n.routes[name]; ok {
+		if existing.pattern == pattern {
+			if !slices.Contains(existing.methods, method) {
+				existing.methods = append(existing.methods, method)
+			}
+			return nil
+		}
+		return fmt.Errorf("route name %q is already used by %s", name, existing.pattern)
+	}
+
+	route := &namedRoute{pattern: pattern, methods: []string{method}}
+	for _, segment := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			paramName, c, err := parseParamSegment(segment)
+			if err != nil {
+				return err
+			}
+			route.segments = append(route.segments, urlSegment{
+				param:      &PathParam{Name: paramName},
+				constraint: c,
+			})
+		case strings.HasPrefix(segment, "*"):
+			paramName := segment[1:]
+			if paramName == "" {
+				paramName = "*"
+			}
+			route.segments = append(route.segments, urlSegment{
+				param: &PathParam{Name: paramName, Wildcard: true},
+			})
+		default:
+			route.segments = append(route.segments, urlSegment{literal: segment})
+		}
+	}
+
+	if n.routes == nil {
+		n.routes = make(map[string]*namedRoute)
+	}
+	n.routes[name] = route
+	return nil
+}
+
+// build fills the route's parameters, in pattern order, with values.
+// Parameter values are path-escaped; a wildcard value keeps its '/'
+// separators. Every parameter needs a value, a ":param" value must be
+// non-empty and satisfy the parameter's constraint, and surplus values are
+// rejected, so a generated URL always routes back to the named route.
+func (nr *namedRoute) build(name string, values []any) (string, error) {
+	var b strings.Builder
+	next := 0
+	for _, s := range nr.segments {
+		b.WriteByte('/')
+		if s.param == nil {
+			b.WriteString(s.literal)
+			continue
+		}
+		if next >= len(values) {
+			return "", fmt.Errorf("route %q (%s): missing value for %s", name, nr.pattern, s.describe())
+		}
+		value := fmt.Sprint(values[next])
+		next++
+
+		if s.param.Wildcard {
+			parts := strings.Split(value, "/")
+			for i, part := range parts {
+				parts[i] = url.PathEscape(part)
+			}
+			b.WriteString(strings.Join(parts, "/"))
+			continue
+		}
+		if value == "" {
+			return "", fmt.Errorf("route %q (%s): empty value for %s", name, nr.pattern, s.describe())
+		}
+		if s.constraint != nil && !s.constraint.match(value) {
+			return "", fmt.Errorf("route %q (%s): value %q for :%s does not match <%s>",
+				name, nr.pattern, value, s.param.Name, s.constraint.pattern)
+		}
+		b.WriteString(url.PathEscape(value))
+	}
+	if len(nr.pattern) > 1 && strings.HasSuffix(nr.pattern, "/") {
+		b.WriteByte('/')
+	}
+	if next < len(values) {
+		return "", fmt.Errorf("route %q (%s): got %d values for %d parameters", name, nr.pattern, len(values), next)
+	}
+	return b.String(), nil
+}
+
+// describe returns the parameter as written in the pattern, for errors.
+func (s urlSegment) describe() string {
+	if s.param.Wildcard {
+		if s.param.Name == "*" {
+			return "*"
+		}
+		return "*" + s.param.Name
+	}
+	return ":" + s.param.Name
+}
+
+// URL builds the path of the route registered under name, filling its
+// parameters in order with values.
+func (r *gortexRouter) URL(name string, values ...any) (string, error) {
+	r.mu.RLock()
+	route, ok := r.names.routes[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownRoute, name)
+	}
+	return route.build(name, values)
+}
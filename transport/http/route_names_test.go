@@ -0,0 +1,141 @@
+package http_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yshengliao/gortex/internal/testutil"
+	ghttp "github.com/yshengliao/gortex/transport/http"
+)
+
+func okHandler(c ghttp.Context) error {
+	return c.NoContent(http.StatusOK)
+}
+
+func TestRouteName_URL(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/", okHandler).Name("home")
+	r.GET("/users/:id<int>", okHandler).Name("user.show")
+	r.PUT("/users/:id<int>", okHandler).Name("user.show")
+	r.GET("/users/:id/posts/:slug", okHandler).Name("user.post")
+	r.GET("/static/*filepath", okHandler).Name("static")
+	r.Group("/api/v1").GET("/orders/:id", okHandler).Name("api.order")
+	r.GET("/dirs/", okHandler).Name("dirs")
+
+	cases := []struct {
+		name   string
+		values []any
+		want   string
+	}{
+		{"home", nil, "/"},
+		{"user.show", []any{42}, "/users/42"},
+		{"user.post", []any{"7", "hello world"}, "/users/7/posts/hello%20world"},
+		{"user.post", []any{"a/b", "x?y#z"}, "/users/a%2Fb/posts/x%3Fy%23z"},
+		{"static", []any{"css/site main.css"}, "/static/css/site%20main.css"},
+		{"static", []any{""}, "/static/"},
+		{"api.order", []any{"o-1"}, "/api/v1/orders/o-1"},
+		{"dirs", nil, "/dirs/"},
+	}
+	for _, tc := range cases {
+		got, err := r.URL(tc.name, tc.values...)
+		if err != nil {
+			t.Errorf("URL(%q, %v): %v", tc.name, tc.values, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("URL(%q, %v) = %q, want %q", tc.name, tc.values, got, tc.want)
+		}
+	}
+}
+
+func TestRouteName_GeneratedURLRoutesBack(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/users/:id/posts/:slug", func(c ghttp.Context) error {
+		return c.String(http.StatusOK, c.Param("id")+"|"+c.Param("slug"))
+	}).Name("user.post")
+
+	path, err := r.URL("user.post", "7", "hello world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, body := serveBody(r, http.MethodGet, path); code != http.StatusOK || body != "7|hello world" {
+		t.Fatalf("GET %s: got %d %q", path, code, body)
+	}
+}
+
+func TestRouteName_URLErrors(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/users/:id<int>", okHandler).Name("user.show")
+	r.GET("/files/*path", okHandler).Name("file")
+
+	cases := []struct {
+		name    string
+		values  []any
+		wantErr string
+	}{
+		{"user.show", nil, `missing value for :id`},
+		{"user.show", []any{""}, `empty value for :id`},
+		{"user.show", []any{"abc"}, `value "abc" for :id does not match <int>`},
+		{"user.show", []any{1, 2}, `got 2 values for 1 parameters`},
+		{"file", nil, `missing value for *path`},
+	}
+	for _, tc := range cases {
+		_, err := r.URL(tc.name, tc.values...)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("URL(%q, %v): got error %v, want %q", tc.name, tc.values, err, tc.wantErr)
+		}
+	}
+
+	_, err := r.URL("nope")
+	if !errors.Is(err, ghttp.ErrUnknownRoute) {
+		t.Errorf("unknown name: got %v, want ErrUnknownRoute", err)
+	}
+}
+
+func TestRouteName_DuplicateNamePanics(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/users/:id", okHandler).Name("user.show")
+
+	defer func() {
+		var routeErr *ghttp.RouteError
+		rec := recover()
+		err, _ := rec.(error)
+		if !errors.As(err, &routeErr) {
+			t.Fatalf("expected *RouteError panic, got %v", rec)
+		}
+		if !strings.Contains(err.Error(), `route name "user.show" is already used by /users/:id`) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+	r.GET("/people/:id", okHandler).Name("user.show")
+}
+
+func TestContextReverse(t *testing.T) {
+	r := ghttp.NewGortexRouter()
+	r.GET("/users/:id", okHandler).Name("user.show")
+	r.POST("/users", func(c ghttp.Context) error {
+		location, err := ghttp.Reverse(c, "user.show", 99)
+		if err != nil {
+			return err
+		}
+		c.Response().Header().Set("Location", location)
+		return c.NoContent(http.StatusCreated)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users", nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Location"); got != "/users/99" {
+		t.Errorf("Location = %q, want /users/99", got)
+	}
+
+	// A Context implementation without Reverse cannot build links.
+	if _, err := ghttp.Reverse(testutil.NewMockContext(http.MethodGet, "/"), "user.show", 99); !errors.Is(err, ghttp.ErrUnknownRoute) {
+		t.Errorf("mock context: err = %v, want ErrUnknownRoute", err)
+	}
+}
@@ -43,6 +43,54 @@ func BenchmarkServeHTTP_ParamRoute(b *testing.B) {
 	}
 }
 
+// BenchmarkServeHTTP_ConstrainedParamRoute benchmarks a typed parameter that
+// must be tried ahead of an unconstrained sibling.
+func BenchmarkServeHTTP_ConstrainedParamRoute(b *testing.B) {
+	router := NewGortexRouter()
+	router.GET("/users/:id<int>", func(c Context) error {
+		_ = c.Param("id")
+		return c.NoContent(http.StatusOK)
+	})
+	router.GET("/users/:name", func(c Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/12345", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Body.Reset()
+		router.ServeHTTP(w, req)
+	}
+}
+
+// BenchmarkServeHTTP_HostParamRoute benchmarks a route on a parameterised
+// host group, with a literal host tried (and rejected) first.
+func BenchmarkServeHTTP_HostParamRoute(b *testing.B) {
+	router := NewGortexRouter()
+	router.Host("api.example.com").GET("/users/:id", func(c Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	router.Host("{tenant}.example.com").GET("/users/:id", func(c Context) error {
+		_ = c.Param("tenant")
+		_ = c.Param("id")
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/12345", nil)
+	req.Host = "acme.example.com:8080"
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Body.Reset()
+		router.ServeHTTP(w, req)
+	}
+}
+
 // BenchmarkServeHTTP_DeepParamRoute benchmarks a deeply nested route with multiple params.
 func BenchmarkServeHTTP_DeepParamRoute(b *testing.B) {
 	router := NewGortexRouter()